@@ -0,0 +1,172 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	config "hissync-10/functions"
+)
+
+// ImportProgress ความคืบหน้าของการนำเข้าไฟล์ binlog
+type ImportProgress struct {
+	File       string // ไฟล์ที่กำลังอ่าน
+	FilesDone  int
+	FilesTotal int
+	Bytes      int64 // จำนวนไบต์ที่อ่านแล้วรวมทุกไฟล์
+	TotalBytes int64
+	Events     int // จำนวนเหตุการณ์การเปลี่ยนแปลงที่ส่งออกแล้ว
+}
+
+// Percent คืนความคืบหน้าเป็นร้อยละตามจำนวนไบต์
+func (p ImportProgress) Percent() float64 {
+	if p.TotalBytes == 0 {
+		return 100
+	}
+	return float64(p.Bytes) * 100 / float64(p.TotalBytes)
+}
+
+// ช่วงเวลาขั้นต่ำระหว่างการรายงานความคืบหน้าระหว่างอ่านไฟล์
+const importProgressInterval = 500 * time.Millisecond
+
+// BinlogFiles คืนไฟล์ binlog จาก paths ซึ่งเป็นไฟล์หรือโฟลเดอร์ เรียงตามชื่อในแต่ละโฟลเดอร์
+// ไฟล์ในโฟลเดอร์ที่ไม่ใช่ binlog (เช่น mysql-bin.index) จะถูกข้าม แต่ไฟล์ที่ระบุตรง ๆ ต้องเป็น binlog
+func BinlogFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !isBinlogFile(path) {
+				return nil, fmt.Errorf("%s ไม่ใช่ไฟล์ binlog", path)
+			}
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			name := filepath.Join(path, entry.Name())
+			if entry.Type().IsRegular() && isBinlogFile(name) {
+				names = append(names, name)
+			}
+		}
+		// ชื่อไฟล์ binlog มีเลขลำดับความยาวคงที่ (mysql-bin.000123) เรียงตามชื่อคือเรียงตามเวลา
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+// isBinlogFile ตรวจว่าไฟล์ขึ้นต้นด้วย magic number ของ binlog
+func isBinlogFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(replication.BinLogFileHeader))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, replication.BinLogFileHeader)
+}
+
+// ImportBinlogFiles อ่านไฟล์ binlog ตามลำดับด้วยตัวกรองตารางและการแปลงคอลัมน์เดียวกับการอ่านแบบ replica
+// รูปแบบ MySQL หรือ MariaDB ใช้ replication.flavor ของโปรไฟล์ หรือตรวจจากเวอร์ชันที่บันทึกในแต่ละไฟล์
+// แล้วส่งเหตุการณ์ไปยัง emit (ไม่เปลี่ยนตำแหน่งที่อ่านถึงใน state file)
+// ถ้าเชื่อมต่อฐานข้อมูลของโปรไฟล์ไม่ได้ จะใช้ชื่อคอลัมน์จาก binlog (binlog_row_metadata=FULL) และ primary_key ใน tables.json
+// progress ถูกเรียกเมื่อเริ่มและจบแต่ละไฟล์ และเป็นระยะระหว่างอ่าน (nil คือไม่รายงาน)
+func ImportBinlogFiles(ctx context.Context, p *config.Profile, tables *config.TableConfig, files []string, emit func(Event), progress func(ImportProgress)) error {
+	if progress == nil {
+		progress = func(ImportProgress) {}
+	}
+	state := ImportProgress{FilesTotal: len(files)}
+	sizes := make([]int64, len(files))
+	for i, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		sizes[i] = info.Size()
+		state.TotalBytes += info.Size()
+	}
+
+	db, err := config.OpenDB(p)
+	if err == nil {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err != nil {
+			db.Close()
+		}
+	}
+	if err != nil {
+		db = nil
+		emit(Event{Notice: fmt.Sprintf("เชื่อมต่อฐานข้อมูลไม่ได้ ใช้ชื่อคอลัมน์จาก binlog: %v", config.RedactError(err))})
+	} else {
+		defer db.Close()
+	}
+
+	source := NewMySQLSource(p, tables)
+	parser := replication.NewBinlogParser()
+	flavor := p.Replica().Flavor
+	parser.SetFlavor(flavor)
+
+	var done int64
+	for i, name := range files {
+		state.File = name
+		state.Bytes = done
+		progress(state)
+
+		decoder := source.newDecoder(db, filepath.Base(name), 0)
+		last := time.Now()
+		err := parser.ParseFile(name, 0, func(ev *replication.BinlogEvent) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if e, ok := ev.Event.(*replication.FormatDescriptionEvent); ok && flavor == "" {
+				// ไม่ได้กำหนด replication.flavor ใช้เวอร์ชันของเซิร์ฟเวอร์ที่เขียนไฟล์นี้
+				parser.SetFlavor(config.FlavorFromVersion(e.ServerVersion))
+			}
+			decoder.decode(ev, func(ev Event) {
+				if ev.IsChange() {
+					state.Events++
+				}
+				emit(ev)
+			})
+			if ev.Header.LogPos > 0 {
+				state.Bytes = done + int64(ev.Header.LogPos)
+			}
+			if time.Since(last) >= importProgressInterval {
+				last = time.Now()
+				progress(state)
+			}
+			return nil
+		})
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return fmt.Errorf("อ่าน %s ไม่สำเร็จ: %v", name, err)
+		}
+		parser.Reset()
+		done += sizes[i]
+		state.FilesDone = i + 1
+		state.Bytes = done
+		progress(state)
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	config "hissync-10/functions"
+)
+
+// diagnoseTimeout เวลาสูงสุดของการตรวจเซิร์ฟเวอร์หนึ่งครั้ง
+const diagnoseTimeout = 30 * time.Second
+
+// ผลการตรวจแต่ละรายการ
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// Check ผลการตรวจการตั้งค่าเซิร์ฟเวอร์หนึ่งรายการ พร้อมวิธีแก้เมื่อไม่ผ่าน
+type Check struct {
+	Name   string
+	Status string
+	// Detail ค่าที่พบบนเซิร์ฟเวอร์
+	Detail string
+	// Fix วิธีแก้ไข (ว่างเมื่อผ่าน)
+	Fix string
+}
+
+// Symbol สัญลักษณ์ของผลการตรวจสำหรับแสดงเป็นรายการ
+func (c Check) Symbol() string {
+	switch c.Status {
+	case CheckPass:
+		return "✓"
+	case CheckWarn:
+		return "!"
+	default:
+		return "✗"
+	}
+}
+
+// Failed บอกว่ามีรายการที่ไม่ผ่านหรือไม่
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == CheckFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnose ตรวจว่าเซิร์ฟเวอร์ต้นทางตั้งค่าพร้อมสำหรับวิธีอ่านการเปลี่ยนแปลง (engine) ของโปรไฟล์หรือไม่
+func Diagnose(p *config.Profile) []Check {
+	connection := Check{Name: "การเชื่อมต่อ", Status: CheckPass, Detail: fmt.Sprintf("%s %s:%s", p.DBType, p.Host, p.Port)}
+	if err := config.TestConnection(p); err != nil {
+		connection.Status = CheckFail
+		connection.Detail = err.Error()
+		connection.Fix = "ตรวจสอบ host, port, ชื่อผู้ใช้ รหัสผ่าน และการตั้งค่า tls/ssh ของโปรไฟล์"
+		return []Check{connection}
+	}
+	checks := []Check{connection}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+	switch p.Engine {
+	case config.EngineBinlog:
+		checks = append(checks, diagnoseMySQL(ctx, p)...)
+	case config.EnginePostgresLog:
+		checks = append(checks, diagnosePostgres(ctx, p)...)
+	}
+	return checks
+}
+
+// diagnoseMySQL ตรวจการตั้งค่า binlog และสิทธิ์ของผู้ใช้สำหรับ binlog replication
+func diagnoseMySQL(ctx context.Context, p *config.Profile) []Check {
+	db, err := config.OpenDB(p)
+	if err != nil {
+		return []Check{{Name: "การเชื่อมต่อ", Status: CheckFail, Detail: config.Redact(err.Error())}}
+	}
+	defer db.Close()
+
+	flavorCheck, flavor := checkFlavor(ctx, db, p)
+	vars, err := globalVariables(ctx, db, "log_bin", "binlog_format", "binlog_row_image", "binlog_row_metadata",
+		"binlog_expire_logs_seconds", "expire_logs_days", "server_id",
+		"binlog_annotate_row_events", "mysql56_temporal_format", "binlog_rows_query_log_events")
+	if err != nil {
+		return []Check{flavorCheck, {Name: "อ่านค่าตัวแปรของเซิร์ฟเวอร์", Status: CheckFail, Detail: config.Redact(err.Error()),
+			Fix: "ผู้ใช้ต้องอ่าน SHOW GLOBAL VARIABLES ได้"}}
+	}
+
+	checks := []Check{flavorCheck}
+	expect := func(name, want, fix string) {
+		c := Check{Name: name, Status: CheckPass, Detail: vars[name]}
+		if !strings.EqualFold(vars[name], want) {
+			c.Status, c.Fix = CheckFail, fix
+		}
+		checks = append(checks, c)
+	}
+	expect("log_bin", "ON", "เพิ่ม log_bin=mysql-bin ใน my.cnf ส่วน [mysqld] แล้วรีสตาร์ท MySQL")
+	expect("binlog_format", "ROW", "ตั้ง binlog_format=ROW ใน my.cnf (หรือ SET GLOBAL binlog_format='ROW')")
+	expect("binlog_row_image", "FULL", "ตั้ง binlog_row_image=FULL ใน my.cnf เพื่อให้มีค่าทุกคอลัมน์ก่อนและหลังแก้ไข")
+
+	metadata := Check{Name: "binlog_row_metadata", Status: CheckPass, Detail: vars["binlog_row_metadata"]}
+	switch {
+	case metadata.Detail == "" && flavor == config.FlavorMariaDB:
+		metadata.Status, metadata.Detail = CheckWarn, "ไม่รองรับ (MariaDB ก่อน 10.5)"
+		metadata.Fix = "ชื่อคอลัมน์จะอ่านจาก information_schema ถ้าตารางถูกแก้ไขโครงสร้างระหว่างอ่านย้อนหลังชื่ออาจไม่ตรง"
+	case metadata.Detail == "":
+		metadata.Status, metadata.Detail = CheckWarn, "ไม่รองรับ (MySQL ก่อน 8.0.14)"
+		metadata.Fix = "ชื่อคอลัมน์จะอ่านจาก information_schema ถ้าตารางถูกแก้ไขโครงสร้างระหว่างอ่านย้อนหลังชื่ออาจไม่ตรง"
+	case !strings.EqualFold(metadata.Detail, "FULL"):
+		metadata.Status = CheckWarn
+		metadata.Fix = "ตั้ง binlog_row_metadata=FULL ใน my.cnf เพื่อให้ binlog มีชื่อคอลัมน์"
+	}
+	checks = append(checks, metadata)
+	if flavor == config.FlavorMariaDB {
+		checks = append(checks, mariaDBChecks(vars)...)
+	} else {
+		rowsQuery := Check{Name: "binlog_rows_query_log_events", Status: CheckPass, Detail: vars["binlog_rows_query_log_events"]}
+		if !strings.EqualFold(rowsQuery.Detail, "ON") {
+			rowsQuery.Status = CheckWarn
+			rowsQuery.Fix = "ตั้ง binlog_rows_query_log_events=ON ใน my.cnf เพื่อให้เหตุการณ์มีคำสั่ง SQL ต้นฉบับ"
+		}
+		checks = append(checks, rowsQuery)
+	}
+
+	checks = append(checks, checkExpireLogs(vars), checkGrants(ctx, db), checkServerID(ctx, db, p, vars["server_id"]))
+	return checks
+}
+
+// checkFlavor ตรวจรูปแบบ binlog จากเวอร์ชันของเซิร์ฟเวอร์ และเทียบกับ replication.flavor ของโปรไฟล์
+// คืนรูปแบบที่จะใช้ตรวจข้อต่อไป (ตามเซิร์ฟเวอร์ถ้าตรวจได้)
+func checkFlavor(ctx context.Context, db *sql.DB, p *config.Profile) (Check, string) {
+	configured := p.Replica().Flavor
+	c := Check{Name: "รูปแบบ binlog", Status: CheckPass}
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		c.Status, c.Detail = CheckWarn, config.Redact(err.Error())
+		if configured == "" {
+			configured = config.FlavorMySQL
+		}
+		return c, configured
+	}
+	detected := config.FlavorFromVersion(version)
+	c.Detail = fmt.Sprintf("%s (%s)", detected, version)
+	switch {
+	case configured == "":
+		c.Detail += " ตรวจอัตโนมัติ"
+	case configured != detected:
+		c.Status = CheckFail
+		c.Detail = fmt.Sprintf("replication.flavor เป็น %s แต่เซิร์ฟเวอร์เป็น %s (%s)", configured, detected, version)
+		c.Fix = fmt.Sprintf("ตั้ง replication.flavor เป็น %s หรือเว้นว่างให้ตรวจอัตโนมัติ", detected)
+	}
+	return c, detected
+}
+
+// mariaDBChecks ตัวแปรที่มีเฉพาะ MariaDB
+func mariaDBChecks(vars map[string]string) []Check {
+	annotate := Check{Name: "binlog_annotate_row_events", Status: CheckPass, Detail: vars["binlog_annotate_row_events"]}
+	if !strings.EqualFold(annotate.Detail, "ON") {
+		annotate.Status = CheckWarn
+		annotate.Fix = "ตั้ง binlog_annotate_row_events=ON ใน my.cnf เพื่อให้เหตุการณ์มีคำสั่ง SQL ต้นฉบับ"
+	}
+	temporal := Check{Name: "mysql56_temporal_format", Status: CheckPass, Detail: vars["mysql56_temporal_format"]}
+	if temporal.Detail != "" && !strings.EqualFold(temporal.Detail, "ON") {
+		// รูปแบบเก่าของ MariaDB 5.3 สำหรับ TIME/DATETIME/TIMESTAMP ที่มีเศษวินาที ตัวอ่าน binlog ไม่รองรับ
+		// (คอลัมน์ที่ไม่มีเศษวินาทีใช้รูปแบบเดียวกับ MySQL 5.5 จึงอ่านได้)
+		temporal.Status = CheckWarn
+		temporal.Detail += " (ไม่รองรับคอลัมน์เวลาแบบเศษวินาทีรูปแบบเก่า)"
+		temporal.Fix = "ตั้ง mysql56_temporal_format=ON แล้ว ALTER TABLE ... FORCE ตารางที่มีคอลัมน์เวลาแบบเศษวินาที"
+	}
+	return []Check{annotate, temporal}
+}
+
+// globalVariables อ่านค่าตัวแปรของเซิร์ฟเวอร์ ตัวแปรที่ไม่มีในเวอร์ชันนั้นจะไม่อยู่ในผลลัพธ์
+func globalVariables(ctx context.Context, db *sql.DB, names ...string) (map[string]string, error) {
+	query := "SHOW GLOBAL VARIABLES WHERE Variable_name IN (?" + strings.Repeat(", ?", len(names)-1) + ")"
+	args := make([]interface{}, len(names))
+	for i, name := range names {
+		args[i] = name
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vars := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		vars[name] = value
+	}
+	return vars, rows.Err()
+}
+
+// checkExpireLogs binlog ต้องเก็บไว้นานพอให้อ่านต่อได้หลังโปรแกรมหยุดทำงาน (เช่น ช่วงวันหยุด)
+func checkExpireLogs(vars map[string]string) Check {
+	c := Check{Name: "อายุไฟล์ binlog", Status: CheckPass}
+	var retention time.Duration
+	if seconds, ok := vars["binlog_expire_logs_seconds"]; ok && seconds != "0" {
+		n, _ := strconv.Atoi(seconds)
+		retention = time.Duration(n) * time.Second
+	} else if days, ok := vars["expire_logs_days"]; ok && days != "0" {
+		n, _ := strconv.Atoi(days)
+		retention = time.Duration(n) * 24 * time.Hour
+	}
+	switch {
+	case retention == 0:
+		c.Detail = "ไม่ลบอัตโนมัติ"
+		c.Status = CheckWarn
+		c.Fix = "ตั้ง binlog_expire_logs_seconds (หรือ expire_logs_days) เพื่อไม่ให้ binlog เต็มดิสก์ แนะนำอย่างน้อย 7 วัน"
+	case retention < 3*24*time.Hour:
+		c.Detail = fmt.Sprintf("%.0f ชั่วโมง", retention.Hours())
+		c.Status = CheckWarn
+		c.Fix = "เพิ่ม binlog_expire_logs_seconds เป็นอย่างน้อย 7 วัน (604800) ถ้าโปรแกรมหยุดนานกว่านี้จะอ่านต่อไม่ได้"
+	default:
+		c.Detail = fmt.Sprintf("%.0f วัน", retention.Hours()/24)
+	}
+	return c
+}
+
+// checkGrants ผู้ใช้ต้องมีสิทธิ์ REPLICATION SLAVE (อ่าน binlog) และ REPLICATION CLIENT (SHOW MASTER STATUS)
+func checkGrants(ctx context.Context, db *sql.DB) Check {
+	c := Check{Name: "สิทธิ์ REPLICATION SLAVE, REPLICATION CLIENT"}
+	rows, err := db.QueryContext(ctx, "SHOW GRANTS")
+	if err != nil {
+		c.Status, c.Detail = CheckWarn, config.Redact(err.Error())
+		return c
+	}
+	defer rows.Close()
+	var grants []string
+	for rows.Next() {
+		var grant string
+		if err := rows.Scan(&grant); err == nil {
+			grants = append(grants, strings.ToUpper(grant))
+		}
+	}
+
+	// MariaDB 10.5 ขึ้นไปแสดงสิทธิ์เดียวกันด้วยชื่อใหม่
+	aliases := map[string][]string{
+		"REPLICATION SLAVE":  {"REPLICATION REPLICA"},
+		"REPLICATION CLIENT": {"BINLOG MONITOR"},
+	}
+	var missing []string
+	for _, privilege := range []string{"REPLICATION SLAVE", "REPLICATION CLIENT"} {
+		names := append([]string{privilege, "ALL PRIVILEGES"}, aliases[privilege]...)
+		granted := false
+		for _, g := range grants {
+			if !strings.Contains(g, " ON *.* ") {
+				continue
+			}
+			for _, name := range names {
+				if strings.Contains(g, name) {
+					granted = true
+				}
+			}
+		}
+		if !granted {
+			missing = append(missing, privilege)
+		}
+	}
+	if len(missing) == 0 {
+		c.Status, c.Detail = CheckPass, "มีสิทธิ์ครบ"
+		return c
+	}
+	c.Status = CheckFail
+	c.Detail = "ไม่มีสิทธิ์ " + strings.Join(missing, ", ")
+	c.Fix = fmt.Sprintf("GRANT %s ON *.* TO '<ผู้ใช้>'@'<เครื่อง>';", strings.Join(missing, ", "))
+	return c
+}
+
+// checkServerID server_id ที่โปรไฟล์ใช้ต้องไม่ซ้ำกับเซิร์ฟเวอร์และ replica อื่น
+func checkServerID(ctx context.Context, db *sql.DB, p *config.Profile, serverID string) Check {
+	c := Check{Name: "server_id ไม่ซ้ำ", Status: CheckPass}
+	id, err := config.ReplicaServerID(p)
+	if err != nil {
+		c.Status, c.Detail = CheckFail, err.Error()
+		return c
+	}
+	c.Detail = fmt.Sprintf("เซิร์ฟเวอร์ %s, โปรไฟล์ใช้ %d", serverID, id)
+	hostname, _ := os.Hostname()
+	owner, err := serverIDOwner(ctx, db, id, hostname)
+	switch {
+	case err != nil:
+		c.Status = CheckWarn
+		c.Detail += " (ตรวจ replica อื่นไม่ได้: " + config.Redact(err.Error()) + ")"
+	case owner != "":
+		c.Status = CheckFail
+		c.Detail = fmt.Sprintf("server_id %d ถูกใช้โดย %s อยู่แล้ว", id, owner)
+		c.Fix = "กำหนด replication.server_id ของโปรไฟล์เป็นเลขอื่นที่ไม่ซ้ำ (หรือลบออกเพื่อให้สร้างอัตโนมัติ)"
+	}
+	return c
+}
+
+// diagnosePostgres ตรวจการตั้งค่าการเขียนไฟล์ Log ของ PostgreSQL และโฟลเดอร์ Log ที่โปรแกรมอ่าน
+func diagnosePostgres(ctx context.Context, p *config.Profile) []Check {
+	db, err := config.OpenDB(p)
+	if err != nil {
+		return []Check{{Name: "การเชื่อมต่อ", Status: CheckFail, Detail: config.Redact(err.Error())}}
+	}
+	defer db.Close()
+
+	setting := func(name string) string {
+		var value string
+		if err := db.QueryRowContext(ctx, "SELECT current_setting($1)", name).Scan(&value); err != nil {
+			return ""
+		}
+		return value
+	}
+	const conf = "ใน postgresql.conf"
+
+	var checks []Check
+	statement := Check{Name: "log_statement", Status: CheckPass, Detail: setting("log_statement")}
+	if statement.Detail != "mod" && statement.Detail != "all" {
+		statement.Status = CheckFail
+		statement.Fix = "ตั้ง log_statement = 'mod' " + conf + " แล้ว SELECT pg_reload_conf()"
+	}
+	checks = append(checks, statement)
+
+	prefix := Check{Name: "log_line_prefix", Status: CheckPass, Detail: fmt.Sprintf("%q", setting("log_line_prefix"))}
+	switch {
+	case !strings.HasPrefix(setting("log_line_prefix"), "%m "):
+		prefix.Status = CheckFail
+		prefix.Fix = "ตั้ง log_line_prefix = '%m " + PostgresAuditPrefix + "' " + conf + " (โปรแกรมอ่านเวลาจากต้นบรรทัด) แล้ว SELECT pg_reload_conf()"
+	case setting("log_line_prefix") != "%m "+PostgresAuditPrefix:
+		prefix.Status = CheckWarn
+		prefix.Fix = "ตั้ง log_line_prefix = '%m " + PostgresAuditPrefix + "' " + conf + " เพื่อให้เหตุการณ์มีผู้ใช้ โปรแกรม และเครื่องที่แก้ไขข้อมูล"
+	}
+	checks = append(checks, prefix)
+
+	collector := Check{Name: "logging_collector", Status: CheckPass, Detail: setting("logging_collector")}
+	if collector.Detail != "on" {
+		collector.Status = CheckFail
+		collector.Fix = "ตั้ง logging_collector = on " + conf + " แล้วรีสตาร์ท PostgreSQL"
+	}
+	checks = append(checks, collector)
+
+	filename := Check{Name: "log_filename", Status: CheckPass, Detail: setting("log_filename")}
+	if !strings.HasSuffix(filename.Detail, ".log") {
+		filename.Status = CheckFail
+		filename.Fix = "ตั้ง log_filename ให้ลงท้ายด้วย .log เช่น 'postgresql-%Y-%m-%d.log' " + conf
+	}
+	checks = append(checks, filename)
+
+	walLevel := Check{Name: "wal_level", Status: CheckPass, Detail: setting("wal_level")}
+	if walLevel.Detail != "logical" {
+		walLevel.Detail += " (ไม่จำเป็นสำหรับ engine postgres_log ที่อ่านจากไฟล์ Log)"
+	}
+	checks = append(checks, walLevel)
+
+	return append(checks, checkLogDirectory(p.LogFilePath, setting("log_directory")))
+}
+
+// checkLogDirectory โฟลเดอร์ log_file_path ต้องอ่านได้ มีไฟล์ .log และบรรทัดล่าสุดอ่านเวลาได้
+func checkLogDirectory(dir, serverDir string) Check {
+	c := Check{Name: "โฟลเดอร์ Log " + dir}
+	fix := fmt.Sprintf("ตั้ง log_file_path ให้ชี้ไปยัง log_directory ของเซิร์ฟเวอร์ (%s) และให้ผู้ใช้ที่รันโปรแกรมอ่านได้", serverDir)
+	latest, err := getLatestPostgresLogFile(dir)
+	if err != nil {
+		c.Status, c.Detail, c.Fix = CheckFail, err.Error(), fix
+		return c
+	}
+	file, err := os.Open(latest)
+	if err != nil {
+		c.Status, c.Detail, c.Fix = CheckFail, err.Error(), fix
+		return c
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+
+	var lastTime string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 28 {
+			continue
+		}
+		if _, err := time.Parse(postgresLogTimeFormat, strings.TrimSpace(line[:27])); err == nil {
+			lastTime = line[:27]
+		}
+	}
+	c.Detail = fmt.Sprintf("ไฟล์ล่าสุด %s (%s)", latest, info.ModTime().Format("2006-01-02 15:04"))
+	switch {
+	case info.Size() > 0 && lastTime == "":
+		c.Status = CheckFail
+		c.Detail += " อ่านเวลาต้นบรรทัดไม่ได้"
+		c.Fix = "ตั้ง log_line_prefix = '%m ' และ log_timezone เป็นเขตเวลาที่แสดงเป็นตัวเลข เช่น 'Asia/Bangkok'"
+	case time.Since(info.ModTime()) > 24*time.Hour:
+		c.Status = CheckWarn
+		c.Detail += " ไม่มีการเขียนเพิ่มเกิน 1 วัน"
+		c.Fix = fix
+	default:
+		c.Status = CheckPass
+	}
+	return c
+}
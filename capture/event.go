@@ -0,0 +1,83 @@
+package capture
+
+import (
+	"strings"
+	"time"
+)
+
+// Event การเปลี่ยนแปลงข้อมูลหนึ่งรายการ หรือข้อความสถานะจากแหล่งข้อมูล
+type Event struct {
+	Profile    string    `json:"profile"`  // ชื่อโปรไฟล์แหล่งข้อมูล
+	Source     string    `json:"source"`   // ประเภทแหล่งข้อมูล เช่น MySQL, PostgreSQL
+	Position   string    `json:"position"` // ตำแหน่งใน binlog หรือเวลาของบรรทัดในไฟล์ Log
+	Time       time.Time `json:"time"`     // เวลาที่เกิดการเปลี่ยนแปลงที่ต้นทาง
+	Database   string    `json:"database"`
+	Table      string    `json:"table"`
+	Operation  string    `json:"operation"` // INSERT, UPDATE หรือ DELETE
+	PrimaryKey string    `json:"primary_key"`
+	SQL        string    `json:"sql"`
+
+	// LogFile ไฟล์ binlog หรือไฟล์ Log ที่อ่านเหตุการณ์นี้มา และ Offset ตำแหน่งไบต์ของบรรทัดในไฟล์ Log
+	LogFile string `json:"log_file,omitempty"`
+	Offset  int64  `json:"offset,omitempty"`
+	// GTID ของธุรกรรม (MySQL ที่เปิด gtid_mode) และ Transaction รหัสของธุรกรรมที่เหตุการณ์นี้อยู่
+	// (GTID ถ้ามี ไม่เช่นนั้นเป็นไฟล์:ตำแหน่ง ของ BEGIN)
+	GTID        string `json:"gtid,omitempty"`
+	Transaction string `json:"transaction,omitempty"`
+	// OriginalSQL คำสั่ง SQL ที่ผู้ใช้ส่งมาจริงซึ่งทำให้แถวนี้เปลี่ยน
+	// (Rows_query ของ MySQL เมื่อเปิด binlog_rows_query_log_events หรือ ANNOTATE_ROWS ของ MariaDB)
+	OriginalSQL string `json:"original_sql,omitempty"`
+	// ผู้ทำรายการเท่าที่ต้นทางบันทึกไว้: ผู้ใช้ฐานข้อมูล โปรแกรมและเครื่องที่เชื่อมต่อ
+	// และรหัส session (thread id ของ MySQL หรือ pid ของ PostgreSQL)
+	DBUser     string `json:"db_user,omitempty"`
+	ClientApp  string `json:"client_app,omitempty"`
+	ClientHost string `json:"client_host,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
+	// Columns ค่าของแต่ละคอลัมน์ก่อนและหลังการเปลี่ยนแปลง
+	Columns []ColumnValue `json:"columns,omitempty"`
+
+	// Notice ข้อความสถานะที่ไม่ใช่การเปลี่ยนแปลงข้อมูล เช่น "เริ่มต้นอ่าน Log"
+	Notice string `json:"-"`
+	// Err ข้อผิดพลาดจากแหล่งข้อมูล
+	Err error `json:"-"`
+	// Commit จุดสิ้นสุดของธุรกรรม Transaction (XID หรือ COMMIT ใน binlog) ไม่ใช่การเปลี่ยนแปลงข้อมูล
+	// ส่งเฉพาะธุรกรรมที่มีแถวของตารางที่ติดตาม
+	Commit bool `json:"-"`
+}
+
+// FullTableName คืนชื่อตารางแบบ database.table
+func (e Event) FullTableName() string {
+	if e.Database == "" {
+		return e.Table
+	}
+	return e.Database + "." + e.Table
+}
+
+// IsChange บอกว่าเหตุการณ์เป็นการเปลี่ยนแปลงข้อมูล (ไม่ใช่ข้อความสถานะ ข้อผิดพลาด หรือจุดสิ้นสุดธุรกรรม)
+func (e Event) IsChange() bool {
+	return e.Err == nil && e.Notice == "" && !e.Commit
+}
+
+// Statement คืนคำสั่ง SQL ที่นำไปใช้ได้ทันที (ตัดส่วนนำหน้าของบรรทัด Log เช่น "LOG:  statement: " ออก)
+func (e Event) Statement() string {
+	if i := strings.Index(e.SQL, "statement: "); i >= 0 {
+		return strings.TrimSpace(e.SQL[i+len("statement: "):])
+	}
+	return e.SQL
+}
+
+// ColumnValue ค่าของคอลัมน์หนึ่งก่อนและหลังการเปลี่ยนแปลง
+// Before เป็น nil เมื่อไม่มีค่าเดิม (INSERT หรือต้นทางไม่ได้บันทึกไว้) หรือค่าเป็น NULL และ After เช่นเดียวกัน
+type ColumnValue struct {
+	Name   string  `json:"name"`
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+// Changed บอกว่าค่าก่อนและหลังต่างกัน
+func (c ColumnValue) Changed() bool {
+	if c.Before == nil || c.After == nil {
+		return c.Before != c.After
+	}
+	return *c.Before != *c.After
+}
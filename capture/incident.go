@@ -0,0 +1,165 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	config "hissync-10/functions"
+)
+
+// ระดับความรุนแรงของปัญหา
+const (
+	// SeverityError การอ่านข้อมูลของโปรไฟล์หยุดอยู่ (เชื่อมต่อไม่ได้ หรือแหล่งข้อมูลหยุดทำงาน) และกำลังลองใหม่
+	SeverityError = "error"
+	// SeverityWarning แหล่งข้อมูลยังทำงานอยู่ แต่บางรอบการอ่านผิดพลาด
+	SeverityWarning = "warning"
+)
+
+// จำนวนปัญหาที่แก้ไขแล้วที่เก็บไว้แสดงสูงสุด เกินนี้จะลบรายการที่เก่าที่สุดออก
+const maxResolvedIncidents = 100
+
+// Incident ปัญหาของโปรไฟล์หนึ่งรายการ ข้อผิดพลาดเดียวกันที่เกิดซ้ำจะนับรวมในรายการเดียว
+type Incident struct {
+	Key       string
+	Profile   string
+	Source    string
+	Severity  string
+	Message   string
+	FirstSeen time.Time
+	LastSeen  time.Time
+	Count     int
+	// Resolved เวลาที่โปรไฟล์กลับมาเชื่อมต่อได้ (ค่าศูนย์ถ้ายังไม่แก้ไข)
+	Resolved time.Time
+}
+
+// Open บอกว่าปัญหายังไม่ได้รับการแก้ไข
+func (i Incident) Open() bool {
+	return i.Resolved.IsZero()
+}
+
+// recordIncident บันทึกปัญหาของ runner (ต้องถือ m.mu อยู่)
+func (m *Manager) recordIncident(r *runner, severity string, err error) {
+	message := config.Redact(err.Error())
+	key := fmt.Sprintf("%s|%s|%s", r.profile.Name, severity, message)
+	now := time.Now()
+	inc, ok := m.incidents[key]
+	if !ok {
+		inc = &Incident{
+			Key:       key,
+			Profile:   r.profile.Name,
+			Source:    r.profile.DBType,
+			Severity:  severity,
+			Message:   message,
+			FirstSeen: now,
+		}
+		m.incidents[key] = inc
+	}
+	inc.LastSeen = now
+	inc.Count++
+	inc.Resolved = time.Time{}
+}
+
+// ReportIncident บันทึกปัญหาที่พบภายนอกแหล่งข้อมูลของโปรไฟล์ เช่น ธุรกรรมที่ถูกกักไว้ (ระดับ warning)
+func (m *Manager) ReportIncident(profile string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.runners[profile]; ok {
+		m.recordIncident(r, SeverityWarning, err)
+	}
+}
+
+// resolveIncidents ปิดปัญหาที่ทำให้การอ่านของโปรไฟล์หยุด เมื่อโปรไฟล์กลับมาทำงาน (ต้องถือ m.mu อยู่)
+func (m *Manager) resolveIncidents(profile string) {
+	now := time.Now()
+	var resolved []*Incident
+	for _, inc := range m.incidents {
+		if inc.Profile == profile && inc.Severity == SeverityError && inc.Open() {
+			inc.Resolved = now
+		}
+		if !inc.Open() {
+			resolved = append(resolved, inc)
+		}
+	}
+	if len(resolved) > maxResolvedIncidents {
+		sort.Slice(resolved, func(i, j int) bool { return resolved[i].LastSeen.Before(resolved[j].LastSeen) })
+		for _, inc := range resolved[:len(resolved)-maxResolvedIncidents] {
+			delete(m.incidents, inc.Key)
+		}
+	}
+}
+
+// Incidents คืนปัญหาทั้งหมด ปัญหาที่ยังไม่แก้ไขก่อน แล้วเรียงตามเวลาที่พบล่าสุด
+func (m *Manager) Incidents() []Incident {
+	m.mu.Lock()
+	incidents := make([]Incident, 0, len(m.incidents))
+	for _, inc := range m.incidents {
+		incidents = append(incidents, *inc)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].Open() != incidents[j].Open() {
+			return incidents[i].Open()
+		}
+		return incidents[i].LastSeen.After(incidents[j].LastSeen)
+	})
+	return incidents
+}
+
+// DismissIncident ลบปัญหาออกจากรายการ (ถ้าเกิดซ้ำจะถูกบันทึกใหม่)
+func (m *Manager) DismissIncident(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.incidents, key)
+}
+
+// Retry สั่งให้โปรไฟล์ที่ผิดพลาดลองเชื่อมต่อใหม่ทันทีโดยไม่ต้องรอครบเวลา
+func (m *Manager) Retry(profile string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.runners[profile]; ok {
+		select {
+		case r.retry <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// ResetCheckpoint หยุดโปรไฟล์ ล้างตำแหน่งที่อ่านถึงให้เริ่มอ่านจากตำแหน่งปัจจุบันของต้นทาง แล้วเริ่มใหม่
+// ใช้เมื่อตำแหน่งเดิมใช้ไม่ได้แล้ว เช่น binlog ถูกลบไปแล้ว การเปลี่ยนแปลงระหว่างตำแหน่งเดิมกับปัจจุบันจะไม่ถูกอ่าน
+func (m *Manager) ResetCheckpoint(profile string) error {
+	m.mu.Lock()
+	old, ok := m.runners[profile]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("ไม่พบโปรไฟล์ %s", profile)
+	}
+	r := m.newRunner(old.profile)
+	m.runners[profile] = r
+	m.mu.Unlock()
+
+	old.stop()
+	err := resetState(&r.profile)
+	m.start(r)
+	return err
+}
+
+// resetState ล้าง state file ของโปรไฟล์ให้แหล่งข้อมูลเริ่มอ่านจากตำแหน่งปัจจุบัน
+func resetState(p *config.Profile) error {
+	var err error
+	switch p.Engine {
+	case config.EngineBinlog:
+		// ไม่มี state file จะเริ่มจาก SHOW MASTER STATUS
+		if err = os.Remove(p.StateFile); os.IsNotExist(err) {
+			err = nil
+		}
+	case config.EnginePostgresLog:
+		err = SaveState(p.StateFile, State{LastLogDatetime: time.Now().Format(CheckpointTimeFormat)})
+	}
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถล้างตำแหน่งของ %s: %v", p.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package capture
+
+import "time"
+
+// Backlog แหล่งข้อมูลที่บอกได้ว่ายังอ่านตามหลังต้นทางอยู่กี่ไบต์ (-1 คือไม่ทราบ)
+type Backlog interface {
+	BytesBehind() int64
+}
+
+// Lag ความล่าช้าในการอ่านของโปรไฟล์หนึ่งรายการ
+type Lag struct {
+	Profile string
+	// LastEvent เวลาที่ต้นทางของเหตุการณ์ล่าสุดที่อ่านได้
+	LastEvent time.Time
+	// BytesBehind จำนวนไบต์ของ binlog/ไฟล์ Log ที่ยังไม่ได้อ่าน (-1 คือไม่ทราบ)
+	BytesBehind int64
+	// Capture เวลาปัจจุบันลบเวลาของเหตุการณ์ล่าสุด เป็น 0 เมื่ออ่านทันต้นทางแล้ว
+	// (ไม่เช่นนั้นฐานข้อมูลที่ไม่มีการเปลี่ยนแปลงจะดูเหมือนล่าช้า) และเป็น 0 เมื่อไม่ทราบ (ดู Unknown)
+	Capture time.Duration
+}
+
+// Unknown บอกว่าไม่ทราบว่าโปรไฟล์อ่านทันต้นทางหรือไม่ เช่น ยังไม่ได้อ่านรอบแรกหรือถามเซิร์ฟเวอร์ไม่สำเร็จ
+func (l Lag) Unknown() bool {
+	return l.BytesBehind < 0
+}
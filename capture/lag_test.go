@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "hissync-10/functions"
+)
+
+// backlogSource แหล่งข้อมูลที่บอกจำนวนไบต์ที่ค้างอ่านตามที่กำหนด
+type backlogSource int64
+
+func (backlogSource) Run(ctx context.Context, emit func(Event)) error { return nil }
+
+func (b backlogSource) BytesBehind() int64 { return int64(b) }
+
+// plainSource แหล่งข้อมูลที่บอกจำนวนไบต์ที่ค้างอ่านไม่ได้
+type plainSource struct{}
+
+func (plainSource) Run(ctx context.Context, emit func(Event)) error { return nil }
+
+func TestManagerLags(t *testing.T) {
+	lastEvent := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name        string
+		source      Source
+		lastEvent   time.Time
+		wantBehind  int64
+		wantUnknown bool
+		wantLagging bool
+	}{
+		{"behind", backlogSource(1024), lastEvent, 1024, false, true},
+		{"caught up", backlogSource(0), lastEvent, 0, false, false},
+		{"unknown backlog", backlogSource(-1), lastEvent, -1, true, false},
+		{"no backlog support", plainSource{}, lastEvent, -1, true, false},
+		{"behind before first event", backlogSource(1024), time.Time{}, 1024, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager()
+			m.config = &config.Config{Profiles: []config.Profile{{Name: "jhcis"}, {Name: "stopped"}}}
+			m.runners["jhcis"] = &runner{source: tt.source, lastEvent: tt.lastEvent, status: ProfileStatus{State: StatusRunning}}
+			m.runners["stopped"] = &runner{source: backlogSource(1), lastEvent: lastEvent, status: ProfileStatus{State: StatusError}}
+
+			lags := m.Lags()
+			if len(lags) != 1 || lags[0].Profile != "jhcis" {
+				t.Fatalf("Lags() = %+v ต้องการเฉพาะโปรไฟล์ที่กำลังทำงาน", lags)
+			}
+			lag := lags[0]
+			if lag.BytesBehind != tt.wantBehind || lag.Unknown() != tt.wantUnknown {
+				t.Fatalf("BytesBehind, Unknown = %d, %v ต้องการ %d, %v", lag.BytesBehind, lag.Unknown(), tt.wantBehind, tt.wantUnknown)
+			}
+			if lagging := lag.Capture > 0; lagging != tt.wantLagging {
+				t.Fatalf("Capture = %s ต้องการล่าช้า %v", lag.Capture, tt.wantLagging)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	config "hissync-10/functions"
+)
+
+// Source แหล่งข้อมูลที่อ่านการเปลี่ยนแปลงและส่งออกทาง emit จนกว่า ctx จะถูกยกเลิก
+type Source interface {
+	Run(ctx context.Context, emit func(Event)) error
+}
+
+// Poller แหล่งข้อมูลที่อ่านเป็นรอบ ซึ่งสั่งให้อ่านทันทีหรือหยุดชั่วคราวได้
+type Poller interface {
+	PollNow()
+	SetPaused(paused bool)
+}
+
+// ระยะเวลารอก่อนเชื่อมต่อใหม่หลังผิดพลาด (เพิ่มเป็นเท่าตัวจนถึง maxRetryDelay)
+const (
+	minRetryDelay = 5 * time.Second
+	maxRetryDelay = 5 * time.Minute
+)
+
+// สถานะของโปรไฟล์
+const (
+	StatusDisabled   = "disabled"
+	StatusConnecting = "connecting"
+	StatusRunning    = "running"
+	StatusStopped    = "stopped"
+	StatusError      = "error"
+)
+
+// ProfileStatus สถานะการทำงานของโปรไฟล์หนึ่งรายการ
+type ProfileStatus struct {
+	Name   string
+	DBType string
+	State  string
+	Err    error
+	// RetryAt เวลาที่จะลองเชื่อมต่อใหม่เมื่อสถานะเป็น error
+	RetryAt time.Time
+}
+
+// runner แหล่งข้อมูลที่กำลังทำงานของโปรไฟล์หนึ่งรายการ
+type runner struct {
+	profile config.Profile
+	source  Source
+	tables  *config.TableConfig
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	retry   chan struct{}
+	status  ProfileStatus
+	// lastEvent เวลาที่ต้นทางของการเปลี่ยนแปลงล่าสุด
+	lastEvent time.Time
+}
+
+// Manager ดูแลแหล่งข้อมูลของทุกโปรไฟล์ใน config ให้ทำงานพร้อมกัน
+// และกระจายเหตุการณ์ไปยังผู้ติดตาม (เช่น หน้าจอ Log)
+type Manager struct {
+	mu              sync.Mutex
+	config          *config.Config
+	runners         map[string]*runner
+	paused          map[string]bool
+	listeners       map[int]func(Event)
+	statusListeners map[int]func(ProfileStatus)
+	incidents       map[string]*Incident
+	nextID          int
+}
+
+// NewManager สร้าง Manager ที่ยังไม่มีแหล่งข้อมูลทำงาน
+func NewManager() *Manager {
+	return &Manager{
+		runners:         make(map[string]*runner),
+		paused:          make(map[string]bool),
+		listeners:       make(map[int]func(Event)),
+		statusListeners: make(map[int]func(ProfileStatus)),
+		incidents:       make(map[string]*Incident),
+	}
+}
+
+// Subscribe ลงทะเบียนรับเหตุการณ์ของทุกโปรไฟล์ คืนฟังก์ชันสำหรับยกเลิก
+func (m *Manager) Subscribe(fn func(Event)) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextID
+	m.nextID++
+	m.listeners[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.listeners, id)
+	}
+}
+
+// SubscribeStatus ลงทะเบียนรับการเปลี่ยนสถานะของโปรไฟล์ คืนฟังก์ชันสำหรับยกเลิก
+func (m *Manager) SubscribeStatus(fn func(ProfileStatus)) (unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := m.nextID
+	m.nextID++
+	m.statusListeners[id] = fn
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.statusListeners, id)
+	}
+}
+
+func (m *Manager) emit(ev Event) {
+	// ข้อผิดพลาดจากไดรเวอร์อาจมี DSN ติดมา ปิดบังค่าลับก่อนส่งต่อไปแสดงผล
+	ev.Err = config.RedactError(ev.Err)
+	m.mu.Lock()
+	listeners := make([]func(Event), 0, len(m.listeners))
+	for _, fn := range m.listeners {
+		listeners = append(listeners, fn)
+	}
+	m.mu.Unlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// setStatus บันทึกสถานะของ runner (ถ้ายังเป็น runner ปัจจุบันของโปรไฟล์) และแจ้งผู้ติดตาม
+func (m *Manager) setStatus(r *runner, state string, err error) {
+	m.updateStatus(r, state, err, time.Time{})
+}
+
+// updateStatus เหมือน setStatus และกำหนดเวลาที่จะลองใหม่
+// ข้อผิดพลาดถูกบันทึกเป็นปัญหา และปัญหาของโปรไฟล์จะถูกปิดเมื่อกลับมาทำงาน
+func (m *Manager) updateStatus(r *runner, state string, err error, retryAt time.Time) {
+	err = config.RedactError(err)
+	m.mu.Lock()
+	if m.runners[r.profile.Name] != r {
+		m.mu.Unlock()
+		return
+	}
+	r.status.State = state
+	r.status.Err = err
+	r.status.RetryAt = retryAt
+	switch {
+	case err != nil:
+		m.recordIncident(r, SeverityError, err)
+	case state == StatusRunning:
+		m.resolveIncidents(r.profile.Name)
+	}
+	status := r.status
+	listeners := make([]func(ProfileStatus), 0, len(m.statusListeners))
+	for _, fn := range m.statusListeners {
+		listeners = append(listeners, fn)
+	}
+	m.mu.Unlock()
+	for _, fn := range listeners {
+		fn(status)
+	}
+}
+
+// Config คืน config ที่ใช้งานอยู่ (nil ถ้ายังไม่ได้ Apply)
+func (m *Manager) Config() *config.Config {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.config
+}
+
+// Statuses คืนสถานะของทุกโปรไฟล์ตามลำดับใน config
+func (m *Manager) Statuses() []ProfileStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.config == nil {
+		return nil
+	}
+	statuses := make([]ProfileStatus, 0, len(m.config.Profiles))
+	for _, p := range m.config.Profiles {
+		if r, ok := m.runners[p.Name]; ok {
+			statuses = append(statuses, r.status)
+		}
+	}
+	return statuses
+}
+
+// Apply ปรับแหล่งข้อมูลให้ตรงกับ cfg โดยไม่ต้องรีสตาร์ทโปรแกรม
+// โปรไฟล์ที่ไม่เปลี่ยนแปลงจะทำงานต่อ โปรไฟล์ที่เปลี่ยนหรือถูกลบจะถูกหยุด/เริ่มใหม่
+func (m *Manager) Apply(cfg *config.Config) {
+	m.mu.Lock()
+	m.config = cfg
+	wanted := make(map[string]config.Profile)
+	for _, p := range cfg.Profiles {
+		wanted[p.Name] = p
+	}
+	for key, inc := range m.incidents {
+		if _, ok := wanted[inc.Profile]; !ok {
+			delete(m.incidents, key)
+		}
+	}
+	var stale []*runner
+	for name, r := range m.runners {
+		if p, ok := wanted[name]; !ok || !reflect.DeepEqual(p, r.profile) {
+			stale = append(stale, r)
+			delete(m.runners, name)
+		}
+	}
+	var started []*runner
+	for _, p := range cfg.Profiles {
+		if _, ok := m.runners[p.Name]; ok {
+			continue
+		}
+		r := m.newRunner(p)
+		m.runners[p.Name] = r
+		started = append(started, r)
+	}
+	m.mu.Unlock()
+
+	for _, r := range stale {
+		r.stop()
+	}
+	config.CloseUnusedTunnels(cfg)
+	for _, r := range started {
+		m.start(r)
+	}
+}
+
+// newRunner สร้าง runner ของโปรไฟล์ที่ยังไม่เริ่มทำงาน
+func (m *Manager) newRunner(p config.Profile) *runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &runner{
+		profile: p,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		retry:   make(chan struct{}, 1),
+		status:  ProfileStatus{Name: p.Name, DBType: p.DBType, State: StatusConnecting},
+	}
+}
+
+// start เริ่มแหล่งข้อมูลของโปรไฟล์ใน goroutine แยก
+// เมื่อเชื่อมต่อไม่ได้หรือแหล่งข้อมูลหยุดเพราะข้อผิดพลาด จะรอแล้วลองใหม่จนกว่าจะถูกหยุด
+func (m *Manager) start(r *runner) {
+	ctx := r.ctx
+	p := &r.profile
+
+	if p.Disabled {
+		close(r.done)
+		m.setStatus(r, StatusDisabled, nil)
+		return
+	}
+	m.setStatus(r, StatusConnecting, nil)
+
+	go func() {
+		defer close(r.done)
+		delay := minRetryDelay
+
+		for {
+			started := time.Now()
+			err := m.run(r)
+			if ctx.Err() != nil {
+				m.setStatus(r, StatusStopped, nil)
+				return
+			}
+			if err == nil {
+				err = errors.New("แหล่งข้อมูลหยุดทำงานโดยไม่ทราบสาเหตุ")
+			}
+			// ทำงานได้นานพอแล้วจึงผิดพลาด ถือเป็นปัญหาใหม่ เริ่มนับเวลารอใหม่
+			if time.Since(started) > maxRetryDelay {
+				delay = minRetryDelay
+			}
+			m.emitFor(r, Event{Source: p.DBType, Time: time.Now(), Err: err})
+			m.updateStatus(r, StatusError, err, time.Now().Add(delay))
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				m.setStatus(r, StatusStopped, nil)
+				return
+			case <-r.retry:
+				timer.Stop()
+			case <-timer.C:
+			}
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+			m.setStatus(r, StatusConnecting, nil)
+		}
+	}()
+}
+
+// run เชื่อมต่อและอ่านการเปลี่ยนแปลงหนึ่งรอบ จนกว่า ctx จะถูกยกเลิก (คืน nil) หรือเกิดข้อผิดพลาด
+func (m *Manager) run(r *runner) error {
+	ctx := r.ctx
+	p := &r.profile
+
+	if err := config.TestConnection(p); err != nil {
+		return err
+	}
+
+	var source Source
+	var tables *config.TableConfig
+	if p.Engine != config.EngineNone {
+		var err error
+		tables, err = config.LoadTableConfig(p.TableConfigFile)
+		if err != nil {
+			return err
+		}
+		switch p.Engine {
+		case config.EngineBinlog:
+			source = NewMySQLSource(p, tables)
+		case config.EnginePostgresLog:
+			source = NewPostgresSource(p, tables)
+		}
+	}
+
+	m.mu.Lock()
+	r.source = source
+	r.tables = tables
+	if poller, ok := source.(Poller); ok {
+		poller.SetPaused(m.paused[p.Name])
+	}
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		r.source = nil
+		m.mu.Unlock()
+	}()
+	m.setStatus(r, StatusRunning, nil)
+
+	if source == nil {
+		// ไม่มีการอ่านการเปลี่ยนแปลง ถือว่าเชื่อมต่อได้จนกว่าจะถูกหยุด
+		<-ctx.Done()
+		return nil
+	}
+
+	return source.Run(ctx, func(ev Event) {
+		if ev.Err != nil {
+			m.mu.Lock()
+			m.recordIncident(r, SeverityWarning, config.RedactError(ev.Err))
+			m.mu.Unlock()
+		}
+		m.emitFor(r, ev)
+	})
+}
+
+// emitFor ใส่ชื่อโปรไฟล์ให้เหตุการณ์แล้วส่งต่อ
+func (m *Manager) emitFor(r *runner, ev Event) {
+	ev.Profile = r.profile.Name
+	if ev.IsChange() && !ev.Time.IsZero() {
+		m.mu.Lock()
+		if ev.Time.After(r.lastEvent) {
+			r.lastEvent = ev.Time
+		}
+		m.mu.Unlock()
+	}
+	m.emit(ev)
+}
+
+// Lags คืนความล่าช้าในการอ่านของทุกโปรไฟล์ที่กำลังทำงานตามลำดับใน config
+func (m *Manager) Lags() []Lag {
+	m.mu.Lock()
+	type entry struct {
+		lag    Lag
+		source Source
+	}
+	var entries []entry
+	if m.config != nil {
+		for _, p := range m.config.Profiles {
+			r, ok := m.runners[p.Name]
+			if !ok || r.status.State != StatusRunning || r.source == nil {
+				continue
+			}
+			entries = append(entries, entry{Lag{Profile: p.Name, LastEvent: r.lastEvent, BytesBehind: -1}, r.source})
+		}
+	}
+	m.mu.Unlock()
+
+	lags := make([]Lag, len(entries))
+	for i, e := range entries {
+		lag := e.lag
+		if b, ok := e.source.(Backlog); ok {
+			lag.BytesBehind = b.BytesBehind()
+		}
+		// ไม่ทราบจำนวนไบต์ที่ค้าง (-1) ไม่ถือว่าล่าช้า ผู้เรียกแยกแสดงด้วย Lag.Unknown
+		if lag.BytesBehind > 0 && !lag.LastEvent.IsZero() {
+			lag.Capture = time.Since(lag.LastEvent)
+		}
+		lags[i] = lag
+	}
+	return lags
+}
+
+// stop หยุด runner และรอจนหยุดเรียบร้อย
+func (r *runner) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// Stop หยุดแหล่งข้อมูลทุกโปรไฟล์และรอจนหยุดเรียบร้อย
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	runners := m.runners
+	m.runners = make(map[string]*runner)
+	m.mu.Unlock()
+
+	for _, r := range runners {
+		r.stop()
+	}
+}
+
+// Restart หยุดแล้วเริ่มแหล่งข้อมูลของโปรไฟล์ใหม่ อ่านต่อจากตำแหน่งเดิม (เช่น หลังแก้ไข tables.json)
+func (m *Manager) Restart(profile string) {
+	m.mu.Lock()
+	old, ok := m.runners[profile]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	r := m.newRunner(old.profile)
+	m.runners[profile] = r
+	m.mu.Unlock()
+
+	old.stop()
+	m.start(r)
+}
+
+// PollNow สั่งให้แหล่งข้อมูลแบบอ่านเป็นรอบของโปรไฟล์อ่านทันที
+func (m *Manager) PollNow(profile string) {
+	if p, ok := m.currentSource(profile).(Poller); ok {
+		p.PollNow()
+	}
+}
+
+// SetPaused หยุดหรือกลับมาอ่านอัตโนมัติสำหรับแหล่งข้อมูลแบบอ่านเป็นรอบของโปรไฟล์
+func (m *Manager) SetPaused(profile string, paused bool) {
+	m.mu.Lock()
+	m.paused[profile] = paused
+	m.mu.Unlock()
+	if p, ok := m.currentSource(profile).(Poller); ok {
+		p.SetPaused(paused)
+	}
+}
+
+// Tables คืนตารางที่ติดตามของโปรไฟล์ตามที่โหลดตอนเริ่มแหล่งข้อมูลครั้งล่าสุด (nil ถ้ายังไม่ได้เริ่ม)
+func (m *Manager) Tables(profile string) *config.TableConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.runners[profile]; ok {
+		return r.tables
+	}
+	return nil
+}
+
+func (m *Manager) currentSource(profile string) Source {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.runners[profile]; ok {
+		return r.source
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package capture
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	config "hissync-10/functions"
+	"hissync-10/logging"
+)
+
+// MySQLSource อ่านการเปลี่ยนแปลงจาก MySQL binlog
+type MySQLSource struct {
+	cfg    *config.Profile
+	tables *config.TableConfig
+	// behind จำนวนไบต์ของ binlog ที่ยังไม่ได้อ่าน ณ ตอนตรวจล่าสุด
+	behind atomic.Int64
+	// tableInfos คอลัมน์ของตารางที่ติดตาม ล้างเมื่อพบคำสั่ง DDL
+	tableInfos map[string]tableInfo
+}
+
+// NewMySQLSource สร้างแหล่งข้อมูล MySQL binlog
+func NewMySQLSource(cfg *config.Profile, tables *config.TableConfig) *MySQLSource {
+	s := &MySQLSource{cfg: cfg, tables: tables, tableInfos: make(map[string]tableInfo)}
+	s.behind.Store(-1)
+	return s
+}
+
+// serverIDOwner คืน host ของ replica อื่นที่ใช้ serverID อยู่ (ว่างคือไม่ซ้ำ)
+// replica ที่รายงาน host เป็นเครื่องนี้ถือเป็นการเชื่อมต่อเดิมของโปรแกรมที่เซิร์ฟเวอร์ยังไม่ได้ปลดออก
+func serverIDOwner(ctx context.Context, db *sql.DB, serverID uint32, hostname string) (string, error) {
+	var own string
+	if err := db.QueryRowContext(ctx, "SELECT @@server_id").Scan(&own); err != nil {
+		return "", err
+	}
+	if own == strconv.FormatUint(uint64(serverID), 10) {
+		return "เซิร์ฟเวอร์ต้นทางเอง", nil
+	}
+
+	// SHOW REPLICAS ตั้งแต่ MySQL 8.0.22 เวอร์ชันก่อนหน้าและ MariaDB ใช้ SHOW SLAVE HOSTS
+	rows, err := db.QueryContext(ctx, "SHOW REPLICAS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE HOSTS")
+	}
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		var id, host string
+		for i, name := range columns {
+			switch strings.ToLower(name) {
+			case "server_id":
+				id = string(values[i])
+			case "host":
+				host = string(values[i])
+			}
+		}
+		if id == strconv.FormatUint(uint64(serverID), 10) && !strings.EqualFold(host, hostname) {
+			if host == "" {
+				host = "replica ที่ไม่ได้ระบุ host"
+			}
+			return host, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+// BytesBehind จำนวนไบต์ของ binlog บนเซิร์ฟเวอร์ที่ยังไม่ได้อ่าน (ตรวจทุกรอบการอ่าน)
+func (s *MySQLSource) BytesBehind() int64 {
+	return s.behind.Load()
+}
+
+// Run อ่าน binlog จนกว่า ctx จะถูกยกเลิกหรือเกิดข้อผิดพลาด
+func (s *MySQLSource) Run(ctx context.Context, emit func(Event)) error {
+	cfg := s.cfg
+
+	db, err := config.OpenDB(cfg)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเชื่อมต่อ MySQL: %v", err)
+	}
+	defer db.Close()
+
+	tlsConfig, err := cfg.ClientTLS()
+	if err != nil {
+		return err
+	}
+	serverID, err := config.ReplicaServerID(cfg)
+	if err != nil {
+		return err
+	}
+	hostname, _ := os.Hostname()
+	if owner, err := serverIDOwner(ctx, db, serverID, hostname); err != nil {
+		logging.For(logging.ComponentCapture).Warn("ตรวจ server_id ซ้ำไม่ได้", "profile", cfg.Name, "error", err)
+	} else if owner != "" {
+		return fmt.Errorf("server_id %d ถูกใช้โดย %s อยู่แล้ว กำหนด replication.server_id ของโปรไฟล์ %s ใหม่", serverID, owner, cfg.Name)
+	}
+
+	replica := cfg.Replica()
+	// Dialer เชื่อมต่อไปยัง port ของโปรไฟล์เสมอ จึงสร้างจากสำเนาที่ใช้พอร์ต replication
+	dialProfile := *cfg
+	dialProfile.Port = replica.Port
+	syncerCfg := replication.BinlogSyncerConfig{
+		ServerID:        serverID,
+		Flavor:          replica.Flavor,
+		Host:            cfg.Host,
+		Port:            replica.PortNumber(),
+		User:            cfg.Username,
+		Password:        cfg.Password.Reveal(),
+		Localhost:       hostname,
+		Charset:         replica.Charset,
+		HeartbeatPeriod: replica.HeartbeatPeriod(),
+		ReadTimeout:     replica.ReadTimeout(),
+		TLSConfig:       tlsConfig,
+		Dialer:          config.NewDialer(&dialProfile).DialContext,
+	}
+
+	flavor := binlogFlavor(ctx, db, cfg)
+	syncerCfg.Flavor = flavor
+	if flavor == config.FlavorMariaDB {
+		// ขอ ANNOTATE_ROWS เพื่อให้ได้คำสั่ง SQL ต้นฉบับของแต่ละแถว
+		syncerCfg.DumpCommandFlag = replication.BINLOG_SEND_ANNOTATE_ROWS_EVENT
+	}
+
+	for {
+		state, _ := LoadState(cfg.StateFile)
+
+		var binlogPos uint32
+		var binlogFile string
+		binlogPosStr := state.LastBinlogPosition
+		gtidPos := state.LastGTID
+		if flavor != config.FlavorMariaDB {
+			gtidPos = ""
+		}
+
+		switch {
+		case gtidPos != "":
+			// MariaDB อ่านต่อจาก GTID ไฟล์และตำแหน่งจะได้จาก Rotate แรกของเซิร์ฟเวอร์
+			binlogFile = state.LastLogFile
+			binlogPos = uint32(atoi(binlogPosStr))
+		case state.LastLogFile == "" || binlogPosStr == "" || binlogPosStr == "0":
+			binlogFile, binlogPos, err = masterStatus(ctx, db)
+			if err != nil {
+				return fmt.Errorf("ไม่สามารถดึง Binlog ล่าสุด: %v", err)
+			}
+		default:
+			binlogFile = state.LastLogFile
+			binlogPos = uint32(atoi(binlogPosStr))
+		}
+
+		// ตำแหน่งที่อ่านถึงจริงอยู่ใน decoder ใช้คำนวณความล่าช้า
+		// ตำแหน่งที่บันทึกเลื่อนเฉพาะเมื่ออ่านธุรกรรมครบ ธุรกรรมที่อ่านค้างไว้จะถูกอ่านซ้ำโดยไม่ส่งแถวเดิม
+		decoder := s.newDecoder(db, binlogFile, binlogPos)
+		decoder.skip = state.LastSent
+		decoder.session = func(thread uint32) sessionInfo { return mysqlSession(ctx, db, thread) }
+		syncer := replication.NewBinlogSyncer(syncerCfg)
+		var streamer *replication.BinlogStreamer
+		if gtidPos != "" {
+			var set mysql.GTIDSet
+			if set, err = mysql.ParseMariadbGTIDSet(gtidPos); err == nil {
+				err = decoder.setGTIDPosition(gtidPos)
+			}
+			if err != nil {
+				syncer.Close()
+				return fmt.Errorf("last_gtid %q ใน %s ไม่ถูกต้อง: %v", gtidPos, cfg.StateFile, err)
+			}
+			streamer, err = syncer.StartSyncGTID(set)
+		} else {
+			if flavor == config.FlavorMariaDB {
+				// เริ่มบันทึก GTID จากตำแหน่งที่ตรงกับไฟล์และตำแหน่งนี้ เพื่อให้รอบถัดไปอ่านต่อด้วย GTID ได้
+				var pos sql.NullString
+				if db.QueryRowContext(ctx, "SELECT BINLOG_GTID_POS(?, ?)", binlogFile, binlogPos).Scan(&pos) == nil {
+					decoder.setGTIDPosition(pos.String)
+				}
+			}
+			streamer, err = syncer.StartSync(mysql.Position{Name: binlogFile, Pos: binlogPos})
+		}
+		if err != nil {
+			syncer.Close()
+			return fmt.Errorf("เริ่มต้น Sync Binlog ไม่สำเร็จ: %v", err)
+		}
+		timeout := time.After(10 * time.Second)
+
+	Loop:
+		for {
+			select {
+			case <-ctx.Done():
+				s.saveState(decoder.checkpoint())
+				syncer.Close()
+				return nil
+			case <-timeout:
+				s.saveState(decoder.checkpoint())
+				syncer.Close()
+				s.updateBacklog(ctx, db, decoder.file, decoder.pos)
+				break Loop
+			default:
+				evCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+				ev, err := streamer.GetEvent(evCtx)
+				cancel()
+				if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+					syncer.Close()
+					return fmt.Errorf("เกิดข้อผิดพลาดในการอ่าน Binlog: %v", err)
+				}
+				if ev == nil {
+					continue
+				}
+				decoder.decode(ev, emit)
+			}
+		}
+	}
+}
+
+// binlogFlavor คืนรูปแบบ binlog ตาม replication.flavor หรือตรวจจากเวอร์ชันของเซิร์ฟเวอร์ถ้าไม่ได้กำหนด
+func binlogFlavor(ctx context.Context, db *sql.DB, p *config.Profile) string {
+	if flavor := p.Replica().Flavor; flavor != "" {
+		return flavor
+	}
+	var version string
+	if err := db.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		return config.FlavorMySQL
+	}
+	return config.FlavorFromVersion(version)
+}
+
+// masterStatus คืนไฟล์และตำแหน่งปัจจุบันของ binlog
+// จำนวนคอลัมน์ของ SHOW MASTER STATUS ต่างกันระหว่าง MySQL และ MariaDB จึงอ่านเฉพาะสองคอลัมน์แรก
+func masterStatus(ctx context.Context, db *sql.DB) (string, uint32, error) {
+	rows, err := db.QueryContext(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(columns) < 2 {
+		return "", 0, fmt.Errorf("SHOW MASTER STATUS คืน %d คอลัมน์", len(columns))
+	}
+	var file string
+	var pos uint32
+	values := make([]interface{}, len(columns))
+	values[0], values[1] = &file, &pos
+	for i := 2; i < len(values); i++ {
+		values[i] = new(sql.RawBytes)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("ไม่ได้เปิด binlog (log_bin=OFF)")
+	}
+	if err := rows.Scan(values...); err != nil {
+		return "", 0, err
+	}
+	return file, pos, nil
+}
+
+// saveState บันทึกตำแหน่งของธุรกรรมล่าสุดที่อ่านครบ (decoder.checkpoint) ลง state file
+func (s *MySQLSource) saveState(state State) {
+	state.LastLogDatetime = time.Now().Format("2006-01-02 15:04:05.000 -07")
+	SaveState(s.cfg.StateFile, state)
+}
+
+// updateBacklog คำนวณจำนวนไบต์ที่ยังไม่ได้อ่านจาก SHOW BINARY LOGS
+func (s *MySQLSource) updateBacklog(ctx context.Context, db *sql.DB, file string, pos uint32) {
+	rows, err := db.QueryContext(ctx, "SHOW BINARY LOGS")
+	if err != nil {
+		s.behind.Store(-1)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil || len(columns) < 2 {
+		s.behind.Store(-1)
+		return
+	}
+	// MySQL 8.0 มีคอลัมน์ Encrypted เพิ่ม อ่านเฉพาะสองคอลัมน์แรก
+	values := make([]interface{}, len(columns))
+	var name string
+	var size int64
+	values[0], values[1] = &name, &size
+	for i := 2; i < len(values); i++ {
+		values[i] = new(sql.RawBytes)
+	}
+
+	found := false
+	var behind int64
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			s.behind.Store(-1)
+			return
+		}
+		switch {
+		case name == file:
+			found = true
+			if size > int64(pos) {
+				behind += size - int64(pos)
+			}
+		case found:
+			behind += size
+		}
+	}
+	if !found || rows.Err() != nil {
+		s.behind.Store(-1)
+		return
+	}
+	s.behind.Store(behind)
+}
+
+// ฟังก์ชันแปลง string เป็น int
+func atoi(s string) int {
+	var result int
+	fmt.Sscanf(s, "%d", &result)
+	return result
+}
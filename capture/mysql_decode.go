@@ -0,0 +1,267 @@
+package capture
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	config "hissync-10/functions"
+)
+
+// binlogDecoder แปลงเหตุการณ์ binlog เป็น Event ตามตารางที่ติดตาม
+// ใช้ร่วมกันระหว่างการอ่านแบบ replica และการนำเข้าไฟล์ binlog จากดิสก์
+type binlogDecoder struct {
+	s *MySQLSource
+	// db ใช้ดึงคอลัมน์ที่ binlog ไม่ได้บันทึก nil คือใช้เฉพาะข้อมูลใน binlog
+	db       *sql.DB
+	tableMap map[uint64]*replication.TableMapEvent
+	// file และ pos ตำแหน่งที่อ่านถึงจริง (รวมเหตุการณ์ของตารางที่ไม่ได้ติดตาม)
+	file string
+	pos  uint32
+	// commitFile และ commitPos ตำแหน่งหลังธุรกรรมล่าสุดที่อ่านครบ (XID หรือ COMMIT) ใช้อ่านต่อเมื่อเชื่อมต่อใหม่
+	commitFile string
+	commitPos  uint32
+	// sent ไฟล์:ตำแหน่ง ของแถวล่าสุดที่ส่งแล้วในธุรกรรมที่ยังอ่านไม่ครบ
+	// skip แถวของธุรกรรมแรกที่อ่านซ้ำจนถึงตำแหน่งนี้ถูกส่งไปแล้วก่อนเชื่อมต่อใหม่ ไม่ต้องส่งอีก
+	sent, skip string
+	// ธุรกรรมปัจจุบัน: GTID และตำแหน่งของ BEGIN
+	gtid, txBegin string
+	// query คำสั่ง SQL ต้นฉบับของแถวถัดไป (Rows_query ของ MySQL หรือ ANNOTATE_ROWS ของ MariaDB)
+	query string
+	// thread รหัส session ของธุรกรรมปัจจุบัน (จาก BEGIN) 0 คือไม่ทราบ
+	thread uint32
+	// tracked ธุรกรรมปัจจุบันมีแถวของตารางที่ติดตาม จึงต้องส่งจุดสิ้นสุดธุรกรรมเมื่อ commit
+	tracked bool
+	// session ค้นผู้ใช้และโปรแกรมจาก thread id nil คือไม่ค้น (เช่น นำเข้าไฟล์ binlog ย้อนหลัง)
+	session  func(thread uint32) sessionInfo
+	sessions map[uint32]sessionInfo
+
+	// gtidPos ตำแหน่ง GTID ของ MariaDB ที่อ่านครบทั้งธุรกรรมแล้ว แยกตาม domain
+	gtidPos map[uint32]mysql.MariadbGTID
+	// pending GTID ของธุรกรรม MariaDB ที่กำลังอ่าน และ standalone คือธุรกรรมที่ไม่มี COMMIT (เช่น DDL)
+	pending    *mysql.MariadbGTID
+	standalone bool
+}
+
+// newDecoder สร้างตัวแปลงที่เริ่มอ่านจาก file ตำแหน่ง pos
+func (s *MySQLSource) newDecoder(db *sql.DB, file string, pos uint32) *binlogDecoder {
+	return &binlogDecoder{
+		s:          s,
+		db:         db,
+		tableMap:   make(map[uint64]*replication.TableMapEvent),
+		file:       file,
+		pos:        pos,
+		commitFile: file,
+		commitPos:  pos,
+		gtidPos:    make(map[uint32]mysql.MariadbGTID),
+		sessions:   make(map[uint32]sessionInfo),
+	}
+}
+
+// setGTIDPosition กำหนดตำแหน่ง GTID ของ MariaDB เริ่มต้น (รูปแบบ domain-server-sequence คั่นด้วยจุลภาค)
+func (d *binlogDecoder) setGTIDPosition(pos string) error {
+	for _, part := range strings.Split(pos, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		gtid, err := mysql.ParseMariadbGTID(part)
+		if err != nil {
+			return err
+		}
+		d.gtidPos[gtid.DomainID] = *gtid
+	}
+	return nil
+}
+
+// gtidPosition คืนตำแหน่ง GTID ของ MariaDB ที่อ่านครบแล้ว (ว่างถ้าไม่ใช่ MariaDB)
+// มีหนึ่ง GTID ต่อ domain ตามที่ MariaDB ใช้ใน gtid_slave_pos
+func (d *binlogDecoder) gtidPosition() string {
+	parts := make([]string, 0, len(d.gtidPos))
+	for _, gtid := range d.gtidPos {
+		parts = append(parts, gtid.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// checkpoint คืนตำแหน่งที่บันทึกใน state.json: ไฟล์และตำแหน่งหลังธุรกรรมล่าสุดที่อ่านครบ
+// ชุด GTID ของ MariaDB และแถวล่าสุดที่ส่งแล้วของธุรกรรมที่ยังอ่านไม่ครบ (ธุรกรรมนี้จะถูกอ่านซ้ำตั้งแต่ต้น)
+func (d *binlogDecoder) checkpoint() State {
+	return State{
+		LastBinlogPosition: fmt.Sprintf("%d", d.commitPos),
+		LastLogFile:        d.commitFile,
+		LastGTID:           d.gtidPosition(),
+		LastSent:           d.sent,
+	}
+}
+
+// transaction คืนรหัสของธุรกรรมปัจจุบัน (GTID ถ้ามี ไม่เช่นนั้นเป็นไฟล์:ตำแหน่ง ของ BEGIN)
+func (d *binlogDecoder) transaction() string {
+	if d.gtid != "" {
+		return d.gtid
+	}
+	return d.txBegin
+}
+
+// commit ปิดธุรกรรมปัจจุบัน และส่งจุดสิ้นสุดธุรกรรมไปยัง emit ถ้ามีแถวของตารางที่ติดตาม
+func (d *binlogDecoder) commit(ev *replication.BinlogEvent, emit func(Event)) {
+	if tx := d.transaction(); d.tracked && tx != "" {
+		emit(Event{
+			Source:      config.DBTypeMySQL,
+			Position:    fmt.Sprintf("%d", ev.Header.LogPos),
+			Time:        time.Unix(int64(ev.Header.Timestamp), 0),
+			LogFile:     d.file,
+			GTID:        d.gtid,
+			Transaction: tx,
+			Commit:      true,
+		})
+	}
+	if d.pending != nil {
+		d.gtidPos[d.pending.DomainID] = *d.pending
+		d.pending = nil
+	}
+	d.commitFile, d.commitPos, d.sent, d.skip = d.file, d.pos, "", ""
+	d.gtid, d.txBegin, d.query, d.standalone, d.thread, d.tracked = "", "", "", false, 0, false
+}
+
+// sessionOf คืนข้อมูลผู้ทำรายการของธุรกรรมปัจจุบัน ณ เวลา t ของเหตุการณ์
+func (d *binlogDecoder) sessionOf(t time.Time) sessionInfo {
+	if d.thread == 0 {
+		return sessionInfo{}
+	}
+	s, ok := d.sessions[d.thread]
+	if !ok && d.session != nil && time.Since(t) < sessionLookupWindow {
+		s = d.session(d.thread)
+		d.sessions[d.thread] = s
+	}
+	s.ID = strconv.FormatUint(uint64(d.thread), 10)
+	return s
+}
+
+// decode ส่งแถวที่เปลี่ยนของตารางที่ติดตามและจุดสิ้นสุดของธุรกรรมที่มีแถวเหล่านั้นไปยัง emit
+func (d *binlogDecoder) decode(ev *replication.BinlogEvent, emit func(Event)) {
+	if ev.Header.LogPos > 0 {
+		d.pos = ev.Header.LogPos
+	}
+
+	switch e := ev.Event.(type) {
+	case *replication.RotateEvent:
+		d.file, d.pos = string(e.NextLogName), uint32(e.Position)
+
+	case *replication.GTIDEvent:
+		if set, err := e.GTIDNext(); err == nil {
+			d.gtid = set.String()
+		}
+
+	case *replication.MariadbGTIDEvent:
+		// MariaDB ไม่มี BEGIN แยก เหตุการณ์ GTID คือจุดเริ่มธุรกรรม
+		gtid := e.GTID
+		d.pending, d.standalone = &gtid, e.IsStandalone()
+		d.gtid, d.txBegin, d.query, d.thread, d.tracked = gtid.String(), "", "", 0, false
+
+	case *replication.MariadbGTIDListEvent:
+		// ตำแหน่ง GTID ณ ต้นไฟล์ binlog
+		for _, gtid := range e.GTIDs {
+			d.gtidPos[gtid.DomainID] = gtid
+		}
+
+	case *replication.MariadbAnnotateRowsEvent:
+		d.query = string(e.Query)
+
+	case *replication.RowsQueryEvent:
+		d.query = string(e.Query)
+
+	case *replication.QueryEvent:
+		switch query := strings.TrimSpace(string(e.Query)); {
+		case strings.EqualFold(query, "BEGIN"):
+			d.txBegin = fmt.Sprintf("%s:%d", d.file, ev.Header.LogPos-ev.Header.EventSize)
+			d.thread = e.SlaveProxyID
+		case strings.EqualFold(query, "COMMIT"):
+			// ธุรกรรมของตารางที่ไม่รองรับ transaction (เช่น MyISAM) จบด้วย COMMIT แทน XID
+			d.commit(ev, emit)
+		default:
+			// อาจเป็น DDL ที่เปลี่ยนคอลัมน์ของตาราง
+			clear(d.s.tableInfos)
+			if d.standalone {
+				d.commit(ev, emit)
+			}
+		}
+
+	case *replication.XIDEvent:
+		d.commit(ev, emit)
+
+	case *replication.TableMapEvent:
+		d.tableMap[e.TableID] = e
+
+	case *replication.RowsEvent:
+		table, ok := d.tableMap[e.TableID]
+		if !ok {
+			return
+		}
+
+		dbName := string(table.Schema)
+		tableName := string(table.Table)
+
+		// ตรวจสอบว่า database และ table อยู่ใน tables.json หรือไม่
+		entry := d.s.tables.Find(dbName, tableName)
+		if entry == nil {
+			return
+		}
+		d.tracked = true
+		at := fmt.Sprintf("%s:%d", d.file, ev.Header.LogPos)
+		d.sent = at
+		if d.skip != "" {
+			if at == d.skip {
+				d.skip = ""
+			}
+			return
+		}
+
+		base := Event{
+			Source:      config.DBTypeMySQL,
+			Position:    fmt.Sprintf("%d", ev.Header.LogPos),
+			Time:        time.Unix(int64(ev.Header.Timestamp), 0),
+			Database:    dbName,
+			Table:       tableName,
+			LogFile:     d.file,
+			GTID:        d.gtid,
+			Transaction: d.transaction(),
+			OriginalSQL: d.query,
+		}
+		d.sessionOf(base.Time).apply(&base)
+		info := d.s.tableInfo(d.db, entry, table.ColumnNameString())
+
+		switch ev.Header.EventType {
+		case replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2, replication.MARIADB_WRITE_ROWS_COMPRESSED_EVENT_V1:
+			if !entry.AllowsOperation("INSERT") {
+				break
+			}
+			base.Operation = "INSERT"
+			for _, row := range e.Rows {
+				emit(rowEvent(base, entry, info, nil, row))
+			}
+		case replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2, replication.MARIADB_UPDATE_ROWS_COMPRESSED_EVENT_V1:
+			if !entry.AllowsOperation("UPDATE") {
+				break
+			}
+			base.Operation = "UPDATE"
+			for i := 0; i+1 < len(e.Rows); i += 2 {
+				emit(rowEvent(base, entry, info, e.Rows[i], e.Rows[i+1]))
+			}
+		case replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2, replication.MARIADB_DELETE_ROWS_COMPRESSED_EVENT_V1:
+			if !entry.AllowsOperation("DELETE") {
+				break
+			}
+			base.Operation = "DELETE"
+			for _, row := range e.Rows {
+				emit(rowEvent(base, entry, info, row, nil))
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package capture
+
+import (
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/replication"
+
+	config "hissync-10/functions"
+)
+
+// binlogEvent สร้างเหตุการณ์ binlog ที่จบที่ตำแหน่ง end
+func binlogEvent(end uint32, eventType replication.EventType, ev replication.Event) *replication.BinlogEvent {
+	return &replication.BinlogEvent{
+		Header: &replication.EventHeader{EventType: eventType, LogPos: end, EventSize: 10},
+		Event:  ev,
+	}
+}
+
+// transactionEvents ธุรกรรมหนึ่งรายการที่เริ่มที่ตำแหน่ง start: BEGIN, TABLE_MAP, แถวที่เพิ่ม 2 แถว และ XID
+func transactionEvents(start uint32, table string) []*replication.BinlogEvent {
+	return []*replication.BinlogEvent{
+		binlogEvent(start+10, replication.QUERY_EVENT, &replication.QueryEvent{Query: []byte("BEGIN")}),
+		binlogEvent(start+20, replication.TABLE_MAP_EVENT, &replication.TableMapEvent{TableID: 1, Schema: []byte("jhcis"), Table: []byte(table)}),
+		binlogEvent(start+30, replication.WRITE_ROWS_EVENTv2, &replication.RowsEvent{TableID: 1, Rows: [][]interface{}{{1}, {2}}}),
+		binlogEvent(start+40, replication.XID_EVENT, &replication.XIDEvent{}),
+	}
+}
+
+func TestDecodeCommit(t *testing.T) {
+	tables := &config.TableConfig{Tables: []config.TableEntry{{Database: "jhcis", Table: "person", PrimaryKey: []string{"pid"}}}}
+	d := NewMySQLSource(&config.Profile{}, tables).newDecoder(nil, "mysql-bin.000001", 4)
+
+	var events []Event
+	for _, ev := range append(transactionEvents(100, "visit"), transactionEvents(200, "person")...) {
+		d.decode(ev, func(ev Event) { events = append(events, ev) })
+	}
+
+	// ธุรกรรมของตารางที่ไม่ได้ติดตามไม่มีจุดสิ้นสุดธุรกรรม
+	if len(events) != 3 {
+		t.Fatalf("ได้ %d เหตุการณ์ ต้องการ 3 (2 แถวและจุดสิ้นสุดธุรกรรม): %+v", len(events), events)
+	}
+	for _, ev := range events[:2] {
+		if !ev.IsChange() || ev.Transaction != "mysql-bin.000001:200" {
+			t.Fatalf("แถว %+v ต้องเป็นการเปลี่ยนแปลงของธุรกรรม mysql-bin.000001:200", ev)
+		}
+	}
+	commit := events[2]
+	if !commit.Commit || commit.IsChange() || commit.Transaction != "mysql-bin.000001:200" || commit.Position != "240" {
+		t.Fatalf("จุดสิ้นสุดธุรกรรม = %+v ต้องการ Commit ของ mysql-bin.000001:200 ที่ตำแหน่ง 240", commit)
+	}
+}
+
+func TestDecodeCheckpoint(t *testing.T) {
+	tables := &config.TableConfig{Tables: []config.TableEntry{{Database: "jhcis", Table: "person", PrimaryKey: []string{"pid"}}}}
+	source := NewMySQLSource(&config.Profile{}, tables)
+	tx := append(transactionEvents(100, "visit"), transactionEvents(200, "person")...)
+	// แถวที่สามของธุรกรรมที่สองก่อน XID
+	more := binlogEvent(235, replication.WRITE_ROWS_EVENTv2, &replication.RowsEvent{TableID: 1, Rows: [][]interface{}{{3}}})
+	tx = append(tx[:7], more, tx[7])
+
+	// อ่านถึงกลางธุรกรรมที่สอง: ตำแหน่งที่บันทึกอยู่หลัง XID ของธุรกรรมแรก
+	d := source.newDecoder(nil, "mysql-bin.000001", 4)
+	var sent []Event
+	for _, ev := range tx[:7] {
+		d.decode(ev, func(ev Event) { sent = append(sent, ev) })
+	}
+	state := d.checkpoint()
+	if state.LastLogFile != "mysql-bin.000001" || state.LastBinlogPosition != "140" || state.LastSent != "mysql-bin.000001:230" {
+		t.Fatalf("checkpoint() = %+v ต้องการ mysql-bin.000001:140 และแถวที่ส่งแล้วถึง 230", state)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("ส่ง %d แถวก่อนเชื่อมต่อใหม่ ต้องการ 2", len(sent))
+	}
+
+	// เชื่อมต่อใหม่จากตำแหน่งที่บันทึก: อ่านธุรกรรมที่สองซ้ำโดยส่งเฉพาะแถวที่ยังไม่ได้ส่งและจุดสิ้นสุดธุรกรรม
+	d = source.newDecoder(nil, state.LastLogFile, 140)
+	d.skip = state.LastSent
+	var resent []Event
+	for _, ev := range tx[4:] {
+		d.decode(ev, func(ev Event) { resent = append(resent, ev) })
+	}
+	if len(resent) != 2 || resent[0].Position != "235" || !resent[1].Commit {
+		t.Fatalf("หลังเชื่อมต่อใหม่ได้ %+v ต้องการแถวที่ 235 และจุดสิ้นสุดธุรกรรม", resent)
+	}
+	if state := d.checkpoint(); state.LastBinlogPosition != "240" || state.LastSent != "" {
+		t.Fatalf("checkpoint() หลัง XID = %+v ต้องการตำแหน่ง 240", state)
+	}
+}
@@ -0,0 +1,233 @@
+package capture
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	config "hissync-10/functions"
+)
+
+// tableInfo ชื่อคอลัมน์ตามลำดับในตาราง และคอลัมน์ primary key
+type tableInfo struct {
+	columns    []string
+	primaryKey []string
+}
+
+// tableInfo คืนข้อมูลคอลัมน์ของตาราง (เก็บไว้ใช้ซ้ำจนกว่าจะมีคำสั่ง DDL)
+// names คือชื่อคอลัมน์จาก binlog (binlog_row_metadata=FULL) ถ้ามี และ db เป็น nil เมื่อไม่มีฐานข้อมูลให้ถาม
+func (s *MySQLSource) tableInfo(db *sql.DB, entry *config.TableEntry, names []string) tableInfo {
+	key := entry.Database + "." + entry.Table
+	if info, ok := s.tableInfos[key]; ok {
+		return info
+	}
+	info := tableInfo{columns: names, primaryKey: entry.PrimaryKey}
+	if db == nil {
+		s.tableInfos[key] = info
+		return info
+	}
+	if len(info.columns) == 0 {
+		info.columns, _ = getColumns(db, entry.Database, entry.Table)
+	}
+	if len(info.primaryKey) == 0 {
+		info.primaryKey, _ = getPrimaryKey(db, entry.Database, entry.Table)
+	}
+	s.tableInfos[key] = info
+	return info
+}
+
+// columnName ชื่อคอลัมน์ที่ i (ถ้าไม่ทราบใช้ @ลำดับ เหมือน mysqlbinlog)
+func (t tableInfo) columnName(i int) string {
+	if i < len(t.columns) {
+		return t.columns[i]
+	}
+	return fmt.Sprintf("@%d", i+1)
+}
+
+// rowEvent เติมค่าคอลัมน์ primary key และคำสั่ง SQL ของแถวที่เปลี่ยน
+// before เป็น nil สำหรับ INSERT และ after เป็น nil สำหรับ DELETE
+func rowEvent(base Event, entry *config.TableEntry, info tableInfo, before, after []interface{}) Event {
+	ev := base
+	n := len(before)
+	if len(after) > n {
+		n = len(after)
+	}
+	ev.Columns = make([]ColumnValue, n)
+	for i := range ev.Columns {
+		ev.Columns[i].Name = info.columnName(i)
+		if i < len(before) {
+			ev.Columns[i].Before = displayValue(before[i])
+		}
+		if i < len(after) {
+			ev.Columns[i].After = displayValue(after[i])
+		}
+	}
+
+	keyRow := before
+	if keyRow == nil {
+		keyRow = after
+	}
+	ev.PrimaryKey = primaryKeyJSON(info, keyRow)
+
+	table := quoteIdent(entry.Database) + "." + quoteIdent(entry.Table)
+	switch {
+	case before == nil:
+		names := make([]string, len(after))
+		values := make([]string, len(after))
+		for i, v := range after {
+			names[i] = quoteIdent(info.columnName(i))
+			values[i] = sqlLiteral(v)
+		}
+		ev.SQL = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", table, strings.Join(names, ", "), strings.Join(values, ", "))
+	case after == nil:
+		ev.SQL = fmt.Sprintf("DELETE FROM %s WHERE %s;", table, whereClause(info, before))
+	default:
+		var set []string
+		for i, col := range ev.Columns {
+			if col.Changed() && i < len(after) {
+				set = append(set, quoteIdent(col.Name)+" = "+sqlLiteral(after[i]))
+			}
+		}
+		if len(set) == 0 {
+			// ไม่มีคอลัมน์ที่ค่าเปลี่ยน (เช่น UPDATE ค่าเดิม) ใช้ค่าทั้งแถว
+			for i, v := range after {
+				set = append(set, quoteIdent(info.columnName(i))+" = "+sqlLiteral(v))
+			}
+		}
+		ev.SQL = fmt.Sprintf("UPDATE %s SET %s WHERE %s;", table, strings.Join(set, ", "), whereClause(info, before))
+	}
+	return ev
+}
+
+// whereClause เงื่อนไขระบุแถวจาก primary key (ถ้าไม่มี primary key ใช้ทุกคอลัมน์)
+func whereClause(info tableInfo, row []interface{}) string {
+	var conds []string
+	for _, name := range info.primaryKey {
+		if i := indexOf(info.columns, name); i >= 0 && i < len(row) {
+			conds = append(conds, condition(name, row[i]))
+		}
+	}
+	if len(conds) == 0 {
+		for i, v := range row {
+			conds = append(conds, condition(info.columnName(i), v))
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func condition(name string, v interface{}) string {
+	if v == nil {
+		return quoteIdent(name) + " IS NULL"
+	}
+	return quoteIdent(name) + " = " + sqlLiteral(v)
+}
+
+// primaryKeyJSON สร้าง JSON ของค่า primary key ของแถว
+func primaryKeyJSON(info tableInfo, row []interface{}) string {
+	primaryKeyMap := make(map[string]interface{})
+	for i, key := range info.primaryKey {
+		// ไม่ทราบชื่อคอลัมน์ ถือว่า primary key เป็นคอลัมน์แรกๆ ตามลำดับ
+		idx := i
+		if len(info.columns) > 0 {
+			idx = indexOf(info.columns, key)
+		}
+		if idx >= 0 && idx < len(row) {
+			primaryKeyMap[key] = jsonValue(row[idx])
+		}
+	}
+	jsonData, _ := json.Marshal(primaryKeyMap)
+	return string(jsonData)
+}
+
+func indexOf(list []string, name string) int {
+	for i, v := range list {
+		if strings.EqualFold(v, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// jsonValue แปลง []byte เป็นข้อความ ไม่เช่นนั้น json จะเข้ารหัสเป็น base64
+func jsonValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+// displayValue ค่าของคอลัมน์สำหรับแสดงผล (nil คือ NULL)
+func displayValue(v interface{}) *string {
+	if v == nil {
+		return nil
+	}
+	var text string
+	switch v := v.(type) {
+	case []byte:
+		text = string(v)
+	case time.Time:
+		text = v.Format("2006-01-02 15:04:05.999999")
+	default:
+		text = fmt.Sprint(v)
+	}
+	return &text
+}
+
+var sqlStringEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`)
+
+// sqlLiteral ค่าในรูปแบบ literal ของ MySQL
+func sqlLiteral(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(v)
+	default:
+		return "'" + sqlStringEscaper.Replace(*displayValue(v)) + "'"
+	}
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// getColumns ดึงชื่อคอลัมน์ของตารางตามลำดับจาก INFORMATION_SCHEMA
+func getColumns(db *sql.DB, dbName, tableName string) ([]string, error) {
+	rows, err := db.Query("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		columns = append(columns, columnName)
+	}
+	return columns, rows.Err()
+}
+
+// ดึง Primary Key พร้อมค่าจากตาราง
+func getPrimaryKey(db *sql.DB, dbName, tableName string) ([]string, error) {
+	rows, err := db.Query("SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? AND COLUMN_KEY = 'PRI' ORDER BY ORDINAL_POSITION", dbName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var primaryKeys []string
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return nil, err
+		}
+		primaryKeys = append(primaryKeys, columnName)
+	}
+
+	return primaryKeys, nil
+}
@@ -0,0 +1,392 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	config "hissync-10/functions"
+	"hissync-10/logging"
+)
+
+// รูปแบบเวลาที่ขึ้นต้นแต่ละบรรทัดของ PostgreSQL log (log_line_prefix = '%m ')
+const postgresLogTimeFormat = "2006-01-02 15:04:05.000 -07"
+
+// PostgresAuditPrefix ส่วนต่อจาก '%m ' ใน log_line_prefix ที่ทำให้แต่ละเหตุการณ์มี pid ผู้ใช้ ฐานข้อมูล โปรแกรม และเครื่อง
+// (log_line_prefix = '%m [%p] user=%u,db=%d,app=%a,client=%h ') ไม่ตั้งก็อ่านได้แต่ไม่มีข้อมูลผู้ทำรายการ
+const PostgresAuditPrefix = "[%p] user=%u,db=%d,app=%a,client=%h "
+
+// ระยะเวลาระหว่างการอ่านไฟล์ Log แต่ละรอบ
+const postgresPollInterval = 10 * time.Second
+
+// PostgresSource อ่านคำสั่ง INSERT, UPDATE, DELETE จากไฟล์ Log ของ PostgreSQL เป็นรอบ
+type PostgresSource struct {
+	cfg    *config.Profile
+	tables []config.TableEntry
+	paused atomic.Bool
+	poll   chan struct{}
+
+	// ไฟล์ Log และขนาดที่อ่านไปแล้วในรอบล่าสุด ใช้คำนวณความล่าช้า
+	mu         sync.Mutex
+	readFile   string
+	readOffset int64
+}
+
+// NewPostgresSource สร้างแหล่งข้อมูลจากไฟล์ Log ของ PostgreSQL
+func NewPostgresSource(cfg *config.Profile, tables *config.TableConfig) *PostgresSource {
+	return &PostgresSource{
+		cfg:    cfg,
+		tables: tables.ForDatabase(cfg.DBName),
+		poll:   make(chan struct{}, 1),
+	}
+}
+
+// PollNow สั่งให้อ่านไฟล์ Log ทันที
+func (s *PostgresSource) PollNow() {
+	select {
+	case s.poll <- struct{}{}:
+	default:
+	}
+}
+
+// SetPaused หยุดหรือกลับมาอ่านไฟล์ Log อัตโนมัติ
+func (s *PostgresSource) SetPaused(paused bool) {
+	s.paused.Store(paused)
+}
+
+// Run อ่านไฟล์ Log ทุก 10 วินาทีจนกว่า ctx จะถูกยกเลิก
+func (s *PostgresSource) Run(ctx context.Context, emit func(Event)) error {
+	ticker := time.NewTicker(postgresPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if s.paused.Load() {
+				continue
+			}
+			s.loadLogs(emit)
+		case <-s.poll:
+			s.loadLogs(emit)
+		}
+	}
+}
+
+// BytesBehind จำนวนไบต์ของไฟล์ Log ที่เขียนเพิ่มหลังจากการอ่านรอบล่าสุด
+func (s *PostgresSource) BytesBehind() int64 {
+	s.mu.Lock()
+	readFile, readOffset := s.readFile, s.readOffset
+	s.mu.Unlock()
+	if readFile == "" {
+		return -1
+	}
+
+	latest, err := getLatestPostgresLogFile(s.cfg.LogFilePath)
+	if err != nil {
+		return -1
+	}
+	var behind int64
+	if info, err := os.Stat(readFile); err == nil && info.Size() > readOffset {
+		behind += info.Size() - readOffset
+	}
+	if latest != readFile {
+		if info, err := os.Stat(latest); err == nil {
+			behind += info.Size()
+		}
+	}
+	return behind
+}
+
+func (s *PostgresSource) loadLogs(emit func(Event)) {
+	startTime := time.Now()
+	state, _ := LoadState(s.cfg.StateFile)
+	logFilePath, err := getLatestPostgresLogFile(s.cfg.LogFilePath)
+	if err != nil {
+		emit(Event{Source: config.DBTypePostgreSQL, Time: startTime, Err: fmt.Errorf("ไม่สามารถค้นหา Log File ล่าสุดได้: %v", err)})
+		return
+	}
+	// ขนาดก่อนอ่าน ส่วนที่เขียนเพิ่มระหว่างอ่านจะนับเป็นส่วนที่ยังไม่ได้อ่าน
+	var size int64 = -1
+	if info, err := os.Stat(logFilePath); err == nil {
+		size = info.Size()
+	}
+
+	events, lastDateTime, err := readPostgresLogFile(logFilePath, state.LastLogDatetime, s.tables)
+	if err != nil {
+		emit(Event{Source: config.DBTypePostgreSQL, Time: startTime, Err: fmt.Errorf("ไม่สามารถโหลด Log File ได้: %v", err)})
+		return
+	}
+	s.markRead(logFilePath, size)
+	if len(events) == 0 {
+		emit(Event{Source: config.DBTypePostgreSQL, Time: startTime, Notice: "ไม่มี Log ใหม่ ใช้ข้อมูลจาก state.json เดิม"})
+		return
+	}
+
+	emit(Event{Source: config.DBTypePostgreSQL, Time: startTime, Notice: "เริ่มต้นอ่าน Log"})
+	for _, ev := range events {
+		emit(ev)
+	}
+	SaveState(s.cfg.StateFile, State{LastLogDatetime: lastDateTime, LastLogFile: filepath.Base(logFilePath)})
+}
+
+// markRead บันทึกว่าอ่านไฟล์ Log ถึงขนาด size แล้ว
+func (s *PostgresSource) markRead(filePath string, size int64) {
+	if size < 0 {
+		return
+	}
+	s.mu.Lock()
+	s.readFile, s.readOffset = filePath, size
+	s.mu.Unlock()
+}
+
+// readPostgresLogFile อ่าน Log File โดยกรองเฉพาะคำสั่ง INSERT, UPDATE, DELETE ใน Table ที่สนใจ
+func readPostgresLogFile(filePath, lastDateTime string, tableConfigs []config.TableEntry) ([]Event, string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	var events []Event
+	var lastReadTime string
+
+	scanner := bufio.NewScanner(file)
+	// ตำแหน่งไบต์ของบรรทัดถัดไปในไฟล์
+	var offset int64
+
+	for scanner.Scan() {
+		lineOffset := offset
+		offset += int64(len(scanner.Bytes())) + 1
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) <= 28 {
+			continue
+		}
+		logTime := strings.TrimSpace(line[:27])
+		logMessage, session := parsePostgresPrefix(strings.TrimSpace(line[28:]))
+
+		parsedTime, err := time.Parse(postgresLogTimeFormat, logTime)
+		if err != nil {
+			logging.For(logging.ComponentCapture).Debug("ข้ามบรรทัดที่แปลงเวลาไม่ได้",
+				"source", "PostgreSQL", "file", filePath, "time", logTime)
+			continue
+		}
+		if lastDateTime != "" && !parsedTime.After(parseDateTime(lastDateTime)) {
+			continue
+		}
+
+		for _, tc := range tableConfigs {
+			queryType := postgresQueryType(logMessage, tc.Table)
+			if queryType == "" || !tc.AllowsOperation(queryType) {
+				continue
+			}
+
+			ev := Event{
+				Source:     config.DBTypePostgreSQL,
+				Position:   logTime,
+				Time:       parsedTime,
+				Database:   tc.Database,
+				Table:      tc.Table,
+				Operation:  queryType,
+				PrimaryKey: extractPostgresKeys(logMessage, queryType, tc),
+				SQL:        logMessage,
+				LogFile:    filePath,
+				Offset:     lineOffset,
+				Columns:    postgresColumns(logMessage, queryType),
+			}
+			session.apply(&ev)
+			events = append(events, ev)
+			lastReadTime = logTime
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+
+	return events, lastReadTime, nil
+}
+
+// parsePostgresPrefix แยกส่วน PostgresAuditPrefix (ถ้ามี) ออกจากข้อความต่อจากเวลา
+// คืนข้อความที่เหลือ (เช่น "LOG:  statement: ...") และข้อมูลผู้ทำรายการ
+func parsePostgresPrefix(rest string) (string, sessionInfo) {
+	var s sessionInfo
+	if strings.HasPrefix(rest, "[") {
+		if end := strings.Index(rest, "] "); end > 0 {
+			s.ID, rest = rest[1:end], rest[end+2:]
+		}
+	}
+	if !strings.HasPrefix(rest, "user=") {
+		return rest, s
+	}
+	// ชื่อโปรแกรม (%a) อาจมีช่องว่างหรือจุลภาค หาขอบเขตจาก ",client=" ซึ่งตามด้วยเครื่อง (%h) ที่ไม่มีช่องว่าง
+	clientAt := strings.Index(rest, ",client=")
+	if clientAt < 0 {
+		return rest, s
+	}
+	hostStart := clientAt + len(",client=")
+	hostEnd := strings.IndexByte(rest[hostStart:], ' ')
+	if hostEnd < 0 {
+		return rest, s
+	}
+	user, fields, _ := strings.Cut(rest[len("user="):clientAt], ",db=")
+	_, app, _ := strings.Cut(fields, ",app=")
+	s.User, s.App, s.Host = user, app, rest[hostStart:hostStart+hostEnd]
+	return strings.TrimSpace(rest[hostStart+hostEnd:]), s
+}
+
+// postgresQueryType ตรวจสอบประเภทคิวรี่ของข้อความ Log สำหรับตารางที่ระบุ
+func postgresQueryType(logMessage, tableName string) string {
+	switch {
+	case strings.Contains(logMessage, fmt.Sprintf(`INSERT INTO "public"."%s"`, tableName)) ||
+		strings.Contains(logMessage, fmt.Sprintf(`INSERT INTO "%s"`, tableName)):
+		return "INSERT"
+	case strings.Contains(logMessage, fmt.Sprintf(`UPDATE "public"."%s"`, tableName)) ||
+		strings.Contains(logMessage, fmt.Sprintf(`UPDATE "%s"`, tableName)):
+		return "UPDATE"
+	case strings.Contains(logMessage, fmt.Sprintf(`DELETE FROM "public"."%s"`, tableName)) ||
+		strings.Contains(logMessage, fmt.Sprintf(`DELETE FROM "%s"`, tableName)):
+		return "DELETE"
+	}
+	return ""
+}
+
+// extractPostgresKeys สกัดค่า keys (INSERT) หรือ primary_key (UPDATE/DELETE) จากข้อความ Log
+func extractPostgresKeys(logMessage, queryType string, tc config.TableEntry) string {
+	extractedData := ""
+	if queryType == "INSERT" {
+		// สกัดข้อมูลจาก VALUES ใน INSERT
+		valuesIdx := strings.Index(logMessage, "VALUES")
+		if valuesIdx != -1 {
+			valuesPart := logMessage[valuesIdx+6:]         // ข้าม "VALUES"
+			valuesPart = strings.Trim(valuesPart, "() \n") // ลบวงเล็บและช่องว่าง
+			valuePairs := strings.Split(valuesPart, ",")
+
+			// สร้าง mapping ของ column และ value จาก INSERT
+			columns := []string{}
+			columnIdx := strings.Index(logMessage, "(")
+			if columnIdx != -1 {
+				columnPart := logMessage[columnIdx+1 : strings.Index(logMessage, ")")]
+				columns = strings.Split(strings.TrimSpace(columnPart), ",")
+			}
+
+			// สกัดข้อมูลจาก keys
+			for i, col := range columns {
+				col = strings.TrimSpace(strings.Trim(col, `"`))
+				for _, key := range tc.Keys {
+					if col == key && i < len(valuePairs) {
+						value := strings.TrimSpace(valuePairs[i])
+						value = strings.Trim(value, `'`) // ลบเครื่องหมายคำพูด
+						extractedData += fmt.Sprintf("%s: %s, ", key, value)
+					}
+				}
+			}
+		}
+	} else { // UPDATE หรือ DELETE
+		// สกัดข้อมูลจาก primary_key
+		for _, pk := range tc.PrimaryKey {
+			startIdx := strings.Index(logMessage, fmt.Sprintf(`"%s" =`, pk))
+			if startIdx != -1 {
+				valuePart := logMessage[startIdx+len(fmt.Sprintf(`"%s" =`, pk)):]
+				value := strings.Split(valuePart, " ")[1]
+				extractedData += fmt.Sprintf("%s: %s, ", pk, strings.TrimSpace(value))
+			}
+		}
+	}
+	return strings.TrimSuffix(extractedData, ", ")
+}
+
+// postgresColumns สกัดค่าคอลัมน์ใหม่จากคำสั่ง INSERT (รายการคอลัมน์และ VALUES) หรือ UPDATE (SET)
+// ไฟล์ Log ไม่มีค่าเดิมของแถว จึงมีเฉพาะค่าหลังการเปลี่ยนแปลง
+func postgresColumns(logMessage, queryType string) []ColumnValue {
+	var names, values []string
+	switch queryType {
+	case "INSERT":
+		valuesIdx := strings.Index(logMessage, "VALUES")
+		columnStart := strings.Index(logMessage, "(")
+		columnEnd := strings.Index(logMessage, ")")
+		if valuesIdx == -1 || columnStart == -1 || columnEnd < columnStart || columnEnd > valuesIdx {
+			return nil
+		}
+		names = strings.Split(logMessage[columnStart+1:columnEnd], ",")
+		values = strings.Split(strings.Trim(strings.TrimSpace(logMessage[valuesIdx+6:]), "();"), ",")
+	case "UPDATE":
+		setIdx := strings.Index(logMessage, " SET ")
+		if setIdx == -1 {
+			return nil
+		}
+		setPart := logMessage[setIdx+5:]
+		if whereIdx := strings.Index(setPart, " WHERE "); whereIdx != -1 {
+			setPart = setPart[:whereIdx]
+		}
+		for _, pair := range strings.Split(setPart, ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			names = append(names, name)
+			values = append(values, value)
+		}
+	default:
+		return nil
+	}
+
+	columns := make([]ColumnValue, 0, len(names))
+	for i, name := range names {
+		col := ColumnValue{Name: strings.Trim(strings.TrimSpace(name), `"`)}
+		if i < len(values) {
+			if value := strings.TrimSpace(values[i]); !strings.EqualFold(value, "NULL") {
+				value = strings.Trim(value, `'`)
+				col.After = &value
+			}
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// parseDateTime แปลง String เป็น Time
+func parseDateTime(dateTimeStr string) time.Time {
+	t, _ := time.Parse(postgresLogTimeFormat, dateTimeStr)
+	return t
+}
+
+// getLatestPostgresLogFile ค้นหาไฟล์ Log ล่าสุดใน Directory
+func getLatestPostgresLogFile(logDirectory string) (string, error) {
+	var logFiles []string
+
+	err := filepath.Walk(logDirectory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Ext(path) == ".log" {
+			logFiles = append(logFiles, path)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(logFiles) == 0 {
+		return "", fmt.Errorf("ไม่พบไฟล์ Log ในโฟลเดอร์ %s", logDirectory)
+	}
+
+	sort.Slice(logFiles, func(i, j int) bool {
+		iInfo, _ := os.Stat(logFiles[i])
+		jInfo, _ := os.Stat(logFiles[j])
+		return iInfo.ModTime().After(jInfo.ModTime())
+	})
+
+	return logFiles[0], nil
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	config "hissync-10/functions"
+)
+
+func TestParsePostgresPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		rest    string
+		message string
+		session sessionInfo
+	}{
+		{
+			name:    "no audit prefix",
+			rest:    `LOG:  statement: DELETE FROM "person" WHERE "pid" = 1`,
+			message: `LOG:  statement: DELETE FROM "person" WHERE "pid" = 1`,
+		},
+		{
+			name:    "pid only",
+			rest:    `[4242] LOG:  statement: SELECT 1`,
+			message: `LOG:  statement: SELECT 1`,
+			session: sessionInfo{ID: "4242"},
+		},
+		{
+			name:    "full audit prefix",
+			rest:    `[4242] user=hosxp,db=hosxp,app=HOSxP,client=10.0.0.5 LOG:  statement: SELECT 1`,
+			message: `LOG:  statement: SELECT 1`,
+			session: sessionInfo{ID: "4242", User: "hosxp", App: "HOSxP", Host: "10.0.0.5"},
+		},
+		{
+			name:    "application name with spaces and commas",
+			rest:    `[7] user=admin,db=hosxp,app=pgAdmin 4 - DB:hosxp, main,client=[local] LOG:  statement: SELECT 1`,
+			message: `LOG:  statement: SELECT 1`,
+			session: sessionInfo{ID: "7", User: "admin", App: "pgAdmin 4 - DB:hosxp, main", Host: "[local]"},
+		},
+		{
+			name:    "empty fields",
+			rest:    `[7] user=,db=,app=,client= LOG:  checkpoint starting`,
+			message: `LOG:  checkpoint starting`,
+			session: sessionInfo{ID: "7"},
+		},
+		{
+			name:    "no client field",
+			rest:    `[7] user=admin,db=hosxp LOG:  statement: SELECT 1`,
+			message: `user=admin,db=hosxp LOG:  statement: SELECT 1`,
+			session: sessionInfo{ID: "7"},
+		},
+		{
+			name:    "unterminated pid",
+			rest:    `[7 LOG:  statement: SELECT 1`,
+			message: `[7 LOG:  statement: SELECT 1`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, session := parsePostgresPrefix(tt.rest)
+			if message != tt.message {
+				t.Errorf("message = %q ต้องการ %q", message, tt.message)
+			}
+			if session != tt.session {
+				t.Errorf("session = %+v ต้องการ %+v", session, tt.session)
+			}
+		})
+	}
+}
+
+func TestReadPostgresLogFile(t *testing.T) {
+	lines := []string{
+		`2024-03-01 08:00:00.000 +07 [11] user=hosxp,db=hosxp,app=HOSxP,client=10.0.0.5 LOG:  statement: INSERT INTO "person" ("pid","fname") VALUES ('1','a')`,
+		`2024-03-01 08:00:01.000 +07 LOG:  statement: UPDATE "public"."person" SET "fname" = 'b' WHERE "pid" = 1`,
+		`2024-03-01 08:00:02.000 +07 [12] user=hosxp,db=hosxp,app=psql,client=[local] LOG:  statement: DELETE FROM "ovst" WHERE "vn" = 9`,
+		`not a log line but long enough to be considered`,
+		`2024-03-01 08:00:03.000 +07 [13] user=hosxp,db=hosxp,app=psql,client=[local] LOG:  statement: DELETE FROM "person" WHERE "pid" = 1`,
+	}
+	path := filepath.Join(t.TempDir(), "postgresql.log")
+	data := ""
+	for _, line := range lines {
+		data += line + "\n"
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tables := []config.TableEntry{{Database: "hosxp", Table: "person", Keys: []string{"pid"}, PrimaryKey: []string{"pid"},
+		Options: &config.TableOptions{Operations: []string{"INSERT", "UPDATE"}}}}
+
+	tests := []struct {
+		name  string
+		after string
+		want  []Event
+	}{
+		{
+			name: "from start",
+			want: []Event{
+				{Operation: "INSERT", Position: "2024-03-01 08:00:00.000 +07", PrimaryKey: "pid: 1",
+					SessionID: "11", DBUser: "hosxp", ClientApp: "HOSxP", ClientHost: "10.0.0.5"},
+				{Operation: "UPDATE", Position: "2024-03-01 08:00:01.000 +07", PrimaryKey: "pid: 1"},
+			},
+		},
+		{
+			name:  "after last read time",
+			after: "2024-03-01 08:00:00.000 +07",
+			want:  []Event{{Operation: "UPDATE", Position: "2024-03-01 08:00:01.000 +07", PrimaryKey: "pid: 1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, last, err := readPostgresLogFile(path, tt.after, tables)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []Event
+			for _, ev := range events {
+				if ev.Database != "hosxp" || ev.Table != "person" || ev.LogFile != path {
+					t.Errorf("event = %+v", ev)
+				}
+				got = append(got, Event{Operation: ev.Operation, Position: ev.Position, PrimaryKey: ev.PrimaryKey,
+					SessionID: ev.SessionID, DBUser: ev.DBUser, ClientApp: ev.ClientApp, ClientHost: ev.ClientHost})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("events = %+v\nต้องการ %+v", got, tt.want)
+			}
+			if want := tt.want[len(tt.want)-1].Position; last != want {
+				t.Fatalf("last = %q ต้องการ %q", last, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package capture
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"time"
+)
+
+// ค้นผู้ใช้จาก thread id เฉพาะเหตุการณ์ที่เพิ่งเกิด
+// เหตุการณ์เก่ากว่านี้ session อาจปิดไปแล้วและ thread id อาจถูกใช้ซ้ำโดยผู้ใช้อื่น
+const sessionLookupWindow = time.Minute
+
+// sessionInfo ข้อมูลผู้ทำรายการของเหตุการณ์
+type sessionInfo struct {
+	ID   string
+	User string
+	App  string
+	Host string
+}
+
+// apply เติมข้อมูลผู้ทำรายการลงในเหตุการณ์
+func (s sessionInfo) apply(ev *Event) {
+	ev.SessionID, ev.DBUser, ev.ClientApp, ev.ClientHost = s.ID, s.User, s.App, s.Host
+}
+
+// mysqlSession ค้นผู้ใช้ เครื่อง และชื่อโปรแกรม (program_name ที่ไดรเวอร์ส่งมา) ของ thread ที่ยังเชื่อมต่ออยู่
+// ต้องมีสิทธิ์ PROCESS จึงเห็น session ของผู้ใช้อื่น ค่าที่หาไม่ได้จะว่าง
+func mysqlSession(ctx context.Context, db *sql.DB, thread uint32) sessionInfo {
+	var s sessionInfo
+	var host string
+	err := db.QueryRowContext(ctx, "SELECT USER, HOST FROM information_schema.PROCESSLIST WHERE ID = ?", thread).Scan(&s.User, &host)
+	if err != nil {
+		return s
+	}
+	s.Host = host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		s.Host = h
+	}
+	// performance_schema อาจปิดอยู่ (MariaDB ค่าเริ่มต้น) ไม่ถือเป็นข้อผิดพลาด
+	db.QueryRowContext(ctx, "SELECT ATTR_VALUE FROM performance_schema.session_connect_attrs WHERE PROCESSLIST_ID = ? AND ATTR_NAME = 'program_name'", thread).Scan(&s.App)
+	return s
+}
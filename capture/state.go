@@ -0,0 +1,45 @@
+package capture
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// CheckpointTimeFormat รูปแบบเวลาใน last_log_datetime
+const CheckpointTimeFormat = postgresLogTimeFormat
+
+// State โครงสร้างสำหรับ state.json เก็บตำแหน่งล่าสุดที่อ่านถึง
+type State struct {
+	LastBinlogPosition string `json:"last_binlog_position,omitempty"`
+	LastLogDatetime    string `json:"last_log_datetime"`
+	LastLogFile        string `json:"last_log_file"`
+	// LastGTID ชุด GTID ของ MariaDB (domain-server-sequence) ที่อ่านครบทั้งธุรกรรมแล้ว
+	// เมื่อมีค่า MariaDB จะอ่านต่อจาก GTID แทนไฟล์และตำแหน่ง
+	LastGTID string `json:"last_gtid,omitempty"`
+	// LastSent ไฟล์:ตำแหน่ง ของแถวล่าสุดที่ส่งแล้วในธุรกรรมที่ยังอ่านไม่ครบ ณ LastBinlogPosition
+	// ธุรกรรมนั้นถูกอ่านซ้ำตั้งแต่ต้นโดยไม่ส่งแถวจนถึงตำแหน่งนี้อีก
+	LastSent string `json:"last_sent,omitempty"`
+}
+
+// LoadState โหลดสถานะจากไฟล์ คืนค่าว่างถ้ายังไม่มีไฟล์
+func LoadState(stateFile string) (State, error) {
+	var state State
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// SaveState บันทึกสถานะลงไฟล์
+func SaveState(stateFile string, state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0644)
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+// runCheckpoint hissync checkpoint show|set
+func runCheckpoint(args []string) error {
+	if len(args) == 0 {
+		return errors.New("วิธีใช้: hissync checkpoint show [-profile ชื่อ] | hissync checkpoint set -profile ชื่อ [-file ไฟล์] [-pos ตำแหน่ง] [-gtid GTID] [-datetime เวลา]")
+	}
+	switch args[0] {
+	case "show":
+		return runCheckpointShow(args[1:])
+	case "set":
+		return runCheckpointSet(args[1:])
+	default:
+		return fmt.Errorf("ไม่รู้จักคำสั่ง checkpoint %s (ใช้ได้: show, set)", args[0])
+	}
+}
+
+func runCheckpointShow(args []string) error {
+	fs := flag.NewFlagSet("checkpoint show", flag.ContinueOnError)
+	profile := fs.String("profile", "", "แสดงเฉพาะโปรไฟล์นี้")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles, err := selectProfiles(cfg, *profile)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range profiles {
+		state, err := capture.LoadState(p.StateFile)
+		if err != nil {
+			return fmt.Errorf("ไม่สามารถอ่าน %s: %v", p.StateFile, err)
+		}
+		fmt.Printf("%s (%s)\n", p.Name, p.StateFile)
+		fmt.Printf("  last_log_file:        %s\n", state.LastLogFile)
+		if p.Engine == config.EngineBinlog {
+			fmt.Printf("  last_binlog_position: %s\n", state.LastBinlogPosition)
+			if state.LastGTID != "" {
+				fmt.Printf("  last_gtid:            %s\n", state.LastGTID)
+			}
+			if state.LastSent != "" {
+				fmt.Printf("  last_sent:            %s\n", state.LastSent)
+			}
+		}
+		fmt.Printf("  last_log_datetime:    %s\n", state.LastLogDatetime)
+	}
+	return nil
+}
+
+func runCheckpointSet(args []string) error {
+	fs := flag.NewFlagSet("checkpoint set", flag.ContinueOnError)
+	profile := fs.String("profile", "", "โปรไฟล์ที่ต้องการกำหนดตำแหน่ง (จำเป็น)")
+	file := fs.String("file", "", "ไฟล์ binlog หรือไฟล์ Log")
+	pos := fs.String("pos", "", "ตำแหน่งใน binlog (MySQL)")
+	gtid := fs.String("gtid", "", "ตำแหน่ง GTID ของ MariaDB เช่น 0-1-1234 (หลาย domain คั่นด้วยจุลภาค)")
+	datetime := fs.String("datetime", "", "เวลาของบรรทัดล่าสุดที่อ่านแล้ว รูปแบบ \""+capture.CheckpointTimeFormat+"\" (PostgreSQL)")
+	force := fs.Bool("force", false, "กำหนดตำแหน่งแม้ HISSYNC กำลังทำงานอยู่")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *profile == "" {
+		return errors.New("ต้องระบุ -profile")
+	}
+	if *file == "" && *pos == "" && *gtid == "" && *datetime == "" {
+		return errors.New("ต้องระบุอย่างน้อยหนึ่งค่าจาก -file, -pos, -gtid, -datetime")
+	}
+	if *gtid != "" {
+		if _, err := mysql.ParseMariadbGTIDSet(*gtid); err != nil {
+			return fmt.Errorf("-gtid %q ไม่ถูกต้อง: %v", *gtid, err)
+		}
+	}
+	if *pos != "" {
+		if _, err := strconv.ParseUint(*pos, 10, 32); err != nil {
+			return fmt.Errorf("-pos %q ไม่ถูกต้อง", *pos)
+		}
+	}
+	if *datetime != "" {
+		if _, err := time.Parse(capture.CheckpointTimeFormat, *datetime); err != nil {
+			return fmt.Errorf("-datetime %q ไม่ตรงรูปแบบ %s", *datetime, capture.CheckpointTimeFormat)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p := cfg.Profile(*profile)
+	if p == nil {
+		return fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", *profile)
+	}
+
+	// แหล่งข้อมูลที่กำลังทำงานจะบันทึกตำแหน่งของตัวเองทับ ต้องหยุดโปรแกรมก่อน
+	if !*force {
+		st, err := store.Open(store.DataFilePath())
+		if errors.Is(err, store.ErrLocked) {
+			return errors.New("HISSYNC กำลังทำงานอยู่ หยุดโปรแกรมก่อนกำหนดตำแหน่ง (หรือใช้ -force)")
+		}
+		if err != nil {
+			return err
+		}
+		st.Close()
+	}
+
+	state, err := capture.LoadState(p.StateFile)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่าน %s: %v", p.StateFile, err)
+	}
+	if *file != "" {
+		state.LastLogFile = *file
+	}
+	if *pos != "" {
+		state.LastBinlogPosition = *pos
+	}
+	if *file != "" || *pos != "" {
+		// MariaDB อ่านต่อจาก GTID ก่อนไฟล์และตำแหน่ง ล้างออกเพื่อให้ตำแหน่งใหม่มีผล
+		state.LastGTID = ""
+	}
+	if *gtid != "" {
+		state.LastGTID = *gtid
+	}
+	if *file != "" || *pos != "" || *gtid != "" {
+		// แถวที่ส่งแล้วของธุรกรรมที่อ่านค้างไว้ไม่เกี่ยวกับตำแหน่งใหม่
+		state.LastSent = ""
+	}
+	if *datetime != "" {
+		state.LastLogDatetime = *datetime
+	}
+	if err := capture.SaveState(p.StateFile, state); err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึก %s: %v", p.StateFile, err)
+	}
+	fmt.Printf("กำหนดตำแหน่งของโปรไฟล์ %s เรียบร้อย\n", p.Name)
+	return nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	config "hissync-10/functions"
+)
+
+// command คำสั่งย่อยของ hissync
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "เริ่มอ่านข้อมูลจากทุกโปรไฟล์และส่งไปยังปลายทางโดยไม่เปิดหน้าจอ (ใช้กับ systemd)", runDaemon},
+		{"status", "แสดงโปรไฟล์ ตำแหน่งล่าสุดที่อ่านถึง และจำนวนรายการค้างส่ง", runStatus},
+		{"checkpoint", "checkpoint show | checkpoint set -profile <ชื่อ> ... ดูหรือกำหนดตำแหน่งที่อ่านถึง", runCheckpoint},
+		{"test-connection", "ทดสอบการเชื่อมต่อฐานข้อมูลของทุกโปรไฟล์ (หรือ -profile)", runTestConnection},
+		{"doctor", "ตรวจการตั้งค่าเซิร์ฟเวอร์ต้นทาง (binlog, สิทธิ์, PostgreSQL log) พร้อมวิธีแก้ไข", runDoctor},
+		{"import-binlog", "นำเข้าไฟล์ binlog จากดิสก์หรือโฟลเดอร์เข้าคิวส่งไปยังปลายทาง (ไม่ต้องใช้สิทธิ์ replication)", runImportBinlog},
+		{"replay", "นำเหตุการณ์ที่บันทึกไว้เข้าคิวส่งไปยังปลายทางอีกครั้ง", runReplay},
+		{"history", "history -person <pid หรือเลขประจำตัวประชาชน> | history -table db.t -pk <json> ประวัติการเปลี่ยนแปลงตามลำดับเวลา", runHistory},
+		{"quarantine", "quarantine list | show <รหัส> | approve <รหัส> | reject <รหัส> จัดการธุรกรรมที่ safeguard กักไว้", runQuarantine},
+		{"undo", "สร้างคำสั่ง SQL ย้อนกลับเหตุการณ์ตามเวลา ตาราง หรือธุรกรรม (-apply ใช้กับต้นทาง)", runUndo},
+		{"export", "ส่งออกเหตุการณ์ที่บันทึกไว้เป็น CSV, JSON Lines หรือ XLSX", runExport},
+		{"report", "สรุปการทำงานรายวัน (อ่านได้ ส่งสำเร็จ ส่งไม่สำเร็จ หยุดทำงาน) เป็น CSV หรือ XLSX", runReport},
+		{"rotate-key", "สร้างกุญแจเข้ารหัสใหม่และเข้ารหัสค่าลับใน config.json ใหม่", runRotateKeyCommand},
+	}
+}
+
+// IsCommand บอกว่า name เป็นคำสั่งย่อยของ hissync หรือไม่
+func IsCommand(name string) bool {
+	if name == "help" || name == "-h" || name == "--help" {
+		return true
+	}
+	for _, c := range commands {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Run เรียกคำสั่งย่อยตาม args (args[0] คือชื่อคำสั่ง) และคืน exit code
+func Run(args []string) int {
+	if len(args) == 0 || !IsCommand(args[0]) || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		if len(args) == 0 || !IsCommand(args[0]) {
+			return 2
+		}
+		return 0
+	}
+	for _, c := range commands {
+		if c.name != args[0] {
+			continue
+		}
+		if err := c.run(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			fmt.Fprintln(os.Stderr, config.Redact(err.Error()))
+			return 1
+		}
+		return 0
+	}
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "วิธีใช้: hissync <คำสั่ง> [ตัวเลือก]")
+	fmt.Fprintln(os.Stderr)
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "ใช้ hissync <คำสั่ง> -h เพื่อดูตัวเลือกของแต่ละคำสั่ง (โปรแกรมรุ่นมีหน้าจอ: ไม่ระบุคำสั่งเพื่อเปิดหน้าจอหลัก)")
+}
+
+// loadConfig ขอรหัสผ่านผู้ดูแลจาก terminal ถ้าจำเป็น แล้วโหลด config.json
+func loadConfig() (*config.Config, error) {
+	if config.KeyUsesPassphrase() && os.Getenv("HISSYNC_PASSPHRASE") == "" && term.IsTerminal(int(os.Stdin.Fd())) {
+		passphrase, err := readPassword("รหัสผ่านผู้ดูแล: ")
+		if err != nil {
+			return nil, err
+		}
+		config.SetPassphrase(passphrase)
+	}
+	return config.LoadConfig(config.ConfigFile)
+}
+
+// selectProfiles คืนโปรไฟล์ตามชื่อ หรือทุกโปรไฟล์ถ้าไม่ระบุชื่อ
+func selectProfiles(cfg *config.Config, name string) ([]config.Profile, error) {
+	if name == "" {
+		return cfg.Profiles, nil
+	}
+	p := cfg.Profile(name)
+	if p == nil {
+		return nil, fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", name)
+	}
+	return []config.Profile{*p}, nil
+}
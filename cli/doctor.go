@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"hissync-10/capture"
+)
+
+// runDoctor hissync doctor [-profile ชื่อ]: ตรวจการตั้งค่าเซิร์ฟเวอร์ต้นทางของแต่ละโปรไฟล์
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	profile := fs.String("profile", "", "ตรวจเฉพาะโปรไฟล์นี้")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles, err := selectProfiles(cfg, *profile)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i := range profiles {
+		p := &profiles[i]
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s, engine %s)\n", p.Name, p.DBType, p.Engine)
+		checks := capture.Diagnose(p)
+		for _, c := range checks {
+			fmt.Printf("  %s %s: %s\n", c.Symbol(), c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("      แก้ไข: %s\n", c.Fix)
+			}
+		}
+		if capture.Failed(checks) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("พบปัญหาที่ต้องแก้ไข %d จาก %d โปรไฟล์", failed, len(profiles))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"hissync-10/export"
+	"hissync-10/store"
+)
+
+// runExport hissync export: ส่งออกเหตุการณ์ที่บันทึกไว้ใน hissync.db เป็น CSV, JSON Lines หรือ XLSX
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "ไฟล์ปลายทาง (จำเป็น) รูปแบบตามนามสกุล .csv, .jsonl หรือ .xlsx")
+	format := fs.String("format", "", "รูปแบบไฟล์ (ค่าเริ่มต้น: ตามนามสกุลของ -o)")
+	var filter store.Filter
+	fs.StringVar(&filter.Profile, "profile", "", "เฉพาะเหตุการณ์ของโปรไฟล์นี้")
+	fs.StringVar(&filter.Table, "table", "", "เฉพาะตารางที่ชื่อมีคำนี้ (database.table)")
+	fs.StringVar(&filter.Operation, "operation", "", "เฉพาะ INSERT, UPDATE หรือ DELETE")
+	fs.StringVar(&filter.PrimaryKey, "pk", "", "เฉพาะ primary key ที่มีคำนี้")
+	fs.StringVar(&filter.Text, "text", "", "เฉพาะเหตุการณ์ที่มีคำนี้ในตาราง primary key, SQL หรือผู้ทำรายการ")
+	from := fs.String("from", "", "ตั้งแต่เวลา \""+replayTimeFormat+"\"")
+	to := fs.String("to", "", "ถึงเวลา \""+replayTimeFormat+"\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("ต้องระบุ -o")
+	}
+
+	var err error
+	if *format == "" {
+		if *format, err = export.FormatFromPath(*output); err != nil {
+			return err
+		}
+	}
+	if *from != "" {
+		if filter.From, err = time.ParseInLocation(replayTimeFormat, *from, time.Local); err != nil {
+			return fmt.Errorf("-from %q ไม่ตรงรูปแบบ %s", *from, replayTimeFormat)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = time.ParseInLocation(replayTimeFormat, *to, time.Local); err != nil {
+			return fmt.Errorf("-to %q ไม่ตรงรูปแบบ %s", *to, replayTimeFormat)
+		}
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ ส่งออกจากหน้าจอหลักแทน หรือหยุดโปรแกรมก่อน")
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถสร้าง %s: %v", *output, err)
+	}
+	count, err := export.Write(file, *format, st, filter)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	fmt.Printf("ส่งออก %d รายการไปยัง %s\n", count, *output)
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+// runHistory hissync history: แสดงประวัติการเปลี่ยนแปลงของบุคคลหรือของแถวตามลำดับเวลา
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	person := fs.String("person", "", "รหัสบุคคล (เช่น pid) หรือเลขประจำตัวประชาชน")
+	table := fs.String("table", "", "ตาราง database.table (ใช้คู่กับ -pk)")
+	pk := fs.String("pk", "", "primary key ของแถวแบบ JSON เช่น '{\"pid\":1}'")
+	profile := fs.String("profile", "", "เฉพาะโปรไฟล์นี้ (ใช้กับ -table)")
+	reindex := fs.Bool("reindex", false, "สร้างดัชนีประวัติใหม่จากเหตุการณ์ทั้งหมด (หลังแก้ไข person ใน tables.json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*reindex && *person == "" && (*table == "" || *pk == "") {
+		return errors.New("ต้องระบุ -person หรือ -table กับ -pk หรือ -reindex")
+	}
+
+	var key string
+	if *pk != "" {
+		var err error
+		if key, err = normalizeKey(*pk); err != nil {
+			return fmt.Errorf("-pk ไม่ใช่ JSON: %v", err)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles, err := selectProfiles(cfg, *profile)
+	if err != nil {
+		return err
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ ดูประวัติจากหน้าจอหลักแทน หรือหยุดโปรแกรมก่อน")
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	if *reindex {
+		for i := range cfg.Profiles {
+			p := &cfg.Profiles[i]
+			tables, err := config.LoadTableConfig(p.TableConfigFile)
+			if err != nil {
+				return fmt.Errorf("โปรไฟล์ %s: %v", p.Name, err)
+			}
+			st.SetTables(p.Name, tables)
+		}
+		count, err := st.Reindex()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("สร้างดัชนีประวัติใหม่จาก %d เหตุการณ์\n", count)
+		if *person == "" && *table == "" {
+			return nil
+		}
+	}
+
+	var records []store.Record
+	if *person != "" {
+		if records, err = st.PersonTimeline(*person); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range profiles {
+			rows, err := st.RowHistory(p.Name, *table, key)
+			if err != nil {
+				return err
+			}
+			records = append(records, rows...)
+		}
+		sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	}
+
+	if len(records) == 0 {
+		fmt.Println("ไม่พบประวัติการเปลี่ยนแปลง")
+		return nil
+	}
+	for _, rec := range records {
+		fmt.Printf("%s  %-12s %-6s %s %s", rec.Time.Local().Format(replayTimeFormat), rec.Profile, rec.Operation, rec.FullTableName(), rec.PrimaryKey)
+		if rec.DBUser != "" {
+			fmt.Printf("  โดย %s", rec.DBUser)
+		}
+		fmt.Println()
+		for _, c := range rec.Columns {
+			if c.Changed() {
+				fmt.Printf("    %s: %s → %s\n", c.Name, displayValue(c.Before), displayValue(c.After))
+			}
+		}
+	}
+	fmt.Printf("รวม %d รายการ\n", len(records))
+	return nil
+}
+
+// normalizeKey จัดรูปแบบ primary key ให้ตรงกับที่บันทึกในเหตุการณ์ (เรียงชื่อคอลัมน์ ไม่มีช่องว่าง)
+func normalizeKey(s string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// displayValue แสดงค่าของคอลัมน์ (NULL เมื่อไม่มีค่า)
+func displayValue(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	return *v
+}
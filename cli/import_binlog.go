@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/pipeline"
+	"hissync-10/store"
+)
+
+// runImportBinlog hissync import-binlog -profile ชื่อ <ไฟล์หรือโฟลเดอร์>...: อ่านไฟล์ binlog จากดิสก์
+// ใช้ตารางใน tables.json ของโปรไฟล์ บันทึกลง hissync.db และเข้าคิวของปลายทางเหมือนเหตุการณ์ที่อ่านจากเซิร์ฟเวอร์
+// รวมทั้งผ่านเกณฑ์ safeguard ของโปรไฟล์ ปลายทางจะได้รับเหตุการณ์เหล่านี้เมื่อเริ่ม hissync run หรือหน้าจอหลักครั้งถัดไป
+func runImportBinlog(args []string) error {
+	fs := flag.NewFlagSet("import-binlog", flag.ContinueOnError)
+	profile := fs.String("profile", "", "โปรไฟล์ MySQL ที่เป็นเจ้าของ binlog (ใช้ tables.json และการเชื่อมต่อของโปรไฟล์นี้)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *profile == "" || fs.NArg() == 0 {
+		return errors.New("วิธีใช้: hissync import-binlog -profile <ชื่อ> <ไฟล์หรือโฟลเดอร์ binlog>...")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p := cfg.Profile(*profile)
+	if p == nil {
+		return fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", *profile)
+	}
+	if p.DBType != config.DBTypeMySQL {
+		return fmt.Errorf("โปรไฟล์ %s ไม่ใช่ MySQL", p.Name)
+	}
+	tables, err := config.LoadTableConfig(p.TableConfigFile)
+	if err != nil {
+		return err
+	}
+	files, err := capture.BinlogFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return errors.New("ไม่พบไฟล์ binlog")
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ หยุดโปรแกรมก่อนนำเข้า binlog")
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	st.SetTables(p.Name, tables)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	sinks := cfg.SinksFor(p.Name)
+	sg := cfg.SafeguardFor(p.Name)
+	guard := pipeline.NewSafeguard(st)
+	saved, quarantined := 0, 0
+	err = capture.ImportBinlogFiles(ctx, p, tables, files, func(ev capture.Event) {
+		if ev.Notice != "" {
+			fmt.Fprintln(os.Stderr, ev.Notice)
+			return
+		}
+		ev.Profile = p.Name
+		if ev.Commit {
+			guard.Commit(ev.Profile, ev.Transaction)
+			return
+		}
+		var err error
+		if sg != nil {
+			// ไม่ได้อ่านจาก replication จึงอ่านจำนวนแถวของตารางที่ต้องการได้ทันที
+			guard.RefreshRows()
+			var result pipeline.HoldResult
+			result, err = guard.Hold(sg, p, ev, sinks)
+			if h := result.Quarantined; h != nil {
+				quarantined++
+				fmt.Fprintf(os.Stderr, "กักธุรกรรม #%d (%s) ไว้รออนุมัติ: %s\n", h.ID, h.Transaction, h.Reason)
+			}
+		} else {
+			_, err = st.Append(ev, sinks)
+		}
+		if err != nil {
+			cancel(fmt.Errorf("ไม่สามารถบันทึกเหตุการณ์ลง hissync.db: %v", err))
+			return
+		}
+		saved++
+	}, func(pr capture.ImportProgress) {
+		fmt.Fprintf(os.Stderr, "%5.1f%% เสร็จ %d/%d ไฟล์ กำลังอ่าน %s เหตุการณ์ %d\n",
+			pr.Percent(), pr.FilesDone, pr.FilesTotal, filepath.Base(pr.File), pr.Events)
+	})
+	// ธุรกรรมสุดท้ายที่อ่านครบแล้วเข้าคิวปลายทาง (ธุรกรรมที่ถูกกักยังรออนุมัติ)
+	guard.ReleaseIdle(0)
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		err = cause
+	} else if errors.Is(err, context.Canceled) {
+		err = errors.New("ยกเลิกการนำเข้า เหตุการณ์ที่นำเข้าแล้วยังอยู่ใน hissync.db")
+	}
+	fmt.Printf("นำเข้า %d เหตุการณ์จาก %d ไฟล์ เข้าคิวปลายทาง %d แห่ง\n", saved, len(files), len(sinks))
+	if quarantined > 0 {
+		fmt.Printf("กักธุรกรรมไว้รออนุมัติ %d รายการ ดูด้วย hissync quarantine list\n", quarantined)
+	}
+	return err
+}
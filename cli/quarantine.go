@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"hissync-10/store"
+)
+
+// จำนวนเหตุการณ์ที่ quarantine show แสดงโดยค่าเริ่มต้น
+const quarantineShowLimit = 50
+
+// runQuarantine hissync quarantine list|show|approve|reject: จัดการธุรกรรมที่ safeguard กักไว้
+// ใช้ได้เมื่อ HISSYNC ไม่ได้ทำงานอยู่ ธุรกรรมที่อนุมัติจะถูกส่งเมื่อเริ่มโปรแกรมครั้งถัดไป
+func runQuarantine(args []string) error {
+	if len(args) == 0 {
+		return errors.New("วิธีใช้: hissync quarantine list | show <รหัส> [-n จำนวน] | approve <รหัส> | reject <รหัส>")
+	}
+	switch args[0] {
+	case "list":
+		return runQuarantineList(args[1:])
+	case "show":
+		return runQuarantineShow(args[1:])
+	case "approve", "reject":
+		return runQuarantineDecide(args[0], args[1:])
+	default:
+		return fmt.Errorf("ไม่รู้จักคำสั่ง quarantine %s (ใช้ได้: list, show, approve, reject)", args[0])
+	}
+}
+
+func runQuarantineList(args []string) error {
+	fs := flag.NewFlagSet("quarantine list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	st, err := openQuarantineStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	holds, err := quarantinedHolds(st)
+	if err != nil {
+		return err
+	}
+	if len(holds) == 0 {
+		fmt.Println("ไม่มีธุรกรรมที่ถูกกักไว้")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tQUARANTINED\tPROFILE\tTRANSACTION\tROWS\tDELETES\tREASON")
+	for _, h := range holds {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\t%s\n", h.ID, h.QuarantinedAt.Local().Format(replayTimeFormat),
+			h.Profile, h.Transaction, h.Count, h.Deletes, h.Reason)
+	}
+	return w.Flush()
+}
+
+func runQuarantineShow(args []string) error {
+	fs := flag.NewFlagSet("quarantine show", flag.ContinueOnError)
+	limit := fs.Int("n", quarantineShowLimit, "จำนวนเหตุการณ์ที่แสดงสูงสุด")
+	id, args, err := quarantineID(args)
+	if err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	st, err := openQuarantineStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	h, err := findQuarantined(st, id)
+	if err != nil {
+		return err
+	}
+	records, err := st.HeldRecords(id, *limit)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ธุรกรรม #%d ของ %s (%s)\n", h.ID, h.Profile, h.Transaction)
+	fmt.Printf("  เหตุผล:   %s\n", h.Reason)
+	fmt.Printf("  จำนวน:   %d แถว (ลบ %d แถว)\n", h.Count, h.Deletes)
+	fmt.Printf("  ปลายทาง: %v\n", h.Sinks)
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQ\tTIME\tOPERATION\tTABLE\tPRIMARY KEY")
+	for _, rec := range records {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", rec.Seq, rec.Time.Local().Format(replayTimeFormat),
+			rec.Operation, rec.FullTableName(), rec.PrimaryKey)
+	}
+	w.Flush()
+	if h.Count > len(records) {
+		fmt.Printf("... และอีก %d เหตุการณ์ (ใช้ -n เพื่อแสดงเพิ่ม)\n", h.Count-len(records))
+	}
+	return nil
+}
+
+// runQuarantineDecide อนุมัติ (เข้าคิวปลายทาง) หรือปฏิเสธ (ไม่ส่ง) ธุรกรรมที่ถูกกัก
+func runQuarantineDecide(action string, args []string) error {
+	id, _, err := quarantineID(args)
+	if err != nil {
+		return err
+	}
+	st, err := openQuarantineStore()
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	h, err := findQuarantined(st, id)
+	if err != nil {
+		return err
+	}
+	if action == "approve" {
+		count, err := st.Release(id)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("อนุมัติธุรกรรม #%d ของ %s แล้ว %d เหตุการณ์เข้าคิวส่งเมื่อเริ่ม HISSYNC\n", id, h.Profile, count)
+		return nil
+	}
+	count, err := st.Reject(id)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ปฏิเสธธุรกรรม #%d ของ %s แล้ว %d เหตุการณ์จะไม่ถูกส่ง (ยังค้นหาได้ในประวัติเหตุการณ์)\n", id, h.Profile, count)
+	return nil
+}
+
+func openQuarantineStore() (*store.Store, error) {
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return nil, errors.New("HISSYNC กำลังทำงานอยู่ ใช้หน้า \"รออนุมัติ\" ในหน้าจอหลักแทน หรือหยุดโปรแกรมก่อน")
+	}
+	return st, err
+}
+
+// quarantineID อ่านรหัสธุรกรรมจากอาร์กิวเมนต์แรก คืนอาร์กิวเมนต์ที่เหลือ
+func quarantineID(args []string) (uint64, []string, error) {
+	if len(args) == 0 {
+		return 0, nil, errors.New("ต้องระบุรหัสธุรกรรม (ดูจาก hissync quarantine list)")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("รหัสธุรกรรม %q ไม่ถูกต้อง", args[0])
+	}
+	return id, args[1:], nil
+}
+
+func quarantinedHolds(st *store.Store) ([]store.Hold, error) {
+	holds, err := st.Holds()
+	if err != nil {
+		return nil, err
+	}
+	quarantined := holds[:0]
+	for _, h := range holds {
+		if h.Quarantined() {
+			quarantined = append(quarantined, h)
+		}
+	}
+	return quarantined, nil
+}
+
+func findQuarantined(st *store.Store, id uint64) (store.Hold, error) {
+	holds, err := quarantinedHolds(st)
+	if err != nil {
+		return store.Hold{}, err
+	}
+	for _, h := range holds {
+		if h.ID == id {
+			return h, nil
+		}
+	}
+	return store.Hold{}, fmt.Errorf("ไม่พบธุรกรรม #%d ที่ถูกกักไว้", id)
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"hissync-10/store"
+)
+
+// รูปแบบเวลาที่รับจากตัวเลือก -from และ -to
+const replayTimeFormat = "2006-01-02 15:04:05"
+
+// runReplay hissync replay: นำเหตุการณ์ที่บันทึกไว้ใน hissync.db เข้าคิวของปลายทางอีกครั้ง
+// ปลายทางจะได้รับเหตุการณ์เหล่านี้ซ้ำเมื่อเริ่ม hissync run หรือหน้าจอหลักครั้งถัดไป
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	profile := fs.String("profile", "", "เฉพาะเหตุการณ์ของโปรไฟล์นี้")
+	sinkName := fs.String("sink", "", "ส่งซ้ำเฉพาะปลายทางนี้ (ค่าเริ่มต้น: ทุกปลายทางที่รับโปรไฟล์นั้น)")
+	table := fs.String("table", "", "เฉพาะตารางนี้ (database.table)")
+	from := fs.String("from", "", "ตั้งแต่เวลา \""+replayTimeFormat+"\"")
+	to := fs.String("to", "", "ถึงเวลา \""+replayTimeFormat+"\"")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var fromTime, toTime time.Time
+	var err error
+	if *from != "" {
+		if fromTime, err = time.ParseInLocation(replayTimeFormat, *from, time.Local); err != nil {
+			return fmt.Errorf("-from %q ไม่ตรงรูปแบบ %s", *from, replayTimeFormat)
+		}
+	}
+	if *to != "" {
+		if toTime, err = time.ParseInLocation(replayTimeFormat, *to, time.Local); err != nil {
+			return fmt.Errorf("-to %q ไม่ตรงรูปแบบ %s", *to, replayTimeFormat)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if *profile != "" && cfg.Profile(*profile) == nil {
+		return fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", *profile)
+	}
+	if *sinkName != "" && cfg.Sink(*sinkName) == nil {
+		return fmt.Errorf("ไม่พบปลายทาง %s ใน config.json", *sinkName)
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ หยุดโปรแกรมก่อน replay")
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	// เหตุการณ์ของธุรกรรมที่ยังรออนุมัติหรือถูกปฏิเสธโดย safeguard ไม่ถูกส่งซ้ำ
+	withheld, err := st.Withheld()
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านเหตุการณ์ที่ถูกกักจาก hissync.db: %v", err)
+	}
+	skipped := 0
+	queued := make(map[string][]uint64)
+	err = st.Each(func(rec store.Record) error {
+		switch {
+		case *profile != "" && rec.Profile != *profile,
+			*table != "" && rec.FullTableName() != *table,
+			!fromTime.IsZero() && rec.Time.Before(fromTime),
+			!toTime.IsZero() && rec.Time.After(toTime):
+			return nil
+		case withheld[rec.Seq]:
+			skipped++
+			return nil
+		}
+		if *sinkName != "" {
+			queued[*sinkName] = append(queued[*sinkName], rec.Seq)
+			return nil
+		}
+		for _, name := range cfg.SinksFor(rec.Profile) {
+			queued[name] = append(queued[name], rec.Seq)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถอ่านเหตุการณ์จาก hissync.db: %v", err)
+	}
+
+	if skipped > 0 {
+		fmt.Printf("ข้าม %d เหตุการณ์ที่ยังรออนุมัติหรือถูกปฏิเสธ (hissync quarantine list)\n", skipped)
+	}
+	if len(queued) == 0 {
+		fmt.Println("ไม่พบเหตุการณ์ที่ตรงเงื่อนไข")
+		return nil
+	}
+	for name, seqs := range queued {
+		n, err := st.Enqueue(name, seqs)
+		if err != nil {
+			return fmt.Errorf("ไม่สามารถเข้าคิวของปลายทาง %s: %v", name, err)
+		}
+		fmt.Printf("เข้าคิวปลายทาง %s %d รายการ\n", name, len(seqs)-n)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"hissync-10/export"
+	"hissync-10/report"
+	"hissync-10/store"
+)
+
+// รูปแบบวันที่ของ -from และ -to ใน hissync report
+const reportDateFormat = "2006-01-02"
+
+// runReport hissync report: สรุปการทำงานรายวันจาก hissync.db แล้วบันทึกเป็น CSV หรือ XLSX
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	output := fs.String("o", "", "ไฟล์ปลายทาง (จำเป็น) รูปแบบตามนามสกุล .csv หรือ .xlsx")
+	today := time.Now().Format(reportDateFormat)
+	from := fs.String("from", time.Now().AddDate(0, 0, -6).Format(reportDateFormat), "ตั้งแต่วันที่ \""+reportDateFormat+"\"")
+	to := fs.String("to", today, "ถึงวันที่ \""+reportDateFormat+"\" (รวมวันนี้)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *output == "" {
+		return errors.New("ต้องระบุ -o")
+	}
+	format, err := export.FormatFromPath(*output)
+	if err != nil {
+		return err
+	}
+	fromDay, err := time.ParseInLocation(reportDateFormat, *from, time.Local)
+	if err != nil {
+		return fmt.Errorf("-from %q ไม่ตรงรูปแบบ %s", *from, reportDateFormat)
+	}
+	toDay, err := time.ParseInLocation(reportDateFormat, *to, time.Local)
+	if err != nil {
+		return fmt.Errorf("-to %q ไม่ตรงรูปแบบ %s", *to, reportDateFormat)
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ สร้างรายงานจากหน้าจอหลักแทน หรือหยุดโปรแกรมก่อน")
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	r, err := report.Build(st, fromDay, toDay)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถสร้าง %s: %v", *output, err)
+	}
+	err = report.Write(file, format, r)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*output)
+		return err
+	}
+	fmt.Printf("อ่านได้ %d ส่งสำเร็จ %d ส่งไม่สำเร็จ %d หยุดทำงาน %d ครั้ง บันทึกรายงานที่ %s\n",
+		r.Captured, r.Delivered, r.Failed, len(r.Downtime), *output)
+	return nil
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+
+	config "hissync-10/functions"
+)
+
+// runRotateKeyCommand hissync rotate-key [-passphrase]
+func runRotateKeyCommand(args []string) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	usePassphrase := fs.Bool("passphrase", false, "สร้างกุญแจใหม่จากรหัสผ่านผู้ดูแลแทนการเก็บกุญแจในไฟล์")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := rotateKey(*usePassphrase); err != nil {
+		return err
+	}
+	fmt.Println("เปลี่ยนกุญแจเข้ารหัสเรียบร้อย:", config.KeyFilePath())
+	return nil
+}
+
+// rotateKey เปลี่ยนกุญแจเข้ารหัสของ config.json
+func rotateKey(usePassphrase bool) error {
+	if config.KeyUsesPassphrase() && os.Getenv("HISSYNC_PASSPHRASE") == "" {
+		old, err := readPassword("รหัสผ่านผู้ดูแลปัจจุบัน: ")
+		if err != nil {
+			return err
+		}
+		config.SetPassphrase(old)
+	}
+
+	newPassphrase := ""
+	if usePassphrase {
+		newPassphrase = os.Getenv("HISSYNC_NEW_PASSPHRASE")
+		if newPassphrase == "" {
+			first, err := readPassword("รหัสผ่านผู้ดูแลใหม่: ")
+			if err != nil {
+				return err
+			}
+			second, err := readPassword("ยืนยันรหัสผ่านผู้ดูแลใหม่: ")
+			if err != nil {
+				return err
+			}
+			if first != second {
+				return errors.New("รหัสผ่านทั้งสองครั้งไม่ตรงกัน")
+			}
+			newPassphrase = first
+		}
+		if len(newPassphrase) < 8 {
+			return errors.New("รหัสผ่านผู้ดูแลต้องมีอย่างน้อย 8 ตัวอักษร")
+		}
+	}
+
+	return config.RotateKey(config.ConfigFile, newPassphrase)
+}
+
+// readPassword อ่านรหัสผ่านจาก terminal โดยไม่แสดงตัวอักษร
+func readPassword(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("ไม่สามารถอ่านรหัสผ่าน: %v", err)
+	}
+	return string(data), nil
+}
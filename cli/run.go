@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/pipeline"
+)
+
+// runDaemon hissync run: ทำงานเหมือนหน้าจอหลักแต่ไม่เปิดหน้าจอ จนกว่าจะได้รับ SIGINT หรือ SIGTERM
+func runDaemon(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	logging.Setup(cfg.LogSettings(), os.Stderr)
+	logger := logging.For(logging.ComponentApp)
+
+	manager := capture.NewManager()
+	pipe, err := pipeline.Open(manager)
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("HISSYNC เริ่มทำงาน", "profiles", len(cfg.Profiles), "sinks", len(cfg.Sinks))
+	pipe.Apply(cfg)
+
+	// เมื่อ config.json เปลี่ยน ให้ปรับแหล่งข้อมูลและปลายทางใหม่โดยไม่ต้องรีสตาร์ท
+	stopWatch, err := config.WatchConfig(config.ConfigFile, func(cfg *config.Config, err error) {
+		if err != nil {
+			logging.For(logging.ComponentConfig).Warn("config.json ไม่ถูกต้อง ใช้การตั้งค่าเดิม", "error", err)
+			return
+		}
+		logging.For(logging.ComponentConfig).Info("config.json เปลี่ยน โหลดการตั้งค่าใหม่")
+		pipe.Apply(cfg)
+	})
+	if err != nil {
+		logging.For(logging.ComponentConfig).Warn("ไม่สามารถติดตามการเปลี่ยนแปลง config.json", "error", err)
+	} else {
+		defer stopWatch()
+	}
+
+	<-ctx.Done()
+	logger.Info("HISSYNC กำลังหยุดทำงาน")
+	return nil
+}
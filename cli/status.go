@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+// runStatus hissync status: แสดงโปรไฟล์ ตำแหน่งล่าสุดที่อ่านถึง และจำนวนรายการค้างส่งของแต่ละปลายทาง
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tDBTYPE\tENGINE\tENABLED\tCHECKPOINT")
+	for _, p := range cfg.Profiles {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\n", p.Name, p.DBType, p.Engine, !p.Disabled, checkpointSummary(p))
+	}
+	w.Flush()
+
+	if len(cfg.Sinks) == 0 {
+		return nil
+	}
+	fmt.Println()
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		fmt.Println("HISSYNC กำลังทำงานอยู่ (hissync.db ถูกใช้งาน) ดูจำนวนค้างส่งได้จากโปรแกรมที่ทำงานอยู่")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SINK\tTYPE\tENABLED\tPENDING")
+	for _, s := range cfg.Sinks {
+		pending, err := st.PendingCount(s.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%d\n", s.Name, s.Type, !s.Disabled, pending)
+	}
+	return w.Flush()
+}
+
+// checkpointSummary ตำแหน่งล่าสุดที่อ่านถึงของโปรไฟล์แบบบรรทัดเดียว
+func checkpointSummary(p config.Profile) string {
+	state, err := capture.LoadState(p.StateFile)
+	if err != nil {
+		return fmt.Sprintf("อ่าน %s ไม่ได้: %v", p.StateFile, err)
+	}
+	switch {
+	case state.LastLogFile == "" && state.LastLogDatetime == "":
+		return "-"
+	case p.Engine == config.EngineBinlog && state.LastGTID != "":
+		return fmt.Sprintf("%s:%s gtid %s", state.LastLogFile, state.LastBinlogPosition, state.LastGTID)
+	case p.Engine == config.EngineBinlog:
+		return fmt.Sprintf("%s:%s", state.LastLogFile, state.LastBinlogPosition)
+	default:
+		return fmt.Sprintf("%s @ %s", state.LastLogFile, state.LastLogDatetime)
+	}
+}
+
+// runTestConnection hissync test-connection [-profile ชื่อ]
+func runTestConnection(args []string) error {
+	fs := flag.NewFlagSet("test-connection", flag.ContinueOnError)
+	profile := fs.String("profile", "", "ทดสอบเฉพาะโปรไฟล์นี้")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profiles, err := selectProfiles(cfg, *profile)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for i := range profiles {
+		p := &profiles[i]
+		if err := config.TestConnection(p); err != nil {
+			failed++
+			fmt.Printf("✗ %s (%s %s:%s): %v\n", p.Name, p.DBType, p.Host, p.Port, err)
+			continue
+		}
+		fmt.Printf("✓ %s (%s %s:%s)\n", p.Name, p.DBType, p.Host, p.Port)
+	}
+	if failed > 0 {
+		return fmt.Errorf("เชื่อมต่อไม่สำเร็จ %d จาก %d โปรไฟล์", failed, len(profiles))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"hissync-10/store"
+	"hissync-10/undo"
+)
+
+// runUndo hissync undo: สร้างคำสั่ง SQL ย้อนกลับเหตุการณ์ที่เลือกจากค่าก่อนและหลังที่บันทึกไว้
+// พิมพ์หรือบันทึกเป็นไฟล์ และใช้กับฐานข้อมูลต้นทางเมื่อระบุ -apply พร้อม -confirm ชื่อโปรไฟล์
+func runUndo(args []string) error {
+	fs := flag.NewFlagSet("undo", flag.ContinueOnError)
+	var filter store.Filter
+	fs.StringVar(&filter.Profile, "profile", "", "โปรไฟล์ของเหตุการณ์ (จำเป็น)")
+	fs.StringVar(&filter.Table, "table", "", "เฉพาะตารางที่ชื่อมีคำนี้ (database.table)")
+	fs.StringVar(&filter.Operation, "operation", "", "เฉพาะ INSERT, UPDATE หรือ DELETE")
+	fs.StringVar(&filter.Transaction, "transaction", "", "เฉพาะธุรกรรมนี้ (GTID หรือไฟล์:ตำแหน่ง ตามที่แสดงในรายละเอียดเหตุการณ์)")
+	from := fs.String("from", "", "ตั้งแต่เวลา \""+replayTimeFormat+"\"")
+	to := fs.String("to", "", "ถึงเวลา \""+replayTimeFormat+"\"")
+	output := fs.String("o", "", "บันทึกคำสั่งลงไฟล์ (ค่าเริ่มต้น: พิมพ์ออกหน้าจอ)")
+	apply := fs.Bool("apply", false, "ใช้คำสั่งกับฐานข้อมูลต้นทางในธุรกรรมเดียว")
+	confirm := fs.String("confirm", "", "ชื่อโปรไฟล์เพื่อยืนยัน -apply")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if filter.Profile == "" {
+		return errors.New("ต้องระบุ -profile")
+	}
+	if filter.Table == "" && filter.Transaction == "" && *from == "" && *to == "" {
+		return errors.New("ต้องระบุอย่างน้อยหนึ่งใน -table, -transaction, -from หรือ -to")
+	}
+	if *apply && *confirm != filter.Profile {
+		return fmt.Errorf("-apply ต้องระบุ -confirm %s เพื่อยืนยัน", filter.Profile)
+	}
+
+	var err error
+	if *from != "" {
+		if filter.From, err = time.ParseInLocation(replayTimeFormat, *from, time.Local); err != nil {
+			return fmt.Errorf("-from %q ไม่ตรงรูปแบบ %s", *from, replayTimeFormat)
+		}
+	}
+	if *to != "" {
+		if filter.To, err = time.ParseInLocation(replayTimeFormat, *to, time.Local); err != nil {
+			return fmt.Errorf("-to %q ไม่ตรงรูปแบบ %s", *to, replayTimeFormat)
+		}
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	p := cfg.Profile(filter.Profile)
+	if p == nil {
+		return fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", filter.Profile)
+	}
+
+	st, err := store.Open(store.DataFilePath())
+	if errors.Is(err, store.ErrLocked) {
+		return errors.New("HISSYNC กำลังทำงานอยู่ ใช้ปุ่ม \"ย้อนกลับ\" ในหน้าจอหลักแทน หรือหยุดโปรแกรมก่อน")
+	}
+	if err != nil {
+		return err
+	}
+	records, err := undo.Collect(st, filter)
+	st.Close()
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New("ไม่พบเหตุการณ์ที่ตรงกับเงื่อนไข")
+	}
+	script := undo.Build(records)
+
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(script.Text()), 0600); err != nil {
+			return fmt.Errorf("ไม่สามารถบันทึก %s: %v", *output, err)
+		}
+		fmt.Fprintf(os.Stderr, "บันทึกคำสั่งย้อนกลับ %d รายการลง %s\n", len(script.Statements), *output)
+	} else {
+		fmt.Print(script.Text())
+	}
+	if len(script.Skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "ข้าม %d เหตุการณ์ที่สร้างคำสั่งย้อนกลับไม่ได้ (ดูหมายเหตุในคำสั่ง)\n", len(script.Skipped))
+	}
+
+	if !*apply {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if err := undo.Apply(ctx, p, script); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "ย้อนกลับ %d รายการในฐานข้อมูล %s แล้ว\n", len(script.Statements), p.DBName)
+	return nil
+}
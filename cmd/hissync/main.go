@@ -0,0 +1,13 @@
+// hissync รุ่นไม่มีหน้าจอ สำหรับเครื่องแม่ข่าย Linux ที่ไม่มีระบบกราฟิก หรือรันภายใต้ systemd
+// ใช้ config.json และ state file ชุดเดียวกับหน้าจอหลัก
+package main
+
+import (
+	"os"
+
+	"hissync-10/cli"
+)
+
+func main() {
+	os.Exit(cli.Run(os.Args[1:]))
+}
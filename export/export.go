@@ -0,0 +1,249 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"hissync-10/store"
+)
+
+// รูปแบบไฟล์ที่ส่งออกได้
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// Formats รูปแบบไฟล์ทั้งหมดที่ส่งออกได้
+var Formats = []string{FormatCSV, FormatJSONL, FormatXLSX}
+
+// utf8BOM ทำให้ Excel เปิด CSV ภาษาไทยได้ถูกต้อง
+const utf8BOM = "\xEF\xBB\xBF"
+
+// รูปแบบเวลาในไฟล์ CSV และ XLSX
+const timeFormat = "2006-01-02 15:04:05"
+
+// หัวตารางของ CSV และ XLSX
+var header = []string{"seq", "captured_at", "profile", "source", "time", "database", "table", "operation",
+	"primary_key", "sql", "log_file", "position", "transaction",
+	"original_sql", "db_user", "client_app", "client_host", "session_id"}
+
+// FormatFromPath รูปแบบไฟล์ตามนามสกุล
+func FormatFromPath(path string) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch ext {
+	case FormatCSV, FormatXLSX:
+		return ext, nil
+	case FormatJSONL, "ndjson":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("ไม่รู้จักรูปแบบไฟล์ %q (ใช้ได้: %s)", filepath.Ext(path), strings.Join(Formats, ", "))
+}
+
+// Write เขียนเหตุการณ์ใน st ที่ตรงกับ filter ลง w ตามรูปแบบ คืนจำนวนเหตุการณ์ที่ส่งออก
+func Write(w io.Writer, format string, st *store.Store, filter store.Filter) (int, error) {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, st, filter)
+	case FormatJSONL:
+		return writeJSONL(w, st, filter)
+	case FormatXLSX:
+		return writeXLSX(w, st, filter)
+	}
+	return 0, fmt.Errorf("ไม่รองรับรูปแบบ %s (ใช้ได้: %s)", format, strings.Join(Formats, ", "))
+}
+
+// each เรียก fn กับเหตุการณ์ที่ตรงกับ filter ตามลำดับ
+func each(st *store.Store, filter store.Filter, fn func(store.Record) error) (int, error) {
+	count := 0
+	err := st.EachMatch(filter, func(rec store.Record) error {
+		count++
+		return fn(rec)
+	})
+	if err != nil {
+		return count, fmt.Errorf("ไม่สามารถส่งออกเหตุการณ์: %v", err)
+	}
+	return count, nil
+}
+
+// escapeFormula ขึ้นต้นค่าที่ Excel อาจตีความเป็นสูตร (= + - @ tab CR) ด้วย ' เพื่อให้แสดงเป็นข้อความ
+// ใช้กับ CSV และ XLSX ที่เปิดด้วยโปรแกรมตารางคำนวณ ไม่ใช้กับ JSON Lines
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// row ค่าของเหตุการณ์ตามหัวตาราง (ข้อความผ่าน escapeFormula แล้ว)
+func row(rec store.Record) []string {
+	values := []string{
+		strconv.FormatUint(rec.Seq, 10),
+		rec.CapturedAt.Local().Format(timeFormat),
+		rec.Profile,
+		rec.Source,
+		rec.Time.Local().Format(timeFormat),
+		rec.Database,
+		rec.Table,
+		rec.Operation,
+		rec.PrimaryKey,
+		rec.Statement(),
+		rec.LogFile,
+		rec.Position,
+		rec.Transaction,
+		rec.OriginalSQL,
+		rec.DBUser,
+		rec.ClientApp,
+		rec.ClientHost,
+		rec.SessionID,
+	}
+	for i, v := range values {
+		values[i] = escapeFormula(v)
+	}
+	return values
+}
+
+func writeCSV(w io.Writer, st *store.Store, filter store.Filter) (int, error) {
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return 0, err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	count, err := each(st, filter, func(rec store.Record) error {
+		return cw.Write(row(rec))
+	})
+	if err != nil {
+		return count, err
+	}
+	cw.Flush()
+	return count, cw.Error()
+}
+
+func writeJSONL(w io.Writer, st *store.Store, filter store.Filter) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	count, err := each(st, filter, func(rec store.Record) error {
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, bw.Flush()
+}
+
+// writeXLSX เขียนหนึ่ง sheet ต่อหนึ่งตาราง เรียงตามชื่อตาราง
+func writeXLSX(w io.Writer, st *store.Store, filter store.Filter) (int, error) {
+	byTable := make(map[string][]store.Record)
+	count, err := each(st, filter, func(rec store.Record) error {
+		byTable[rec.FullTableName()] = append(byTable[rec.FullTableName()], rec)
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	tables := make([]string, 0, len(byTable))
+	for table := range byTable {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	if len(tables) == 0 {
+		tables = []string{"events"}
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	used := make(map[string]bool)
+	for i, table := range tables {
+		name := sheetName(table, used)
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), name); err != nil {
+				return count, err
+			}
+		} else if _, err := f.NewSheet(name); err != nil {
+			return count, err
+		}
+		if err := writeSheet(f, name, byTable[table]); err != nil {
+			return count, fmt.Errorf("ไม่สามารถเขียน sheet %s: %v", name, err)
+		}
+	}
+	return count, f.Write(w)
+}
+
+func writeSheet(f *excelize.File, name string, records []store.Record) error {
+	sw, err := f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	if err := sw.SetRow("A1", cells(header)); err != nil {
+		return err
+	}
+	for i, rec := range records {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		values := cells(row(rec))
+		// ลำดับเป็นตัวเลข และเวลาเป็นวันที่ของ Excel เพื่อให้เรียงและกรองได้
+		values[0] = rec.Seq
+		values[1] = rec.CapturedAt.Local()
+		values[4] = rec.Time.Local()
+		if err := sw.SetRow(cell, values); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+func cells(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// sheetName ชื่อ sheet ที่ Excel ยอมรับ (ไม่เกิน 31 ตัวอักษร ไม่มี : \ / ? * [ ]) และไม่ซ้ำ
+func sheetName(table string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, table)
+	if name == "" {
+		name = "events"
+	}
+	base := []rune(name)
+	if len(base) > 31 {
+		base = base[:31]
+	}
+	name = string(base)
+	for n := 2; used[strings.ToLower(name)]; n++ {
+		suffix := fmt.Sprintf("~%d", n)
+		trimmed := base
+		if len(trimmed)+len(suffix) > 31 {
+			trimmed = trimmed[:31-len(suffix)]
+		}
+		name = string(trimmed) + suffix
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+// FileName ชื่อไฟล์เริ่มต้นสำหรับส่งออก เช่น hissync-jhcis-20240131-0830.xlsx
+func FileName(profile, format string) string {
+	name := "hissync"
+	if profile != "" {
+		name += "-" + profile
+	}
+	return fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-1504"), format)
+}
@@ -0,0 +1,151 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+
+	"hissync-10/capture"
+	"hissync-10/store"
+)
+
+// openTestStore ที่เก็บเหตุการณ์ในโฟลเดอร์ชั่วคราว มีเหตุการณ์ของสองโปรไฟล์และสามตาราง
+func openTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "hissync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	for _, ev := range []capture.Event{
+		{Profile: "jhcis", Database: "jhcis", Table: "person", Operation: "INSERT", PrimaryKey: "{pid=1}", SQL: "INSERT INTO person VALUES (1, 'สมชาย');"},
+		{Profile: "jhcis", Database: "jhcis", Table: "visit", Operation: "UPDATE", PrimaryKey: "{visitno=7}", SQL: "UPDATE visit SET x = 1;"},
+		{Profile: "hosxp", Database: "hos", Table: "person", Operation: "DELETE", PrimaryKey: "{hn=9}"},
+		{Profile: "jhcis", Database: "jhcis", Table: "person", Operation: "UPDATE", PrimaryKey: "{pid=1}", DBUser: "=HYPERLINK(\"http://x\")", ClientApp: "@SUM(A1)"},
+	} {
+		if _, err := st.Append(ev, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st
+}
+
+func TestWrite(t *testing.T) {
+	st := openTestStore(t)
+	tests := []struct {
+		name   string
+		format string
+		filter store.Filter
+		want   int
+		check  func(t *testing.T, data []byte)
+	}{
+		{"csv", FormatCSV, store.Filter{Profile: "jhcis", Table: "person"}, 2, func(t *testing.T, data []byte) {
+			if !bytes.HasPrefix(data, []byte(utf8BOM)) {
+				t.Fatal("CSV ไม่ขึ้นต้นด้วย UTF-8 BOM")
+			}
+			rows, err := csv.NewReader(bytes.NewReader(data[len(utf8BOM):])).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(header, ",") {
+				t.Fatalf("CSV มี %d แถว หัวตาราง %v", len(rows), rows[0])
+			}
+			if rows[1][0] != "1" || rows[1][9] != "INSERT INTO person VALUES (1, 'สมชาย');" {
+				t.Fatalf("แถวแรก = %v", rows[1])
+			}
+			if rows[2][0] != "4" || rows[2][14] != `'=HYPERLINK("http://x")` || rows[2][15] != "'@SUM(A1)" {
+				t.Fatalf("ค่าที่ขึ้นต้นเหมือนสูตรไม่ถูกป้องกัน: %v", rows[2])
+			}
+		}},
+		{"jsonl", FormatJSONL, store.Filter{Profile: "jhcis"}, 3, func(t *testing.T, data []byte) {
+			scanner := bufio.NewScanner(bytes.NewReader(data))
+			var seqs []uint64
+			for scanner.Scan() {
+				var rec store.Record
+				if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+					t.Fatalf("บรรทัด %q: %v", scanner.Text(), err)
+				}
+				if rec.Seq == 4 && rec.DBUser != `=HYPERLINK("http://x")` {
+					t.Fatalf("JSON Lines ต้องเก็บค่าเดิม ได้ %q", rec.DBUser)
+				}
+				seqs = append(seqs, rec.Seq)
+			}
+			if len(seqs) != 3 || seqs[0] != 1 || seqs[1] != 2 || seqs[2] != 4 {
+				t.Fatalf("ลำดับ = %v ต้องการ [1 2 4]", seqs)
+			}
+		}},
+		{"xlsx", FormatXLSX, store.Filter{}, 4, func(t *testing.T, data []byte) {
+			f, err := excelize.OpenReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if got := strings.Join(f.GetSheetList(), ","); got != "hos.person,jhcis.person,jhcis.visit" {
+				t.Fatalf("sheet = %s ต้องการหนึ่ง sheet ต่อตาราง เรียงตามชื่อ", got)
+			}
+			for sheet, want := range map[string]int{"hos.person": 1, "jhcis.person": 2, "jhcis.visit": 1} {
+				rows, err := f.GetRows(sheet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(rows) != want+1 {
+					t.Fatalf("sheet %s มี %d แถว ต้องการ %d", sheet, len(rows)-1, want)
+				}
+			}
+			if v, _ := f.GetCellValue("jhcis.person", "O3"); v != `'=HYPERLINK("http://x")` {
+				t.Fatalf("O3 = %q ค่าที่ขึ้นต้นเหมือนสูตรไม่ถูกป้องกัน", v)
+			}
+			if formula, _ := f.GetCellFormula("jhcis.person", "O3"); formula != "" {
+				t.Fatalf("O3 เป็นสูตร %q", formula)
+			}
+		}},
+		{"xlsx without events", FormatXLSX, store.Filter{Profile: "nope"}, 0, func(t *testing.T, data []byte) {
+			f, err := excelize.OpenReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if got := strings.Join(f.GetSheetList(), ","); got != "events" {
+				t.Fatalf("sheet = %s ต้องการ events", got)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			count, err := Write(&b, tt.format, st, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tt.want {
+				t.Fatalf("Write() = %d ต้องการ %d", count, tt.want)
+			}
+			tt.check(t, b.Bytes())
+		})
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", ""},
+		{"สมชาย", "สมชาย"},
+		{"=1+1", "'=1+1"},
+		{"+66812345678", "'+66812345678"},
+		{"-5", "'-5"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tcmd", "'\tcmd"},
+		{"\r=1", "'\r=1"},
+		{"a=1", "a=1"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.in); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q ต้องการ %q", tt.in, got, tt.want)
+		}
+	}
+}
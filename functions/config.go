@@ -4,35 +4,328 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
-// Config โครงสร้างของ config.json
+// ConfigFile ชื่อไฟล์ตั้งค่าหลัก
+const ConfigFile = "config.json"
+
+// Config โครงสร้างของ config.json ประกอบด้วยโปรไฟล์แหล่งข้อมูลหลายรายการที่ทำงานพร้อมกัน
+// และปลายทางที่รับเหตุการณ์จากโปรไฟล์เหล่านั้น
 type Config struct {
-	DBType       string   `json:"dbtype"`
-	Host         string   `json:"host"`
-	Port         string   `json:"port"`
-	Username     string   `json:"username"`
-	Password     string   `json:"password"`
-	DBName       string   `json:"dbname"`
-	LogFilePath  string   `json:"log_file_path"`
-	StateFile    string   `json:"state_file"`
-	FilterTables []string `json:"filter_tables"`
-}
-
-// LoadConfig โหลดการตั้งค่าจาก config.json
+	Profiles []Profile      `json:"profiles"`
+	Sinks    []SinkConfig   `json:"sinks,omitempty"`
+	Monitor  *MonitorConfig `json:"monitor,omitempty"`
+	// LagWarning เกณฑ์เตือนความล่าช้า ไม่ระบุคือใช้ค่าเริ่มต้น
+	LagWarning *LagWarning `json:"lag_warning,omitempty"`
+	// Log การตั้งค่า log ของโปรแกรม ไม่ระบุคือใช้ค่าเริ่มต้น
+	Log *LogConfig `json:"log,omitempty"`
+	// Display การตั้งค่าการแสดงผล ไม่ระบุคือใช้ค่าเริ่มต้น
+	Display *DisplayConfig `json:"display,omitempty"`
+	// Safeguard เกณฑ์กักธุรกรรมที่เปลี่ยนข้อมูลจำนวนมากผิดปกติ ไม่ระบุคือไม่กัก
+	Safeguard *SafeguardConfig `json:"safeguard,omitempty"`
+	// Events ระยะเก็บรักษาเหตุการณ์ใน hissync.db ไม่ระบุคือเก็บทุกเหตุการณ์
+	Events *EventsConfig `json:"events,omitempty"`
+}
+
+// Sink คืนปลายทางตามชื่อ หรือ nil ถ้าไม่พบ
+func (c *Config) Sink(name string) *SinkConfig {
+	for i := range c.Sinks {
+		if c.Sinks[i].Name == name {
+			return &c.Sinks[i]
+		}
+	}
+	return nil
+}
+
+// SinksFor คืนชื่อปลายทางที่รับเหตุการณ์ของโปรไฟล์
+func (c *Config) SinksFor(profile string) []string {
+	var names []string
+	for i := range c.Sinks {
+		if c.Sinks[i].Accepts(profile) {
+			names = append(names, c.Sinks[i].Name)
+		}
+	}
+	return names
+}
+
+// Profile คืนโปรไฟล์ตามชื่อ หรือ nil ถ้าไม่พบ
+func (c *Config) Profile(name string) *Profile {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// Upsert เพิ่มโปรไฟล์ใหม่ หรือแทนที่โปรไฟล์ชื่อ oldName (ใช้เปลี่ยนชื่อโปรไฟล์ได้)
+func (c *Config) Upsert(oldName string, p Profile) {
+	if existing := c.Profile(oldName); oldName != "" && existing != nil {
+		*existing = p
+		return
+	}
+	c.Profiles = append(c.Profiles, p)
+}
+
+// Remove ลบโปรไฟล์ตามชื่อ
+func (c *Config) Remove(name string) {
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			c.Profiles = append(c.Profiles[:i], c.Profiles[i+1:]...)
+			return
+		}
+	}
+}
+
+// LoadConfig โหลดการตั้งค่าจาก config.json แล้วใช้ค่าจากตัวแปรสภาพแวดล้อม
+// เติมค่าเริ่มต้น ตรวจสอบความถูกต้อง และย้ายไฟล์ตั้งค่าตารางรูปแบบเก่าเป็น tables.json
+// ถ้าพบรหัสผ่านที่ยังไม่เข้ารหัส หรือไฟล์ยังเป็นรูปแบบการเชื่อมต่อเดียว จะบันทึกไฟล์ใหม่ให้อัตโนมัติ
 func LoadConfig(filePath string) (*Config, error) {
-	file, err := os.Open(filePath)
+	config, needsSave, err := readConfig(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์ config.json: %v", err)
+		return nil, err
+	}
+	if needsSave {
+		if err := SaveConfig(filePath, config); err != nil {
+			return nil, fmt.Errorf("ไม่สามารถบันทึก config.json รูปแบบใหม่: %v", err)
+		}
+	}
+	if err := config.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	config.ApplyDefaults()
+	if err := config.Validate(); err != nil {
+		if verr, ok := err.(*ValidationError); ok {
+			verr.File = filePath
+		}
+		return nil, err
+	}
+	if err := config.MigrateTableConfigs(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ReadConfigFile อ่าน config.json ตามที่บันทึกไว้ (ถอดรหัสค่าลับแล้ว) โดยไม่ใช้ค่าจากตัวแปรสภาพแวดล้อมหรือค่าเริ่มต้น
+// (ใช้สำหรับฟอร์มแก้ไขเพื่อไม่ให้ค่าชั่วคราวถูกบันทึกลงไฟล์)
+func ReadConfigFile(filePath string) (*Config, error) {
+	config, _, err := readConfig(filePath)
+	return config, err
+}
+
+// readConfig อ่าน config.json และบอกว่าควรบันทึกไฟล์ใหม่หรือไม่
+// (มีค่าลับที่ยังไม่เข้ารหัส หรือเป็นรูปแบบเก่าที่มีการเชื่อมต่อเดียว)
+func readConfig(filePath string) (*Config, bool, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("ไม่สามารถเปิดไฟล์ config.json: %v", err)
 	}
-	defer file.Close()
 
 	config := &Config{}
-	decoder := json.NewDecoder(file)
-	err = decoder.Decode(config)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, false, fmt.Errorf("ไม่สามารถอ่าน config.json: %w", err)
+	}
+
+	// รูปแบบเก่า: ค่าการเชื่อมต่ออยู่ที่ระดับบนสุดของไฟล์ ย้ายเป็นโปรไฟล์ "default"
+	if len(config.Profiles) == 0 {
+		var legacy Profile
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, false, fmt.Errorf("ไม่สามารถอ่าน config.json: %w", err)
+		}
+		if legacy.DBType != "" {
+			legacy.Name = DefaultProfileName
+			if legacy.StateFile == "" {
+				// รักษาตำแหน่งที่อ่านถึงเดิมไว้
+				legacy.StateFile = DefaultStateFile
+			}
+			config.Profiles = []Profile{legacy}
+			return config, true, nil
+		}
+	}
+
+	return config, hasPlaintextSecrets(data, config), nil
+}
+
+// hasPlaintextSecrets เทียบไฟล์เดิมกับผลการบันทึกใหม่ ถ้าตำแหน่งที่ควรเป็นค่าเข้ารหัสยังเป็นข้อความธรรมดา แสดงว่ามีค่าลับที่ยังไม่เข้ารหัส
+func hasPlaintextSecrets(data []byte, config *Config) bool {
+	encoded, err := json.Marshal(config)
 	if err != nil {
-		return nil, fmt.Errorf("ไม่สามารถอ่าน config.json: %v", err)
+		return false
 	}
+	var before, after interface{}
+	if json.Unmarshal(data, &before) != nil || json.Unmarshal(encoded, &after) != nil {
+		return false
+	}
+	return plaintextAt(before, after)
+}
 
-	return config, nil
+func plaintextAt(before, after interface{}) bool {
+	switch a := after.(type) {
+	case string:
+		b, ok := before.(string)
+		return ok && b != "" && strings.HasPrefix(a, secretPrefix) && !strings.HasPrefix(b, secretPrefix)
+	case map[string]interface{}:
+		b, _ := before.(map[string]interface{})
+		for k, v := range a {
+			if plaintextAt(b[k], v) {
+				return true
+			}
+		}
+	case []interface{}:
+		b, _ := before.([]interface{})
+		for i, v := range a {
+			if i < len(b) && plaintextAt(b[i], v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SaveConfig บันทึกการตั้งค่าลงไฟล์ config.json
+func SaveConfig(filePath string, config *Config) error {
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return err
+	}
+	// เขียนไฟล์ชั่วคราวแล้วเปลี่ยนชื่อ เพื่อไม่ให้ตัวเฝ้าดูไฟล์อ่านเจอไฟล์ที่เขียนไม่ครบ
+	tmp := filePath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึก config.json: %v", err)
+	}
+	if err := os.Rename(tmp, filePath); err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึก config.json: %v", err)
+	}
+	return nil
+}
+
+// ApplyEnv แทนค่าด้วยตัวแปรสภาพแวดล้อม
+// HISSYNC_<โปรไฟล์>_<ฟิลด์> ใช้กับโปรไฟล์ที่ระบุ เช่น HISSYNC_JHCIS_PASSWORD
+// HISSYNC_<ฟิลด์> ใช้ได้เมื่อมีโปรไฟล์เดียว ตัวแปรที่มีค่าไม่ถูกต้องจะไม่ถูกใช้และคืนเป็น ValidationError
+func (c *Config) ApplyEnv() error {
+	var problems []string
+	apply := func(p *Profile, name, field string, set func(*Profile, string)) {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if check := envChecks[field]; check != nil {
+			if err := check(v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+				return
+			}
+		}
+		set(p, v)
+	}
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+		for _, o := range envOverrides {
+			if len(c.Profiles) == 1 {
+				apply(p, "HISSYNC_"+o.name, o.name, o.set)
+			}
+			apply(p, "HISSYNC_"+p.envName()+"_"+o.name, o.name, o.set)
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{File: "ตัวแปรสภาพแวดล้อม", Problems: problems}
+	}
+	return nil
+}
+
+// ApplyDefaults เติมค่าเริ่มต้นให้ทุกโปรไฟล์และปลายทาง
+func (c *Config) ApplyDefaults() {
+	for i := range c.Profiles {
+		c.Profiles[i].ApplyDefaults()
+	}
+	for i := range c.Sinks {
+		c.Sinks[i].ApplyDefaults()
+	}
+}
+
+// Validate ตรวจสอบทุกโปรไฟล์และปลายทาง และชื่อโปรไฟล์/state file/ปลายทางต้องไม่ซ้ำกัน
+func (c *Config) Validate() error {
+	var problems []string
+	if len(c.Profiles) == 0 {
+		problems = append(problems, "ไม่มีโปรไฟล์แหล่งข้อมูลใน profiles")
+	}
+
+	names := make(map[string]int)
+	stateFiles := make(map[string]int)
+	serverIDs := make(map[string]int)
+	for i, p := range c.Profiles {
+		where := fmt.Sprintf("profiles[%d]", i)
+		if p.Name != "" {
+			where = fmt.Sprintf("profiles[%d] (%s)", i, p.Name)
+			if j, ok := names[p.Name]; ok {
+				problems = append(problems, fmt.Sprintf("%s: ชื่อโปรไฟล์ซ้ำกับ profiles[%d]", where, j))
+			}
+			names[p.Name] = i
+		}
+		if p.StateFile != "" {
+			if j, ok := stateFiles[p.StateFile]; ok {
+				problems = append(problems, fmt.Sprintf("%s: state_file %s ซ้ำกับ profiles[%d]", where, p.StateFile, j))
+			}
+			stateFiles[p.StateFile] = i
+		}
+		// โปรไฟล์ที่อ่าน binlog จากเซิร์ฟเวอร์เดียวกันด้วย server_id เดียวกันจะตัดการเชื่อมต่อของกันและกัน
+		if p.Engine == EngineBinlog && p.Replication != nil && p.Replication.ServerID != 0 {
+			key := fmt.Sprintf("%s:%s/%d", p.Host, p.Replica().Port, p.Replication.ServerID)
+			if j, ok := serverIDs[key]; ok {
+				problems = append(problems, fmt.Sprintf("%s: replication.server_id %d ซ้ำกับ profiles[%d] ที่อ่านจากเซิร์ฟเวอร์เดียวกัน",
+					where, p.Replication.ServerID, j))
+			}
+			serverIDs[key] = i
+		}
+		for _, problem := range p.problems() {
+			problems = append(problems, where+": "+problem)
+		}
+	}
+
+	sinkNames := make(map[string]int)
+	for i, s := range c.Sinks {
+		where := fmt.Sprintf("sinks[%d]", i)
+		if s.Name != "" {
+			where = fmt.Sprintf("sinks[%d] (%s)", i, s.Name)
+			if j, ok := sinkNames[s.Name]; ok {
+				problems = append(problems, fmt.Sprintf("%s: ชื่อปลายทางซ้ำกับ sinks[%d]", where, j))
+			}
+			sinkNames[s.Name] = i
+		}
+		for _, problem := range s.problems(names) {
+			problems = append(problems, where+": "+problem)
+		}
+	}
+
+	if c.LagWarning != nil && (c.LagWarning.CaptureSeconds < 0 || c.LagWarning.DeliverySeconds < 0) {
+		problems = append(problems, "lag_warning: ค่าต้องไม่ติดลบ")
+	}
+	if c.Display != nil && c.Display.MaxEvents < 0 {
+		problems = append(problems, "display: max_events ต้องไม่ติดลบ")
+	}
+	if c.Log != nil {
+		for _, problem := range c.Log.problems() {
+			problems = append(problems, "log: "+problem)
+		}
+	}
+	if c.Monitor != nil {
+		for _, problem := range c.Monitor.problems() {
+			problems = append(problems, "monitor: "+problem)
+		}
+	}
+	if c.Safeguard != nil {
+		for _, problem := range c.Safeguard.problems(c.Profiles, names) {
+			problems = append(problems, "safeguard: "+problem)
+		}
+	}
+	if c.Events != nil {
+		for _, problem := range c.Events.problems() {
+			problems = append(problems, "events: "+problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{File: ConfigFile, Problems: problems}
+	}
+	return nil
 }
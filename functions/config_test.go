@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyEnvReplicationServerID(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    uint32
+		wantErr string
+	}{
+		{"profile variable", map[string]string{"HISSYNC_JHCIS_REPLICATION_SERVER_ID": "4201"}, 4201, ""},
+		{"single profile variable", map[string]string{"HISSYNC_REPLICATION_SERVER_ID": "4202"}, 4202, ""},
+		{"typo", map[string]string{"HISSYNC_JHCIS_REPLICATION_SERVER_ID": "42O1"}, 7, "HISSYNC_JHCIS_REPLICATION_SERVER_ID"},
+		{"out of range", map[string]string{"HISSYNC_REPLICATION_SERVER_ID": "4294967296"}, 7, "HISSYNC_REPLICATION_SERVER_ID"},
+		{"negative", map[string]string{"HISSYNC_JHCIS_REPLICATION_SERVER_ID": "-1"}, 7, "HISSYNC_JHCIS_REPLICATION_SERVER_ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg := &Config{Profiles: []Profile{{Name: "jhcis", Replication: &ReplicationConfig{ServerID: 7}}}}
+			err := cfg.ApplyEnv()
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ApplyEnv() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ApplyEnv() error = %v ต้องการข้อผิดพลาดที่ระบุ %s", err, tt.wantErr)
+			}
+			if got := cfg.Profiles[0].Replication.ServerID; got != tt.want {
+				t.Fatalf("server_id = %d ต้องการ %d", got, tt.want)
+			}
+		})
+	}
+}
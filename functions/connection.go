@@ -0,0 +1,210 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/denisenkom/go-mssqldb/msdsn"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// connectTimeout เวลาสูงสุดในการทดสอบการเชื่อมต่อ
+const connectTimeout = 10 * time.Second
+
+// OpenDB เปิดการเชื่อมต่อ database/sql ตามประเภทฐานข้อมูลของโปรไฟล์ พร้อมการเข้ารหัสตาม tls
+func OpenDB(config *Profile) (*sql.DB, error) {
+	switch config.DBType {
+	case DBTypePostgreSQL:
+		connector, err := postgresConnector(config)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+
+	case DBTypeMySQL:
+		dsn, err := mysqlConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		connector, err := mysql.NewConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+
+	case DBTypeSQLServer:
+		connector, err := sqlServerConnector(config)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+
+	default:
+		return nil, fmt.Errorf("ไม่รองรับฐานข้อมูลประเภท %s", config.DBType)
+	}
+}
+
+// mysqlConfig การตั้งค่าไดรเวอร์ MySQL ของโปรไฟล์ พร้อมการเข้ารหัสตาม tls
+func mysqlConfig(config *Profile) (*mysql.Config, error) {
+	tlsConfig, err := config.ClientTLS()
+	if err != nil {
+		return nil, err
+	}
+	dsn := mysql.NewConfig()
+	dsn.User = config.Username
+	dsn.Passwd = config.Password.Reveal()
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(config.Host, config.Port)
+	dsn.DBName = config.DBName
+	dsn.TLS = tlsConfig
+	dsn.DialFunc = NewDialer(config).DialContext
+	return dsn, nil
+}
+
+// postgresConnector เชื่อมต่อ PostgreSQL ตาม postgresDSN ผ่าน Dialer ของโปรไฟล์
+func postgresConnector(config *Profile) (*pq.Connector, error) {
+	connector, err := pq.NewConnector(postgresDSN(config))
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer(NewDialer(config))
+	return connector, nil
+}
+
+// postgresDSN lib/pq รับการเข้ารหัสเป็นพารามิเตอร์ sslmode เท่านั้น
+// เมื่อระบุ server_name จะใช้ชื่อนั้นเป็น host (เพื่อตรวจใบรับรอง) แต่ Dialer เชื่อมต่อไปยัง host จริง
+func postgresDSN(config *Profile) string {
+	host := config.Host
+	params := url.Values{"sslmode": {config.TLSMode()}}
+	if t := config.TLS; t != nil && config.TLSMode() != TLSModeDisable {
+		if t.CAFile != "" {
+			params.Set("sslrootcert", t.CAFile)
+		}
+		if t.CertFile != "" {
+			params.Set("sslcert", t.CertFile)
+			params.Set("sslkey", t.KeyFile)
+		}
+		if t.ServerName != "" {
+			host = t.ServerName
+		}
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(config.Username, config.Password.Reveal()),
+		Host:     net.JoinHostPort(host, config.Port),
+		Path:     "/" + config.DBName,
+		RawQuery: params.Encode(),
+	}
+	return dsn.String()
+}
+
+// sqlServerConnector เชื่อมต่อ SQL Server ตาม sqlServerParams ผ่าน Dialer ของโปรไฟล์
+func sqlServerConnector(config *Profile) (*mssql.Connector, error) {
+	params, err := sqlServerParams(config)
+	if err != nil {
+		return nil, err
+	}
+	connector := mssql.NewConnectorConfig(params)
+	connector.Dialer = NewDialer(config)
+	return connector, nil
+}
+
+// sqlServerParams disable ใช้ค่าเริ่มต้นของไดรเวอร์ (เข้ารหัสเฉพาะขั้นตอน login)
+func sqlServerParams(config *Profile) (msdsn.Config, error) {
+	dsn := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(config.Username, config.Password.Reveal()),
+		Host:     net.JoinHostPort(config.Host, config.Port),
+		RawQuery: url.Values{"database": {config.DBName}}.Encode(),
+	}
+	params, _, err := msdsn.Parse(dsn.String())
+	if err != nil {
+		return params, err
+	}
+	tlsConfig, err := config.ClientTLS()
+	if err != nil {
+		return params, err
+	}
+	if tlsConfig != nil {
+		// SQL Server ต้องการ TLS record ละหนึ่ง TDS packet (ดู msdsn.SetupTLS)
+		tlsConfig.DynamicRecordSizingDisabled = true
+		params.Encryption = msdsn.EncryptionRequired
+		params.TLSConfig = tlsConfig
+		params.HostInCertificateProvided = true
+	}
+	if config.SSH != nil {
+		// ไดรเวอร์แปลงชื่อเครื่องเป็น IP ก่อนเชื่อมต่อ แต่ชื่อนั้นอาจรู้จักเฉพาะฝั่งเครื่อง SSH
+		// Dialer เชื่อมต่อไปยัง host ของโปรไฟล์เสมอ จึงใช้ IP ใดก็ได้แทน
+		params.Host = "127.0.0.1"
+	}
+	return params, nil
+}
+
+// MongoOptions ตัวเลือกการเชื่อมต่อ MongoDB ของโปรไฟล์
+func MongoOptions(config *Profile) (*options.ClientOptions, error) {
+	uri := url.URL{
+		Scheme: "mongodb",
+		Host:   net.JoinHostPort(config.Host, config.Port),
+		Path:   "/" + config.DBName,
+	}
+	if config.Username != "" {
+		uri.User = url.UserPassword(config.Username, config.Password.Reveal())
+	}
+	opts := options.Client().ApplyURI(uri.String())
+	if config.SSH != nil {
+		// ผ่าน tunnel เชื่อมต่อได้เฉพาะ host ของโปรไฟล์ จึงไม่ค้นหาสมาชิกอื่นของ replica set
+		opts.SetDialer(NewDialer(config)).SetDirect(true)
+	}
+	tlsConfig, err := config.ClientTLS()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+	return opts, nil
+}
+
+// PortNumber คืนพอร์ตของโปรไฟล์เป็นตัวเลข
+func (p *Profile) PortNumber() uint16 {
+	port, _ := strconv.ParseUint(p.Port, 10, 16)
+	return uint16(port)
+}
+
+// TestConnection ทดสอบการเชื่อมต่อกับฐานข้อมูล
+func TestConnection(config *Profile) error {
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	if config.DBType == DBTypeMongoDB {
+		opts, err := MongoOptions(config)
+		if err != nil {
+			return RedactError(err)
+		}
+		client, err := mongo.Connect(ctx, opts)
+		if err != nil {
+			return RedactError(fmt.Errorf("MongoDB connection error: %v", err))
+		}
+		defer client.Disconnect(context.Background())
+		return RedactError(client.Ping(ctx, nil))
+	}
+
+	db, err := OpenDB(config)
+	if err != nil {
+		return RedactError(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return RedactError(fmt.Errorf("%s connection error: %v", config.DBType, err))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/denisenkom/go-mssqldb/msdsn"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		name     string
+		tls      *TLSConfig
+		wantHost string
+		want     url.Values
+	}{
+		{"no tls", nil, "db.example:5432", url.Values{"sslmode": {"disable"}}},
+		{"disable ignores files", &TLSConfig{Mode: TLSModeDisable, CAFile: ca.file, ServerName: "other.example"},
+			"db.example:5432", url.Values{"sslmode": {"disable"}}},
+		{"require", &TLSConfig{Mode: TLSModeRequire}, "db.example:5432", url.Values{"sslmode": {"require"}}},
+		{"verify-ca", &TLSConfig{Mode: TLSModeVerifyCA, CAFile: ca.file},
+			"db.example:5432", url.Values{"sslmode": {"verify-ca"}, "sslrootcert": {ca.file}}},
+		{"verify-full with client certificate", &TLSConfig{Mode: TLSModeVerifyFull, CAFile: ca.file, CertFile: "client.pem", KeyFile: "client.key", ServerName: "other.example"},
+			"other.example:5432", url.Values{"sslmode": {"verify-full"}, "sslrootcert": {ca.file}, "sslcert": {"client.pem"}, "sslkey": {"client.key"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{Host: "db.example", Port: "5432", Username: "hissync", Password: "p@ss/word", DBName: "jhcis", TLS: tt.tls}
+			dsn, err := url.Parse(postgresDSN(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dsn.Scheme != "postgres" || dsn.Host != tt.wantHost || dsn.Path != "/jhcis" {
+				t.Fatalf("DSN = %s ต้องการ postgres://%s/jhcis", dsn.Redacted(), tt.wantHost)
+			}
+			if password, _ := dsn.User.Password(); dsn.User.Username() != "hissync" || password != "p@ss/word" {
+				t.Fatalf("ผู้ใช้ใน DSN = %s", dsn.User)
+			}
+			if got := dsn.Query(); got.Encode() != tt.want.Encode() {
+				t.Fatalf("พารามิเตอร์ = %s ต้องการ %s", got.Encode(), tt.want.Encode())
+			}
+		})
+	}
+}
+
+func TestMySQLConfig(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		name    string
+		tls     *TLSConfig
+		wantTLS bool
+		wantErr bool
+	}{
+		{"no tls", nil, false, false},
+		{"disable", &TLSConfig{Mode: TLSModeDisable}, false, false},
+		{"verify-full", &TLSConfig{Mode: TLSModeVerifyFull, CAFile: ca.file}, true, false},
+		{"missing ca file", &TLSConfig{Mode: TLSModeVerifyCA, CAFile: "missing.pem"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{Host: "db.example", Port: "3306", Username: "hissync", Password: "secret", DBName: "jhcis", TLS: tt.tls}
+			dsn, err := mysqlConfig(p)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mysqlConfig() error = %v ต้องการข้อผิดพลาด %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if dsn.Net != "tcp" || dsn.Addr != "db.example:3306" || dsn.User != "hissync" || dsn.Passwd != "secret" || dsn.DBName != "jhcis" {
+				t.Fatalf("mysqlConfig() = %s", dsn.FormatDSN())
+			}
+			if (dsn.TLS != nil) != tt.wantTLS {
+				t.Fatalf("TLS = %v ต้องการเข้ารหัส %v", dsn.TLS, tt.wantTLS)
+			}
+			if dsn.DialFunc == nil {
+				t.Fatal("ไม่ได้ใช้ Dialer ของโปรไฟล์")
+			}
+		})
+	}
+}
+
+func TestSQLServerParams(t *testing.T) {
+	ca := newTestCA(t)
+	tests := []struct {
+		name     string
+		tls      *TLSConfig
+		ssh      *SSHConfig
+		wantHost string
+		wantTLS  bool
+	}{
+		{"no tls", nil, nil, "db.example", false},
+		{"verify-full", &TLSConfig{Mode: TLSModeVerifyFull, CAFile: ca.file}, nil, "db.example", true},
+		{"through ssh", nil, &SSHConfig{Host: "bastion.example", User: "hissync"}, "127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{Host: "db.example", Port: "1433", Username: "sa", Password: "secret", DBName: "jhcis", TLS: tt.tls, SSH: tt.ssh}
+			params, err := sqlServerParams(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if params.Host != tt.wantHost || params.Port != 1433 || params.Database != "jhcis" {
+				t.Fatalf("host, port, database = %s, %d, %s", params.Host, params.Port, params.Database)
+			}
+			if tt.wantTLS && (params.Encryption != msdsn.EncryptionRequired || params.TLSConfig == nil || !params.TLSConfig.DynamicRecordSizingDisabled) {
+				t.Fatalf("Encryption = %v TLSConfig = %v ต้องการเข้ารหัสด้วยใบรับรองของโปรไฟล์", params.Encryption, params.TLSConfig)
+			}
+			if !tt.wantTLS && params.Encryption == msdsn.EncryptionRequired {
+				t.Fatal("เข้ารหัสทั้งการเชื่อมต่อทั้งที่ไม่ได้ระบุ tls")
+			}
+		})
+	}
+}
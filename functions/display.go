@@ -0,0 +1,18 @@
+package config
+
+// จำนวนเหตุการณ์ที่หน้าจอ Log ของแต่ละโปรไฟล์เก็บไว้ในหน่วยความจำโดยค่าเริ่มต้น
+const defaultDisplayMaxEvents = 5000
+
+// DisplayConfig การตั้งค่าการแสดงผลของหน้าจอหลัก
+type DisplayConfig struct {
+	// MaxEvents จำนวนเหตุการณ์สูงสุดที่แสดงในตารางของแต่ละโปรไฟล์ เหตุการณ์ที่เก่ากว่าเปิดดูได้ทีละหน้าจาก hissync.db
+	MaxEvents int `json:"max_events,omitempty"`
+}
+
+// DisplayMaxEvents คืนจำนวนเหตุการณ์สูงสุดที่แสดงในตารางของแต่ละโปรไฟล์
+func (c *Config) DisplayMaxEvents() int {
+	if c == nil || c.Display == nil || c.Display.MaxEvents <= 0 {
+		return defaultDisplayMaxEvents
+	}
+	return c.Display.MaxEvents
+}
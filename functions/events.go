@@ -0,0 +1,31 @@
+package config
+
+import "time"
+
+// EventsConfig ระยะเก็บรักษาเหตุการณ์ใน hissync.db ไม่ระบุ events ใน config.json คือเก็บทุกเหตุการณ์
+// เหตุการณ์ที่ยังค้างส่งหรือถูกกักไว้รออนุมัติจะไม่ถูกลบ ค่าที่เป็น 0 คือไม่จำกัด
+type EventsConfig struct {
+	// MaxAgeDays ลบเหตุการณ์ที่บันทึกไว้นานกว่าจำนวนวันนี้
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// MaxEvents เก็บเหตุการณ์ล่าสุดไม่เกินจำนวนนี้
+	MaxEvents int `json:"max_events,omitempty"`
+}
+
+// EventsRetention คืนเวลาที่เหตุการณ์ซึ่งบันทึกก่อนหน้านั้นถูกลบได้ (ค่าศูนย์คือไม่จำกัดอายุ)
+// และจำนวนเหตุการณ์สูงสุดที่เก็บ (0 คือไม่จำกัด)
+func (c *Config) EventsRetention(now time.Time) (before time.Time, keep int) {
+	if c == nil || c.Events == nil {
+		return time.Time{}, 0
+	}
+	if c.Events.MaxAgeDays > 0 {
+		before = now.AddDate(0, 0, -c.Events.MaxAgeDays)
+	}
+	return before, c.Events.MaxEvents
+}
+
+func (e *EventsConfig) problems() []string {
+	if e.MaxAgeDays < 0 || e.MaxEvents < 0 {
+		return []string{"max_age_days และ max_events ต้องไม่ติดลบ"}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ค่าเริ่มต้นของไฟล์ log ของโปรแกรม
+const (
+	DefaultLogFile       = "logs/hissync.log"
+	defaultLogMaxSizeMB  = 10
+	defaultLogMaxAgeDays = 30
+	defaultLogMaxBackups = 10
+)
+
+// LogConfig การตั้งค่า log ของโปรแกรม (หมุนไฟล์ตามขนาดและอายุ)
+type LogConfig struct {
+	File       string `json:"file,omitempty"`
+	Level      string `json:"level,omitempty"` // debug, info, warn, error
+	MaxSizeMB  int    `json:"max_size_mb,omitempty"`
+	MaxAgeDays int    `json:"max_age_days,omitempty"`
+	MaxBackups int    `json:"max_backups,omitempty"`
+}
+
+// LogSettings คืนการตั้งค่า log พร้อมค่าเริ่มต้นสำหรับฟิลด์ที่ไม่ได้ระบุ
+func (c *Config) LogSettings() LogConfig {
+	var l LogConfig
+	if c != nil && c.Log != nil {
+		l = *c.Log
+	}
+	if l.File == "" {
+		l.File = DefaultLogFile
+	}
+	if l.Level == "" {
+		l.Level = "info"
+	}
+	if l.MaxSizeMB <= 0 {
+		l.MaxSizeMB = defaultLogMaxSizeMB
+	}
+	if l.MaxAgeDays <= 0 {
+		l.MaxAgeDays = defaultLogMaxAgeDays
+	}
+	if l.MaxBackups <= 0 {
+		l.MaxBackups = defaultLogMaxBackups
+	}
+	return l
+}
+
+// SlogLevel แปลง level เป็น slog.Level
+func (l LogConfig) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(l.Level))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("level %q ไม่ถูกต้อง (ใช้ได้: debug, info, warn, error)", l.Level)
+	}
+	return level, nil
+}
+
+func (l *LogConfig) problems() []string {
+	var problems []string
+	if l.Level != "" {
+		if _, err := l.SlogLevel(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if l.MaxSizeMB < 0 || l.MaxAgeDays < 0 || l.MaxBackups < 0 {
+		problems = append(problems, "max_size_mb, max_age_days และ max_backups ต้องไม่ติดลบ")
+	}
+	return problems
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// MonitorConfig ตัวรับ HTTP สำหรับตรวจสุขภาพและเก็บ metrics (/healthz, /readyz, /metrics)
+// ไม่ระบุ monitor ใน config.json คือไม่เปิดตัวรับ
+type MonitorConfig struct {
+	// Listen ที่อยู่ที่รับการเชื่อมต่อ เช่น "127.0.0.1:9187"
+	Listen string `json:"listen"`
+}
+
+func (m *MonitorConfig) problems() []string {
+	if m.Listen == "" {
+		return []string{"ไม่ได้ระบุ listen"}
+	}
+	if _, port, err := net.SplitHostPort(m.Listen); err != nil || port == "" {
+		return []string{fmt.Sprintf("listen %q ไม่ถูกต้อง (ตัวอย่าง: 127.0.0.1:9187)", m.Listen)}
+	}
+	return nil
+}
+
+// ค่าเริ่มต้นของเกณฑ์เตือนความล่าช้า
+const (
+	defaultCaptureLagWarning  = time.Minute
+	defaultDeliveryLagWarning = 5 * time.Minute
+)
+
+// LagWarning เกณฑ์ความล่าช้าที่ถือว่าผิดปกติ (แสดงสีเตือนและ /readyz ตอบ 503)
+type LagWarning struct {
+	// CaptureSeconds การอ่านตามหลังต้นทางเกินกี่วินาที
+	CaptureSeconds int `json:"capture_seconds,omitempty"`
+	// DeliverySeconds รายการค้างส่งที่เก่าที่สุดรอนานเกินกี่วินาที
+	DeliverySeconds int `json:"delivery_seconds,omitempty"`
+}
+
+// LagThresholds คืนเกณฑ์เตือนความล่าช้า ใช้ค่าเริ่มต้นถ้าไม่ได้ระบุ
+func (c *Config) LagThresholds() (capture, delivery time.Duration) {
+	capture, delivery = defaultCaptureLagWarning, defaultDeliveryLagWarning
+	if c.LagWarning != nil {
+		if c.LagWarning.CaptureSeconds > 0 {
+			capture = time.Duration(c.LagWarning.CaptureSeconds) * time.Second
+		}
+		if c.LagWarning.DeliverySeconds > 0 {
+			delivery = time.Duration(c.LagWarning.DeliverySeconds) * time.Second
+		}
+	}
+	return capture, delivery
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ประเภทฐานข้อมูลที่รองรับ (ค่าใน dbtype)
+const (
+	DBTypePostgreSQL = "PostgreSQL"
+	DBTypeMySQL      = "MySQL"
+	DBTypeSQLServer  = "Microsoft SQL Server"
+	DBTypeMongoDB    = "MongoDB"
+)
+
+// DBTypes รายการประเภทฐานข้อมูลตามลำดับที่แสดงในฟอร์ม
+var DBTypes = []string{DBTypePostgreSQL, DBTypeMySQL, DBTypeSQLServer, DBTypeMongoDB}
+
+// วิธีอ่านการเปลี่ยนแปลง (ค่าใน engine)
+const (
+	EngineBinlog      = "binlog"       // MySQL binlog replication
+	EnginePostgresLog = "postgres_log" // อ่านไฟล์ Log ของ PostgreSQL
+	EngineNone        = "none"         // ตรวจสอบการเชื่อมต่ออย่างเดียว
+)
+
+// engines ที่ใช้ได้กับแต่ละประเภทฐานข้อมูล (ตัวแรกคือค่าเริ่มต้น)
+var engines = map[string][]string{
+	DBTypeMySQL:      {EngineBinlog, EngineNone},
+	DBTypePostgreSQL: {EnginePostgresLog, EngineNone},
+	DBTypeSQLServer:  {EngineNone},
+	DBTypeMongoDB:    {EngineNone},
+}
+
+// พอร์ตเริ่มต้นของแต่ละประเภทฐานข้อมูล
+var defaultPorts = map[string]string{
+	DBTypePostgreSQL: "5432",
+	DBTypeMySQL:      "3306",
+	DBTypeSQLServer:  "1433",
+	DBTypeMongoDB:    "27017",
+}
+
+// DefaultProfileName ชื่อโปรไฟล์ที่ได้จากการย้าย config.json รูปแบบการเชื่อมต่อเดียว
+const DefaultProfileName = "default"
+
+// DefaultStateFile ชื่อไฟล์เก็บตำแหน่งล่าสุดที่อ่านถึงของโปรไฟล์ default
+const DefaultStateFile = "state.json"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// Profile การเชื่อมต่อแหล่งข้อมูลหนึ่งรายการ พร้อมตาราง state file และวิธีอ่านการเปลี่ยนแปลงของตัวเอง
+type Profile struct {
+	Name            string `json:"name"`
+	Disabled        bool   `json:"disabled,omitempty"`
+	DBType          string `json:"dbtype"`
+	Engine          string `json:"engine,omitempty"`
+	Host            string `json:"host"`
+	Port            string `json:"port"`
+	Username        string `json:"username"`
+	Password        Secret `json:"password"`
+	DBName          string `json:"dbname"`
+	LogFilePath     string `json:"log_file_path"`
+	StateFile       string `json:"state_file"`
+	TableConfigFile string `json:"table_config_file,omitempty"`
+	// TLS การเข้ารหัสการเชื่อมต่อ ไม่ระบุคือไม่เข้ารหัส
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// SSH เชื่อมต่อผ่าน SSH tunnel ไม่ระบุคือเชื่อมต่อตรง
+	SSH *SSHConfig `json:"ssh,omitempty"`
+	// Replication การตั้งค่า replica สำหรับ engine binlog
+	Replication *ReplicationConfig `json:"replication,omitempty"`
+	// FilterTables เลิกใช้แล้ว ใช้ tables.json แทน (เก็บไว้เพื่อย้ายข้อมูลจากรูปแบบเก่า)
+	FilterTables []string `json:"filter_tables,omitempty"`
+}
+
+// envOverrides ฟิลด์ที่แทนค่าได้ด้วยตัวแปรสภาพแวดล้อม (ดู Config.ApplyEnv)
+var envOverrides = []struct {
+	name string
+	set  func(p *Profile, v string)
+}{
+	{"DBTYPE", func(p *Profile, v string) { p.DBType = v }},
+	{"ENGINE", func(p *Profile, v string) { p.Engine = v }},
+	{"HOST", func(p *Profile, v string) { p.Host = v }},
+	{"PORT", func(p *Profile, v string) { p.Port = v }},
+	{"USERNAME", func(p *Profile, v string) { p.Username = v }},
+	{"PASSWORD", func(p *Profile, v string) { RegisterSecret(v); p.Password = Secret(v) }},
+	{"DBNAME", func(p *Profile, v string) { p.DBName = v }},
+	{"LOG_FILE_PATH", func(p *Profile, v string) { p.LogFilePath = v }},
+	{"STATE_FILE", func(p *Profile, v string) { p.StateFile = v }},
+	{"TABLE_CONFIG_FILE", func(p *Profile, v string) { p.TableConfigFile = v }},
+	{"TLS_MODE", func(p *Profile, v string) { p.tlsSettings().Mode = v }},
+	{"TLS_CA_FILE", func(p *Profile, v string) { p.tlsSettings().CAFile = v }},
+	{"TLS_CERT_FILE", func(p *Profile, v string) { p.tlsSettings().CertFile = v }},
+	{"TLS_KEY_FILE", func(p *Profile, v string) { p.tlsSettings().KeyFile = v }},
+	{"TLS_SERVER_NAME", func(p *Profile, v string) { p.tlsSettings().ServerName = v }},
+	{"SSH_HOST", func(p *Profile, v string) { p.sshSettings().Host = v }},
+	{"SSH_PORT", func(p *Profile, v string) { p.sshSettings().Port = v }},
+	{"SSH_USER", func(p *Profile, v string) { p.sshSettings().User = v }},
+	{"SSH_PASSWORD", func(p *Profile, v string) { RegisterSecret(v); p.sshSettings().Password = Secret(v) }},
+	{"SSH_KEY_FILE", func(p *Profile, v string) { p.sshSettings().KeyFile = v }},
+	{"REPLICATION_FLAVOR", func(p *Profile, v string) { p.replicationSettings().Flavor = v }},
+	{"REPLICATION_SERVER_ID", func(p *Profile, v string) {
+		id, _ := strconv.ParseUint(v, 10, 32)
+		p.replicationSettings().ServerID = uint32(id)
+	}},
+}
+
+// envChecks ตรวจค่าของตัวแปรสภาพแวดล้อมก่อนใช้ ฟิลด์ที่ไม่อยู่ในนี้รับทุกค่า
+var envChecks = map[string]func(v string) error{
+	"REPLICATION_SERVER_ID": func(v string) error {
+		if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return fmt.Errorf("%q ไม่ใช่ตัวเลข 0 ถึง 4294967295", v)
+		}
+		return nil
+	},
+}
+
+// envName ชื่อโปรไฟล์ในรูปแบบที่ใช้ในชื่อตัวแปรสภาพแวดล้อม
+func (p *Profile) envName() string {
+	return strings.ToUpper(strings.ReplaceAll(p.Name, "-", "_"))
+}
+
+// ApplyDefaults เติมค่าเริ่มต้นให้ฟิลด์ที่ไม่ได้ระบุ
+func (p *Profile) ApplyDefaults() {
+	if p.Port == "" {
+		p.Port = defaultPorts[p.DBType]
+	}
+	if p.Engine == "" && len(engines[p.DBType]) > 0 {
+		p.Engine = engines[p.DBType][0]
+	}
+	if p.StateFile == "" && p.Name != "" {
+		p.StateFile = fmt.Sprintf("state_%s.json", p.Name)
+	}
+	if p.TableConfigFile == "" {
+		p.TableConfigFile = TableConfigFile
+	}
+}
+
+// Validate ตรวจสอบฟิลด์ที่จำเป็นตามประเภทฐานข้อมูล
+func (p *Profile) Validate() error {
+	if problems := p.problems(); len(problems) > 0 {
+		return &ValidationError{File: ConfigFile, Problems: problems}
+	}
+	return nil
+}
+
+func (p *Profile) problems() []string {
+	var problems []string
+	require := func(value, name string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, fmt.Sprintf("ไม่ได้ระบุ %s", name))
+		}
+	}
+
+	if p.Name == "" {
+		problems = append(problems, "ไม่ได้ระบุ name")
+	} else if !profileNamePattern.MatchString(p.Name) {
+		problems = append(problems, fmt.Sprintf("name %q ใช้ได้เฉพาะ A-Z a-z 0-9 _ -", p.Name))
+	}
+	if _, ok := defaultPorts[p.DBType]; !ok {
+		if p.DBType == "" {
+			problems = append(problems, "ไม่ได้ระบุ dbtype")
+		} else {
+			problems = append(problems, fmt.Sprintf("ไม่รองรับ dbtype %q (ใช้ได้: %s)", p.DBType, strings.Join(DBTypes, ", ")))
+		}
+	} else if !p.supportsEngine(p.Engine) {
+		problems = append(problems, fmt.Sprintf("engine %q ใช้กับ %s ไม่ได้ (ใช้ได้: %s)",
+			p.Engine, p.DBType, strings.Join(engines[p.DBType], ", ")))
+	}
+	require(p.Host, "host")
+	if port, err := strconv.Atoi(p.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port %q ไม่ถูกต้อง", p.Port))
+	}
+
+	switch p.DBType {
+	case DBTypeMySQL, DBTypeSQLServer:
+		require(p.Username, "username")
+		require(p.DBName, "dbname")
+	case DBTypePostgreSQL:
+		require(p.Username, "username")
+		require(p.DBName, "dbname")
+		if p.Engine == EnginePostgresLog {
+			require(p.LogFilePath, "log_file_path")
+		}
+	case DBTypeMongoDB:
+		require(p.DBName, "dbname")
+	}
+	if p.TLS != nil {
+		problems = append(problems, p.TLS.problems()...)
+	}
+	if p.SSH != nil {
+		problems = append(problems, p.SSH.problems()...)
+	}
+	if p.Replication != nil {
+		problems = append(problems, p.Replication.problems()...)
+	}
+	return problems
+}
+
+func (p *Profile) supportsEngine(engine string) bool {
+	for _, e := range engines[p.DBType] {
+		if e == engine {
+			return true
+		}
+	}
+	return false
+}
+
+// HasTransactions บอกว่า engine ของโปรไฟล์ระบุธุรกรรมและจุดสิ้นสุดของธุรกรรม (binlog)
+// ไฟล์ Log ของ PostgreSQL ไม่มีรหัสธุรกรรม
+func (p *Profile) HasTransactions() bool {
+	return p.Engine == EngineBinlog
+}
+
+// Engines คืนรายการ engine ที่ใช้ได้กับประเภทฐานข้อมูล
+func Engines(dbType string) []string {
+	return engines[dbType]
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplicaIDFile ไฟล์เก็บ server_id ที่สร้างอัตโนมัติของแต่ละโปรไฟล์ในเครื่องนี้
+const ReplicaIDFile = "replica_ids.json"
+
+// รูปแบบ binlog ของเซิร์ฟเวอร์ (ค่าใน replication.flavor)
+const (
+	FlavorMySQL   = "mysql"
+	FlavorMariaDB = "mariadb"
+)
+
+// Flavors รายการรูปแบบ binlog ที่รองรับ
+var Flavors = []string{FlavorMySQL, FlavorMariaDB}
+
+// ช่วงของ server_id ที่สร้างอัตโนมัติ หลีกเลี่ยงเลขน้อยที่ผู้ดูแลมักตั้งให้เซิร์ฟเวอร์และ replica เอง
+const (
+	minAutoServerID = 1 << 20
+	maxAutoServerID = 1<<31 - 1
+)
+
+// ReplicationConfig การเชื่อมต่อเป็น replica เพื่ออ่าน binlog (engine binlog)
+// ไม่ระบุ replication ใน config.json คือใช้ค่าเริ่มต้นทั้งหมด
+type ReplicationConfig struct {
+	// Flavor รูปแบบ binlog ว่างคือตรวจจากเวอร์ชันของเซิร์ฟเวอร์
+	Flavor string `json:"flavor,omitempty"`
+	// Port พอร์ตที่ใช้อ่าน binlog ว่างคือ port ของโปรไฟล์
+	Port string `json:"port,omitempty"`
+	// ServerID ต้องไม่ซ้ำกับเซิร์ฟเวอร์และ replica อื่น 0 คือสร้างอัตโนมัติและเก็บใน replica_ids.json
+	ServerID uint32 `json:"server_id,omitempty"`
+	Charset  string `json:"charset,omitempty"`
+	// HeartbeatSeconds ให้เซิร์ฟเวอร์ส่ง heartbeat ทุกกี่วินาทีเมื่อไม่มีการเปลี่ยนแปลง 0 คือไม่ใช้
+	HeartbeatSeconds int `json:"heartbeat_seconds,omitempty"`
+	// ReadTimeoutSeconds ตัดการเชื่อมต่อเมื่อไม่ได้รับข้อมูลนานเกินกี่วินาที 0 คือไม่จำกัด
+	ReadTimeoutSeconds int `json:"read_timeout_seconds,omitempty"`
+}
+
+// Replica คืนการตั้งค่า replication ของโปรไฟล์พร้อมค่าเริ่มต้น
+// server_id ยังเป็น 0 ถ้าให้สร้างอัตโนมัติ และ flavor ว่างถ้าให้ตรวจจากเซิร์ฟเวอร์ (ดู FlavorFromVersion)
+func (p *Profile) Replica() ReplicationConfig {
+	var r ReplicationConfig
+	if p.Replication != nil {
+		r = *p.Replication
+	}
+	if r.Port == "" {
+		r.Port = p.Port
+	}
+	return r
+}
+
+// FlavorFromVersion คืนรูปแบบ binlog จากเวอร์ชันของเซิร์ฟเวอร์ (SELECT VERSION() หรือ server version ใน binlog)
+func FlavorFromVersion(version string) string {
+	if strings.Contains(strings.ToLower(version), "mariadb") {
+		return FlavorMariaDB
+	}
+	return FlavorMySQL
+}
+
+// HeartbeatPeriod คืนช่วงเวลา heartbeat
+func (r ReplicationConfig) HeartbeatPeriod() time.Duration {
+	return time.Duration(r.HeartbeatSeconds) * time.Second
+}
+
+// ReadTimeout คืนเวลารอข้อมูลสูงสุด
+func (r ReplicationConfig) ReadTimeout() time.Duration {
+	return time.Duration(r.ReadTimeoutSeconds) * time.Second
+}
+
+// PortNumber คืนพอร์ตที่ใช้อ่าน binlog เป็นตัวเลข
+func (r ReplicationConfig) PortNumber() uint16 {
+	port, _ := strconv.ParseUint(r.Port, 10, 16)
+	return uint16(port)
+}
+
+// replicationSettings คืน p.Replication สร้างใหม่ถ้ายังไม่มี (ใช้กับตัวแปรสภาพแวดล้อม)
+func (p *Profile) replicationSettings() *ReplicationConfig {
+	if p.Replication == nil {
+		p.Replication = &ReplicationConfig{}
+	}
+	return p.Replication
+}
+
+func (r *ReplicationConfig) problems() []string {
+	var problems []string
+	if r.Flavor != "" && r.Flavor != FlavorMySQL && r.Flavor != FlavorMariaDB {
+		problems = append(problems, fmt.Sprintf("ไม่รองรับ replication.flavor %q (ใช้ได้: %s)", r.Flavor, strings.Join(Flavors, ", ")))
+	}
+	if r.Port != "" {
+		if port, err := strconv.Atoi(r.Port); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("replication.port %q ไม่ถูกต้อง", r.Port))
+		}
+	}
+	if r.HeartbeatSeconds < 0 {
+		problems = append(problems, "replication.heartbeat_seconds ต้องไม่ติดลบ")
+	}
+	if r.ReadTimeoutSeconds < 0 {
+		problems = append(problems, "replication.read_timeout_seconds ต้องไม่ติดลบ")
+	}
+	if r.ReadTimeoutSeconds > 0 && r.HeartbeatSeconds > 0 && r.ReadTimeoutSeconds <= r.HeartbeatSeconds {
+		problems = append(problems, "replication.read_timeout_seconds ต้องมากกว่า heartbeat_seconds")
+	}
+	return problems
+}
+
+// replicaIDs ป้องกันการสร้าง server_id ซ้อนกันเมื่อหลายโปรไฟล์เริ่มพร้อมกัน
+var replicaIDs sync.Mutex
+
+// ReplicaIDFilePath คืนตำแหน่งไฟล์ server_id (เปลี่ยนได้ด้วย HISSYNC_REPLICA_ID_FILE)
+func ReplicaIDFilePath() string {
+	if path := os.Getenv("HISSYNC_REPLICA_ID_FILE"); path != "" {
+		return path
+	}
+	return ReplicaIDFile
+}
+
+// ReplicaServerID คืน server_id ที่โปรไฟล์ใช้เมื่ออ่าน binlog
+// ถ้าไม่ได้กำหนด replication.server_id จะสุ่มครั้งแรกแล้วเก็บใน replica_ids.json เพื่อให้เครื่องนี้ใช้เลขเดิมเสมอ
+// และไม่ชนกับ HISSYNC เครื่องอื่นที่อ่านจากเซิร์ฟเวอร์เดียวกัน
+func ReplicaServerID(p *Profile) (uint32, error) {
+	if id := p.Replica().ServerID; id != 0 {
+		return id, nil
+	}
+
+	replicaIDs.Lock()
+	defer replicaIDs.Unlock()
+	path := ReplicaIDFilePath()
+	ids := make(map[string]uint32)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return 0, fmt.Errorf("%s ไม่ถูกต้อง: %v", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	if id, ok := ids[p.Name]; ok {
+		return id, nil
+	}
+
+	used := make(map[uint32]bool, len(ids))
+	for _, id := range ids {
+		used[id] = true
+	}
+	var id uint32
+	for id == 0 || used[id] {
+		var b [4]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+		id = minAutoServerID + binary.BigEndian.Uint32(b[:])%(maxAutoServerID-minAutoServerID)
+	}
+	ids[p.Name] = id
+	data, err := json.MarshalIndent(ids, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return 0, fmt.Errorf("ไม่สามารถบันทึก server_id ลง %s: %v", path, err)
+	}
+	return id, nil
+}
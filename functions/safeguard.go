@@ -0,0 +1,80 @@
+package config
+
+import "fmt"
+
+// ตารางที่มีแถวน้อยกว่านี้ไม่ใช้เกณฑ์ร้อยละต่อชั่วโมงโดยค่าเริ่มต้น (ตารางเล็กเปลี่ยนเกินร้อยละได้ง่าย)
+const defaultSafeguardMinTableRows = 1000
+
+// SafeguardConfig เกณฑ์กันการเปลี่ยนแปลงจำนวนมากผิดปกติ เช่น สคริปต์ที่ลบข้อมูลทั้งตาราง
+// ธุรกรรมที่เกินเกณฑ์จะถูกกักไว้ไม่ส่งไปยังปลายทางจนกว่าจะอนุมัติ
+// โปรไฟล์ที่ไม่มีรหัสธุรกรรม (ไฟล์ Log ของ PostgreSQL) นับแต่ละเหตุการณ์เป็นหนึ่งธุรกรรม จึงใช้ได้เฉพาะเกณฑ์ร้อยละ
+// เกณฑ์ที่เป็น 0 คือไม่ใช้ ไม่ระบุ safeguard ใน config.json คือส่งทุกธุรกรรมทันที
+type SafeguardConfig struct {
+	// MaxDeletesPerTransaction จำนวนแถวที่ลบในธุรกรรมเดียวสูงสุด
+	MaxDeletesPerTransaction int `json:"max_deletes_per_transaction,omitempty"`
+	// MaxRowsPerTransaction จำนวนแถวที่เปลี่ยน (ทุกประเภท) ในธุรกรรมเดียวสูงสุด
+	MaxRowsPerTransaction int `json:"max_rows_per_transaction,omitempty"`
+	// MaxTablePercentPerHour ร้อยละสูงสุดของแถวในตารางที่ถูก UPDATE หรือ DELETE ภายในหนึ่งชั่วโมง
+	MaxTablePercentPerHour float64 `json:"max_table_percent_per_hour,omitempty"`
+	// MinTableRows ตารางที่มีแถวน้อยกว่านี้ไม่ใช้เกณฑ์ร้อยละ (ค่าเริ่มต้น 1000)
+	MinTableRows int `json:"min_table_rows,omitempty"`
+	// Profiles ใช้เฉพาะโปรไฟล์เหล่านี้ ว่างคือทุกโปรไฟล์
+	Profiles []string `json:"profiles,omitempty"`
+}
+
+// SafeguardFor คืนเกณฑ์ที่ใช้กับโปรไฟล์ (nil ถ้าไม่ได้กำหนดหรือไม่ใช้กับโปรไฟล์นี้)
+func (c *Config) SafeguardFor(profile string) *SafeguardConfig {
+	if c == nil || c.Safeguard == nil || !c.Safeguard.covers(profile) {
+		return nil
+	}
+	return c.Safeguard
+}
+
+// covers บอกว่าเกณฑ์ใช้กับโปรไฟล์นี้
+func (s *SafeguardConfig) covers(profile string) bool {
+	if len(s.Profiles) == 0 {
+		return true
+	}
+	for _, name := range s.Profiles {
+		if name == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// TableRowsMinimum คืนจำนวนแถวขั้นต่ำของตารางที่ใช้เกณฑ์ร้อยละ
+func (s *SafeguardConfig) TableRowsMinimum() int64 {
+	if s.MinTableRows > 0 {
+		return int64(s.MinTableRows)
+	}
+	return defaultSafeguardMinTableRows
+}
+
+func (s *SafeguardConfig) problems(profiles []Profile, names map[string]int) []string {
+	var problems []string
+	if s.MaxDeletesPerTransaction < 0 || s.MaxRowsPerTransaction < 0 || s.MinTableRows < 0 {
+		problems = append(problems, "ค่าต้องไม่ติดลบ")
+	}
+	if s.MaxTablePercentPerHour < 0 || s.MaxTablePercentPerHour > 100 {
+		problems = append(problems, "max_table_percent_per_hour ต้องอยู่ระหว่าง 0 ถึง 100")
+	}
+	if s.MaxDeletesPerTransaction == 0 && s.MaxRowsPerTransaction == 0 && s.MaxTablePercentPerHour == 0 {
+		problems = append(problems, "ไม่ได้กำหนดเกณฑ์ (max_deletes_per_transaction, max_rows_per_transaction หรือ max_table_percent_per_hour)")
+	}
+	for _, p := range s.Profiles {
+		if _, ok := names[p]; !ok {
+			problems = append(problems, fmt.Sprintf("ไม่พบโปรไฟล์ %q ใน profiles", p))
+		}
+	}
+	if s.MaxTablePercentPerHour == 0 {
+		// เกณฑ์ต่อธุรกรรมของธุรกรรมที่มีเหตุการณ์เดียวไม่มีทางเกิน
+		for _, p := range profiles {
+			if p.Engine == EnginePostgresLog && s.covers(p.Name) {
+				problems = append(problems, fmt.Sprintf(
+					"โปรไฟล์ %q ไม่มีรหัสธุรกรรม ใช้ได้เฉพาะ max_table_percent_per_hour (หรือไม่ระบุโปรไฟล์นี้ใน safeguard.profiles)", p.Name))
+			}
+		}
+	}
+	return problems
+}
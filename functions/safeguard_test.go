@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSafeguardProblems(t *testing.T) {
+	profiles := []Profile{
+		{Name: "jhcis", DBType: DBTypeMySQL, Engine: EngineBinlog},
+		{Name: "hosxp", DBType: DBTypePostgreSQL, Engine: EnginePostgresLog},
+	}
+	names := map[string]int{"jhcis": 0, "hosxp": 1}
+	tests := []struct {
+		name string
+		sg   SafeguardConfig
+		want string // ส่วนหนึ่งของปัญหา ว่างคือไม่มีปัญหา
+	}{
+		{"binlog profile only", SafeguardConfig{MaxDeletesPerTransaction: 100, Profiles: []string{"jhcis"}}, ""},
+		{"postgres log with per-transaction limits", SafeguardConfig{MaxDeletesPerTransaction: 100}, `"hosxp" ไม่มีรหัสธุรกรรม`},
+		{"postgres log listed", SafeguardConfig{MaxRowsPerTransaction: 500, Profiles: []string{"hosxp"}}, `"hosxp" ไม่มีรหัสธุรกรรม`},
+		{"postgres log with hourly percentage", SafeguardConfig{MaxDeletesPerTransaction: 100, MaxTablePercentPerHour: 20}, ""},
+		{"unknown profile", SafeguardConfig{MaxTablePercentPerHour: 20, Profiles: []string{"hdc"}}, `"hdc"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := strings.Join(tt.sg.problems(profiles, names), "; ")
+			if tt.want == "" && problems != "" {
+				t.Fatalf("problems() = %s ต้องการไม่มีปัญหา", problems)
+			}
+			if !strings.Contains(problems, tt.want) {
+				t.Fatalf("problems() = %q ต้องการปัญหาที่มี %s", problems, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// schemaTimeout เวลาสูงสุดในการอ่านโครงสร้างฐานข้อมูลหนึ่งครั้ง
+const schemaTimeout = 30 * time.Second
+
+// Column คอลัมน์ของตารางในฐานข้อมูลต้นทาง
+type Column struct {
+	Name       string
+	Type       string
+	PrimaryKey bool
+}
+
+// SchemaTable ตารางในฐานข้อมูลต้นทางพร้อมคอลัมน์ตามลำดับในตาราง
+type SchemaTable struct {
+	Database string
+	Table    string
+	Columns  []Column
+}
+
+// FullName คืนชื่อตารางแบบ database.table
+func (t SchemaTable) FullName() string {
+	return t.Database + "." + t.Table
+}
+
+// PrimaryKey คืนชื่อคอลัมน์ที่เป็น primary key ในฐานข้อมูล
+func (t SchemaTable) PrimaryKey() []string {
+	var keys []string
+	for _, c := range t.Columns {
+		if c.PrimaryKey {
+			keys = append(keys, c.Name)
+		}
+	}
+	return keys
+}
+
+// ColumnNames คืนชื่อคอลัมน์ทั้งหมด
+func (t SchemaTable) ColumnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// คำสั่งอ่านรายชื่อฐานข้อมูล (ไม่รวมฐานข้อมูลของระบบ)
+var databasesQuery = map[string]string{
+	DBTypeMySQL: `SELECT SCHEMA_NAME FROM information_schema.SCHEMATA
+		WHERE SCHEMA_NAME NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+		ORDER BY SCHEMA_NAME`,
+	DBTypePostgreSQL: `SELECT datname FROM pg_catalog.pg_database
+		WHERE NOT datistemplate AND datallowconn ORDER BY datname`,
+	DBTypeSQLServer: `SELECT name FROM sys.databases WHERE database_id > 4 ORDER BY name`,
+}
+
+// คำสั่งอ่านตารางและคอลัมน์ คืน (schema, ตาราง, คอลัมน์, ชนิด, เป็น primary key) เรียงตามตาราง schema และลำดับคอลัมน์
+// MySQL รับชื่อฐานข้อมูลเป็นพารามิเตอร์ ส่วน PostgreSQL และ SQL Server อ่านจากฐานข้อมูลที่เชื่อมต่ออยู่
+var tablesQuery = map[string]string{
+	DBTypeMySQL: `SELECT c.TABLE_SCHEMA, c.TABLE_NAME, c.COLUMN_NAME, c.COLUMN_TYPE, c.COLUMN_KEY = 'PRI'
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = ? AND t.TABLE_TYPE = 'BASE TABLE'
+		ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`,
+	DBTypePostgreSQL: `SELECT n.nspname, c.relname, a.attname, pg_catalog.format_type(a.atttypid, a.atttypmod),
+			COALESCE(a.attnum = ANY(i.indkey), false)
+		FROM pg_catalog.pg_class c
+		JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		LEFT JOIN pg_catalog.pg_index i ON i.indrelid = c.oid AND i.indisprimary
+		WHERE c.relkind IN ('r', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			AND n.nspname NOT LIKE 'pg_toast%'
+		ORDER BY c.relname, n.nspname <> 'public', n.nspname, a.attnum`,
+	DBTypeSQLServer: `SELECT SCHEMA_NAME(t.schema_id), t.name, c.name, ty.name, CAST(CASE WHEN ic.column_id IS NULL THEN 0 ELSE 1 END AS bit)
+		FROM sys.tables t
+		JOIN sys.columns c ON c.object_id = t.object_id
+		JOIN sys.types ty ON ty.user_type_id = c.user_type_id
+		LEFT JOIN sys.indexes i ON i.object_id = t.object_id AND i.is_primary_key = 1
+		LEFT JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+			AND ic.column_id = c.column_id
+		WHERE t.is_ms_shipped = 0
+		ORDER BY t.name, CASE WHEN t.schema_id = SCHEMA_ID('dbo') THEN 0 ELSE 1 END, t.schema_id, c.column_id`,
+}
+
+// ListDatabases คืนรายชื่อฐานข้อมูลบนเซิร์ฟเวอร์ของโปรไฟล์
+func ListDatabases(p *Profile) ([]string, error) {
+	query, ok := databasesQuery[p.DBType]
+	if !ok {
+		return nil, fmt.Errorf("ไม่รองรับการอ่านโครงสร้างฐานข้อมูลประเภท %s", p.DBType)
+	}
+	db, err := OpenDB(p)
+	if err != nil {
+		return nil, RedactError(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, RedactError(fmt.Errorf("ไม่สามารถอ่านรายชื่อฐานข้อมูล: %v", err))
+	}
+	defer rows.Close()
+
+	var databases []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		databases = append(databases, name)
+	}
+	return databases, rows.Err()
+}
+
+// ListTables คืนตารางและคอลัมน์ของฐานข้อมูล database เรียงตามชื่อตาราง
+// PostgreSQL และ SQL Server จะเชื่อมต่อใหม่ไปยัง database เพราะอ่าน catalog ได้เฉพาะฐานข้อมูลที่เชื่อมต่ออยู่
+func ListTables(p *Profile, database string) ([]SchemaTable, error) {
+	query, ok := tablesQuery[p.DBType]
+	if !ok {
+		return nil, fmt.Errorf("ไม่รองรับการอ่านโครงสร้างฐานข้อมูลประเภท %s", p.DBType)
+	}
+	target := *p
+	var args []interface{}
+	if p.DBType == DBTypeMySQL {
+		args = append(args, database)
+	} else {
+		target.DBName = database
+	}
+	db, err := OpenDB(&target)
+	if err != nil {
+		return nil, RedactError(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, RedactError(fmt.Errorf("ไม่สามารถอ่านตารางของ %s: %v", database, err))
+	}
+	defer rows.Close()
+	return scanTables(rows, database)
+}
+
+// scanTables รวมแถว (schema, ตาราง, คอลัมน์, ชนิด, เป็น primary key) เป็นรายการตาราง
+// tables.json อ้างอิงตารางด้วยชื่ออย่างเดียว ตารางชื่อซ้ำกันในหลาย schema จึงใช้ schema แรก (public หรือ dbo ก่อน)
+func scanTables(rows *sql.Rows, database string) ([]SchemaTable, error) {
+	var tables []SchemaTable
+	var currentSchema string
+	for rows.Next() {
+		var schema, table string
+		var col Column
+		if err := rows.Scan(&schema, &table, &col.Name, &col.Type, &col.PrimaryKey); err != nil {
+			return nil, err
+		}
+		last := len(tables) - 1
+		if last < 0 || tables[last].Table != table {
+			tables = append(tables, SchemaTable{Database: database, Table: table})
+			currentSchema = schema
+			last++
+		}
+		if schema != currentSchema {
+			continue
+		}
+		tables[last].Columns = append(tables[last].Columns, col)
+	}
+	return tables, rows.Err()
+}
+
+// คำสั่งอ่านจำนวนแถวโดยประมาณจากสถิติของฐานข้อมูล (ไม่นับแถวจริง จึงไม่ทำให้ต้นทางช้า)
+var tableRowsQuery = map[string]string{
+	DBTypeMySQL: `SELECT COALESCE(TABLE_ROWS, 0) FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`,
+	DBTypePostgreSQL: `SELECT COALESCE(MAX(c.reltuples), 0)::bigint FROM pg_catalog.pg_class c
+		WHERE c.relkind IN ('r', 'p') AND c.relname = $1`,
+}
+
+// TableRows คืนจำนวนแถวโดยประมาณของตาราง database.table
+func TableRows(p *Profile, database, table string) (int64, error) {
+	query, ok := tableRowsQuery[p.DBType]
+	if !ok {
+		return 0, fmt.Errorf("ไม่รองรับการอ่านจำนวนแถวของฐานข้อมูลประเภท %s", p.DBType)
+	}
+	target := *p
+	args := []interface{}{table}
+	if p.DBType == DBTypeMySQL {
+		args = []interface{}{database, table}
+	} else {
+		target.DBName = database
+	}
+	db, err := OpenDB(&target)
+	if err != nil {
+		return 0, RedactError(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaTimeout)
+	defer cancel()
+	var rows int64
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&rows); err != nil {
+		return 0, RedactError(fmt.Errorf("ไม่สามารถอ่านจำนวนแถวของ %s.%s: %v", database, table, err))
+	}
+	return rows, nil
+}
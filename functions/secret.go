@@ -0,0 +1,409 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// KeyFile ชื่อไฟล์กุญแจสำหรับเข้ารหัสค่าลับใน config.json
+// ควรเก็บแยกจาก config.json และจำกัดสิทธิ์ให้เฉพาะผู้ดูแลระบบ
+const KeyFile = "hissync.key"
+
+// คำนำหน้าของค่าที่ถูกเข้ารหัสแล้วใน config.json
+const secretPrefix = "enc:v1:"
+
+// ข้อความที่ใช้แทนค่าลับใน log และข้อความแจ้งข้อผิดพลาด
+const redacted = "******"
+
+// ค่าที่เข้ารหัสไว้ในไฟล์กุญแจแบบรหัสผ่าน เพื่อตรวจสอบว่ารหัสผ่านถูกต้อง
+const passphraseCheck = "hissync-key-check"
+
+// ErrPassphraseRequired ไฟล์กุญแจถูกสร้างจากรหัสผ่านผู้ดูแล แต่ยังไม่ได้ระบุรหัสผ่าน
+var ErrPassphraseRequired = errors.New("ต้องระบุรหัสผ่านผู้ดูแลเพื่อถอดรหัสการตั้งค่า (HISSYNC_PASSPHRASE)")
+
+// ErrWrongPassphrase รหัสผ่านผู้ดูแลไม่ถูกต้อง
+var ErrWrongPassphrase = errors.New("รหัสผ่านผู้ดูแลไม่ถูกต้อง")
+
+// keyFileContent โครงสร้างของไฟล์กุญแจ
+type keyFileContent struct {
+	Version int `json:"version"`
+	// KDF "none" = เก็บกุญแจไว้ในไฟล์, "scrypt" = สร้างกุญแจจากรหัสผ่านผู้ดูแลและ salt
+	KDF   string `json:"kdf"`
+	Key   string `json:"key,omitempty"`
+	Salt  string `json:"salt,omitempty"`
+	Check string `json:"check,omitempty"`
+}
+
+var keyring struct {
+	sync.Mutex
+	key        []byte
+	passphrase string
+}
+
+var secretRegistry struct {
+	sync.RWMutex
+	values map[string]bool
+}
+
+// Secret ค่าลับ เช่น รหัสผ่านฐานข้อมูลหรือ token ของปลายทาง
+// จะถูกเข้ารหัสเมื่อบันทึกเป็น JSON และแสดงเป็น ****** เมื่อพิมพ์ลง log
+type Secret string
+
+// String ไม่คืนค่าจริง เพื่อป้องกันการหลุดลง log
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// GoString ไม่คืนค่าจริงเมื่อพิมพ์ด้วย %#v
+func (s Secret) GoString() string {
+	return fmt.Sprintf("%q", s.String())
+}
+
+// Reveal คืนค่าจริง ใช้เฉพาะตอนเชื่อมต่อเท่านั้น
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// MarshalJSON เข้ารหัสค่าก่อนบันทึก
+func (s Secret) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return json.Marshal("")
+	}
+	enc, err := EncryptSecret(string(s))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON ถอดรหัสค่าที่อ่านจากไฟล์ (ค่าที่ยังไม่เข้ารหัสจะถูกใช้ตามเดิม)
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if strings.HasPrefix(v, secretPrefix) {
+		plain, err := DecryptSecret(v)
+		if err != nil {
+			return err
+		}
+		v = plain
+	}
+	RegisterSecret(v)
+	*s = Secret(v)
+	return nil
+}
+
+// SetPassphrase กำหนดรหัสผ่านผู้ดูแลสำหรับไฟล์กุญแจแบบ scrypt
+func SetPassphrase(passphrase string) {
+	keyring.Lock()
+	defer keyring.Unlock()
+	keyring.passphrase = passphrase
+	keyring.key = nil
+}
+
+// KeyFilePath คืนตำแหน่งไฟล์กุญแจ (กำหนดได้ด้วย HISSYNC_KEY_FILE)
+func KeyFilePath() string {
+	if p := os.Getenv("HISSYNC_KEY_FILE"); p != "" {
+		return p
+	}
+	return KeyFile
+}
+
+func currentPassphrase() string {
+	if keyring.passphrase != "" {
+		return keyring.passphrase
+	}
+	return os.Getenv("HISSYNC_PASSPHRASE")
+}
+
+// loadKey โหลดกุญแจจากไฟล์ คืน os.ErrNotExist ถ้ายังไม่มีไฟล์กุญแจ
+func loadKey() ([]byte, error) {
+	keyring.Lock()
+	defer keyring.Unlock()
+	if keyring.key != nil {
+		return keyring.key, nil
+	}
+
+	data, err := os.ReadFile(KeyFilePath())
+	if err != nil {
+		return nil, err
+	}
+	var kf keyFileContent
+	if err := json.Unmarshal(data, &kf); err != nil {
+		return nil, fmt.Errorf("ไฟล์กุญแจ %s ไม่ถูกต้อง: %v", KeyFilePath(), err)
+	}
+
+	var key []byte
+	switch kf.KDF {
+	case "none":
+		key, err = base64.StdEncoding.DecodeString(kf.Key)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("ไฟล์กุญแจ %s ไม่ถูกต้อง", KeyFilePath())
+		}
+	case "scrypt":
+		passphrase := currentPassphrase()
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+		salt, err := base64.StdEncoding.DecodeString(kf.Salt)
+		if err != nil {
+			return nil, fmt.Errorf("ไฟล์กุญแจ %s ไม่ถูกต้อง", KeyFilePath())
+		}
+		key, err = deriveKey(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		if check, err := decryptWithKey(key, kf.Check); err != nil || check != passphraseCheck {
+			return nil, ErrWrongPassphrase
+		}
+	default:
+		return nil, fmt.Errorf("ไฟล์กุญแจ %s ใช้ kdf %q ที่ไม่รองรับ", KeyFilePath(), kf.KDF)
+	}
+
+	keyring.key = key
+	return key, nil
+}
+
+// ensureKey โหลดกุญแจ หรือสร้างไฟล์กุญแจใหม่ถ้ายังไม่มี
+func ensureKey() ([]byte, error) {
+	key, err := loadKey()
+	if err == nil || !os.IsNotExist(err) {
+		return key, err
+	}
+	kf, key, err := newKeyFile(currentPassphrase())
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(KeyFilePath(), kf); err != nil {
+		return nil, err
+	}
+	keyring.Lock()
+	keyring.key = key
+	keyring.Unlock()
+	return key, nil
+}
+
+// newKeyFile สร้างกุญแจใหม่ ถ้ามี passphrase จะสร้างกุญแจจากรหัสผ่านแทนการเก็บกุญแจในไฟล์
+func newKeyFile(passphrase string) (keyFileContent, []byte, error) {
+	if passphrase == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return keyFileContent{}, nil, err
+		}
+		return keyFileContent{Version: 1, KDF: "none", Key: base64.StdEncoding.EncodeToString(key)}, key, nil
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return keyFileContent{}, nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return keyFileContent{}, nil, err
+	}
+	check, err := encryptWithKey(key, passphraseCheck)
+	if err != nil {
+		return keyFileContent{}, nil, err
+	}
+	return keyFileContent{
+		Version: 1,
+		KDF:     "scrypt",
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Check:   check,
+	}, key, nil
+}
+
+func writeKeyFile(path string, kf keyFileContent) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกไฟล์กุญแจ: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// KeyUsesPassphrase ตรวจสอบว่าไฟล์กุญแจต้องใช้รหัสผ่านผู้ดูแลหรือไม่
+func KeyUsesPassphrase() bool {
+	data, err := os.ReadFile(KeyFilePath())
+	if err != nil {
+		return false
+	}
+	var kf keyFileContent
+	return json.Unmarshal(data, &kf) == nil && kf.KDF == "scrypt"
+}
+
+// EncryptSecret เข้ารหัสค่าด้วย AES-256-GCM (สร้างไฟล์กุญแจให้ถ้ายังไม่มี)
+func EncryptSecret(plain string) (string, error) {
+	key, err := ensureKey()
+	if err != nil {
+		return "", err
+	}
+	return encryptWithKey(key, plain)
+}
+
+// DecryptSecret ถอดรหัสค่าที่เข้ารหัสด้วย EncryptSecret
+func DecryptSecret(value string) (string, error) {
+	key, err := loadKey()
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("ไม่พบไฟล์กุญแจ %s สำหรับถอดรหัสการตั้งค่า", KeyFilePath())
+	}
+	if err != nil {
+		return "", err
+	}
+	plain, err := decryptWithKey(key, value)
+	if err != nil {
+		return "", fmt.Errorf("ถอดรหัสค่าลับไม่สำเร็จ (กุญแจไม่ตรงกับ config.json?): %v", err)
+	}
+	return plain, nil
+}
+
+func encryptWithKey(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptWithKey(key []byte, value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ข้อมูลสั้นเกินไป")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// RotateKey สร้างกุญแจใหม่แล้วเข้ารหัสค่าลับใน config.json ใหม่ทั้งหมด
+// ถ้าระบุ newPassphrase กุญแจใหม่จะสร้างจากรหัสผ่านผู้ดูแลแทนการเก็บในไฟล์
+func RotateKey(configPath, newPassphrase string) error {
+	// ถอดรหัสด้วยกุญแจเดิม (ค่าใน Secret จะเป็นค่าจริงในหน่วยความจำ)
+	cfg, err := ReadConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	kf, key, err := newKeyFile(newPassphrase)
+	if err != nil {
+		return err
+	}
+
+	keyPath := KeyFilePath()
+	backup := keyPath + ".old"
+	if _, err := os.Stat(keyPath); err == nil {
+		if err := os.Rename(keyPath, backup); err != nil {
+			return fmt.Errorf("ไม่สามารถสำรองไฟล์กุญแจเดิม: %v", err)
+		}
+	}
+	restore := func() {
+		os.Rename(backup, keyPath)
+		keyring.Lock()
+		keyring.key = nil
+		keyring.Unlock()
+	}
+
+	if err := writeKeyFile(keyPath, kf); err != nil {
+		restore()
+		return err
+	}
+	keyring.Lock()
+	keyring.key = key
+	keyring.passphrase = newPassphrase
+	keyring.Unlock()
+
+	if err := SaveConfig(configPath, cfg); err != nil {
+		restore()
+		return err
+	}
+	os.Remove(backup)
+	return nil
+}
+
+// RegisterSecret จดจำค่าลับเพื่อปิดบังใน log และข้อความแจ้งข้อผิดพลาด
+func RegisterSecret(value string) {
+	if len(value) < 3 {
+		return
+	}
+	secretRegistry.Lock()
+	defer secretRegistry.Unlock()
+	if secretRegistry.values == nil {
+		secretRegistry.values = make(map[string]bool)
+	}
+	secretRegistry.values[value] = true
+}
+
+var (
+	// password=xxx ใน DSN ของ PostgreSQL
+	dsnPasswordPattern = regexp.MustCompile(`(?i)(password=)[^\s;&]+`)
+	// user:pass@ ใน URI เช่น mongodb:// หรือ sqlserver://
+	uriPasswordPattern = regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+@`)
+)
+
+// Redact ปิดบังค่าลับที่รู้จักและรหัสผ่านในรูปแบบ DSN/URI
+func Redact(s string) string {
+	secretRegistry.RLock()
+	for v := range secretRegistry.values {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	secretRegistry.RUnlock()
+	s = dsnPasswordPattern.ReplaceAllString(s, "${1}"+redacted)
+	s = uriPasswordPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+	return s
+}
+
+// RedactError คืน error ที่ข้อความถูกปิดบังค่าลับแล้ว
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := Redact(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return errors.New(msg)
+}
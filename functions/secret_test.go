@@ -0,0 +1,225 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useKeyFile ใช้ไฟล์กุญแจในโฟลเดอร์ชั่วคราวและล้างกุญแจที่จำไว้ก่อนและหลังการทดสอบ
+func useKeyFile(t *testing.T) (dir string) {
+	t.Helper()
+	dir = t.TempDir()
+	t.Setenv("HISSYNC_KEY_FILE", filepath.Join(dir, KeyFile))
+	t.Setenv("HISSYNC_PASSPHRASE", "")
+	SetPassphrase("")
+	t.Cleanup(func() { SetPassphrase("") })
+	return dir
+}
+
+func TestEncryptDecryptSecret(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		plain      string
+	}{
+		{"key file", "", "s3cret"},
+		{"passphrase", "admin-pass", "s3cret"},
+		{"empty value", "", ""},
+		{"thai text", "", "รหัสผ่าน"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeyFile(t)
+			SetPassphrase(tt.passphrase)
+			enc, err := EncryptSecret(tt.plain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(enc, secretPrefix) || (tt.plain != "" && strings.Contains(enc, tt.plain)) {
+				t.Fatalf("EncryptSecret(%q) = %q", tt.plain, enc)
+			}
+			if again, _ := EncryptSecret(tt.plain); again == enc {
+				t.Fatal("เข้ารหัสค่าเดิมได้ผลเหมือนเดิม (nonce ซ้ำ)")
+			}
+			if got := KeyUsesPassphrase(); got != (tt.passphrase != "") {
+				t.Fatalf("KeyUsesPassphrase() = %v", got)
+			}
+
+			// โหลดกุญแจใหม่จากไฟล์ เหมือนเปิดโปรแกรมอีกครั้ง
+			SetPassphrase(tt.passphrase)
+			plain, err := DecryptSecret(enc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plain != tt.plain {
+				t.Fatalf("DecryptSecret() = %q ต้องการ %q", plain, tt.plain)
+			}
+		})
+	}
+}
+
+func TestDecryptSecretErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, enc string) string
+		want  error
+		// wantMsg ข้อความที่ต้องพบเมื่อไม่ได้ตรวจด้วย errors.Is
+		wantMsg string
+	}{
+		{"missing passphrase", func(t *testing.T, enc string) string {
+			SetPassphrase("")
+			return enc
+		}, ErrPassphraseRequired, ""},
+		{"wrong passphrase", func(t *testing.T, enc string) string {
+			SetPassphrase("wrong")
+			return enc
+		}, ErrWrongPassphrase, ""},
+		{"missing key file", func(t *testing.T, enc string) string {
+			os.Remove(KeyFilePath())
+			SetPassphrase("admin-pass")
+			return enc
+		}, nil, "ไม่พบไฟล์กุญแจ"},
+		{"tampered value", func(t *testing.T, enc string) string {
+			SetPassphrase("admin-pass")
+			return enc[:len(enc)-4] + "AAAA"
+		}, nil, "ถอดรหัสค่าลับไม่สำเร็จ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeyFile(t)
+			SetPassphrase("admin-pass")
+			enc, err := EncryptSecret("s3cret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = DecryptSecret(tt.setup(t, enc))
+			switch {
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Fatalf("DecryptSecret() = %v ต้องการ %v", err, tt.want)
+			case tt.want == nil && (err == nil || !strings.Contains(err.Error(), tt.wantMsg)):
+				t.Fatalf("DecryptSecret() = %v ต้องการข้อผิดพลาดที่มี %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestSecretJSON(t *testing.T) {
+	useKeyFile(t)
+	data, err := json.Marshal(struct {
+		Password Secret `json:"password"`
+		Empty    Secret `json:"empty"`
+	}{Password: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "s3cret") || !strings.Contains(string(data), `"empty":""`) {
+		t.Fatalf("Marshal = %s", data)
+	}
+
+	var decoded struct {
+		Password Secret `json:"password"`
+		Plain    Secret `json:"plain"`
+	}
+	if err := json.Unmarshal([]byte(strings.Replace(string(data), `"empty"`, `"plain"`, 1)), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Password.Reveal() != "s3cret" {
+		t.Fatalf("Unmarshal = %q", decoded.Password.Reveal())
+	}
+	// ค่าที่ยังไม่เข้ารหัสใช้ตามเดิม
+	if err := json.Unmarshal([]byte(`{"plain":"plain-text"}`), &decoded); err != nil || decoded.Plain.Reveal() != "plain-text" {
+		t.Fatalf("Unmarshal plain = %q, %v", decoded.Plain.Reveal(), err)
+	}
+	if s := Secret("s3cret"); s.String() != redacted || Redact("password s3cret") != "password "+redacted {
+		t.Fatalf("ค่าลับหลุดลงข้อความ: %v / %s", s, Redact("password s3cret"))
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	tests := []struct {
+		name          string
+		oldPassphrase string
+		newPassphrase string
+	}{
+		{"key file to key file", "", ""},
+		{"key file to passphrase", "", "new-pass"},
+		{"passphrase to key file", "old-pass", ""},
+		{"passphrase to passphrase", "old-pass", "new-pass"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useKeyFile(t)
+			SetPassphrase(tt.oldPassphrase)
+			configPath := filepath.Join(dir, ConfigFile)
+			cfg := &Config{Profiles: []Profile{{Name: "jhcis", DBType: "mysql", Password: "s3cret"}}}
+			if err := SaveConfig(configPath, cfg); err != nil {
+				t.Fatal(err)
+			}
+			oldKey, err := os.ReadFile(KeyFilePath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, _ := os.ReadFile(configPath)
+
+			if err := RotateKey(configPath, tt.newPassphrase); err != nil {
+				t.Fatal(err)
+			}
+			newKey, err := os.ReadFile(KeyFilePath())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(newKey) == string(oldKey) {
+				t.Fatal("ไฟล์กุญแจไม่เปลี่ยน")
+			}
+			if after, _ := os.ReadFile(configPath); string(after) == string(before) {
+				t.Fatal("config.json ไม่ได้เข้ารหัสใหม่")
+			}
+			if _, err := os.Stat(KeyFilePath() + ".old"); !os.IsNotExist(err) {
+				t.Fatal("ไม่ได้ลบไฟล์กุญแจสำรองหลังเปลี่ยนกุญแจสำเร็จ")
+			}
+			if got := KeyUsesPassphrase(); got != (tt.newPassphrase != "") {
+				t.Fatalf("KeyUsesPassphrase() = %v", got)
+			}
+
+			// อ่านด้วยกุญแจใหม่หลังเปิดโปรแกรมอีกครั้ง
+			SetPassphrase(tt.newPassphrase)
+			read, err := ReadConfigFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := read.Profiles[0].Password.Reveal(); got != "s3cret" {
+				t.Fatalf("password หลังเปลี่ยนกุญแจ = %q", got)
+			}
+		})
+	}
+}
+
+func TestRotateKeyKeepsOldKeyOnFailure(t *testing.T) {
+	dir := useKeyFile(t)
+	configPath := filepath.Join(dir, ConfigFile)
+	if err := SaveConfig(configPath, &Config{Profiles: []Profile{{Name: "jhcis", Password: "s3cret"}}}); err != nil {
+		t.Fatal(err)
+	}
+	oldKey, _ := os.ReadFile(KeyFilePath())
+	// ไฟล์ชั่วคราวของ SaveConfig เป็นโฟลเดอร์ จึงบันทึก config.json ที่เข้ารหัสใหม่ไม่ได้
+	if err := os.Mkdir(configPath+".tmp", 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateKey(configPath, ""); err == nil {
+		t.Fatal("RotateKey สำเร็จทั้งที่บันทึก config.json ไม่ได้")
+	}
+	if key, _ := os.ReadFile(KeyFilePath()); string(key) != string(oldKey) {
+		t.Fatal("ไม่ได้คืนไฟล์กุญแจเดิมเมื่อเปลี่ยนกุญแจไม่สำเร็จ")
+	}
+	read, err := ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatalf("อ่าน config.json ด้วยกุญแจเดิมไม่ได้: %v", err)
+	}
+	if got := read.Profiles[0].Password.Reveal(); got != "s3cret" {
+		t.Fatalf("password = %q", got)
+	}
+}
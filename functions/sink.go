@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ประเภทปลายทางที่รองรับ (ค่าใน type ของ sinks)
+const (
+	SinkTypeFile = "file" // เขียนต่อท้ายไฟล์ JSON Lines
+	SinkTypeHTTP = "http" // ส่ง POST แบบ JSON ไปยัง URL
+)
+
+// SinkTypes รายการประเภทปลายทางที่รองรับ
+var SinkTypes = []string{SinkTypeFile, SinkTypeHTTP}
+
+// จำนวนเหตุการณ์ที่ส่งต่อครั้งเริ่มต้น
+const defaultSinkBatchSize = 100
+
+// SinkConfig ปลายทางที่รับเหตุการณ์ที่อ่านได้จากแหล่งข้อมูล
+// เหตุการณ์จะถูกเก็บไว้ในคิวจนกว่าปลายทางจะรับสำเร็จ
+type SinkConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Disabled bool   `json:"disabled,omitempty"`
+	Path     string `json:"path,omitempty"`  // ใช้กับ type file
+	URL      string `json:"url,omitempty"`   // ใช้กับ type http
+	Token    Secret `json:"token,omitempty"` // ส่งเป็น Authorization: Bearer (type http)
+	// Profiles โปรไฟล์ที่ส่งมายังปลายทางนี้ ค่าว่างคือทุกโปรไฟล์
+	Profiles  []string `json:"profiles,omitempty"`
+	BatchSize int      `json:"batch_size,omitempty"`
+}
+
+// Accepts บอกว่าปลายทางรับเหตุการณ์ของโปรไฟล์นี้หรือไม่
+func (s *SinkConfig) Accepts(profile string) bool {
+	if s.Disabled {
+		return false
+	}
+	if len(s.Profiles) == 0 {
+		return true
+	}
+	for _, p := range s.Profiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyDefaults เติมค่าเริ่มต้นให้ฟิลด์ที่ไม่ได้ระบุ
+func (s *SinkConfig) ApplyDefaults() {
+	if s.BatchSize <= 0 {
+		s.BatchSize = defaultSinkBatchSize
+	}
+}
+
+func (s *SinkConfig) problems(profiles map[string]int) []string {
+	var problems []string
+	if s.Name == "" {
+		problems = append(problems, "ไม่ได้ระบุ name")
+	} else if !profileNamePattern.MatchString(s.Name) {
+		problems = append(problems, fmt.Sprintf("name %q ใช้ได้เฉพาะ A-Z a-z 0-9 _ -", s.Name))
+	}
+
+	switch s.Type {
+	case SinkTypeFile:
+		if strings.TrimSpace(s.Path) == "" {
+			problems = append(problems, "ไม่ได้ระบุ path")
+		}
+	case SinkTypeHTTP:
+		if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("url %q ไม่ถูกต้อง (ต้องขึ้นต้นด้วย http:// หรือ https://)", s.URL))
+		}
+	case "":
+		problems = append(problems, "ไม่ได้ระบุ type")
+	default:
+		problems = append(problems, fmt.Sprintf("ไม่รองรับ type %q (ใช้ได้: %s)", s.Type, strings.Join(SinkTypes, ", ")))
+	}
+
+	for _, p := range s.Profiles {
+		if _, ok := profiles[p]; !ok {
+			problems = append(problems, fmt.Sprintf("ไม่พบโปรไฟล์ %q ใน profiles", p))
+		}
+	}
+	return problems
+}
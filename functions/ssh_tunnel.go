@@ -0,0 +1,391 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ค่าเริ่มต้นของ SSH tunnel
+const (
+	defaultSSHPort = "22"
+	// sshKeepAlive ช่วงเวลาส่ง keepalive เพื่อตรวจว่าการเชื่อมต่อ SSH ยังใช้งานได้
+	sshKeepAlive = 30 * time.Second
+)
+
+// SSHConfig การเชื่อมต่อฐานข้อมูลต้นทางผ่าน SSH tunnel ไม่ระบุ ssh ใน config.json คือเชื่อมต่อตรง
+// host และ port ของโปรไฟล์เป็นที่อยู่ของฐานข้อมูลเมื่อมองจากเครื่อง SSH (เช่น 127.0.0.1)
+type SSHConfig struct {
+	Host     string `json:"host"`
+	Port     string `json:"port,omitempty"`
+	User     string `json:"user"`
+	Password Secret `json:"password,omitempty"`
+	// KeyFile private key (OpenSSH หรือ PEM) ใช้แทนหรือร่วมกับ password
+	KeyFile       string `json:"key_file,omitempty"`
+	KeyPassphrase Secret `json:"key_passphrase,omitempty"`
+	// KnownHostsFile ไฟล์ known_hosts ที่ใช้ตรวจกุญแจของเครื่อง SSH ว่างคือ ~/.ssh/known_hosts
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+	// HostKeyFingerprint ลายนิ้วมือกุญแจของเครื่อง SSH (SHA256:...) ใช้แทน known_hosts
+	HostKeyFingerprint string `json:"host_key_fingerprint,omitempty"`
+}
+
+// Address คืนที่อยู่ของเครื่อง SSH แบบ host:port
+func (s *SSHConfig) Address() string {
+	port := s.Port
+	if port == "" {
+		port = defaultSSHPort
+	}
+	return net.JoinHostPort(s.Host, port)
+}
+
+// sshSettings คืน p.SSH สร้างใหม่ถ้ายังไม่มี (ใช้กับตัวแปรสภาพแวดล้อม)
+func (p *Profile) sshSettings() *SSHConfig {
+	if p.SSH == nil {
+		p.SSH = &SSHConfig{}
+	}
+	return p.SSH
+}
+
+func (s *SSHConfig) problems() []string {
+	var problems []string
+	if strings.TrimSpace(s.Host) == "" {
+		problems = append(problems, "ไม่ได้ระบุ ssh.host")
+	}
+	if s.Port != "" {
+		if port, err := strconv.Atoi(s.Port); err != nil || port < 1 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("ssh.port %q ไม่ถูกต้อง", s.Port))
+		}
+	}
+	if strings.TrimSpace(s.User) == "" {
+		problems = append(problems, "ไม่ได้ระบุ ssh.user")
+	}
+	if s.Password == "" && s.KeyFile == "" {
+		problems = append(problems, "ต้องระบุ ssh.password หรือ ssh.key_file")
+	}
+	if s.KeyFile != "" {
+		if _, err := os.Stat(s.KeyFile); err != nil {
+			problems = append(problems, fmt.Sprintf("ไม่พบไฟล์ ssh.key_file %q", s.KeyFile))
+		}
+	}
+	if s.HostKeyFingerprint != "" && !strings.HasPrefix(s.HostKeyFingerprint, "SHA256:") {
+		problems = append(problems, "ssh.host_key_fingerprint ต้องอยู่ในรูปแบบ SHA256:...")
+	}
+	return problems
+}
+
+// clientConfig สร้างการตั้งค่า SSH client พร้อมวิธียืนยันตัวตนและการตรวจกุญแจของเครื่อง
+func (s *SSHConfig) clientConfig() (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if s.KeyFile != "" {
+		pem, err := os.ReadFile(s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่าน ssh.key_file %s: %v", s.KeyFile, err)
+		}
+		var signer ssh.Signer
+		if s.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(s.KeyPassphrase.Reveal()))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh.key_file %s ไม่ถูกต้อง: %v", s.KeyFile, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if s.Password != "" {
+		auth = append(auth, ssh.Password(s.Password.Reveal()))
+	}
+
+	hostKey, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	return &ssh.ClientConfig{
+		User:            s.User,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         connectTimeout,
+	}, nil
+}
+
+// hostKeyCallback ตรวจกุญแจของเครื่อง SSH ด้วยลายนิ้วมือที่ระบุ หรือไฟล์ known_hosts
+func (s *SSHConfig) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if s.HostKeyFingerprint != "" {
+		want := s.HostKeyFingerprint
+		return func(hostname string, _ net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != want {
+				return fmt.Errorf("กุญแจของ %s ไม่ตรงกับ host_key_fingerprint (ได้รับ %s)", hostname, got)
+			}
+			return nil
+		}, nil
+	}
+
+	path := s.KnownHostsFile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ไม่พบโฟลเดอร์ผู้ใช้สำหรับ known_hosts: %v", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	check, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่าน known_hosts %s (หรือระบุ ssh.host_key_fingerprint): %v", path, err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("ไม่พบ %s ใน %s (ลายนิ้วมือ %s)", hostname, path, ssh.FingerprintSHA256(key))
+			}
+			return fmt.Errorf("กุญแจของ %s ไม่ตรงกับ %s อาจถูกดักการเชื่อมต่อ (ได้รับ %s)",
+				hostname, path, ssh.FingerprintSHA256(key))
+		}
+		return err
+	}, nil
+}
+
+// Tunnel การเชื่อมต่อ SSH หนึ่งรายการที่ใช้เปิดการเชื่อมต่อ TCP ไปยังปลายทางฝั่งเครื่อง SSH
+// เชื่อมต่อเมื่อมีการ Dial ครั้งแรก และเชื่อมต่อใหม่อัตโนมัติเมื่อการเชื่อมต่อเดิมหลุด
+type Tunnel struct {
+	config SSHConfig
+
+	mu     sync.Mutex
+	client *ssh.Client
+	// dialing ปิดเมื่อการเชื่อมต่อที่กำลังทำอยู่เสร็จ (nil ถ้าไม่ได้เชื่อมต่ออยู่)
+	dialing chan struct{}
+	closed  bool
+}
+
+// NewTunnel สร้าง tunnel ที่ยังไม่เชื่อมต่อ
+func NewTunnel(config SSHConfig) *Tunnel {
+	return &Tunnel{config: config}
+}
+
+// DialContext เปิดการเชื่อมต่อไปยัง addr ผ่านเครื่อง SSH
+// ถ้าการเชื่อมต่อ SSH เดิมใช้ไม่ได้แล้ว จะเชื่อมต่อใหม่แล้วลองอีกครั้ง
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, network, addr)
+	if err == nil || ctx.Err() != nil {
+		return conn, err
+	}
+	// ช่องทางถูกปฏิเสธแต่ SSH ยังใช้ได้ เช่น ปลายทางไม่ได้เปิดพอร์ต ไม่ต้องเชื่อมต่อ SSH ใหม่
+	var openErr *ssh.OpenChannelError
+	if errors.As(err, &openErr) {
+		return nil, fmt.Errorf("เครื่อง SSH %s เชื่อมต่อ %s ไม่ได้: %v", t.config.Address(), addr, err)
+	}
+	t.drop(client)
+	if client, err = t.connect(ctx); err != nil {
+		return nil, err
+	}
+	return client.DialContext(ctx, network, addr)
+}
+
+// connect คืน SSH client ที่เชื่อมต่ออยู่ หรือเชื่อมต่อใหม่
+// เชื่อมต่อนอก t.mu ผู้เรียกพร้อมกันรอผลของการเชื่อมต่อเดียวกันแทนการเชื่อมต่อซ้ำ
+func (t *Tunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	for {
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			return nil, errors.New("SSH tunnel ถูกปิดแล้ว")
+		}
+		if client := t.client; client != nil {
+			t.mu.Unlock()
+			return client, nil
+		}
+		if wait := t.dialing; wait != nil {
+			t.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		t.dialing = done
+		t.mu.Unlock()
+
+		client, err := t.dial(ctx)
+
+		t.mu.Lock()
+		t.dialing = nil
+		close(done)
+		if err == nil && t.closed {
+			client.Close()
+			err = errors.New("SSH tunnel ถูกปิดแล้ว")
+		}
+		if err != nil {
+			t.mu.Unlock()
+			return nil, err
+		}
+		t.client = client
+		t.mu.Unlock()
+		go t.keepAlive(client)
+		return client, nil
+	}
+}
+
+// dial เชื่อมต่อและยืนยันตัวตนกับเครื่อง SSH
+func (t *Tunnel) dial(ctx context.Context) (*ssh.Client, error) {
+	clientConfig, err := t.config.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := t.config.Address()
+	dialer := net.Dialer{Timeout: connectTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเชื่อมต่อเครื่อง SSH %s: %v", addr, err)
+	}
+	deadline := time.Now().Add(connectTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	if err != nil {
+		conn.Close()
+		return nil, RedactError(fmt.Errorf("SSH %s: %v", addr, err))
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// keepAlive ส่ง keepalive เป็นระยะ และปลด client ออกเมื่อการเชื่อมต่อหลุด เพื่อให้ Dial ครั้งถัดไปเชื่อมต่อใหม่
+func (t *Tunnel) keepAlive(client *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+	ticker := time.NewTicker(sshKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			t.drop(client)
+			return
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				t.drop(client)
+				return
+			}
+		}
+	}
+}
+
+// drop ปิด client ถ้ายังเป็น client ปัจจุบันของ tunnel
+func (t *Tunnel) drop(client *ssh.Client) {
+	t.mu.Lock()
+	if t.client == client {
+		t.client = nil
+	}
+	t.mu.Unlock()
+	client.Close()
+}
+
+// Close ปิดการเชื่อมต่อ SSH การเชื่อมต่อที่เปิดผ่าน tunnel จะถูกปิดด้วย
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	client := t.client
+	t.client, t.closed = nil, true
+	t.mu.Unlock()
+	if client != nil {
+		return client.Close()
+	}
+	return nil
+}
+
+// tunnels tunnel ที่เปิดอยู่ แยกตามการตั้งค่า ssh ทั้งหมด โปรไฟล์ที่ใช้การตั้งค่าเดียวกันจึงใช้การเชื่อมต่อ SSH ร่วมกัน
+// การตั้งค่าที่ต่างกัน (แม้เครื่องและผู้ใช้เดียวกัน) ได้ tunnel แยก จึงไม่ปิดการเชื่อมต่อของโปรไฟล์อื่น
+var tunnels = struct {
+	sync.Mutex
+	byConfig map[SSHConfig]*Tunnel
+}{byConfig: make(map[SSHConfig]*Tunnel)}
+
+// tunnelFor คืน tunnel ของการตั้งค่า ssh สร้างใหม่ถ้ายังไม่มี
+func tunnelFor(config *SSHConfig) *Tunnel {
+	tunnels.Lock()
+	defer tunnels.Unlock()
+	t, ok := tunnels.byConfig[*config]
+	if !ok {
+		t = NewTunnel(*config)
+		tunnels.byConfig[*config] = t
+	}
+	return t
+}
+
+// CloseUnusedTunnels ปิด tunnel ที่ไม่มีโปรไฟล์ใดใน cfg ใช้แล้ว (เช่น หลังเปลี่ยนรหัสผ่านหรือลบโปรไฟล์)
+func CloseUnusedTunnels(cfg *Config) {
+	used := make(map[SSHConfig]bool)
+	for _, p := range cfg.Profiles {
+		if p.SSH != nil {
+			used[*p.SSH] = true
+		}
+	}
+	tunnels.Lock()
+	var unused []*Tunnel
+	for config, t := range tunnels.byConfig {
+		if !used[config] {
+			unused = append(unused, t)
+			delete(tunnels.byConfig, config)
+		}
+	}
+	tunnels.Unlock()
+	for _, t := range unused {
+		t.Close()
+	}
+}
+
+// Dialer เปิดการเชื่อมต่อ TCP ไปยังฐานข้อมูลของโปรไฟล์ ผ่าน SSH tunnel เมื่อกำหนด ssh
+// ไม่สนใจที่อยู่ที่ไดรเวอร์ขอ เพราะไดรเวอร์บางตัวแปลงชื่อเครื่องเองหรือใช้ชื่อตามใบรับรอง
+type Dialer struct {
+	addr   string
+	tunnel *Tunnel
+}
+
+// NewDialer สร้าง Dialer ไปยัง host:port ของโปรไฟล์
+func NewDialer(p *Profile) *Dialer {
+	d := &Dialer{addr: net.JoinHostPort(p.Host, p.Port)}
+	if p.SSH != nil {
+		d.tunnel = tunnelFor(p.SSH)
+	}
+	return d
+}
+
+// DialContext เชื่อมต่อไปยังฐานข้อมูลของโปรไฟล์
+func (d *Dialer) DialContext(ctx context.Context, network, _ string) (net.Conn, error) {
+	if d.tunnel != nil {
+		return d.tunnel.DialContext(ctx, network, d.addr)
+	}
+	var nd net.Dialer
+	return nd.DialContext(ctx, network, d.addr)
+}
+
+// Dial และ DialTimeout ใช้กับ lib/pq
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *Dialer) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, addr)
+}
@@ -0,0 +1,366 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer เครื่อง SSH ในโปรเซสที่รองรับ direct-tcpip สำหรับทดสอบ Tunnel
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu       sync.Mutex
+	conns    []*ssh.ServerConn
+	accepted int
+}
+
+func newTestSSHServer(t *testing.T, password string, authorized ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if password != "" && string(pass) == password {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && bytes.Equal(key.Marshal(), authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s := &testSSHServer{addr: ln.Addr().String(), hostKey: hostKey}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	t.Cleanup(s.dropAll)
+	return s
+}
+
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mu.Lock()
+	s.conns = append(s.conns, sshConn)
+	s.accepted++
+	s.mu.Unlock()
+
+	go ssh.DiscardRequests(reqs)
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			ch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &target); err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, requests, err := ch.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// dropAll ตัดการเชื่อมต่อของทุก client เหมือนเครื่อง SSH รีสตาร์ท
+func (s *testSSHServer) dropAll() {
+	s.mu.Lock()
+	conns := s.conns
+	s.conns = nil
+	s.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+}
+
+func (s *testSSHServer) acceptedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+func (s *testSSHServer) config(user string) SSHConfig {
+	host, port, _ := net.SplitHostPort(s.addr)
+	return SSHConfig{
+		Host:               host,
+		Port:               port,
+		User:               user,
+		HostKeyFingerprint: ssh.FingerprintSHA256(s.hostKey.PublicKey()),
+	}
+}
+
+// newEchoServer ปลายทางที่ตอบข้อมูลที่ได้รับกลับไป แทนฐานข้อมูลฝั่งเครื่อง SSH
+func newEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// roundTrip ส่งข้อความผ่าน tunnel ไปยังปลายทาง echo แล้วตรวจว่าได้ข้อความเดิมกลับมา
+func roundTrip(t *testing.T, tunnel *Tunnel, target string) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := tunnel.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		return err
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	if string(buf) != "ping" {
+		t.Fatalf("ได้รับ %q ผ่าน tunnel ต้องการ %q", buf, "ping")
+	}
+	return nil
+}
+
+func writeClientKey(t *testing.T) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "hissync-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, sshPub
+}
+
+func writeKnownHosts(t *testing.T, addr string, key ssh.PublicKey) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, key) + "\n"
+	if err := os.WriteFile(path, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTunnelPasswordAuth(t *testing.T) {
+	server := newTestSSHServer(t, "s3cret", nil)
+	target := newEchoServer(t)
+
+	tests := []struct {
+		name     string
+		password Secret
+		wantErr  bool
+	}{
+		{"correct password", "s3cret", false},
+		{"wrong password", "wrong", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := server.config("hissync")
+			cfg.Password = tt.password
+			tunnel := NewTunnel(cfg)
+			defer tunnel.Close()
+			err := roundTrip(t, tunnel, target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTunnelKeyAuth(t *testing.T) {
+	keyFile, pub := writeClientKey(t)
+	server := newTestSSHServer(t, "", pub)
+	target := newEchoServer(t)
+
+	cfg := server.config("hissync")
+	cfg.KeyFile = keyFile
+	// ตรวจกุญแจของเครื่องด้วย known_hosts แทนลายนิ้วมือ
+	cfg.HostKeyFingerprint = ""
+	cfg.KnownHostsFile = writeKnownHosts(t, server.addr, server.hostKey.PublicKey())
+	tunnel := NewTunnel(cfg)
+	defer tunnel.Close()
+	if err := roundTrip(t, tunnel, target); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTunnelRejectsUnknownHostKey(t *testing.T) {
+	server := newTestSSHServer(t, "s3cret", nil)
+	target := newEchoServer(t)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ssh.NewSignerFromKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(*SSHConfig)
+		want  string
+	}{
+		{"fingerprint mismatch", func(c *SSHConfig) {
+			c.HostKeyFingerprint = ssh.FingerprintSHA256(otherKey.PublicKey())
+		}, "host_key_fingerprint"},
+		{"known_hosts mismatch", func(c *SSHConfig) {
+			c.HostKeyFingerprint = ""
+			c.KnownHostsFile = writeKnownHosts(t, server.addr, otherKey.PublicKey())
+		}, "ไม่ตรงกับ"},
+		{"not in known_hosts", func(c *SSHConfig) {
+			c.HostKeyFingerprint = ""
+			c.KnownHostsFile = writeKnownHosts(t, "example.invalid:22", server.hostKey.PublicKey())
+		}, "ไม่พบ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := server.config("hissync")
+			cfg.Password = "s3cret"
+			tt.setup(&cfg)
+			tunnel := NewTunnel(cfg)
+			defer tunnel.Close()
+			err := roundTrip(t, tunnel, target)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("error = %v, ต้องการข้อผิดพลาดที่มี %q", err, tt.want)
+			}
+		})
+	}
+	if n := server.acceptedCount(); n != 0 {
+		t.Fatalf("เครื่อง SSH ยอมรับการเชื่อมต่อ %d ครั้ง ทั้งที่กุญแจของเครื่องไม่ตรง", n)
+	}
+}
+
+func TestTunnelReconnectsAfterDrop(t *testing.T) {
+	server := newTestSSHServer(t, "s3cret", nil)
+	target := newEchoServer(t)
+	cfg := server.config("hissync")
+	cfg.Password = "s3cret"
+	tunnel := NewTunnel(cfg)
+	defer tunnel.Close()
+
+	if err := roundTrip(t, tunnel, target); err != nil {
+		t.Fatal(err)
+	}
+	server.dropAll()
+	if err := roundTrip(t, tunnel, target); err != nil {
+		t.Fatalf("ไม่เชื่อมต่อใหม่หลังเครื่อง SSH ตัดการเชื่อมต่อ: %v", err)
+	}
+	if n := server.acceptedCount(); n != 2 {
+		t.Fatalf("เครื่อง SSH ยอมรับการเชื่อมต่อ %d ครั้ง ต้องการ 2", n)
+	}
+}
+
+func TestTunnelForSharesOnlyIdenticalConfig(t *testing.T) {
+	a := SSHConfig{Host: "db.example", User: "hissync", Password: "one"}
+	b := a
+	b.Password = "two"
+
+	ta, tb := tunnelFor(&a), tunnelFor(&b)
+	if ta == tb {
+		t.Fatal("การตั้งค่าต่างกันได้ tunnel เดียวกัน")
+	}
+	if tunnelFor(&a) != ta {
+		t.Fatal("การตั้งค่าเดียวกันได้ tunnel ใหม่")
+	}
+	ta.mu.Lock()
+	closed := ta.closed
+	ta.mu.Unlock()
+	if closed {
+		t.Fatal("tunnel ของโปรไฟล์อื่นถูกปิดเมื่อมีการตั้งค่าใหม่ของเครื่องเดียวกัน")
+	}
+
+	CloseUnusedTunnels(&Config{Profiles: []Profile{{Name: "p", SSH: &b}}})
+	if !ta.closed || tb.closed {
+		t.Fatalf("CloseUnusedTunnels: closed a=%v b=%v ต้องการ a=true b=false", ta.closed, tb.closed)
+	}
+	CloseUnusedTunnels(&Config{})
+}
@@ -0,0 +1,448 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// TableConfigFile ชื่อไฟล์กำหนดตารางที่ต้องการติดตาม (ใช้ร่วมกันทุกแหล่งข้อมูล)
+const TableConfigFile = "tables.json"
+
+// TableConfigVersion เวอร์ชันของรูปแบบไฟล์ tables.json
+const TableConfigVersion = 1
+
+// ชื่อไฟล์รูปแบบเก่าที่จะถูกย้ายเข้า tables.json อัตโนมัติ
+const (
+	legacyDBTableConfigFile = "db_table_config.json"
+	legacyTableConfigFile   = "table_config.json"
+)
+
+// Operations ที่รองรับใน options.operations
+var supportedOperations = []string{"INSERT", "UPDATE", "DELETE"}
+
+// TableConfig โครงสร้างของ tables.json
+type TableConfig struct {
+	Version int          `json:"version"`
+	Tables  []TableEntry `json:"tables"`
+}
+
+// TableEntry การตั้งค่าของแต่ละตาราง (อ้างอิงด้วย database.table)
+type TableEntry struct {
+	Database   string        `json:"database"`
+	Table      string        `json:"table"`
+	Keys       []string      `json:"keys,omitempty"`
+	PrimaryKey []string      `json:"primary_key,omitempty"`
+	Options    *TableOptions `json:"options,omitempty"`
+	// Person การระบุบุคคลของแถว ใช้ทำประวัติการเปลี่ยนแปลงรายบุคคล
+	Person *PersonKey `json:"person,omitempty"`
+}
+
+// PersonKey คอลัมน์ที่บอกว่าแถวเป็นของบุคคลใด
+type PersonKey struct {
+	// Column คอลัมน์รหัสบุคคล เช่น pid ของ JHCIS
+	Column string `json:"column,omitempty"`
+	// CitizenColumn คอลัมน์เลขประจำตัวประชาชน เช่น idcard ของตาราง person
+	CitizenColumn string `json:"citizen_column,omitempty"`
+	// Via หารหัสบุคคลจากตารางอื่นในฐานข้อมูลเดียวกัน รูปแบบ "ตาราง.คอลัมน์"
+	// เช่น visitdiag ใช้ "visit.visitno" คือใช้ pid ของแถว visit ที่มี visitno เท่ากัน (ตารางนั้นต้องกำหนด person.column)
+	Via string `json:"via,omitempty"`
+}
+
+// ViaTable คืนตารางและคอลัมน์ของ Via (ว่างถ้าไม่ได้กำหนดหรือรูปแบบไม่ถูกต้อง)
+func (k PersonKey) ViaTable() (table, column string) {
+	table, column, ok := strings.Cut(k.Via, ".")
+	if !ok || table == "" || column == "" {
+		return "", ""
+	}
+	return table, column
+}
+
+// TableOptions ตัวเลือกเพิ่มเติมของตาราง
+type TableOptions struct {
+	// Operations จำกัดประเภทคำสั่งที่เก็บ (ว่าง = ทุกประเภท)
+	Operations []string `json:"operations,omitempty"`
+}
+
+// FullName คืนชื่อตารางแบบ database.table
+func (e TableEntry) FullName() string {
+	return e.Database + "." + e.Table
+}
+
+// AllowsOperation ตรวจสอบว่าตารางนี้ต้องการเก็บคำสั่งประเภท op หรือไม่
+func (e TableEntry) AllowsOperation(op string) bool {
+	if e.Options == nil || len(e.Options.Operations) == 0 {
+		return true
+	}
+	for _, o := range e.Options.Operations {
+		if strings.EqualFold(o, op) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find ค้นหาการตั้งค่าของตาราง database.table คืน nil ถ้าไม่ได้ติดตามตารางนี้
+func (tc *TableConfig) Find(database, table string) *TableEntry {
+	for i := range tc.Tables {
+		if tc.Tables[i].Database == database && tc.Tables[i].Table == table {
+			return &tc.Tables[i]
+		}
+	}
+	return nil
+}
+
+// ForDatabase คืนรายการตารางที่อยู่ใน database ที่ระบุ
+func (tc *TableConfig) ForDatabase(database string) []TableEntry {
+	var entries []TableEntry
+	for _, e := range tc.Tables {
+		if e.Database == database {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// ValidationError รวมปัญหาที่พบระหว่างตรวจสอบไฟล์ตั้งค่า
+type ValidationError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s ไม่ถูกต้อง:\n  - %s", e.File, strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate ตรวจสอบความถูกต้องของ TableConfig และปรับ options.operations เป็นตัวพิมพ์ใหญ่
+func (tc *TableConfig) Validate() error {
+	var problems []string
+	if tc.Version != TableConfigVersion {
+		problems = append(problems, fmt.Sprintf("version ต้องเป็น %d (พบ %d)", TableConfigVersion, tc.Version))
+	}
+
+	seen := make(map[string]int)
+	for i, e := range tc.Tables {
+		where := fmt.Sprintf("tables[%d]", i)
+		if e.Database == "" {
+			problems = append(problems, where+": ไม่ได้ระบุ database")
+		} else if msg := checkIdentifier(e.Database); msg != "" {
+			problems = append(problems, fmt.Sprintf("%s: database %q %s", where, e.Database, msg))
+		}
+		if e.Table == "" {
+			problems = append(problems, where+": ไม่ได้ระบุ table")
+		} else if msg := checkIdentifier(e.Table); msg != "" {
+			problems = append(problems, fmt.Sprintf("%s: table %q %s", where, e.Table, msg))
+		}
+		if e.Database != "" && e.Table != "" {
+			if j, ok := seen[e.FullName()]; ok {
+				problems = append(problems, fmt.Sprintf("%s: %s ซ้ำกับ tables[%d]", where, e.FullName(), j))
+			} else {
+				seen[e.FullName()] = i
+			}
+		}
+		problems = append(problems, checkColumns(where+".keys", e.Keys)...)
+		problems = append(problems, checkColumns(where+".primary_key", e.PrimaryKey)...)
+		if e.Options != nil {
+			for j, op := range e.Options.Operations {
+				// เก็บเป็นตัวพิมพ์ใหญ่เสมอ ให้ "insert" ใช้ได้เหมือน "INSERT" ตาม AllowsOperation
+				e.Options.Operations[j] = strings.ToUpper(op)
+				if !isSupportedOperation(op) {
+					problems = append(problems, fmt.Sprintf("%s.options.operations: ไม่รองรับ %q (ใช้ได้: %s)",
+						where, op, strings.Join(supportedOperations, ", ")))
+				}
+			}
+		}
+		if e.Person != nil {
+			problems = append(problems, tc.personProblems(where+".person", e)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{File: TableConfigFile, Problems: problems}
+	}
+	return nil
+}
+
+// personProblems ตรวจ person ของตาราง e
+func (tc *TableConfig) personProblems(where string, e TableEntry) []string {
+	var problems []string
+	k := e.Person
+	if k.Column == "" && k.CitizenColumn == "" && k.Via == "" {
+		problems = append(problems, where+": ต้องระบุ column, citizen_column หรือ via")
+	}
+	for _, col := range []struct{ name, value string }{{"column", k.Column}, {"citizen_column", k.CitizenColumn}} {
+		if msg := checkIdentifier(col.value); col.value != "" && msg != "" {
+			problems = append(problems, fmt.Sprintf("%s.%s: คอลัมน์ %q %s", where, col.name, col.value, msg))
+		}
+	}
+	if k.Via != "" {
+		table, column := k.ViaTable()
+		switch {
+		case table == "":
+			problems = append(problems, fmt.Sprintf("%s.via: %q ต้องอยู่ในรูปแบบ ตาราง.คอลัมน์", where, k.Via))
+		case checkIdentifier(column) != "":
+			problems = append(problems, fmt.Sprintf("%s.via: คอลัมน์ %q %s", where, column, checkIdentifier(column)))
+		default:
+			if target := tc.Find(e.Database, table); target == nil || target.Person == nil || target.Person.Column == "" {
+				problems = append(problems, fmt.Sprintf("%s.via: ตาราง %s.%s ต้องถูกติดตามและกำหนด person.column", where, e.Database, table))
+			}
+		}
+	}
+	return problems
+}
+
+func checkIdentifier(name string) string {
+	if strings.TrimSpace(name) != name {
+		return "มีช่องว่างหน้าหรือท้ายชื่อ"
+	}
+	if strings.ContainsAny(name, ".`\"'") {
+		return "มีอักขระที่ไม่อนุญาต (. ` \" ')"
+	}
+	return ""
+}
+
+func checkColumns(where string, columns []string) []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for i, col := range columns {
+		switch {
+		case col == "":
+			problems = append(problems, fmt.Sprintf("%s[%d]: ชื่อคอลัมน์ว่าง", where, i))
+		case seen[col]:
+			problems = append(problems, fmt.Sprintf("%s[%d]: คอลัมน์ %q ซ้ำ", where, i, col))
+		default:
+			if msg := checkIdentifier(col); msg != "" {
+				problems = append(problems, fmt.Sprintf("%s[%d]: คอลัมน์ %q %s", where, i, col, msg))
+			}
+		}
+		seen[col] = true
+	}
+	return problems
+}
+
+func isSupportedOperation(op string) bool {
+	op = strings.ToUpper(op)
+	for _, o := range supportedOperations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadTableConfig โหลดและตรวจสอบ tables.json
+// ไฟล์รูปแบบเก่าถูกย้ายมาเป็น tables.json แล้วเมื่อโหลด config.json (ดู Config.MigrateTableConfigs)
+func LoadTableConfig(filePath string) (*TableConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("ไม่พบไฟล์ %s กรุณาสร้างไฟล์ก่อนใช้งาน", filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์ %s: %v", filePath, err)
+	}
+	return ParseTableConfig(filePath, data)
+}
+
+// ParseTableConfig แปลงข้อมูล JSON เป็น TableConfig โดยไม่ยอมรับฟิลด์ที่ไม่รู้จัก
+func ParseTableConfig(filePath string, data []byte) (*TableConfig, error) {
+	tc := &TableConfig{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(tc); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			line, col := lineColumn(data, syntaxErr.Offset)
+			return nil, fmt.Errorf("%s บรรทัด %d คอลัมน์ %d: %v", filePath, line, col, err)
+		case errors.As(err, &typeErr):
+			line, col := lineColumn(data, typeErr.Offset)
+			return nil, fmt.Errorf("%s บรรทัด %d คอลัมน์ %d: %s ต้องเป็น %s", filePath, line, col, typeErr.Field, typeErr.Type)
+		}
+		return nil, fmt.Errorf("ไม่สามารถอ่าน %s: %v", filePath, err)
+	}
+	if err := tc.Validate(); err != nil {
+		if verr, ok := err.(*ValidationError); ok {
+			verr.File = filePath
+		}
+		return nil, err
+	}
+	return tc, nil
+}
+
+func lineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, col
+}
+
+// SaveTableConfig ตรวจสอบแล้วบันทึก TableConfig ลงไฟล์
+func SaveTableConfig(filePath string, tc *TableConfig) error {
+	if tc.Version == 0 {
+		tc.Version = TableConfigVersion
+	}
+	if err := tc.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tc, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, append(data, '\n'), 0644)
+}
+
+// รูปแบบเก่าของ db_table_config.json (MySQL)
+type legacyDBTableEntry struct {
+	Database string `json:"database"`
+	Table    string `json:"table"`
+}
+
+// รูปแบบเก่าของ table_config.json (PostgreSQL)
+type legacyTableEntry struct {
+	TableName  string   `json:"table_name"`
+	Keys       []string `json:"keys"`
+	PrimaryKey []string `json:"primary_key"`
+}
+
+// migrateMu ให้ย้ายไฟล์รูปแบบเก่าครั้งละหนึ่งการโหลด config.json
+var migrateMu sync.Mutex
+
+// MigrateTableConfigs ย้ายไฟล์ตั้งค่าตารางรูปแบบเก่าของทุก tables.json ที่โปรไฟล์ใช้และยังไม่มีไฟล์ (เรียกจาก LoadConfig)
+// ทำก่อนแหล่งข้อมูลเริ่มทำงาน โปรไฟล์ที่ใช้ tables.json ร่วมกันจึงไม่ย้ายพร้อมกัน
+func (c *Config) MigrateTableConfigs() error {
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	var files []string
+	profiles := make(map[string][]Profile)
+	for _, p := range c.Profiles {
+		path := p.TableConfigFile
+		if path == "" {
+			path = TableConfigFile
+		}
+		if _, ok := profiles[path]; !ok {
+			files = append(files, path)
+		}
+		profiles[path] = append(profiles[path], p)
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			continue
+		}
+		if _, _, err := MigrateLegacyTableConfig(path, profiles[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateLegacyTableConfig รวมไฟล์ตั้งค่าตารางรูปแบบเก่าที่อยู่ในโฟลเดอร์เดียวกับ filePath
+// แล้วบันทึกเป็น tables.json ไฟล์เก่าจะถูกเปลี่ยนชื่อเป็น .migrated
+// profiles โปรไฟล์ที่ใช้ filePath: db_table_config.json (MySQL) มีชื่อ database อยู่แล้ว
+// ตารางใน table_config.json (PostgreSQL) ใช้ dbname ของทุกโปรไฟล์ PostgreSQL
+// และ filter_tables ของแต่ละโปรไฟล์ใช้ dbname ของโปรไฟล์นั้น
+// คืนค่า migrated = false ถ้าไม่พบไฟล์รูปแบบเก่าเลย
+func MigrateLegacyTableConfig(filePath string, profiles []Profile) (*TableConfig, bool, error) {
+	dir := filepath.Dir(filePath)
+	dbTablePath := filepath.Join(dir, legacyDBTableConfigFile)
+	tablePath := filepath.Join(dir, legacyTableConfigFile)
+
+	tc := &TableConfig{Version: TableConfigVersion}
+	add := func(e TableEntry) {
+		if existing := tc.Find(e.Database, e.Table); existing != nil {
+			existing.Keys = appendMissing(existing.Keys, e.Keys)
+			existing.PrimaryKey = appendMissing(existing.PrimaryKey, e.PrimaryKey)
+			return
+		}
+		tc.Tables = append(tc.Tables, e)
+	}
+
+	var postgresDBs []string
+	for _, p := range profiles {
+		if p.DBType == DBTypePostgreSQL && p.DBName != "" {
+			postgresDBs = appendMissing(postgresDBs, []string{p.DBName})
+		}
+	}
+
+	var migratedFiles []string
+
+	if data, err := os.ReadFile(dbTablePath); err == nil {
+		var entries []legacyDBTableEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, false, fmt.Errorf("ไม่สามารถแปลง %s: %v", dbTablePath, err)
+		}
+		for _, e := range entries {
+			add(TableEntry{Database: e.Database, Table: e.Table})
+		}
+		migratedFiles = append(migratedFiles, dbTablePath)
+	} else if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	if data, err := os.ReadFile(tablePath); err == nil {
+		var entries []legacyTableEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, false, fmt.Errorf("ไม่สามารถแปลง %s: %v", tablePath, err)
+		}
+		if len(entries) > 0 && len(postgresDBs) == 0 {
+			return nil, false, fmt.Errorf("ไม่สามารถย้าย %s: ต้องมีโปรไฟล์ PostgreSQL ที่ระบุ dbname ใน config.json ก่อน", tablePath)
+		}
+		for _, db := range postgresDBs {
+			for _, e := range entries {
+				add(TableEntry{Database: db, Table: e.TableName, Keys: e.Keys, PrimaryKey: e.PrimaryKey})
+			}
+		}
+		migratedFiles = append(migratedFiles, tablePath)
+	} else if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	for _, p := range profiles {
+		if p.DBName == "" {
+			continue
+		}
+		for _, name := range p.FilterTables {
+			if name = strings.TrimSpace(name); name != "" {
+				add(TableEntry{Database: p.DBName, Table: name})
+			}
+		}
+	}
+
+	if len(migratedFiles) == 0 && len(tc.Tables) == 0 {
+		return nil, false, nil
+	}
+
+	if err := SaveTableConfig(filePath, tc); err != nil {
+		return nil, false, fmt.Errorf("ย้ายการตั้งค่าตารางไม่สำเร็จ: %v", err)
+	}
+	for _, f := range migratedFiles {
+		if err := os.Rename(f, f+".migrated"); err != nil {
+			return nil, false, err
+		}
+	}
+	return tc, true, nil
+}
+
+func appendMissing(dst, src []string) []string {
+	for _, s := range src {
+		found := false
+		for _, d := range dst {
+			if d == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, s)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTableConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []TableEntry
+		// want ข้อความที่ต้องพบในข้อผิดพลาด (ว่าง = ต้องผ่าน)
+		want []string
+	}{
+		{
+			name:   "valid",
+			tables: []TableEntry{{Database: "jhcis", Table: "person", Keys: []string{"pid"}}},
+		},
+		{
+			name:   "missing database and table",
+			tables: []TableEntry{{}},
+			want:   []string{"tables[0]: ไม่ได้ระบุ database", "tables[0]: ไม่ได้ระบุ table"},
+		},
+		{
+			name:   "invalid identifier",
+			tables: []TableEntry{{Database: "jhcis", Table: "per.son"}},
+			want:   []string{`table "per.son" มีอักขระที่ไม่อนุญาต`},
+		},
+		{
+			name:   "duplicate table",
+			tables: []TableEntry{{Database: "jhcis", Table: "person"}, {Database: "jhcis", Table: "person"}},
+			want:   []string{"tables[1]: jhcis.person ซ้ำกับ tables[0]"},
+		},
+		{
+			name:   "duplicate and empty column",
+			tables: []TableEntry{{Database: "jhcis", Table: "person", Keys: []string{"pid", "pid", ""}}},
+			want:   []string{`tables[0].keys[1]: คอลัมน์ "pid" ซ้ำ`, "tables[0].keys[2]: ชื่อคอลัมน์ว่าง"},
+		},
+		{
+			name: "lower case operations",
+			tables: []TableEntry{{Database: "jhcis", Table: "person",
+				Options: &TableOptions{Operations: []string{"insert", "Update"}}}},
+		},
+		{
+			name: "unsupported operation",
+			tables: []TableEntry{{Database: "jhcis", Table: "person",
+				Options: &TableOptions{Operations: []string{"TRUNCATE"}}}},
+			want: []string{`tables[0].options.operations: ไม่รองรับ "TRUNCATE"`},
+		},
+		{
+			name:   "person without column",
+			tables: []TableEntry{{Database: "jhcis", Table: "person", Person: &PersonKey{}}},
+			want:   []string{"tables[0].person: ต้องระบุ column, citizen_column หรือ via"},
+		},
+		{
+			name: "person via tracked table",
+			tables: []TableEntry{
+				{Database: "jhcis", Table: "visit", Person: &PersonKey{Column: "pid"}},
+				{Database: "jhcis", Table: "visitdiag", Person: &PersonKey{Via: "visit.visitno"}},
+			},
+		},
+		{
+			name:   "person via untracked table",
+			tables: []TableEntry{{Database: "jhcis", Table: "visitdiag", Person: &PersonKey{Via: "visit.visitno"}}},
+			want:   []string{"tables[0].person.via: ตาราง jhcis.visit ต้องถูกติดตามและกำหนด person.column"},
+		},
+		{
+			name:   "person via without column",
+			tables: []TableEntry{{Database: "jhcis", Table: "visitdiag", Person: &PersonKey{Via: "visit"}}},
+			want:   []string{`tables[0].person.via: "visit" ต้องอยู่ในรูปแบบ ตาราง.คอลัมน์`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &TableConfig{Version: TableConfigVersion, Tables: tt.tables}
+			err := tc.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v ต้องการไม่มีข้อผิดพลาด", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate() = %v ต้องการ *ValidationError", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %q ไม่มี %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTableConfigValidateVersion(t *testing.T) {
+	tc := &TableConfig{Version: 0}
+	if err := tc.Validate(); err == nil || !strings.Contains(err.Error(), "version ต้องเป็น 1") {
+		t.Fatalf("Validate() = %v ต้องการข้อผิดพลาดเรื่อง version", err)
+	}
+}
+
+func TestTableConfigValidateNormalisesOperations(t *testing.T) {
+	tc := &TableConfig{Version: TableConfigVersion, Tables: []TableEntry{{Database: "jhcis", Table: "person",
+		Options: &TableOptions{Operations: []string{"insert", "Delete"}}}}}
+	if err := tc.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tc.Tables[0].Options.Operations, []string{"INSERT", "DELETE"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("operations = %v ต้องการ %v", got, want)
+	}
+	if !tc.Tables[0].AllowsOperation("insert") || tc.Tables[0].AllowsOperation("UPDATE") {
+		t.Fatal("AllowsOperation ไม่ตรงกับ options.operations")
+	}
+}
+
+func TestMigrateLegacyTableConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		// files ไฟล์รูปแบบเก่าที่มีอยู่ก่อนย้าย
+		files    map[string]string
+		profiles []Profile
+		want     []TableEntry
+		migrated bool
+		wantErr  string
+	}{
+		{
+			name: "no legacy files",
+		},
+		{
+			name: "mysql db_table_config.json",
+			files: map[string]string{
+				legacyDBTableConfigFile: `[{"database":"jhcis","table":"person"},{"database":"jhcis","table":"visit"}]`,
+			},
+			want:     []TableEntry{{Database: "jhcis", Table: "person"}, {Database: "jhcis", Table: "visit"}},
+			migrated: true,
+		},
+		{
+			name: "postgres table_config.json uses profile dbname",
+			files: map[string]string{
+				legacyTableConfigFile: `[{"table_name":"person","keys":["pid"],"primary_key":["pid"]}]`,
+			},
+			profiles: []Profile{{DBType: DBTypePostgreSQL, DBName: "hosxp"}},
+			want:     []TableEntry{{Database: "hosxp", Table: "person", Keys: []string{"pid"}, PrimaryKey: []string{"pid"}}},
+			migrated: true,
+		},
+		{
+			name: "postgres table_config.json without dbname",
+			files: map[string]string{
+				legacyTableConfigFile: `[{"table_name":"person"}]`,
+			},
+			profiles: []Profile{{DBType: DBTypePostgreSQL}},
+			wantErr:  "ต้องมีโปรไฟล์ PostgreSQL ที่ระบุ dbname",
+		},
+		{
+			name: "postgres table_config.json without postgres profile",
+			files: map[string]string{
+				legacyTableConfigFile: `[{"table_name":"person"}]`,
+			},
+			profiles: []Profile{{DBType: DBTypeMySQL, DBName: "jhcis"}},
+			wantErr:  "ต้องมีโปรไฟล์ PostgreSQL ที่ระบุ dbname",
+		},
+		{
+			name: "one profile of each type",
+			files: map[string]string{
+				legacyDBTableConfigFile: `[{"database":"jhcis","table":"person"}]`,
+				legacyTableConfigFile:   `[{"table_name":"person","keys":["pid"]},{"table_name":"ovst","keys":["vn"]}]`,
+			},
+			profiles: []Profile{
+				{DBType: DBTypeMySQL, DBName: "jhcis", FilterTables: []string{"visit"}},
+				{DBType: DBTypePostgreSQL, DBName: "hosxp", FilterTables: []string{"opdscreen"}},
+			},
+			want: []TableEntry{
+				{Database: "jhcis", Table: "person"},
+				{Database: "hosxp", Table: "person", Keys: []string{"pid"}},
+				{Database: "hosxp", Table: "ovst", Keys: []string{"vn"}},
+				{Database: "jhcis", Table: "visit"},
+				{Database: "hosxp", Table: "opdscreen"},
+			},
+			migrated: true,
+		},
+		{
+			name: "merge files and filter_tables",
+			files: map[string]string{
+				legacyDBTableConfigFile: `[{"database":"hosxp","table":"person"}]`,
+				legacyTableConfigFile:   `[{"table_name":"person","keys":["pid"]},{"table_name":"ovst","keys":["vn"]}]`,
+			},
+			profiles: []Profile{{DBType: DBTypePostgreSQL, DBName: "hosxp", FilterTables: []string{" ovst ", "opdscreen", ""}}},
+			want: []TableEntry{
+				{Database: "hosxp", Table: "person", Keys: []string{"pid"}},
+				{Database: "hosxp", Table: "ovst", Keys: []string{"vn"}},
+				{Database: "hosxp", Table: "opdscreen"},
+			},
+			migrated: true,
+		},
+		{
+			name:    "invalid json",
+			files:   map[string]string{legacyDBTableConfigFile: `{`},
+			wantErr: "ไม่สามารถแปลง",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := filepath.Join(dir, TableConfigFile)
+			tc, migrated, err := MigrateLegacyTableConfig(path, tt.profiles)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v ต้องการข้อผิดพลาดที่มี %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if migrated != tt.migrated {
+				t.Fatalf("migrated = %v ต้องการ %v", migrated, tt.migrated)
+			}
+			if !migrated {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Fatalf("สร้าง %s ทั้งที่ไม่มีไฟล์รูปแบบเก่า", TableConfigFile)
+				}
+				return
+			}
+			if !reflect.DeepEqual(tc.Tables, tt.want) {
+				t.Fatalf("tables = %+v ต้องการ %+v", tc.Tables, tt.want)
+			}
+			saved, err := LoadTableConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(saved.Tables, tt.want) {
+				t.Fatalf("%s = %+v ต้องการ %+v", TableConfigFile, saved.Tables, tt.want)
+			}
+			for name := range tt.files {
+				if _, err := os.Stat(filepath.Join(dir, name+".migrated")); err != nil {
+					t.Errorf("ไม่ได้เปลี่ยนชื่อ %s เป็น .migrated: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateTableConfigs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		legacyDBTableConfigFile: `[{"database":"jhcis","table":"person"}]`,
+		legacyTableConfigFile:   `[{"table_name":"person","keys":["pid"]}]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, TableConfigFile)
+	// โปรไฟล์ MySQL อยู่ก่อน ตาราง PostgreSQL ต้องยังได้ dbname ของโปรไฟล์ PostgreSQL
+	cfg := &Config{Profiles: []Profile{
+		{Name: "jhcis", DBType: DBTypeMySQL, DBName: "jhcis", TableConfigFile: path},
+		{Name: "hosxp", DBType: DBTypePostgreSQL, DBName: "hosxp", TableConfigFile: path},
+	}}
+
+	// โหลด config.json พร้อมกันหลายครั้ง (เช่น หน้าจอหลักและตัวเฝ้าดูไฟล์) ย้ายเพียงครั้งเดียวโดยไม่มีข้อผิดพลาด
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- cfg.MigrateTableConfigs() }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("MigrateTableConfigs() error = %v", err)
+		}
+	}
+
+	tc, err := LoadTableConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tc.ForDatabase("hosxp"); len(got) != 1 || got[0].Table != "person" || !reflect.DeepEqual(got[0].Keys, []string{"pid"}) {
+		t.Fatalf("ตารางของ hosxp = %+v", got)
+	}
+	if got := tc.ForDatabase("jhcis"); len(got) != 1 || got[0].Table != "person" {
+		t.Fatalf("ตารางของ jhcis = %+v", got)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// โหมดการเข้ารหัสการเชื่อมต่อ (ค่าใน tls.mode) ความหมายเดียวกับ sslmode ของ PostgreSQL
+const (
+	TLSModeDisable    = "disable"     // ไม่เข้ารหัส
+	TLSModeRequire    = "require"     // เข้ารหัส ตรวจใบรับรองเฉพาะเมื่อระบุ ca_file
+	TLSModeVerifyCA   = "verify-ca"   // ตรวจว่าใบรับรองออกโดย CA ที่เชื่อถือ
+	TLSModeVerifyFull = "verify-full" // ตรวจ CA และชื่อเซิร์ฟเวอร์ในใบรับรอง
+)
+
+// TLSModes รายการโหมดตามลำดับที่แสดงในฟอร์ม
+var TLSModes = []string{TLSModeDisable, TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull}
+
+// TLSConfig การเข้ารหัสการเชื่อมต่อกับฐานข้อมูลต้นทาง ไม่ระบุ tls ใน config.json คือ disable
+type TLSConfig struct {
+	Mode string `json:"mode"`
+	// CAFile ใบรับรอง CA (PEM) ที่ใช้ตรวจเซิร์ฟเวอร์ ว่างคือใช้ CA ของระบบ
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile และ KeyFile ใบรับรองของโปรแกรม (PEM) สำหรับเซิร์ฟเวอร์ที่ยืนยันตัวตนด้วยใบรับรอง
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// ServerName ชื่อที่คาดว่าอยู่ในใบรับรอง เมื่อเชื่อมต่อด้วย IP หรือชื่อที่ต่างจากใบรับรอง
+	ServerName string `json:"server_name,omitempty"`
+}
+
+// TLSMode คืนโหมดการเข้ารหัสของโปรไฟล์
+func (p *Profile) TLSMode() string {
+	if p.TLS == nil || p.TLS.Mode == "" {
+		return TLSModeDisable
+	}
+	return p.TLS.Mode
+}
+
+// tlsSettings คืน p.TLS สร้างใหม่ถ้ายังไม่มี (ใช้กับตัวแปรสภาพแวดล้อม)
+func (p *Profile) tlsSettings() *TLSConfig {
+	if p.TLS == nil {
+		p.TLS = &TLSConfig{Mode: TLSModeDisable}
+	}
+	return p.TLS
+}
+
+func (t *TLSConfig) problems() []string {
+	var problems []string
+	switch t.Mode {
+	case TLSModeDisable, TLSModeRequire, TLSModeVerifyCA, TLSModeVerifyFull:
+	case "":
+		problems = append(problems, "ไม่ได้ระบุ tls.mode")
+	default:
+		problems = append(problems, fmt.Sprintf("ไม่รองรับ tls.mode %q (ใช้ได้: %s)", t.Mode, strings.Join(TLSModes, ", ")))
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "ต้องระบุ tls.cert_file และ tls.key_file คู่กัน")
+	}
+	if t.Mode == TLSModeDisable {
+		return problems
+	}
+	for _, f := range []struct{ name, path string }{
+		{"tls.ca_file", t.CAFile}, {"tls.cert_file", t.CertFile}, {"tls.key_file", t.KeyFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			problems = append(problems, fmt.Sprintf("ไม่พบไฟล์ %s %q", f.name, f.path))
+		}
+	}
+	return problems
+}
+
+// ClientTLS สร้าง tls.Config ตามโหมดการเข้ารหัสของโปรไฟล์ คืน nil เมื่อไม่เข้ารหัส
+// ใช้ร่วมกันทั้งไดรเวอร์ database/sql, MongoDB และ binlog replication
+func (p *Profile) ClientTLS() (*tls.Config, error) {
+	mode := p.TLSMode()
+	if mode == TLSModeDisable {
+		return nil, nil
+	}
+	t := p.TLS
+	serverName := t.ServerName
+	if serverName == "" {
+		serverName = p.Host
+	}
+	config := &tls.Config{ServerName: serverName}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านใบรับรองของโปรแกรม %s: %v", t.CertFile, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ไม่สามารถอ่านใบรับรอง CA %s: %v", t.CAFile, err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ไม่พบใบรับรองในไฟล์ CA %s", t.CAFile)
+		}
+	}
+
+	// require ที่ระบุ CA ทำงานเหมือน verify-ca ตามพฤติกรรมของ libpq
+	if mode == TLSModeRequire && t.CAFile != "" {
+		mode = TLSModeVerifyCA
+	}
+	switch mode {
+	case TLSModeRequire:
+		config.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// ตรวจ chain เองโดยไม่ตรวจชื่อเซิร์ฟเวอร์ (crypto/tls ตรวจทั้งสองอย่างเสมอ)
+		config.InsecureSkipVerify = true
+		roots := config.RootCAs
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, roots)
+		}
+	case TLSModeVerifyFull:
+	default:
+		return nil, fmt.Errorf("ไม่รองรับ tls.mode %q", mode)
+	}
+	return config, nil
+}
+
+// verifyChain ตรวจว่าใบรับรองของเซิร์ฟเวอร์ออกโดย CA ใน roots (nil คือ CA ของระบบ)
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("เซิร์ฟเวอร์ไม่ได้ส่งใบรับรอง")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("ใบรับรองของเซิร์ฟเวอร์ไม่ถูกต้อง: %v", err)
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA CA สำหรับทดสอบ พร้อมไฟล์ PEM ของใบรับรอง
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hissync test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// serverCert ใบรับรองของเซิร์ฟเวอร์ชื่อ name ที่ CA นี้ออกให้
+func (ca *testCA) serverCert(t *testing.T, name string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// handshake เชื่อมต่อ TLS ด้วย client กับเซิร์ฟเวอร์ที่ใช้ใบรับรอง cert
+func handshake(client *tls.Config, cert tls.Certificate) error {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	server := tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{cert}})
+	go server.Handshake()
+	return tls.Client(clientConn, client).Handshake()
+}
+
+func TestClientTLS(t *testing.T) {
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0600)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name       string
+		tls        *TLSConfig
+		wantNil    bool
+		wantErr    string
+		serverName string
+		// handshakes ผลการเชื่อมต่อกับใบรับรองแต่ละแบบ (true = สำเร็จ)
+		trustedName, trustedOtherName, untrusted bool
+	}{
+		{name: "no tls", wantNil: true},
+		{name: "disable", tls: &TLSConfig{Mode: TLSModeDisable, CAFile: missing}, wantNil: true},
+		{name: "require", tls: &TLSConfig{Mode: TLSModeRequire}, serverName: "db.example",
+			trustedName: true, trustedOtherName: true, untrusted: true},
+		{name: "require with ca", tls: &TLSConfig{Mode: TLSModeRequire, CAFile: ca.file}, serverName: "db.example",
+			trustedName: true, trustedOtherName: true},
+		{name: "verify-ca", tls: &TLSConfig{Mode: TLSModeVerifyCA, CAFile: ca.file}, serverName: "db.example",
+			trustedName: true, trustedOtherName: true},
+		{name: "verify-full", tls: &TLSConfig{Mode: TLSModeVerifyFull, CAFile: ca.file}, serverName: "db.example",
+			trustedName: true},
+		{name: "verify-full server name", tls: &TLSConfig{Mode: TLSModeVerifyFull, CAFile: ca.file, ServerName: "other.example"},
+			serverName: "other.example", trustedOtherName: true},
+		{name: "missing ca file", tls: &TLSConfig{Mode: TLSModeVerifyCA, CAFile: missing}, wantErr: "ไม่สามารถอ่านใบรับรอง CA " + missing},
+		{name: "ca file without certificate", tls: &TLSConfig{Mode: TLSModeVerifyFull, CAFile: notPEM}, wantErr: "ไม่พบใบรับรองในไฟล์ CA " + notPEM},
+		{name: "missing client certificate", tls: &TLSConfig{Mode: TLSModeRequire, CertFile: missing, KeyFile: missing}, wantErr: "ไม่สามารถอ่านใบรับรองของโปรแกรม " + missing},
+		{name: "unknown mode", tls: &TLSConfig{Mode: "prefer"}, wantErr: `ไม่รองรับ tls.mode "prefer"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{Host: "db.example", TLS: tt.tls}
+			got, err := p.ClientTLS()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ClientTLS() error = %v ต้องการ %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ClientTLS() error = %v", err)
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("ClientTLS() = %v ต้องการ nil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if got.ServerName != tt.serverName {
+				t.Fatalf("ServerName = %q ต้องการ %q", got.ServerName, tt.serverName)
+			}
+			for _, h := range []struct {
+				name string
+				cert tls.Certificate
+				want bool
+			}{
+				{"trusted db.example", ca.serverCert(t, "db.example"), tt.trustedName},
+				{"trusted other.example", ca.serverCert(t, "other.example"), tt.trustedOtherName},
+				{"untrusted db.example", other.serverCert(t, "db.example"), tt.untrusted},
+			} {
+				if err := handshake(got.Clone(), h.cert); (err == nil) != h.want {
+					t.Errorf("เชื่อมต่อกับใบรับรอง %s: error = %v ต้องการสำเร็จ %v", h.name, err, h.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ระยะเวลารอให้การเขียนไฟล์เสร็จก่อนโหลดใหม่ (โปรแกรมแก้ไขไฟล์มักเขียนหลายครั้งติดกัน)
+const watchDebounce = 500 * time.Millisecond
+
+// WatchConfig เฝ้าดูการเปลี่ยนแปลงของ config.json และเรียก onChange เมื่อค่าที่โหลดได้เปลี่ยนไป
+// ถ้าไฟล์ใหม่ไม่ถูกต้อง onChange จะได้รับ err และค่าเดิมยังคงใช้งานต่อ
+// เรียก stop เพื่อหยุดเฝ้าดู
+func WatchConfig(filePath string, onChange func(*Config, error)) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// เฝ้าดูทั้งโฟลเดอร์ เพราะการบันทึกแบบเปลี่ยนชื่อไฟล์จะทำให้ watch ของไฟล์เดิมหลุด
+	if err := watcher.Add(filepath.Dir(filePath)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	last, _ := LoadConfig(filePath)
+	name := filepath.Clean(filePath)
+	done := make(chan struct{})
+
+	go func() {
+		var timer *time.Timer
+		reload := make(chan struct{}, 1)
+		for {
+			select {
+			case <-done:
+				return
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != name || ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(watchDebounce, func() {
+					select {
+					case reload <- struct{}{}:
+					default:
+					}
+				})
+			case <-reload:
+				cfg, err := LoadConfig(filePath)
+				if err != nil {
+					onChange(nil, err)
+					continue
+				}
+				if last != nil && reflect.DeepEqual(last, cfg) {
+					continue
+				}
+				last = cfg
+				onChange(cfg, nil)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		watcher.Close()
+	}, nil
+}
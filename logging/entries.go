@@ -0,0 +1,93 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry log หนึ่งบรรทัดที่อ่านจากไฟล์ log
+type Entry struct {
+	Time      time.Time
+	Level     slog.Level
+	Message   string
+	Component string
+	Profile   string
+	// Attrs ฟิลด์อื่นๆ ในรูปแบบ key=value เรียงตามชื่อ
+	Attrs string
+}
+
+// ReadEntries อ่าน log ล่าสุดไม่เกิน limit บรรทัดจากไฟล์ log ปัจจุบัน เรียงจากใหม่ไปเก่า
+// บรรทัดที่ไม่ใช่ JSON (เช่น จากโปรแกรมรุ่นเก่า) จะถูกข้าม
+func ReadEntries(limit int) ([]Entry, error) {
+	path := File()
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ไม่สามารถเปิดไฟล์ log %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry, ok := parseEntry(scanner.Bytes())
+		if !ok {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > limit*2 {
+			entries = append(entries[:0], entries[len(entries)-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ไม่สามารถอ่านไฟล์ log %s: %v", path, err)
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+func parseEntry(line []byte) (Entry, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return Entry{}, false
+	}
+	var entry Entry
+	if s, ok := fields[slog.TimeKey].(string); ok {
+		entry.Time, _ = time.Parse(time.RFC3339Nano, s)
+	}
+	if s, ok := fields[slog.LevelKey].(string); ok {
+		entry.Level.UnmarshalText([]byte(s))
+	}
+	entry.Message, _ = fields[slog.MessageKey].(string)
+	entry.Component, _ = fields["component"].(string)
+	entry.Profile, _ = fields["profile"].(string)
+	for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey, "component", "profile"} {
+		delete(fields, key)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attrs := make([]string, len(keys))
+	for i, key := range keys {
+		attrs[i] = fmt.Sprintf("%s=%v", key, fields[key])
+	}
+	entry.Attrs = strings.Join(attrs, " ")
+	return entry, true
+}
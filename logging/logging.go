@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	config "hissync-10/functions"
+)
+
+// ชื่อ component มาตรฐาน ใช้กรองใน log viewer
+const (
+	ComponentApp     = "app"
+	ComponentConfig  = "config"
+	ComponentCapture = "capture"
+	ComponentSink    = "sink"
+	ComponentMonitor = "monitor"
+	ComponentUI      = "ui"
+)
+
+var state struct {
+	sync.Mutex
+	level    slog.LevelVar
+	file     *lumberjack.Logger
+	settings config.LogConfig
+}
+
+// Setup ตั้งค่า slog ให้เขียน log แบบ JSON ลงไฟล์ที่หมุนตามขนาดและอายุ
+// และเขียนแบบข้อความไปที่ console ด้วยถ้าไม่เป็น nil
+// log.Println เดิมจะถูกส่งผ่าน slog ด้วย
+func Setup(settings config.LogConfig, console io.Writer) {
+	state.Lock()
+	defer state.Unlock()
+
+	state.file = newFile(settings)
+	state.settings = settings
+	if level, err := settings.SlogLevel(); err == nil {
+		state.level.Set(level)
+	}
+
+	opts := &slog.HandlerOptions{Level: &state.level}
+	handlers := []slog.Handler{slog.NewJSONHandler(redactWriter{fileWriter{}}, opts)}
+	if console != nil {
+		handlers = append(handlers, slog.NewTextHandler(redactWriter{console}, opts))
+	}
+	slog.SetDefault(slog.New(fanout(handlers)))
+}
+
+// Apply ปรับ level และการหมุนไฟล์ตาม config ใหม่โดยไม่ต้องรีสตาร์ท
+func Apply(settings config.LogConfig) {
+	state.Lock()
+	defer state.Unlock()
+
+	if level, err := settings.SlogLevel(); err == nil {
+		state.level.Set(level)
+	}
+	if settings == state.settings {
+		return
+	}
+	old := state.file
+	state.file = newFile(settings)
+	state.settings = settings
+	if old != nil {
+		old.Close()
+	}
+}
+
+// File คืนตำแหน่งไฟล์ log ปัจจุบัน
+func File() string {
+	state.Lock()
+	defer state.Unlock()
+	if state.file == nil {
+		return config.DefaultLogFile
+	}
+	return state.file.Filename
+}
+
+// For คืน logger ของ component (เรียกเมื่อจะใช้ เพื่อให้ได้ logger หลัง Setup)
+func For(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+func newFile(settings config.LogConfig) *lumberjack.Logger {
+	os.MkdirAll(filepath.Dir(settings.File), 0755)
+	return &lumberjack.Logger{
+		Filename:   settings.File,
+		MaxSize:    settings.MaxSizeMB,
+		MaxAge:     settings.MaxAgeDays,
+		MaxBackups: settings.MaxBackups,
+		LocalTime:  true,
+	}
+}
+
+// fileWriter เขียนลงไฟล์ log ปัจจุบัน (เปลี่ยนไฟล์ได้ด้วย Apply)
+type fileWriter struct{}
+
+func (fileWriter) Write(p []byte) (int, error) {
+	state.Lock()
+	defer state.Unlock()
+	return state.file.Write(p)
+}
+
+// redactWriter ปิดบังค่าลับที่ลงทะเบียนไว้ก่อนเขียน log
+type redactWriter struct {
+	w io.Writer
+}
+
+func (r redactWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write([]byte(config.Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// fanout ส่ง record เดียวกันไปยังหลาย handler
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanout) Handle(ctx context.Context, r slog.Record) error {
+	var first error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			if err := h.Handle(ctx, r.Clone()); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	out := make(fanout, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
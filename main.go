@@ -2,13 +2,17 @@ package main
 
 //test
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strings"
+	"time"
 
+	"hissync-10/capture"
+	"hissync-10/cli"
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/pipeline"
 	"hissync-10/ui"
 	"hissync-10/ui/forms"
 	"hissync-10/ui/views"
@@ -16,36 +20,39 @@ import (
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
-
-	_ "github.com/denisenkom/go-mssqldb" // Microsoft SQL Server driver
-	_ "github.com/go-sql-driver/mysql"   // MySQL driver
-	_ "github.com/lib/pq"                // PostgreSQL driver
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Config โครงสร้างสำหรับจัดเก็บการตั้งค่าฐานข้อมูล
-type Config struct {
-    DBType   string `json:"dbtype"`
-    Host     string `json:"host"`
-    Port     string `json:"port"`
-    Username string `json:"username"`
-    Password string `json:"password"`
-    DBName   string `json:"dbname"`
-    LogFilePath string `json:"log_file_path"`
-    StateFile    string `json:"state_file"`
-}
-
 // สร้างตัวแปรสำหรับ Status Bar และ Content
 var statusLabel *widget.RichText
 var contentContainer *fyne.Container
-var appConfig Config
+
+// หน้าต่างหลัก
+var mainWindow fyne.Window
+
+// รายการโปรไฟล์แหล่งข้อมูลใน Sidebar
+var profileMenu *fyne.Container
+
+// activePipe แหล่งข้อมูลและปลายทางที่ทำงานอยู่ (nil ถ้าเปิดที่เก็บเหตุการณ์ไม่ได้)
+var activePipe *pipeline.Pipeline
+
+// ระยะเวลาปรับข้อความความล่าช้าใน Status Bar
+const statusRefreshInterval = 2 * time.Second
 
 func main() {
+    // hissync <คำสั่ง> ทำงานแบบไม่เปิดหน้าจอ เช่น hissync run, hissync status
+    if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+        os.Exit(cli.Run(os.Args[1:]))
+    }
+
+    // เขียน log ด้วยค่าเริ่มต้นก่อน แล้วปรับตามหัวข้อ log ใน config.json เมื่อโหลดสำเร็จ
+    logging.Setup((*config.Config)(nil).LogSettings(), os.Stderr)
+
     myApp := app.New()
     myWindow := myApp.NewWindow("HISSYNC v10.0")
+    mainWindow = myWindow
 
     statusLabel = widget.NewRichTextFromMarkdown("**สถานะ:** กำลังตรวจสอบการเชื่อมต่อฐานข้อมูล...")
 
@@ -58,16 +65,34 @@ func main() {
         ),
     )
 
-    config, err := loadConfig("config.json")
-    appConfig = config
+    manager := capture.NewManager()
 
-    if err != nil || !testConnection(config) {
-        log.Println("Failed to connect to the database, showing connection form.")
-        updateStatusBar("สถานะ: ไม่เชื่อมต่อฐานข้อมูล", false)
-        forms.ShowConnectionForm(myWindow)
+    // รายการโปรไฟล์แหล่งข้อมูลพร้อมสถานะ สร้างใหม่เมื่อ config หรือสถานะเปลี่ยน
+    profileMenu = container.NewVBox()
+    manager.SubscribeStatus(func(capture.ProfileStatus) {
+        refreshProfileMenu(manager)
+    })
+
+    // เหตุการณ์ถูกบันทึกลง hissync.db และส่งไปยังปลายทาง เหมือน hissync run
+    pipe, err := pipeline.Open(manager)
+    if err != nil {
+        logging.For(logging.ComponentApp).Error("ไม่สามารถเปิดที่เก็บเหตุการณ์", "error", err)
+        updateStatusBar(fmt.Sprintf("สถานะ: ไม่เริ่มอ่านข้อมูล (%v)", err), false)
+        dialog.ShowError(err, myWindow)
     } else {
-        log.Println("Connected to the database successfully!")
-        updateStatusBar(fmt.Sprintf("สถานะ: เชื่อมต่อฐานข้อมูล %s สำเร็จ", config.DBType), true)
+        defer pipe.Close()
+        activePipe = pipe
+        go func() {
+            for range time.Tick(statusRefreshInterval) {
+                updateProfileSummary(manager)
+            }
+        }()
+        // ถ้ากุญแจเข้ารหัสสร้างจากรหัสผ่านผู้ดูแล ต้องถามรหัสผ่านก่อนอ่าน config.json
+        if config.KeyUsesPassphrase() && os.Getenv("HISSYNC_PASSPHRASE") == "" {
+            askPassphrase(myWindow, func() { startCapture(pipe, myWindow) })
+        } else {
+            startCapture(pipe, myWindow)
+        }
     }
 
     contentContainer = container.NewMax(widget.NewLabel("ยินดีต้อนรับสู่แอพพลิเคชัน"))
@@ -79,36 +104,52 @@ func main() {
     sidebarMenu := container.NewVBox(
         widget.NewButton("ข้อมูลค้างส่ง", func() {
             contentContainer.Objects = []fyne.CanvasObject{
-                views.PendingDataView(),
+                views.PendingDataView(pipe),
+            }
+            contentContainer.Refresh()
+        }),
+        widget.NewButton("ประวัติรายบุคคล", func() {
+            contentContainer.Objects = []fyne.CanvasObject{
+                views.TimelineView(pipe, myWindow),
+            }
+            contentContainer.Refresh()
+        }),
+        widget.NewButton("รออนุมัติ", func() {
+            contentContainer.Objects = []fyne.CanvasObject{
+                views.QuarantineView(pipe, myWindow),
             }
             contentContainer.Refresh()
         }),
         widget.NewButton("รายงาน", func() {
             contentContainer.Objects = []fyne.CanvasObject{
-                views.ReportView(),
+                views.ReportView(pipe, myWindow),
             }
             contentContainer.Refresh()
         }),
         widget.NewButton("การตั้งค่า", func() {
             contentContainer.Objects = []fyne.CanvasObject{
-                views.SettingsView(),
+                views.SettingsView(manager, myWindow),
             }
             contentContainer.Refresh()
         }),
-        widget.NewButton("Postgres Log File"+appConfig.LogFilePath, func() {
+        widget.NewButton("ปัญหา", func() {
+            if incidentsView == nil {
+                incidentsView = views.IncidentsView(manager, myWindow)
+            }
             contentContainer.Objects = []fyne.CanvasObject{
-                views.PostgreSQLLogView("config.json", "table_config.json"),
+                incidentsView,
             }
             contentContainer.Refresh()
         }),
-
-        widget.NewButton("MySQL Log File", func() {
+        widget.NewButton("Log ของโปรแกรม", func() {
             contentContainer.Objects = []fyne.CanvasObject{
-                views.MySQLLogView("config.json", "db_table_config.json", myWindow),
+                views.AppLogView(),
             }
             contentContainer.Refresh()
         }),
-
+        widget.NewSeparator(),
+        widget.NewLabelWithStyle("แหล่งข้อมูล", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+        profileMenu,
     )
 
     sidebarContainer := container.NewVBox(
@@ -132,89 +173,228 @@ func main() {
 // updateStatusBar อัปเดตข้อความและสีของ Status Bar
 func updateStatusBar(message string, isSuccess bool) {
     if isSuccess {
-        statusLabel.Segments = []widget.RichTextSegment{
-            &widget.TextSegment{
-                Text: message,
-                Style: widget.RichTextStyle{
-                    ColorName: theme.ColorNameSuccess,
-                    TextStyle: fyne.TextStyle{Bold: true},
-                },
-            },
-        }
+        setStatusBar(message, theme.ColorNameSuccess)
     } else {
-        statusLabel.Segments = []widget.RichTextSegment{
-            &widget.TextSegment{
-                Text: message,
-                Style: widget.RichTextStyle{
-                    ColorName: theme.ColorNameError,
-                    TextStyle: fyne.TextStyle{Bold: true},
-                },
+        setStatusBar(message, theme.ColorNameError)
+    }
+}
+
+// setStatusBar แสดงข้อความใน Status Bar ด้วยสีที่ระบุ
+func setStatusBar(message string, colorName fyne.ThemeColorName) {
+    statusLabel.Segments = []widget.RichTextSegment{
+        &widget.TextSegment{
+            Text: message,
+            Style: widget.RichTextStyle{
+                ColorName: colorName,
+                TextStyle: fyne.TextStyle{Bold: true},
             },
-        }
+        },
     }
     statusLabel.Refresh()
 }
 
+// startCapture โหลด config.json เริ่มแหล่งข้อมูล และเฝ้าดูการเปลี่ยนแปลงของไฟล์
+func startCapture(pipe *pipeline.Pipeline, myWindow fyne.Window) {
+    cfg, err := config.LoadConfig(config.ConfigFile)
+    if err != nil {
+        logging.For(logging.ComponentConfig).Warn("โหลด config.json ไม่สำเร็จ แสดงหน้าตั้งค่าการเชื่อมต่อ", "error", err)
+        updateStatusBar("สถานะ: ไม่เชื่อมต่อฐานข้อมูล", false)
+        forms.ShowConnectionForm(myWindow)
+    } else {
+        applyConfig(pipe, cfg)
+    }
 
-// loadConfig โหลดการตั้งค่าจากไฟล์ config.json
-func loadConfig(filepath string) (Config, error) {
-    var config Config
-    file, err := os.Open(filepath)
+    // เมื่อ config.json เปลี่ยน ให้เชื่อมต่อใหม่โดยไม่ต้องรีสตาร์ทโปรแกรม
+    _, err = config.WatchConfig(config.ConfigFile, func(cfg *config.Config, err error) {
+        if err != nil {
+            logging.For(logging.ComponentConfig).Warn("config.json ไม่ถูกต้อง ใช้การตั้งค่าเดิม", "error", err)
+            updateStatusBar(fmt.Sprintf("สถานะ: config.json ไม่ถูกต้อง ใช้ค่าเดิมต่อ (%v)", config.RedactError(err)), false)
+            return
+        }
+        logging.For(logging.ComponentConfig).Info("config.json เปลี่ยน เชื่อมต่อใหม่")
+        applyConfig(pipe, cfg)
+    })
     if err != nil {
-        return config, err
+        logging.For(logging.ComponentConfig).Warn("ไม่สามารถติดตามการเปลี่ยนแปลง config.json", "error", err)
     }
-    defer file.Close()
+}
 
-    decoder := json.NewDecoder(file)
-    err = decoder.Decode(&config)
-    return config, err
+// askPassphrase ถามรหัสผ่านผู้ดูแลสำหรับถอดรหัส config.json แล้วเรียก onUnlock เมื่อรหัสผ่านถูกต้อง
+func askPassphrase(myWindow fyne.Window, onUnlock func()) {
+    passphraseEntry := widget.NewPasswordEntry()
+    dialog.ShowForm("ปลดล็อกการตั้งค่า", "ตกลง", "ยกเลิก",
+        []*widget.FormItem{widget.NewFormItem("รหัสผ่านผู้ดูแล", passphraseEntry)},
+        func(ok bool) {
+            if !ok {
+                updateStatusBar("สถานะ: ไม่ได้ปลดล็อกการตั้งค่า", false)
+                return
+            }
+            config.SetPassphrase(passphraseEntry.Text)
+            if _, err := config.ReadConfigFile(config.ConfigFile); errors.Is(err, config.ErrWrongPassphrase) {
+                dialog.ShowError(err, myWindow)
+                askPassphrase(myWindow, onUnlock)
+                return
+            }
+            onUnlock()
+        }, myWindow)
 }
 
-// testConnection ทดสอบการเชื่อมต่อกับฐานข้อมูล
-func testConnection(config Config) bool {
-    switch config.DBType {
-    case "PostgreSQL":
-        dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-            config.Host, config.Port, config.Username, config.Password, config.DBName)
-        db, err := sql.Open("postgres", dsn)
-        if err != nil {
-            log.Println("PostgreSQL connection error:", err)
-            return false
+// applyConfig เริ่มแหล่งข้อมูลของทุกโปรไฟล์และปลายทางตาม cfg (แต่ละโปรไฟล์เชื่อมต่อและรายงานสถานะของตัวเอง)
+func applyConfig(pipe *pipeline.Pipeline, cfg *config.Config) {
+    pipe.Apply(cfg)
+    refreshProfileMenu(pipe.Manager)
+}
+
+// หน้าจอปัญหา สร้างครั้งแรกที่เปิดและติดตามเหตุการณ์จาก manager ตลอดการทำงาน
+var incidentsView fyne.CanvasObject
+
+// หน้าจอ Log ของแต่ละโปรไฟล์ สร้างครั้งเดียวและติดตามเหตุการณ์จาก manager ตลอดการทำงาน
+var logViews = map[string]fyne.CanvasObject{}
+
+// profileLogView คืนหน้าจอ Log ของโปรไฟล์ตามประเภทฐานข้อมูล
+func profileLogView(pipe *pipeline.Pipeline, p config.Profile) fyne.CanvasObject {
+    key := p.Name + "|" + p.Engine
+    if view, ok := logViews[key]; ok {
+        return view
+    }
+    var view fyne.CanvasObject
+    switch p.Engine {
+    case config.EngineBinlog:
+        view = views.MySQLLogView(pipe, p.Name, mainWindow)
+    case config.EnginePostgresLog:
+        view = views.PostgreSQLLogView(pipe, p.Name, mainWindow)
+    default:
+        view = widget.NewLabel(fmt.Sprintf("โปรไฟล์ %s ตรวจสอบการเชื่อมต่ออย่างเดียว ไม่มีการอ่านการเปลี่ยนแปลง", p.Name))
+    }
+    logViews[key] = view
+    return view
+}
+
+// refreshProfileMenu สร้างปุ่มของแต่ละโปรไฟล์ใน Sidebar พร้อมสถานะล่าสุด
+func refreshProfileMenu(manager *capture.Manager) {
+    cfg := manager.Config()
+    if cfg == nil {
+        return
+    }
+    statuses := make(map[string]capture.ProfileStatus)
+    for _, st := range manager.Statuses() {
+        statuses[st.Name] = st
+    }
+
+    objects := make([]fyne.CanvasObject, 0, len(cfg.Profiles))
+    for _, p := range cfg.Profiles {
+        p := p
+        button := widget.NewButton(fmt.Sprintf("%s %s (%s)", statusIcon(statuses[p.Name].State), p.Name, p.DBType), func() {
+            contentContainer.Objects = []fyne.CanvasObject{
+                profileLogView(activePipe, p),
+            }
+            contentContainer.Refresh()
+        })
+        button.Alignment = widget.ButtonAlignLeading
+        objects = append(objects, button)
+    }
+    profileMenu.Objects = objects
+    profileMenu.Refresh()
+    updateProfileSummary(manager)
+}
+
+// statusIcon สัญลักษณ์แสดงสถานะของโปรไฟล์
+func statusIcon(state string) string {
+    switch state {
+    case capture.StatusRunning:
+        return "🟢"
+    case capture.StatusConnecting:
+        return "🟡"
+    case capture.StatusError:
+        return "🔴"
+    default:
+        return "⚪"
+    }
+}
+
+// updateProfileSummary แสดงจำนวนโปรไฟล์ที่เชื่อมต่อสำเร็จและความล่าช้าใน Status Bar
+// สีเตือนเมื่อความล่าช้าเกินเกณฑ์ใน lag_warning
+func updateProfileSummary(manager *capture.Manager) {
+    cfg := manager.Config()
+    if cfg == nil {
+        return
+    }
+    running, enabled := 0, 0
+    var failed []string
+    for _, st := range manager.Statuses() {
+        if st.State == capture.StatusDisabled {
+            continue
+        }
+        enabled++
+        switch st.State {
+        case capture.StatusRunning:
+            running++
+        case capture.StatusError:
+            failed = append(failed, st.Name)
         }
-        return db.Ping() == nil
+    }
+    message := fmt.Sprintf("สถานะ: เชื่อมต่อ %d/%d โปรไฟล์", running, enabled)
+    if len(failed) > 0 {
+        message += fmt.Sprintf(" (ผิดพลาด: %s)", strings.Join(failed, ", "))
+    }
 
-    case "MySQL":
-        dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        db, err := sql.Open("mysql", dsn)
-        if err != nil {
-            log.Println("MySQL connection error:", err)
-            return false
+    captureWarn, deliveryWarn := cfg.LagThresholds()
+
+    var captureLag time.Duration
+    var bytesBehind int64
+    var unknown []string
+    for _, lag := range manager.Lags() {
+        if lag.Unknown() {
+            unknown = append(unknown, lag.Profile)
+            continue
+        }
+        if lag.Capture > captureLag {
+            captureLag = lag.Capture
         }
-        return db.Ping() == nil
+        bytesBehind += lag.BytesBehind
+    }
+    message += fmt.Sprintf(" | อ่านล่าช้า %s", captureLag.Round(time.Second))
+    if bytesBehind > 0 {
+        message += fmt.Sprintf(" (ค้างอ่าน %d bytes)", bytesBehind)
+    }
+    if len(unknown) > 0 {
+        message += fmt.Sprintf(" (ไม่ทราบความล่าช้า: %s)", strings.Join(unknown, ", "))
+    }
+    warning := captureLag > captureWarn
 
-    case "Microsoft SQL Server":
-        dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        db, err := sql.Open("sqlserver", dsn)
-        if err != nil {
-            log.Println("SQL Server connection error:", err)
-            return false
+    if activePipe != nil {
+        pending := 0
+        var deliveryLag time.Duration
+        for _, st := range activePipe.Dispatcher.Statuses() {
+            pending += st.Pending
+            if lag := st.DeliveryLag(); lag > deliveryLag {
+                deliveryLag = lag
+            }
         }
-        return db.Ping() == nil
+        message += fmt.Sprintf(" | ค้างส่ง %d รายการ", pending)
+        if deliveryLag > 0 {
+            message += fmt.Sprintf(" (เก่าสุด %s)", deliveryLag.Round(time.Second))
+        }
+        warning = warning || deliveryLag > deliveryWarn
+    }
 
-    case "MongoDB":
-        dsn := fmt.Sprintf("mongodb://%s:%s@%s:%s/%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(dsn))
-        if err != nil {
-            log.Println("MongoDB connection error:", err)
-            return false
+    openIncidents := 0
+    for _, inc := range manager.Incidents() {
+        if inc.Open() {
+            openIncidents++
         }
-        return client.Ping(context.TODO(), nil) == nil
+    }
+    if openIncidents > 0 {
+        message += fmt.Sprintf(" | ปัญหาที่ยังไม่แก้ไข %d รายการ", openIncidents)
+        warning = true
+    }
 
+    switch {
+    case enabled == 0 || len(failed) > 0:
+        setStatusBar(message, theme.ColorNameError)
+    case warning:
+        setStatusBar(message, theme.ColorNameWarning)
     default:
-        log.Println("Unsupported database type:", config.DBType)
-        return false
+        setStatusBar(message, theme.ColorNameSuccess)
     }
 }
@@ -0,0 +1,261 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+)
+
+// metricWriter เขียน metrics ในรูปแบบข้อความของ Prometheus (text exposition format 0.0.4)
+type metricWriter struct {
+	w *bufio.Writer
+}
+
+func (mw *metricWriter) header(name, kind, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample เขียนค่าหนึ่งบรรทัด labels เป็นคู่ ชื่อ, ค่า
+func (mw *metricWriter) sample(name string, value float64, labels ...string) {
+	mw.w.WriteString(name)
+	if len(labels) > 0 {
+		mw.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				mw.w.WriteByte(',')
+			}
+			fmt.Fprintf(mw.w, "%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		mw.w.WriteByte('}')
+	}
+	mw.w.WriteByte(' ')
+	mw.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	mw.w.WriteByte('\n')
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
+
+func (m *Monitor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	mw := &metricWriter{w: bufio.NewWriter(w)}
+	defer mw.w.Flush()
+
+	mw.header("hissync_start_time_seconds", "gauge", "Start time of the HISSYNC process since unix epoch in seconds.")
+	mw.sample("hissync_start_time_seconds", unixSeconds(m.started))
+
+	m.writeProfiles(mw)
+	m.writeLags(mw)
+	m.writeEvents(mw)
+	m.writeSinks(mw)
+	m.writeQuarantine(mw)
+	m.writeCheckpoints(mw)
+}
+
+func (m *Monitor) writeProfiles(mw *metricWriter) {
+	mw.header("hissync_profile_running", "gauge", "Whether the source profile is connected and capturing (1) or not (0).")
+	for _, st := range m.manager.Statuses() {
+		running := 0.0
+		if st.State == capture.StatusRunning {
+			running = 1
+		}
+		mw.sample("hissync_profile_running", running, "profile", st.Name, "dbtype", st.DBType, "state", st.State)
+	}
+	type incidentKey struct{ profile, severity string }
+	open := make(map[incidentKey]int)
+	for _, inc := range m.manager.Incidents() {
+		if inc.Open() {
+			open[incidentKey{inc.Profile, inc.Severity}]++
+		}
+	}
+	keys := make([]incidentKey, 0, len(open))
+	for k := range open {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].profile != keys[j].profile {
+			return keys[i].profile < keys[j].profile
+		}
+		return keys[i].severity < keys[j].severity
+	})
+	mw.header("hissync_open_incidents", "gauge", "Number of unresolved capture incidents.")
+	for _, k := range keys {
+		mw.sample("hissync_open_incidents", float64(open[k]), "profile", k.profile, "severity", k.severity)
+	}
+}
+
+func (m *Monitor) writeLags(mw *metricWriter) {
+	lags := m.manager.Lags()
+	mw.header("hissync_capture_lag_seconds", "gauge", "Now minus the source timestamp of the latest captured event (0 when caught up).")
+	for _, lag := range lags {
+		mw.sample("hissync_capture_lag_seconds", lag.Capture.Seconds(), "profile", lag.Profile)
+	}
+	mw.header("hissync_capture_bytes_behind", "gauge", "Bytes of binlog or log file not yet read by the profile.")
+	for _, lag := range lags {
+		if lag.BytesBehind >= 0 {
+			mw.sample("hissync_capture_bytes_behind", float64(lag.BytesBehind), "profile", lag.Profile)
+		}
+	}
+	mw.header("hissync_capture_lag_unknown", "gauge", "Whether the profile cannot tell how far behind the source it is (1), in which case capture lag reads 0.")
+	for _, lag := range lags {
+		unknown := 0.0
+		if lag.Unknown() {
+			unknown = 1
+		}
+		mw.sample("hissync_capture_lag_unknown", unknown, "profile", lag.Profile)
+	}
+}
+
+func (m *Monitor) writeEvents(mw *metricWriter) {
+	m.mu.Lock()
+	keys := make([]eventKey, 0, len(m.events))
+	for k := range m.events {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.profile != b.profile {
+			return a.profile < b.profile
+		}
+		if a.table != b.table {
+			return a.table < b.table
+		}
+		return a.operation < b.operation
+	})
+	events := make([]uint64, len(keys))
+	for i, k := range keys {
+		events[i] = m.events[k]
+	}
+	errorProfiles := sortedKeys(m.errors)
+	errors := make([]uint64, len(errorProfiles))
+	for i, p := range errorProfiles {
+		errors[i] = m.errors[p]
+	}
+	lastProfiles := sortedKeys(m.lastEvent)
+	lastEvents := make([]time.Time, len(lastProfiles))
+	for i, p := range lastProfiles {
+		lastEvents[i] = m.lastEvent[p]
+	}
+	m.mu.Unlock()
+
+	mw.header("hissync_events_captured_total", "counter", "Change events captured since start, by profile, table and operation.")
+	for i, k := range keys {
+		mw.sample("hissync_events_captured_total", float64(events[i]), "profile", k.profile, "table", k.table, "operation", k.operation)
+	}
+	mw.header("hissync_capture_errors_total", "counter", "Errors reported by source profiles since start.")
+	for i, p := range errorProfiles {
+		mw.sample("hissync_capture_errors_total", float64(errors[i]), "profile", p)
+	}
+	mw.header("hissync_last_event_timestamp_seconds", "gauge", "Source timestamp of the latest captured change event.")
+	for i, p := range lastProfiles {
+		mw.sample("hissync_last_event_timestamp_seconds", unixSeconds(lastEvents[i]), "profile", p)
+	}
+}
+
+func (m *Monitor) writeSinks(mw *metricWriter) {
+	statuses := m.dispatcher.Statuses()
+
+	mw.header("hissync_queue_depth", "gauge", "Events waiting to be delivered to the sink.")
+	for _, st := range statuses {
+		mw.sample("hissync_queue_depth", float64(st.Pending), "sink", st.Name)
+	}
+	mw.header("hissync_delivery_lag_seconds", "gauge", "Age of the oldest undelivered event in the sink queue (0 when the queue is empty).")
+	for _, st := range statuses {
+		mw.sample("hissync_delivery_lag_seconds", st.DeliveryLag().Seconds(), "sink", st.Name)
+	}
+	mw.header("hissync_sink_delivered_total", "counter", "Events delivered to the sink since start.")
+	for _, st := range statuses {
+		mw.sample("hissync_sink_delivered_total", float64(st.Delivered), "sink", st.Name)
+	}
+	mw.header("hissync_sink_failed_total", "counter", "Events whose delivery to the sink failed since start (they are retried).")
+	for _, st := range statuses {
+		mw.sample("hissync_sink_failed_total", float64(st.Failed), "sink", st.Name)
+	}
+	mw.header("hissync_sink_last_success_timestamp_seconds", "gauge", "Time of the latest successful delivery to the sink.")
+	for _, st := range statuses {
+		if !st.LastSent.IsZero() {
+			mw.sample("hissync_sink_last_success_timestamp_seconds", unixSeconds(st.LastSent), "sink", st.Name)
+		}
+	}
+}
+
+// writeQuarantine จำนวนธุรกรรมที่ถูกกักไว้รออนุมัติของแต่ละโปรไฟล์
+func (m *Monitor) writeQuarantine(mw *metricWriter) {
+	holds, err := m.store.Holds()
+	if err != nil {
+		return
+	}
+	quarantined := make(map[string]int)
+	for _, h := range holds {
+		if h.Quarantined() {
+			quarantined[h.Profile]++
+		}
+	}
+	mw.header("hissync_quarantined_transactions", "gauge", "Transactions held by the mass-change safeguard and waiting for approval.")
+	for _, p := range sortedKeys(quarantined) {
+		mw.sample("hissync_quarantined_transactions", float64(quarantined[p]), "profile", p)
+	}
+}
+
+// writeCheckpoints อ่านตำแหน่งล่าสุดจาก state file ของแต่ละโปรไฟล์
+func (m *Monitor) writeCheckpoints(mw *metricWriter) {
+	cfg := m.manager.Config()
+	if cfg == nil {
+		return
+	}
+
+	type checkpoint struct {
+		profile config.Profile
+		state   capture.State
+		saved   time.Time
+	}
+	var checkpoints []checkpoint
+	for _, p := range cfg.Profiles {
+		info, err := os.Stat(p.StateFile)
+		if err != nil {
+			continue
+		}
+		state, err := capture.LoadState(p.StateFile)
+		if err != nil {
+			continue
+		}
+		checkpoints = append(checkpoints, checkpoint{p, state, info.ModTime()})
+	}
+
+	mw.header("hissync_checkpoint_binlog_position", "gauge", "Binlog position of the latest checkpoint (MySQL profiles).")
+	for _, c := range checkpoints {
+		if pos, err := strconv.ParseUint(c.state.LastBinlogPosition, 10, 64); err == nil && c.profile.Engine == config.EngineBinlog {
+			mw.sample("hissync_checkpoint_binlog_position", float64(pos), "profile", c.profile.Name, "file", c.state.LastLogFile)
+		}
+	}
+	mw.header("hissync_checkpoint_log_timestamp_seconds", "gauge", "Timestamp of the last log line read (PostgreSQL log profiles).")
+	for _, c := range checkpoints {
+		if t, err := time.Parse(capture.CheckpointTimeFormat, c.state.LastLogDatetime); err == nil && c.profile.Engine == config.EnginePostgresLog {
+			mw.sample("hissync_checkpoint_log_timestamp_seconds", unixSeconds(t), "profile", c.profile.Name, "file", c.state.LastLogFile)
+		}
+	}
+	mw.header("hissync_checkpoint_saved_timestamp_seconds", "gauge", "Time the checkpoint (state file) was last saved.")
+	for _, c := range checkpoints {
+		mw.sample("hissync_checkpoint_saved_timestamp_seconds", unixSeconds(c.saved), "profile", c.profile.Name)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package monitor
+
+import (
+	"bufio"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricWriterSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric string
+		value  float64
+		labels []string
+		want   string
+	}{
+		{"no labels", "hissync_up", 1, nil, "hissync_up 1\n"},
+		{"one label", "hissync_profile_running", 0, []string{"profile", "jhcis"},
+			`hissync_profile_running{profile="jhcis"} 0` + "\n"},
+		{"several labels", "hissync_events_total", 42, []string{"profile", "jhcis", "operation", "DELETE"},
+			`hissync_events_total{profile="jhcis",operation="DELETE"} 42` + "\n"},
+		{"escaped label value", "hissync_sink_pending", 3, []string{"sink", "a\\b \"c\"\nd"},
+			`hissync_sink_pending{sink="a\\b \"c\"\nd"} 3` + "\n"},
+		{"thai label value", "hissync_profile_running", 1, []string{"profile", "รพ.สต."},
+			`hissync_profile_running{profile="รพ.สต."} 1` + "\n"},
+		{"odd label list ignores last name", "hissync_up", 1, []string{"profile", "jhcis", "dangling"},
+			`hissync_up{profile="jhcis"} 1` + "\n"},
+		{"fraction", "hissync_lag_seconds", 0.25, nil, "hissync_lag_seconds 0.25\n"},
+		{"large value", "hissync_bytes", 1.5e12, nil, "hissync_bytes 1.5e+12\n"},
+		{"infinity", "hissync_lag_seconds", math.Inf(1), nil, "hissync_lag_seconds +Inf\n"},
+		{"not a number", "hissync_lag_seconds", math.NaN(), nil, "hissync_lag_seconds NaN\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			mw := &metricWriter{w: bufio.NewWriter(&b)}
+			mw.sample(tt.metric, tt.value, tt.labels...)
+			mw.w.Flush()
+			if b.String() != tt.want {
+				t.Fatalf("sample() = %q ต้องการ %q", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricWriterHeader(t *testing.T) {
+	var b strings.Builder
+	mw := &metricWriter{w: bufio.NewWriter(&b)}
+	mw.header("hissync_events_total", "counter", "Change events captured.")
+	mw.sample("hissync_events_total", 7, "profile", "jhcis")
+	mw.w.Flush()
+	want := "# HELP hissync_events_total Change events captured.\n" +
+		"# TYPE hissync_events_total counter\n" +
+		`hissync_events_total{profile="jhcis"} 7` + "\n"
+	if b.String() != want {
+		t.Fatalf("ได้\n%s\nต้องการ\n%s", b.String(), want)
+	}
+}
+
+func TestUnixSeconds(t *testing.T) {
+	at := time.Date(2024, 3, 1, 8, 0, 0, 500_000_000, time.UTC)
+	if got, want := unixSeconds(at), float64(at.Unix())+0.5; got != want {
+		t.Fatalf("unixSeconds() = %v ต้องการ %v", got, want)
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	got := sortedKeys(map[string]int{"sink-b": 1, "sink-a": 2, "sink-c": 3})
+	if strings.Join(got, ",") != "sink-a,sink-b,sink-c" {
+		t.Fatalf("sortedKeys() = %v", got)
+	}
+}
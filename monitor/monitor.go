@@ -0,0 +1,175 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/sink"
+	"hissync-10/store"
+)
+
+// เวลารอให้คำขอที่ค้างอยู่เสร็จก่อนปิดตัวรับ
+const shutdownTimeout = 5 * time.Second
+
+// eventKey กลุ่มของตัวนับเหตุการณ์
+type eventKey struct {
+	profile, table, operation string
+}
+
+// Monitor ให้บริการ /healthz, /readyz และ /metrics (รูปแบบข้อความของ Prometheus)
+type Monitor struct {
+	manager    *capture.Manager
+	store      *store.Store
+	dispatcher *sink.Dispatcher
+	started    time.Time
+
+	mu        sync.Mutex
+	events    map[eventKey]uint64
+	errors    map[string]uint64
+	lastEvent map[string]time.Time
+	listen    string
+	server    *http.Server
+}
+
+// New สร้าง Monitor และเริ่มนับเหตุการณ์จาก manager (ยังไม่เปิดตัวรับจนกว่าจะเรียก Apply)
+func New(manager *capture.Manager, st *store.Store, dispatcher *sink.Dispatcher) *Monitor {
+	m := &Monitor{
+		manager:    manager,
+		store:      st,
+		dispatcher: dispatcher,
+		started:    time.Now(),
+		events:     make(map[eventKey]uint64),
+		errors:     make(map[string]uint64),
+		lastEvent:  make(map[string]time.Time),
+	}
+	manager.Subscribe(m.count)
+	return m
+}
+
+func (m *Monitor) count(ev capture.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case ev.Err != nil:
+		m.errors[ev.Profile]++
+	case ev.IsChange():
+		m.events[eventKey{ev.Profile, ev.FullTableName(), ev.Operation}]++
+		m.lastEvent[ev.Profile] = ev.Time
+	}
+}
+
+// Apply เปิด ปิด หรือเปลี่ยนที่อยู่ของตัวรับ HTTP ตาม cfg (nil คือปิด)
+func (m *Monitor) Apply(cfg *config.MonitorConfig) {
+	listen := ""
+	if cfg != nil {
+		listen = cfg.Listen
+	}
+
+	m.mu.Lock()
+	if listen == m.listen {
+		m.mu.Unlock()
+		return
+	}
+	old := m.server
+	m.server = nil
+	m.listen = listen
+	m.mu.Unlock()
+
+	if old != nil {
+		shutdown(old)
+	}
+	if listen == "" {
+		return
+	}
+
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		logging.For(logging.ComponentMonitor).Error("ไม่สามารถเปิดตัวรับ monitor", "listen", listen, "error", err)
+		m.mu.Lock()
+		m.listen = ""
+		m.mu.Unlock()
+		return
+	}
+	server := &http.Server{Handler: m.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	m.mu.Lock()
+	m.server = server
+	m.mu.Unlock()
+
+	logging.For(logging.ComponentMonitor).Info("monitor เปิดรับที่", "addr", ln.Addr().String())
+	go func() {
+		if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.For(logging.ComponentMonitor).Error("ตัวรับ monitor หยุดทำงาน", "error", err)
+		}
+	}()
+}
+
+// Close ปิดตัวรับ HTTP
+func (m *Monitor) Close() {
+	m.Apply(nil)
+}
+
+func shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(ctx)
+}
+
+// Handler คืน http.Handler ของ /healthz, /readyz และ /metrics
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", m.serveReady)
+	mux.HandleFunc("/metrics", m.serveMetrics)
+	return mux
+}
+
+// serveReady พร้อมเมื่อโหลด config แล้ว ทุกโปรไฟล์ที่เปิดใช้งานกำลังทำงาน
+// และความล่าช้าไม่เกินเกณฑ์ใน lag_warning
+func (m *Monitor) serveReady(w http.ResponseWriter, r *http.Request) {
+	var problems []string
+	cfg := m.manager.Config()
+	if cfg == nil {
+		problems = append(problems, "config.json ยังไม่ถูกโหลด")
+	} else {
+		captureWarn, deliveryWarn := cfg.LagThresholds()
+		for _, lag := range m.manager.Lags() {
+			if lag.Capture > captureWarn {
+				problems = append(problems, fmt.Sprintf("profile %s: capture lag %s", lag.Profile, lag.Capture.Round(time.Second)))
+			}
+		}
+		for _, st := range m.dispatcher.Statuses() {
+			if lag := st.DeliveryLag(); lag > deliveryWarn {
+				problems = append(problems, fmt.Sprintf("sink %s: delivery lag %s (%d pending)", st.Name, lag.Round(time.Second), st.Pending))
+			}
+		}
+	}
+	for _, st := range m.manager.Statuses() {
+		switch st.State {
+		case capture.StatusRunning, capture.StatusDisabled:
+		default:
+			problem := fmt.Sprintf("profile %s: %s", st.Name, st.State)
+			if st.Err != nil {
+				problem += ": " + st.Err.Error()
+			}
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(w, strings.Join(problems, "\n"))
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/monitor"
+	"hissync-10/sink"
+	"hissync-10/store"
+)
+
+// Pipeline เชื่อมแหล่งข้อมูลทุกโปรไฟล์เข้ากับที่เก็บเหตุการณ์และปลายทาง
+// ใช้ร่วมกันทั้งหน้าจอหลักและ hissync run
+type Pipeline struct {
+	Manager    *capture.Manager
+	Store      *store.Store
+	Dispatcher *sink.Dispatcher
+	Monitor    *monitor.Monitor
+
+	mu          sync.Mutex
+	config      *config.Config
+	listeners   map[int]func(store.Record)
+	nextID      int
+	unsubscribe []func()
+	// down โปรไฟล์ที่หยุดทำงานเพราะข้อผิดพลาด (ใช้บันทึกช่วงเวลาที่หยุดทำงานสำหรับรายงาน)
+	down  map[string]bool
+	guard *Safeguard
+}
+
+// Open เปิดที่เก็บเหตุการณ์และเริ่มบันทึกเหตุการณ์จาก manager
+// แหล่งข้อมูลและปลายทางจะเริ่มทำงานเมื่อเรียก Apply
+func Open(manager *capture.Manager) (*Pipeline, error) {
+	st, err := store.Open(store.DataFilePath())
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipeline{
+		Manager:    manager,
+		Store:      st,
+		Dispatcher: sink.NewDispatcher(st),
+		listeners:  make(map[int]func(store.Record)),
+		down:       make(map[string]bool),
+		guard:      NewSafeguard(st),
+	}
+	p.Monitor = monitor.New(manager, st, p.Dispatcher)
+	p.unsubscribe = []func(){
+		manager.Subscribe(p.record),
+		manager.Subscribe(logEvent),
+		manager.SubscribeStatus(logStatus),
+		manager.SubscribeStatus(p.recordStatus),
+		p.startSafeguard(),
+		p.startRetention(),
+	}
+	return p, nil
+}
+
+// Apply ปรับแหล่งข้อมูลและปลายทางให้ตรงกับ cfg โดยไม่ต้องรีสตาร์ทโปรแกรม
+func (p *Pipeline) Apply(cfg *config.Config) {
+	logging.Apply(cfg.LogSettings())
+	p.mu.Lock()
+	p.config = cfg
+	p.mu.Unlock()
+	p.Dispatcher.Apply(cfg.Sinks)
+	p.Manager.Apply(cfg)
+	p.Monitor.Apply(cfg.Monitor)
+}
+
+// Subscribe ลงทะเบียนรับเหตุการณ์ที่บันทึกลงที่เก็บเหตุการณ์แล้ว (พร้อมลำดับ) คืนฟังก์ชันสำหรับยกเลิก
+func (p *Pipeline) Subscribe(fn func(store.Record)) (unsubscribe func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	id := p.nextID
+	p.nextID++
+	p.listeners[id] = fn
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.listeners, id)
+	}
+}
+
+// Close หยุดแหล่งข้อมูลและปลายทางทั้งหมด แล้วปิดที่เก็บเหตุการณ์
+func (p *Pipeline) Close() {
+	p.Monitor.Close()
+	p.Manager.Stop()
+	for _, unsubscribe := range p.unsubscribe {
+		unsubscribe()
+	}
+	p.Dispatcher.Stop()
+	p.Store.Close()
+}
+
+// record บันทึกการเปลี่ยนแปลงข้อมูลลงที่เก็บเหตุการณ์พร้อมเข้าคิวของปลายทางที่เกี่ยวข้อง
+// ทำก่อนที่แหล่งข้อมูลจะบันทึกตำแหน่งล่าสุด จึงไม่มีเหตุการณ์ตกหล่นเมื่อโปรแกรมหยุดกลางคัน
+// โปรไฟล์ที่ใช้ safeguard จะพักเหตุการณ์ไว้จนกว่าจะอ่านธุรกรรมครบก่อนเข้าคิว
+func (p *Pipeline) record(ev capture.Event) {
+	if ev.Commit {
+		if p.guard.Commit(ev.Profile, ev.Transaction) {
+			p.Dispatcher.Notify()
+		}
+		return
+	}
+	if !ev.IsChange() {
+		return
+	}
+	p.mu.Lock()
+	cfg := p.config
+	p.mu.Unlock()
+
+	var sinks []string
+	if cfg != nil {
+		sinks = cfg.SinksFor(ev.Profile)
+	}
+	p.Store.SetTables(ev.Profile, p.Manager.Tables(ev.Profile))
+	var rec store.Record
+	var err error
+	if sg := cfg.SafeguardFor(ev.Profile); sg != nil {
+		rec, err = p.holdEvent(cfg, sg, ev, sinks)
+	} else {
+		rec, err = p.Store.Append(ev, sinks)
+	}
+	if err != nil {
+		logging.For(logging.ComponentCapture).Error("ไม่สามารถบันทึกเหตุการณ์",
+			"profile", ev.Profile, "source", ev.Source, "table", ev.FullTableName(), "error", err)
+		return
+	}
+	if len(sinks) > 0 {
+		p.Dispatcher.Notify()
+	}
+
+	p.mu.Lock()
+	listeners := make([]func(store.Record), 0, len(p.listeners))
+	for _, fn := range p.listeners {
+		listeners = append(listeners, fn)
+	}
+	p.mu.Unlock()
+	for _, fn := range listeners {
+		fn(rec)
+	}
+}
+
+// recordStatus บันทึกเวลาที่โปรไฟล์หยุดทำงานเพราะข้อผิดพลาดและเวลาที่กลับมาทำงาน
+// โปรไฟล์ที่เริ่มทำงานครั้งแรกหลังเปิดโปรแกรมก็บันทึกว่ากลับมาทำงาน เพื่อปิดช่วงที่ค้างจากครั้งก่อน
+func (p *Pipeline) recordStatus(st capture.ProfileStatus) {
+	p.mu.Lock()
+	down, seen := p.down[st.Name]
+	var activity *store.Activity
+	switch {
+	case st.Err != nil && !down:
+		p.down[st.Name] = true
+		activity = &store.Activity{Kind: store.ActivityDown, Profile: st.Name, Message: st.Err.Error()}
+	case st.State == capture.StatusRunning && (down || !seen):
+		p.down[st.Name] = false
+		activity = &store.Activity{Kind: store.ActivityUp, Profile: st.Name}
+	}
+	p.mu.Unlock()
+
+	if activity == nil {
+		return
+	}
+	if err := p.Store.RecordActivity(*activity); err != nil {
+		logging.For(logging.ComponentCapture).Warn("ไม่สามารถบันทึกสถานะสำหรับรายงาน", "profile", st.Name, "error", err)
+	}
+}
+
+// logEvent เขียนข้อผิดพลาดและข้อความแจ้งจากแหล่งข้อมูลลง log
+func logEvent(ev capture.Event) {
+	logger := logging.For(logging.ComponentCapture).With("profile", ev.Profile, "source", ev.Source)
+	switch {
+	case ev.Err != nil:
+		logger.Error(config.Redact(ev.Err.Error()))
+	case ev.Notice != "":
+		logger.Info(ev.Notice)
+	case ev.IsChange():
+		logger.Debug("เหตุการณ์", "table", ev.FullTableName(), "operation", ev.Operation, "position", ev.Position)
+	}
+}
+
+// logStatus เขียนการเปลี่ยนสถานะของแต่ละโปรไฟล์ลง log
+func logStatus(st capture.ProfileStatus) {
+	logger := logging.For(logging.ComponentCapture).With("profile", st.Name, "state", st.State)
+	if st.Err != nil {
+		// ข้อผิดพลาดถูกเขียนแล้วใน logEvent
+		logger.Warn("โปรไฟล์ผิดพลาด จะลองเชื่อมต่อใหม่", "retry_at", st.RetryAt.Format(time.DateTime))
+		return
+	}
+	logger.Info("สถานะโปรไฟล์เปลี่ยน")
+}
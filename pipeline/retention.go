@@ -0,0 +1,55 @@
+package pipeline
+
+import (
+	"time"
+
+	"hissync-10/logging"
+)
+
+// ระยะห่างระหว่างการลบเหตุการณ์ที่เกินระยะเก็บรักษา (events ใน config.json)
+const retentionInterval = time.Hour
+
+// startRetention ลบเหตุการณ์ที่เกินระยะเก็บรักษาเมื่อเริ่มทำงานและทุก retentionInterval คืนฟังก์ชันสำหรับหยุด
+func (p *Pipeline) startRetention() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		// รอให้ Apply กำหนด config ก่อนลบครั้งแรก
+		first := time.NewTimer(time.Minute)
+		defer first.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-first.C:
+				p.purge()
+			case <-ticker.C:
+				p.purge()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// purge ลบเหตุการณ์ที่เกินระยะเก็บรักษาตาม config ปัจจุบัน
+func (p *Pipeline) purge() {
+	p.mu.Lock()
+	cfg := p.config
+	p.mu.Unlock()
+	before, keep := cfg.EventsRetention(time.Now())
+	deleted, err := p.Store.Purge(before, keep)
+	log := logging.For(logging.ComponentApp)
+	if err != nil {
+		log.Error("ไม่สามารถลบเหตุการณ์ที่เกินระยะเก็บรักษา", "error", err)
+		return
+	}
+	if deleted > 0 {
+		log.Info("ลบเหตุการณ์ที่เกินระยะเก็บรักษา", "count", deleted)
+	}
+}
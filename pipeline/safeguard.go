@@ -0,0 +1,410 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/store"
+)
+
+const (
+	// transactionQuietPeriod ธุรกรรมของต้นทางที่ไม่แจ้งจุดสิ้นสุดธุรกรรม ซึ่งไม่มีเหตุการณ์ใหม่นานเท่านี้ถือว่าอ่านครบแล้ว
+	// ธุรกรรมจาก binlog เข้าคิวเมื่อได้รับจุดสิ้นสุดธุรกรรม (Event.Commit) เท่านั้น
+	transactionQuietPeriod = 2 * time.Second
+	// tableRowsTTL อายุของจำนวนแถวของตารางที่อ่านจากต้นทาง
+	tableRowsTTL = time.Hour
+)
+
+// Safeguard เกณฑ์กันการเปลี่ยนแปลงจำนวนมาก ใช้ร่วมกันทั้ง Pipeline และ hissync import-binlog
+// เหตุการณ์ของธุรกรรมถูกพักไว้ใน store จนกว่าจะอ่านครบ แล้วจึงเข้าคิวปลายทาง หรือถูกกักไว้ถ้าเกินเกณฑ์
+type Safeguard struct {
+	store *store.Store
+
+	mu sync.Mutex
+	// open ธุรกรรมที่กำลังอ่าน ตาม profile\x00transaction
+	open map[string]*openHold
+	// changes จำนวนแถวที่ถูก UPDATE หรือ DELETE ในชั่วโมงล่าสุด ตาม profile\x00database.table
+	changes map[string]*hourlyChanges
+
+	rowsMu sync.Mutex
+	rows   map[string]tableRows
+	// wanted ตารางที่ต้องอ่านจำนวนแถวจากต้นทาง (ยังไม่มีหรือเก่ากว่า tableRowsTTL) อ่านใน RefreshRows
+	wanted     map[string]rowsRequest
+	refreshing atomic.Bool
+}
+
+// HoldResult ผลของการพักเหตุการณ์หนึ่งรายการ
+type HoldResult struct {
+	Record store.Record
+	// Released ธุรกรรมก่อนหน้าของโปรไฟล์ หรือเหตุการณ์นี้เอง (ไม่มีรหัสธุรกรรม) เข้าคิวปลายทางแล้ว
+	Released bool
+	// Quarantined ธุรกรรมที่ถูกกักเพราะเหตุการณ์นี้ (nil ถ้าไม่ถูกกักหรือถูกกักไปก่อนแล้ว)
+	Quarantined *store.Hold
+}
+
+type openHold struct {
+	hold     store.Hold
+	lastSeen time.Time
+}
+
+type tableRows struct {
+	rows int64
+	at   time.Time
+}
+
+type rowsRequest struct {
+	profile         config.Profile
+	database, table string
+}
+
+// hourlyChanges นับการเปลี่ยนแปลงรายนาทีย้อนหลัง 60 นาที
+type hourlyChanges [60]struct {
+	minute int64
+	count  int
+}
+
+// add นับการเปลี่ยนแปลงหนึ่งแถว ณ เวลา t แล้วคืนจำนวนใน 60 นาทีก่อนหน้าถึง t
+func (h *hourlyChanges) add(t time.Time) int {
+	minute := t.Unix() / 60
+	slot := &h[minute%60]
+	if slot.minute != minute {
+		slot.minute, slot.count = minute, 0
+	}
+	slot.count++
+
+	total := 0
+	for _, s := range h {
+		if s.minute > minute-60 && s.minute <= minute {
+			total += s.count
+		}
+	}
+	return total
+}
+
+// NewSafeguard นำธุรกรรมที่ยังไม่ได้ส่งจากครั้งก่อนใน st กลับมารอส่ง
+func NewSafeguard(st *store.Store) *Safeguard {
+	g := &Safeguard{
+		store:   st,
+		open:    make(map[string]*openHold),
+		changes: make(map[string]*hourlyChanges),
+		rows:    make(map[string]tableRows),
+		wanted:  make(map[string]rowsRequest),
+	}
+	holds, err := st.Holds()
+	if err != nil {
+		logging.For(logging.ComponentCapture).Warn("ไม่สามารถอ่านธุรกรรมที่รอส่ง", "error", err)
+	}
+	now := time.Now()
+	for _, h := range holds {
+		if !h.Quarantined() {
+			g.open[h.Profile+"\x00"+h.Transaction] = &openHold{hold: h, lastSeen: now}
+		}
+	}
+	return g
+}
+
+// Hold บันทึกเหตุการณ์ของธุรกรรมโดยพักไว้จนกว่าจะอ่านครบ และกักธุรกรรมไว้ถ้าเกินเกณฑ์ sg
+// profile ใช้อ่านจำนวนแถวของตารางสำหรับเกณฑ์ร้อยละ และบอกว่าต้นทางแจ้งจุดสิ้นสุดธุรกรรมหรือไม่
+// (nil คือไม่ใช้เกณฑ์ร้อยละและรอจนไม่มีเหตุการณ์ใหม่)
+// เหตุการณ์ที่ไม่มีรหัสธุรกรรม (เช่น จากไฟล์ Log ของ PostgreSQL) เป็นธุรกรรมของตัวเอง เข้าคิวทันทีถ้าไม่เกินเกณฑ์
+func (g *Safeguard) Hold(sg *config.SafeguardConfig, profile *config.Profile, ev capture.Event, sinks []string) (HoldResult, error) {
+	var result HoldResult
+	changesRows := ev.Operation == "UPDATE" || ev.Operation == "DELETE"
+	var rows int64
+	if sg.MaxTablePercentPerHour > 0 && changesRows {
+		rows = g.tableRows(profile, ev)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	single := ev.Transaction == ""
+	key := ev.Profile + "\x00" + ev.Transaction
+	result.Released = g.closeTransactions(ev.Profile, key)
+	entry, ok := g.open[key]
+	if !ok || single {
+		entry = &openHold{hold: store.Hold{Profile: ev.Profile, Transaction: ev.Transaction, Sinks: sinks,
+			Bounded: profile != nil && profile.HasTransactions()}}
+	}
+	var err error
+	if result.Record, err = g.store.AppendHeld(ev, &entry.hold); err != nil {
+		return result, err
+	}
+	entry.lastSeen = now
+	if !single {
+		g.open[key] = entry
+	}
+
+	changed := 0
+	if changesRows {
+		tableKey := ev.Profile + "\x00" + ev.FullTableName()
+		if g.changes[tableKey] == nil {
+			g.changes[tableKey] = new(hourlyChanges)
+		}
+		// ใช้เวลาของต้นทาง เพื่อให้ binlog ที่นำเข้าย้อนหลังนับตามชั่วโมงที่เกิดจริง
+		at := ev.Time
+		if at.IsZero() {
+			at = now
+		}
+		changed = g.changes[tableKey].add(at)
+	}
+
+	h := entry.hold
+	if h.Quarantined() {
+		return result, nil
+	}
+	reason := exceeded(sg, h, ev.FullTableName(), changed, rows)
+	if reason == "" {
+		if single && g.releaseHold(entry) {
+			result.Released = true
+		}
+		return result, nil
+	}
+	if err := g.store.Quarantine(h.ID, reason); err != nil {
+		logging.For(logging.ComponentCapture).Error("ไม่สามารถกักธุรกรรม", "profile", ev.Profile, "transaction", ev.Transaction, "error", err)
+		return result, nil
+	}
+	entry.hold.Reason = reason
+	h.Reason = reason
+	result.Quarantined = &h
+	logging.For(logging.ComponentCapture).Warn("กักธุรกรรมไว้รออนุมัติ",
+		"profile", ev.Profile, "transaction", ev.Transaction, "hold", h.ID, "reason", reason)
+	return result, nil
+}
+
+// exceeded คืนเหตุผลถ้าธุรกรรม h เกินเกณฑ์ sg (ว่างถ้าไม่เกิน)
+// changed จำนวนแถวของ table ที่ถูกแก้ไขหรือลบในชั่วโมงล่าสุด rows จำนวนแถวโดยประมาณของตาราง (0 คือไม่ทราบ)
+func exceeded(sg *config.SafeguardConfig, h store.Hold, table string, changed int, rows int64) string {
+	switch {
+	case sg.MaxDeletesPerTransaction > 0 && h.Deletes > sg.MaxDeletesPerTransaction:
+		return fmt.Sprintf("ลบ %d แถวในธุรกรรมเดียว เกินเกณฑ์ %d แถว", h.Deletes, sg.MaxDeletesPerTransaction)
+	case sg.MaxRowsPerTransaction > 0 && h.Count > sg.MaxRowsPerTransaction:
+		return fmt.Sprintf("เปลี่ยน %d แถวในธุรกรรมเดียว เกินเกณฑ์ %d แถว", h.Count, sg.MaxRowsPerTransaction)
+	case sg.MaxTablePercentPerHour > 0 && rows > 0 && rows >= sg.TableRowsMinimum() &&
+		float64(changed)*100/float64(rows) > sg.MaxTablePercentPerHour:
+		return fmt.Sprintf("%s ถูกแก้ไขหรือลบ %d แถวใน 1 ชั่วโมง (%.1f%% ของประมาณ %d แถว) เกินเกณฑ์ %g%%",
+			table, changed, float64(changed)*100/float64(rows), rows, sg.MaxTablePercentPerHour)
+	}
+	return ""
+}
+
+// closeTransactions ปิดธุรกรรมอื่นของโปรไฟล์เมื่อเริ่มธุรกรรม key แล้วส่งธุรกรรมที่ไม่ถูกกัก (ต้องถือ g.mu อยู่)
+// ไม่ปิดธุรกรรมของต้นทางที่แจ้งจุดสิ้นสุดธุรกรรม ซึ่งรอ Commit
+func (g *Safeguard) closeTransactions(profile, key string) (released bool) {
+	for k, entry := range g.open {
+		if k == key || entry.hold.Profile != profile || entry.hold.Bounded {
+			continue
+		}
+		if g.releaseHold(entry) {
+			released = true
+		}
+		delete(g.open, k)
+	}
+	return released
+}
+
+// Commit ส่งธุรกรรม transaction ของโปรไฟล์เมื่อต้นทางแจ้งว่าธุรกรรมจบแล้ว (ธุรกรรมที่ถูกกักยังรออนุมัติ)
+// คืน true ถ้ามีเหตุการณ์เข้าคิวปลายทาง
+func (g *Safeguard) Commit(profile, transaction string) (released bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := profile + "\x00" + transaction
+	entry, ok := g.open[key]
+	if !ok {
+		return false
+	}
+	delete(g.open, key)
+	return g.releaseHold(entry)
+}
+
+// ReleaseIdle ส่งธุรกรรมของต้นทางที่ไม่แจ้งจุดสิ้นสุดธุรกรรม ซึ่งไม่มีเหตุการณ์ใหม่นานกว่า quiet
+// quiet เป็น 0 คือทุกธุรกรรมที่ยังเปิดอยู่ รวมทั้งธุรกรรมที่รอ Commit (เช่น เมื่ออ่านไฟล์ binlog ที่นำเข้าจบ)
+// คืน true ถ้ามีเหตุการณ์เข้าคิวปลายทาง
+func (g *Safeguard) ReleaseIdle(quiet time.Duration) (released bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, entry := range g.open {
+		if quiet > 0 && (entry.hold.Bounded || time.Since(entry.lastSeen) < quiet) {
+			continue
+		}
+		if g.releaseHold(entry) {
+			released = true
+		}
+		delete(g.open, k)
+	}
+	return released
+}
+
+// releaseHold นำธุรกรรมที่อ่านครบและไม่ถูกกักเข้าคิวปลายทาง (ต้องถือ g.mu อยู่)
+func (g *Safeguard) releaseHold(entry *openHold) bool {
+	if entry.hold.Quarantined() {
+		return false
+	}
+	if _, err := g.store.Release(entry.hold.ID); err != nil {
+		logging.For(logging.ComponentCapture).Error("ไม่สามารถส่งธุรกรรม",
+			"profile", entry.hold.Profile, "transaction", entry.hold.Transaction, "error", err)
+		return false
+	}
+	return len(entry.hold.Sinks) > 0
+}
+
+// tableRows คืนจำนวนแถวโดยประมาณของตารางของเหตุการณ์จากค่าที่อ่านไว้ (0 ถ้ายังไม่ทราบ)
+// ไม่อ่านจากต้นทางที่นี่ เพราะอยู่ในเส้นทางของการอ่าน binlog ตารางที่ยังไม่มีหรือค่าเก่าจะถูกอ่านใน RefreshRows
+func (g *Safeguard) tableRows(profile *config.Profile, ev capture.Event) int64 {
+	if profile == nil {
+		return 0
+	}
+	key := ev.Profile + "\x00" + ev.FullTableName()
+	g.rowsMu.Lock()
+	defer g.rowsMu.Unlock()
+	cached, ok := g.rows[key]
+	if !ok || time.Since(cached.at) >= tableRowsTTL {
+		g.wanted[key] = rowsRequest{profile: *profile, database: ev.Database, table: ev.Table}
+	}
+	return cached.rows
+}
+
+// RefreshRows อ่านจำนวนแถวของตารางที่ต้องการจากต้นทาง ถ้ากำลังอ่านอยู่แล้วจะไม่ทำซ้ำ
+func (g *Safeguard) RefreshRows() {
+	if !g.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer g.refreshing.Store(false)
+
+	g.rowsMu.Lock()
+	wanted := g.wanted
+	g.wanted = make(map[string]rowsRequest)
+	g.rowsMu.Unlock()
+
+	for key, req := range wanted {
+		rows, err := config.TableRows(&req.profile, req.database, req.table)
+		if err != nil {
+			// จำค่า 0 ไว้ด้วย เพื่อไม่ให้อ่านจากต้นทางซ้ำจนกว่าจะครบ tableRowsTTL
+			logging.For(logging.ComponentCapture).Warn("ไม่สามารถอ่านจำนวนแถวสำหรับ safeguard",
+				"profile", req.profile.Name, "table", req.database+"."+req.table, "error", err)
+		}
+		g.rowsMu.Lock()
+		g.rows[key] = tableRows{rows: rows, at: time.Now()}
+		g.rowsMu.Unlock()
+	}
+}
+
+// Approve ส่งธุรกรรมที่ถูกกักไว้ไปยังปลายทาง คืนจำนวนเหตุการณ์
+func (g *Safeguard) Approve(id uint64) (int, error) {
+	h, err := g.quarantined(id)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	count, err := g.store.Release(id)
+	g.forget(id)
+	g.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	logging.For(logging.ComponentSink).Info("อนุมัติส่งธุรกรรมที่ถูกกัก",
+		"profile", h.Profile, "transaction", h.Transaction, "hold", id, "events", count)
+	return count, nil
+}
+
+// Reject ทิ้งธุรกรรมที่ถูกกักไว้โดยไม่ส่งไปยังปลายทาง เหตุการณ์ยังค้นหาได้ในหน้าเหตุการณ์ คืนจำนวนเหตุการณ์
+func (g *Safeguard) Reject(id uint64) (int, error) {
+	h, err := g.quarantined(id)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	count, err := g.store.Reject(id)
+	g.forget(id)
+	g.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	logging.For(logging.ComponentSink).Warn("ปฏิเสธธุรกรรมที่ถูกกัก ไม่ส่งไปยังปลายทาง",
+		"profile", h.Profile, "transaction", h.Transaction, "hold", id, "events", count)
+	return count, nil
+}
+
+// quarantined คืนธุรกรรมที่ถูกกักตามรหัส
+func (g *Safeguard) quarantined(id uint64) (store.Hold, error) {
+	holds, err := g.store.Holds()
+	if err != nil {
+		return store.Hold{}, err
+	}
+	for _, h := range holds {
+		if h.ID == id && h.Quarantined() {
+			return h, nil
+		}
+	}
+	return store.Hold{}, fmt.Errorf("ไม่พบธุรกรรม #%d ที่ถูกกักไว้", id)
+}
+
+// forget ลบธุรกรรมออกจากรายการที่กำลังอ่าน (ต้องถือ g.mu อยู่)
+func (g *Safeguard) forget(id uint64) {
+	for k, entry := range g.open {
+		if entry.hold.ID == id {
+			delete(g.open, k)
+		}
+	}
+}
+
+// startSafeguard เริ่มส่งธุรกรรมที่อ่านครบและอ่านจำนวนแถวของตารางเป็นระยะ คืนฟังก์ชันสำหรับหยุด
+func (p *Pipeline) startSafeguard() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if p.guard.ReleaseIdle(transactionQuietPeriod) {
+					p.Dispatcher.Notify()
+				}
+				// อ่านจำนวนแถวแยก goroutine เพื่อไม่ให้ต้นทางที่ช้าทำให้การส่งธุรกรรมที่อ่านครบช้าตาม
+				go p.guard.RefreshRows()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// holdEvent พักเหตุการณ์ของธุรกรรมไว้ด้วย safeguard และแจ้งปัญหาของโปรไฟล์เมื่อธุรกรรมถูกกัก
+func (p *Pipeline) holdEvent(cfg *config.Config, sg *config.SafeguardConfig, ev capture.Event, sinks []string) (store.Record, error) {
+	result, err := p.guard.Hold(sg, cfg.Profile(ev.Profile), ev, sinks)
+	if err != nil {
+		return result.Record, err
+	}
+	if result.Released {
+		p.Dispatcher.Notify()
+	}
+	if h := result.Quarantined; h != nil {
+		p.Manager.ReportIncident(ev.Profile, fmt.Errorf("กักธุรกรรม #%d (%s) ไว้รออนุมัติ: %s", h.ID, h.Transaction, h.Reason))
+	}
+	return result.Record, nil
+}
+
+// Approve ส่งธุรกรรมที่ถูกกักไว้ไปยังปลายทาง คืนจำนวนเหตุการณ์
+func (p *Pipeline) Approve(id uint64) (int, error) {
+	count, err := p.guard.Approve(id)
+	if err == nil {
+		p.Dispatcher.Notify()
+	}
+	return count, err
+}
+
+// Reject ทิ้งธุรกรรมที่ถูกกักไว้โดยไม่ส่งไปยังปลายทาง คืนจำนวนเหตุการณ์
+func (p *Pipeline) Reject(id uint64) (int, error) {
+	return p.guard.Reject(id)
+}
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+func TestExceeded(t *testing.T) {
+	tests := []struct {
+		name    string
+		sg      config.SafeguardConfig
+		hold    store.Hold
+		changed int
+		rows    int64
+		// want ข้อความที่ต้องพบในเหตุผล (ว่าง = ไม่เกินเกณฑ์)
+		want string
+	}{
+		{
+			name: "no thresholds",
+			hold: store.Hold{Count: 1000000, Deletes: 1000000},
+		},
+		{
+			name: "deletes at threshold",
+			sg:   config.SafeguardConfig{MaxDeletesPerTransaction: 100},
+			hold: store.Hold{Count: 100, Deletes: 100},
+		},
+		{
+			name: "deletes over threshold",
+			sg:   config.SafeguardConfig{MaxDeletesPerTransaction: 100},
+			hold: store.Hold{Count: 101, Deletes: 101},
+			want: "ลบ 101 แถวในธุรกรรมเดียว เกินเกณฑ์ 100 แถว",
+		},
+		{
+			name: "rows over threshold",
+			sg:   config.SafeguardConfig{MaxRowsPerTransaction: 500},
+			hold: store.Hold{Count: 501},
+			want: "เปลี่ยน 501 แถวในธุรกรรมเดียว เกินเกณฑ์ 500 แถว",
+		},
+		{
+			name: "deletes reported before rows",
+			sg:   config.SafeguardConfig{MaxDeletesPerTransaction: 10, MaxRowsPerTransaction: 10},
+			hold: store.Hold{Count: 20, Deletes: 20},
+			want: "ลบ 20 แถว",
+		},
+		{
+			name:    "table percent over threshold",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 10},
+			changed: 201,
+			rows:    2000,
+			want:    "jhcis.person ถูกแก้ไขหรือลบ 201 แถวใน 1 ชั่วโมง (10.1% ของประมาณ 2000 แถว) เกินเกณฑ์ 10%",
+		},
+		{
+			name:    "table percent at threshold",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 10},
+			changed: 200,
+			rows:    2000,
+		},
+		{
+			name:    "table rows unknown",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 10},
+			changed: 5000,
+		},
+		{
+			name:    "table smaller than default minimum",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 10},
+			changed: 999,
+			rows:    999,
+		},
+		{
+			name:    "table smaller than configured minimum",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 10, MinTableRows: 5000},
+			changed: 4000,
+			rows:    4000,
+		},
+		{
+			name:    "configured minimum lowered",
+			sg:      config.SafeguardConfig{MaxTablePercentPerHour: 50, MinTableRows: 10},
+			changed: 6,
+			rows:    10,
+			want:    "(60.0% ของประมาณ 10 แถว)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := exceeded(&tt.sg, tt.hold, "jhcis.person", tt.changed, tt.rows)
+			if tt.want == "" {
+				if got != "" {
+					t.Fatalf("exceeded() = %q ต้องการไม่เกินเกณฑ์", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("exceeded() = %q ต้องการ %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHourlyChanges(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// offsets เวลาที่เกิดการเปลี่ยนแปลงแต่ละแถวนับจาก start
+		offsets []time.Duration
+		want    int
+	}{
+		{"single change", []time.Duration{0}, 1},
+		{"same minute", []time.Duration{0, 10 * time.Second, 59 * time.Second}, 3},
+		{"within the hour", []time.Duration{0, 30 * time.Minute, 59 * time.Minute}, 3},
+		{"older than an hour dropped", []time.Duration{0, time.Hour}, 1},
+		{"slot reused after an hour", []time.Duration{0, 0, time.Hour + 30*time.Second}, 1},
+		{"partially expired", []time.Duration{0, 30 * time.Minute, 61 * time.Minute}, 2},
+		{"long gap", []time.Duration{0, 5 * time.Hour, 5*time.Hour + time.Minute}, 2},
+		// เหตุการณ์ที่มาช้า (เวลาย้อนหลัง) ไม่นับเหตุการณ์ที่ใหม่กว่า
+		{"late event", []time.Duration{10 * time.Minute, 0}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h hourlyChanges
+			got := 0
+			for _, off := range tt.offsets {
+				got = h.add(start.Add(off))
+			}
+			if got != tt.want {
+				t.Fatalf("add() = %d ต้องการ %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafeguardHold(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "hissync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	g := NewSafeguard(st)
+	sg := &config.SafeguardConfig{MaxDeletesPerTransaction: 2}
+	sinks := []string{"hub"}
+	event := func(tx, op string) capture.Event {
+		return capture.Event{Profile: "jhcis", Transaction: tx, Database: "jhcis", Table: "person", Operation: op}
+	}
+
+	// ธุรกรรมปกติ: พักไว้จนเริ่มธุรกรรมถัดไป
+	for _, op := range []string{"INSERT", "DELETE"} {
+		if res, err := g.Hold(sg, nil, event("tx1", op), sinks); err != nil || res.Released || res.Quarantined != nil {
+			t.Fatalf("Hold(tx1 %s) = %+v, %v", op, res, err)
+		}
+	}
+	if n, _ := st.PendingCount("hub"); n != 0 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์ก่อนอ่านธุรกรรมครบ", n)
+	}
+
+	// ธุรกรรมที่ลบเกินเกณฑ์: กักไว้ และธุรกรรมก่อนหน้าเข้าคิว
+	var quarantined *store.Hold
+	for i, op := range []string{"DELETE", "DELETE", "DELETE"} {
+		res, err := g.Hold(sg, nil, event("tx2", op), sinks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && !res.Released {
+			t.Fatal("ไม่ได้ส่งธุรกรรม tx1 เมื่อเริ่ม tx2")
+		}
+		if res.Quarantined != nil {
+			quarantined = res.Quarantined
+		}
+	}
+	if quarantined == nil || !strings.Contains(quarantined.Reason, "ลบ 3 แถว") {
+		t.Fatalf("ไม่ได้กัก tx2: %+v", quarantined)
+	}
+	if n, _ := st.PendingCount("hub"); n != 2 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์ ต้องการ 2 (tx1)", n)
+	}
+
+	// ธุรกรรมที่ถูกกักไม่เข้าคิวแม้อ่านครบ จนกว่าจะอนุมัติ
+	g.ReleaseIdle(0)
+	if n, _ := st.PendingCount("hub"); n != 2 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์หลัง ReleaseIdle ต้องการ 2", n)
+	}
+	if n, err := g.Approve(quarantined.ID); err != nil || n != 3 {
+		t.Fatalf("Approve() = %d, %v ต้องการ 3", n, err)
+	}
+	if n, _ := st.PendingCount("hub"); n != 5 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์หลังอนุมัติ ต้องการ 5", n)
+	}
+	if _, err := g.Reject(quarantined.ID); err == nil {
+		t.Fatal("ปฏิเสธธุรกรรมที่อนุมัติไปแล้วได้")
+	}
+}
+
+func TestSafeguardCommit(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "hissync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	g := NewSafeguard(st)
+	sg := &config.SafeguardConfig{MaxDeletesPerTransaction: 100}
+	binlog := &config.Profile{Name: "jhcis", DBType: config.DBTypeMySQL, Engine: config.EngineBinlog}
+	sinks := []string{"hub"}
+	event := func(tx string) capture.Event {
+		return capture.Event{Profile: "jhcis", Transaction: tx, Database: "jhcis", Table: "person", Operation: "INSERT"}
+	}
+
+	// ต้นทางที่แจ้งจุดสิ้นสุดธุรกรรม: ไม่ส่งเมื่อเงียบหรือเมื่อเริ่มธุรกรรมถัดไป
+	for _, tx := range []string{"tx1", "tx2"} {
+		if res, err := g.Hold(sg, binlog, event(tx), sinks); err != nil || res.Released {
+			t.Fatalf("Hold(%s) = %+v, %v", tx, res, err)
+		}
+	}
+	g.mu.Lock()
+	for _, entry := range g.open {
+		entry.lastSeen = time.Now().Add(-time.Hour)
+	}
+	g.mu.Unlock()
+	if g.ReleaseIdle(transactionQuietPeriod) {
+		t.Fatal("ReleaseIdle ส่งธุรกรรมที่ยังไม่ได้รับจุดสิ้นสุด")
+	}
+	if n, _ := st.PendingCount("hub"); n != 0 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์ก่อนได้รับจุดสิ้นสุดธุรกรรม", n)
+	}
+
+	if !g.Commit("jhcis", "tx2") {
+		t.Fatal("Commit(tx2) ไม่ได้ส่งธุรกรรม")
+	}
+	if n, _ := st.PendingCount("hub"); n != 1 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์หลัง Commit(tx2) ต้องการ 1", n)
+	}
+	if g.Commit("jhcis", "tx2") {
+		t.Fatal("Commit(tx2) ซ้ำส่งธุรกรรมอีกครั้ง")
+	}
+
+	// ธุรกรรมที่ค้างจากครั้งก่อนยังรอจุดสิ้นสุดหลังเปิดใหม่
+	g = NewSafeguard(st)
+	if g.ReleaseIdle(transactionQuietPeriod) {
+		t.Fatal("ReleaseIdle ส่งธุรกรรมที่ค้างจากครั้งก่อน")
+	}
+	if !g.Commit("jhcis", "tx1") {
+		t.Fatal("Commit(tx1) ไม่ได้ส่งธุรกรรมที่ค้างจากครั้งก่อน")
+	}
+	if n, _ := st.PendingCount("hub"); n != 2 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์หลัง Commit(tx1) ต้องการ 2", n)
+	}
+}
+
+func TestSafeguardSingleEvent(t *testing.T) {
+	st, err := store.Open(filepath.Join(t.TempDir(), "hissync.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	g := NewSafeguard(st)
+	sg := &config.SafeguardConfig{MaxTablePercentPerHour: 50, MinTableRows: 1}
+	logProfile := &config.Profile{Name: "hosxp", DBType: config.DBTypePostgreSQL, Engine: config.EnginePostgresLog}
+	g.rows["hosxp\x00hosxp.person"] = tableRows{rows: 4, at: time.Now()}
+	sinks := []string{"hub"}
+	event := capture.Event{Profile: "hosxp", Database: "hosxp", Table: "person", Operation: "DELETE", Time: time.Now()}
+
+	// เหตุการณ์ที่ไม่มีรหัสธุรกรรมเข้าคิวทันทีจนกว่าจะเกินเกณฑ์ร้อยละต่อชั่วโมง
+	for i := 1; i <= 3; i++ {
+		res, err := g.Hold(sg, logProfile, event, sinks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quarantined := i == 3; (res.Quarantined != nil) != quarantined || res.Released == quarantined {
+			t.Fatalf("Hold() ครั้งที่ %d = %+v ต้องการกัก %v", i, res, quarantined)
+		}
+	}
+	if n, _ := st.PendingCount("hub"); n != 2 {
+		t.Fatalf("เข้าคิว %d เหตุการณ์ ต้องการ 2", n)
+	}
+	if len(g.open) != 0 {
+		t.Fatalf("เหลือธุรกรรมที่เปิดอยู่ %d รายการ", len(g.open))
+	}
+}
@@ -0,0 +1,225 @@
+// Package report สรุปการทำงานของโปรแกรมในช่วงเวลาที่เลือกจากที่เก็บเหตุการณ์
+// (จำนวนเหตุการณ์ที่อ่านได้และส่งสำเร็จ การส่งไม่สำเร็จ ช่วงที่หยุดทำงาน และความล่าช้า)
+package report
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"hissync-10/store"
+)
+
+// Row ยอดรวมของหนึ่งวัน หนึ่งโปรไฟล์ หนึ่งตาราง และหนึ่งประเภทคำสั่ง
+// Delivered และ Failed นับแยกตามปลายทาง เหตุการณ์ที่ส่งไปสองปลายทางจึงนับสองครั้ง
+type Row struct {
+	Day       time.Time
+	Profile   string
+	Table     string
+	Operation string
+	Captured  int
+	Delivered int
+	Failed    int
+	// Retried จำนวนที่ส่งสำเร็จหลังจากเคยส่งไม่สำเร็จ
+	Retried int
+	// MaxLag ระยะเวลานานที่สุดตั้งแต่บันทึกเหตุการณ์จนส่งถึงปลายทาง
+	MaxLag time.Duration
+}
+
+// Day ยอดรวมของหนึ่งวัน
+type Day struct {
+	Day       time.Time
+	Captured  int
+	Delivered int
+	Failed    int
+	Retried   int
+	MaxLag    time.Duration
+	// Downtime เวลารวมที่โปรไฟล์ใดโปรไฟล์หนึ่งหยุดทำงานในวันนี้ (นับซ้อนกันถ้าหยุดพร้อมกันหลายโปรไฟล์)
+	Downtime time.Duration
+}
+
+// Downtime ช่วงเวลาที่โปรไฟล์หยุดทำงานเพราะข้อผิดพลาด
+type Downtime struct {
+	Profile string
+	From    time.Time
+	To      time.Time
+	Reason  string
+	// Ongoing ยังไม่กลับมาทำงานเมื่อสิ้นสุดช่วงรายงาน
+	Ongoing bool
+}
+
+// Duration ระยะเวลาที่หยุดทำงาน
+func (d Downtime) Duration() time.Duration {
+	return d.To.Sub(d.From)
+}
+
+// Lag ความล่าช้าในการส่งที่นานที่สุดในช่วงรายงาน
+type Lag struct {
+	Duration time.Duration
+	At       time.Time
+	Sink     string
+	Table    string
+}
+
+// Report รายงานการทำงานในช่วง [From, To)
+type Report struct {
+	From       time.Time
+	To         time.Time
+	Days       []Day
+	Rows       []Row
+	Downtime   []Downtime
+	LongestLag Lag
+	// ยอดรวมทั้งช่วง
+	Captured  int
+	Delivered int
+	Failed    int
+	Retried   int
+}
+
+// StartOfDay เวลาเที่ยงคืนของวันที่ t ตามเวลาท้องถิ่น
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// Build สร้างรายงานของวันที่ from ถึงวันที่ to (รวมทั้งสองวัน)
+func Build(st *store.Store, from, to time.Time) (*Report, error) {
+	from, to = StartOfDay(from), StartOfDay(to).AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return nil, errors.New("วันเริ่มต้นต้องไม่อยู่หลังวันสิ้นสุด")
+	}
+	r := &Report{From: from, To: to}
+	dayIndex := make(map[time.Time]int)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		dayIndex[day] = len(r.Days)
+		r.Days = append(r.Days, Day{Day: day})
+	}
+
+	rowIndex := make(map[Row]int)
+	row := func(t time.Time, profile, table, operation string) *Row {
+		key := Row{Day: StartOfDay(t), Profile: profile, Table: table, Operation: operation}
+		i, ok := rowIndex[key]
+		if !ok {
+			i = len(r.Rows)
+			rowIndex[key] = i
+			r.Rows = append(r.Rows, key)
+		}
+		return &r.Rows[i]
+	}
+
+	err := st.EachBetween(from, to, func(rec store.Record) error {
+		row(rec.CapturedAt, rec.Profile, rec.FullTableName(), rec.Operation).Captured++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// อ่านบันทึกตั้งแต่ต้น เพื่อรู้ช่วงที่หยุดทำงานซึ่งเริ่มก่อนช่วงรายงาน
+	open := make(map[string]*Downtime)
+	err = st.Activities(time.Time{}, to, func(a store.Activity) error {
+		switch a.Kind {
+		case store.ActivityDown:
+			if open[a.Profile] == nil {
+				open[a.Profile] = &Downtime{Profile: a.Profile, From: a.Time, Reason: a.Message}
+			}
+		case store.ActivityUp:
+			if d := open[a.Profile]; d != nil {
+				d.To = a.Time
+				r.addDowntime(*d)
+				delete(open, a.Profile)
+			}
+		case store.ActivityDelivered, store.ActivityFailed:
+			if a.Time.Before(from) {
+				return nil
+			}
+			rw := row(a.Time, a.Profile, a.Table, a.Operation)
+			if a.Kind == store.ActivityFailed {
+				rw.Failed += a.Count
+				return nil
+			}
+			rw.Delivered += a.Count
+			if a.Retried {
+				rw.Retried += a.Count
+			}
+			if a.Lag > rw.MaxLag {
+				rw.MaxLag = a.Lag
+			}
+			if a.Lag > r.LongestLag.Duration {
+				r.LongestLag = Lag{Duration: a.Lag, At: a.Time, Sink: a.Sink, Table: a.Table}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	end := to
+	if now := time.Now(); now.Before(end) {
+		end = now
+	}
+	for _, d := range open {
+		d.To, d.Ongoing = end, true
+		r.addDowntime(*d)
+	}
+
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		switch {
+		case !a.Day.Equal(b.Day):
+			return a.Day.Before(b.Day)
+		case a.Profile != b.Profile:
+			return a.Profile < b.Profile
+		case a.Table != b.Table:
+			return a.Table < b.Table
+		}
+		return a.Operation < b.Operation
+	})
+	sort.Slice(r.Downtime, func(i, j int) bool { return r.Downtime[i].From.Before(r.Downtime[j].From) })
+	for _, rw := range r.Rows {
+		day := &r.Days[dayIndex[rw.Day]]
+		day.Captured += rw.Captured
+		day.Delivered += rw.Delivered
+		day.Failed += rw.Failed
+		day.Retried += rw.Retried
+		if rw.MaxLag > day.MaxLag {
+			day.MaxLag = rw.MaxLag
+		}
+		r.Captured += rw.Captured
+		r.Delivered += rw.Delivered
+		r.Failed += rw.Failed
+		r.Retried += rw.Retried
+	}
+	for _, d := range r.Downtime {
+		for i := range r.Days {
+			day := &r.Days[i]
+			start, stop := later(d.From, day.Day), earlier(d.To, day.Day.AddDate(0, 0, 1))
+			if start.Before(stop) {
+				day.Downtime += stop.Sub(start)
+			}
+		}
+	}
+	return r, nil
+}
+
+// addDowntime เพิ่มช่วงที่หยุดทำงานโดยตัดให้อยู่ในช่วงรายงาน
+func (r *Report) addDowntime(d Downtime) {
+	d.From, d.To = later(d.From, r.From), earlier(d.To, r.To)
+	if d.From.Before(d.To) {
+		r.Downtime = append(r.Downtime, d)
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,130 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"hissync-10/export"
+)
+
+// Formats รูปแบบไฟล์ที่ส่งออกรายงานได้
+var Formats = []string{export.FormatCSV, export.FormatXLSX}
+
+const dayFormat = "2006-01-02"
+
+var (
+	rowHeader      = []string{"day", "profile", "table", "operation", "captured", "delivered", "failed", "retried", "max_lag_seconds"}
+	dayHeader      = []string{"day", "captured", "delivered", "failed", "retried", "max_lag_seconds", "downtime_seconds"}
+	downtimeHeader = []string{"profile", "from", "to", "duration_seconds", "ongoing", "reason"}
+)
+
+// Write เขียนรายงานในรูปแบบ format
+// CSV มีเฉพาะยอดรายวันแยกตามตาราง ส่วน XLSX มีสรุปรายวัน ยอดแยกตามตาราง และช่วงที่หยุดทำงานคนละ sheet
+func Write(w io.Writer, format string, r *Report) error {
+	switch format {
+	case export.FormatCSV:
+		return writeCSV(w, r)
+	case export.FormatXLSX:
+		return writeXLSX(w, r)
+	}
+	return fmt.Errorf("ไม่รองรับการส่งออกรายงานเป็น %s", format)
+}
+
+// FileName ชื่อไฟล์เริ่มต้นสำหรับส่งออกรายงาน เช่น hissync-report-20240101-20240131.xlsx
+func FileName(r *Report, format string) string {
+	return fmt.Sprintf("hissync-report-%s-%s.%s",
+		r.From.Format("20060102"), r.To.AddDate(0, 0, -1).Format("20060102"), format)
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Second).Seconds()
+}
+
+func rowValues(rw Row) []interface{} {
+	return []interface{}{rw.Day.Format(dayFormat), rw.Profile, rw.Table, rw.Operation,
+		rw.Captured, rw.Delivered, rw.Failed, rw.Retried, seconds(rw.MaxLag)}
+}
+
+func writeCSV(w io.Writer, r *Report) error {
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(rowHeader); err != nil {
+		return err
+	}
+	for _, rw := range r.Rows {
+		values := rowValues(rw)
+		record := make([]string, len(values))
+		for i, v := range values {
+			switch v := v.(type) {
+			case string:
+				record[i] = v
+			case int:
+				record[i] = strconv.Itoa(v)
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeXLSX(w io.Writer, r *Report) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheets := []struct {
+		name   string
+		header []string
+		rows   [][]interface{}
+	}{
+		{name: "daily", header: dayHeader},
+		{name: "tables", header: rowHeader},
+		{name: "downtime", header: downtimeHeader},
+	}
+	for _, d := range r.Days {
+		sheets[0].rows = append(sheets[0].rows, []interface{}{d.Day.Format(dayFormat),
+			d.Captured, d.Delivered, d.Failed, d.Retried, seconds(d.MaxLag), seconds(d.Downtime)})
+	}
+	for _, rw := range r.Rows {
+		sheets[1].rows = append(sheets[1].rows, rowValues(rw))
+	}
+	for _, d := range r.Downtime {
+		sheets[2].rows = append(sheets[2].rows, []interface{}{d.Profile, d.From.Local(), d.To.Local(),
+			seconds(d.Duration()), d.Ongoing, d.Reason})
+	}
+
+	for i, sheet := range sheets {
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), sheet.name); err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(sheet.name); err != nil {
+			return err
+		}
+		header := make([]interface{}, len(sheet.header))
+		for j, h := range sheet.header {
+			header[j] = h
+		}
+		if err := f.SetSheetRow(sheet.name, "A1", &header); err != nil {
+			return err
+		}
+		for j, values := range sheet.rows {
+			cell, _ := excelize.CoordinatesToCellName(1, j+2)
+			if err := f.SetSheetRow(sheet.name, cell, &values); err != nil {
+				return fmt.Errorf("ไม่สามารถเขียน sheet %s: %v", sheet.name, err)
+			}
+		}
+	}
+	return f.Write(w)
+}
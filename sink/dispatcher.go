@@ -0,0 +1,281 @@
+package sink
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	config "hissync-10/functions"
+	"hissync-10/logging"
+	"hissync-10/store"
+)
+
+// ระยะเวลาตรวจคิวค้างส่งเมื่อไม่มีการแจ้งเหตุการณ์ใหม่
+const pollInterval = 5 * time.Second
+
+// ระยะเวลารอก่อนส่งใหม่หลังส่งไม่สำเร็จ (เพิ่มเป็นเท่าตัวจนถึง maxBackoff)
+const (
+	minBackoff = 2 * time.Second
+	maxBackoff = time.Minute
+)
+
+// Status สถานะการส่งของปลายทางหนึ่งรายการ
+type Status struct {
+	Name      string
+	Type      string
+	Delivered uint64
+	Failed    uint64
+	LastSent  time.Time
+	LastErr   error
+	// Pending จำนวนรายการค้างส่ง และ OldestPending เวลาที่บันทึกรายการค้างส่งที่เก่าที่สุด
+	Pending       int
+	OldestPending time.Time
+}
+
+// DeliveryLag ระยะเวลาที่รายการค้างส่งที่เก่าที่สุดรออยู่ (0 ถ้าไม่มีค้างส่ง)
+func (s Status) DeliveryLag() time.Duration {
+	if s.OldestPending.IsZero() {
+		return 0
+	}
+	return time.Since(s.OldestPending)
+}
+
+// runner ปลายทางที่กำลังส่งอยู่หนึ่งรายการ
+type runner struct {
+	cfg    config.SinkConfig
+	sink   Sink
+	ctx    context.Context
+	cancel context.CancelFunc
+	wake   chan struct{}
+	done   chan struct{}
+	status Status
+	// failing การส่งครั้งล่าสุดไม่สำเร็จ ชุดที่ส่งสำเร็จถัดไปนับเป็นการส่งซ้ำ
+	failing bool
+}
+
+// Dispatcher ส่งเหตุการณ์จากคิวค้างส่งใน store ไปยังทุกปลายทางใน config
+type Dispatcher struct {
+	mu      sync.Mutex
+	store   *store.Store
+	runners map[string]*runner
+}
+
+// NewDispatcher สร้าง Dispatcher ที่อ่านคิวจาก st
+func NewDispatcher(st *store.Store) *Dispatcher {
+	return &Dispatcher{store: st, runners: make(map[string]*runner)}
+}
+
+// Apply ปรับปลายทางให้ตรงกับ sinks ปลายทางที่ไม่เปลี่ยนแปลงจะส่งต่อ ที่เปลี่ยนหรือถูกลบจะถูกหยุด/เริ่มใหม่
+func (d *Dispatcher) Apply(sinks []config.SinkConfig) {
+	d.mu.Lock()
+	wanted := make(map[string]config.SinkConfig)
+	for _, s := range sinks {
+		if !s.Disabled {
+			wanted[s.Name] = s
+		}
+	}
+	var stale []*runner
+	for name, r := range d.runners {
+		if s, ok := wanted[name]; !ok || !reflect.DeepEqual(s, r.cfg) {
+			stale = append(stale, r)
+			delete(d.runners, name)
+		}
+	}
+	var started []*runner
+	for _, s := range sinks {
+		if _, ok := d.runners[s.Name]; ok || s.Disabled {
+			continue
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &runner{
+			cfg:    s,
+			ctx:    ctx,
+			cancel: cancel,
+			wake:   make(chan struct{}, 1),
+			done:   make(chan struct{}),
+			status: Status{Name: s.Name, Type: s.Type},
+		}
+		r.sink, r.status.LastErr = New(s)
+		d.runners[s.Name] = r
+		started = append(started, r)
+	}
+	d.mu.Unlock()
+
+	for _, r := range stale {
+		r.stop()
+	}
+	for _, r := range started {
+		d.start(r)
+	}
+}
+
+// Notify แจ้งทุกปลายทางว่ามีเหตุการณ์ใหม่ในคิว
+func (d *Dispatcher) Notify() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.runners {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Statuses คืนสถานะการส่งและจำนวนค้างส่งของทุกปลายทางเรียงตามชื่อ
+func (d *Dispatcher) Statuses() []Status {
+	d.mu.Lock()
+	statuses := make([]Status, 0, len(d.runners))
+	for _, r := range d.runners {
+		statuses = append(statuses, r.status)
+	}
+	d.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	for i := range statuses {
+		statuses[i].Pending, _ = d.store.PendingCount(statuses[i].Name)
+		statuses[i].OldestPending, _ = d.store.OldestPending(statuses[i].Name)
+	}
+	return statuses
+}
+
+// Stop หยุดทุกปลายทางและรอจนหยุดเรียบร้อย
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	runners := d.runners
+	d.runners = make(map[string]*runner)
+	d.mu.Unlock()
+
+	for _, r := range runners {
+		r.stop()
+	}
+}
+
+func (d *Dispatcher) start(r *runner) {
+	ctx := r.ctx
+	if r.sink == nil {
+		close(r.done)
+		return
+	}
+
+	go func() {
+		defer close(r.done)
+		backoff := minBackoff
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.wake:
+			case <-timer.C:
+			}
+
+			sent, err := d.deliver(ctx, r)
+			wait := pollInterval
+			switch {
+			case err != nil:
+				wait = backoff
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+			case sent > 0:
+				// อาจยังมีรายการค้างอยู่ ส่งชุดถัดไปทันที
+				backoff = minBackoff
+				wait = 0
+			default:
+				backoff = minBackoff
+			}
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+		}
+	}()
+}
+
+// deliver ส่งเหตุการณ์ค้างส่งหนึ่งชุด คืนจำนวนที่ส่งสำเร็จ
+func (d *Dispatcher) deliver(ctx context.Context, r *runner) (int, error) {
+	records, err := d.store.Pending(r.cfg.Name, r.cfg.BatchSize)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+
+	err = r.sink.Send(ctx, records)
+	if err == nil {
+		seqs := make([]uint64, len(records))
+		for i, rec := range records {
+			seqs[i] = rec.Seq
+		}
+		err = d.store.Ack(r.cfg.Name, seqs)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		// ถูกหยุดระหว่างส่ง รายการยังอยู่ในคิวและจะส่งใหม่เมื่อเริ่มทำงาน
+		return 0, ctx.Err()
+	}
+
+	d.recordActivity(r, records, err)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err != nil {
+		r.failing = true
+		r.status.Failed += uint64(len(records))
+		r.status.LastErr = config.RedactError(err)
+		logging.For(logging.ComponentSink).Warn("ส่งเหตุการณ์ไม่สำเร็จ จะส่งใหม่ภายหลัง",
+			"sink", r.cfg.Name, "type", r.cfg.Type, "records", len(records), "error", r.status.LastErr)
+		return 0, err
+	}
+	r.failing = false
+	r.status.Delivered += uint64(len(records))
+	r.status.LastSent = time.Now()
+	r.status.LastErr = nil
+	return len(records), nil
+}
+
+// recordActivity บันทึกผลการส่งหนึ่งชุดแยกตามตารางและประเภทคำสั่งสำหรับรายงาน
+func (d *Dispatcher) recordActivity(r *runner, records []store.Record, sendErr error) {
+	now := time.Now()
+	d.mu.Lock()
+	retried := r.failing
+	d.mu.Unlock()
+
+	index := make(map[string]int)
+	var activities []store.Activity
+	for _, rec := range records {
+		key := rec.Profile + "\x00" + rec.FullTableName() + "\x00" + rec.Operation
+		i, ok := index[key]
+		if !ok {
+			i = len(activities)
+			index[key] = i
+			a := store.Activity{Time: now, Kind: store.ActivityDelivered, Profile: rec.Profile, Sink: r.cfg.Name,
+				Table: rec.FullTableName(), Operation: rec.Operation, Retried: retried}
+			if sendErr != nil {
+				a.Kind = store.ActivityFailed
+				a.Retried = false
+				a.Message = config.Redact(sendErr.Error())
+			}
+			activities = append(activities, a)
+		}
+		activities[i].Count++
+		if lag := now.Sub(rec.CapturedAt); sendErr == nil && lag > activities[i].Lag {
+			activities[i].Lag = lag
+		}
+	}
+	if err := d.store.RecordActivity(activities...); err != nil {
+		logging.For(logging.ComponentSink).Warn("ไม่สามารถบันทึกผลการส่งสำหรับรายงาน", "sink", r.cfg.Name, "error", err)
+	}
+}
+
+// stop หยุด runner และรอจนหยุดเรียบร้อย
+func (r *runner) stop() {
+	r.cancel()
+	<-r.done
+}
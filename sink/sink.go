@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+// เวลาสูงสุดในการส่งเหตุการณ์หนึ่งชุดไปยังปลายทาง HTTP
+const httpTimeout = 30 * time.Second
+
+// Sink ปลายทางที่รับเหตุการณ์ครั้งละหลายรายการ
+// ถ้า Send คืนข้อผิดพลาด เหตุการณ์ทั้งชุดจะยังอยู่ในคิวและถูกส่งใหม่ภายหลัง
+type Sink interface {
+	Send(ctx context.Context, records []store.Record) error
+}
+
+// New สร้างปลายทางตามประเภทใน cfg
+func New(cfg config.SinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case config.SinkTypeFile:
+		return &FileSink{path: cfg.Path}, nil
+	case config.SinkTypeHTTP:
+		return &HTTPSink{url: cfg.URL, token: cfg.Token, client: &http.Client{Timeout: httpTimeout}}, nil
+	default:
+		return nil, fmt.Errorf("ไม่รองรับปลายทางประเภท %s", cfg.Type)
+	}
+}
+
+// FileSink เขียนเหตุการณ์ต่อท้ายไฟล์ในรูปแบบ JSON Lines
+type FileSink struct {
+	path string
+}
+
+// Send เขียนเหตุการณ์ทั้งชุดลงไฟล์แล้ว sync ลงดิสก์
+func (s *FileSink) Send(ctx context.Context, records []store.Record) error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเปิดไฟล์ปลายทาง %s: %v", s.path, err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("ไม่สามารถเขียนไฟล์ปลายทาง %s: %v", s.path, err)
+	}
+	return file.Sync()
+}
+
+// HTTPSink ส่งเหตุการณ์เป็น JSON ด้วย POST ไปยัง URL ปลายทาง
+type HTTPSink struct {
+	url    string
+	token  config.Secret
+	client *http.Client
+}
+
+// Send ส่ง {"events": [...]} และถือว่าสำเร็จเมื่อได้สถานะ 2xx
+func (s *HTTPSink) Send(ctx context.Context, records []store.Record) error {
+	body, err := json.Marshal(struct {
+		Events []store.Record `json:"events"`
+	}{records})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token.Reveal())
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ส่งข้อมูลไปยัง %s ไม่สำเร็จ: %v", s.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("ปลายทาง %s ตอบกลับ %s: %s", s.url, resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
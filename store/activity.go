@@ -0,0 +1,156 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ประเภทของบันทึกการทำงาน
+const (
+	ActivityDelivered = "delivered" // ส่งเหตุการณ์ถึงปลายทางสำเร็จ
+	ActivityFailed    = "failed"    // ส่งเหตุการณ์ไม่สำเร็จ (จะส่งใหม่ภายหลัง)
+	ActivityDown      = "down"      // โปรไฟล์หยุดทำงานเพราะข้อผิดพลาด
+	ActivityUp        = "up"        // โปรไฟล์กลับมาทำงาน
+)
+
+// เก็บบันทึกการทำงานย้อนหลังไม่เกินระยะนี้ รายการที่เก่ากว่าจะถูกลบเมื่อบันทึกรายการใหม่
+const activityRetention = 400 * 24 * time.Hour
+
+var activityBucket = []byte("activity")
+
+// Activity บันทึกการทำงานของโปรแกรมหนึ่งรายการ ใช้สำหรับทำรายงาน
+// การส่งหนึ่งชุดบันทึกแยกตามตารางและประเภทคำสั่ง
+type Activity struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Profile   string    `json:"profile,omitempty"`
+	Sink      string    `json:"sink,omitempty"`
+	Table     string    `json:"table,omitempty"`
+	Operation string    `json:"operation,omitempty"`
+	Count     int       `json:"count,omitempty"`
+	// Retried ส่งสำเร็จหลังจากเคยส่งไม่สำเร็จ
+	Retried bool `json:"retried,omitempty"`
+	// Lag ระยะเวลานานที่สุดตั้งแต่บันทึกเหตุการณ์จนส่งถึงปลายทางในชุดนี้
+	Lag     time.Duration `json:"lag,omitempty"`
+	Message string        `json:"message,omitempty"`
+}
+
+// activityKey คีย์เรียงตามเวลา ต่อท้ายด้วยลำดับเพื่อไม่ให้ซ้ำกัน
+func activityKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// RecordActivity บันทึกการทำงานและลบรายการที่เก่ากว่าระยะเก็บรักษา
+func (s *Store) RecordActivity(activities ...Activity) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(activityBucket)
+		if err != nil {
+			return err
+		}
+		for _, a := range activities {
+			if a.Time.IsZero() {
+				a.Time = time.Now()
+			}
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(a)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(activityKey(a.Time, seq), data); err != nil {
+				return err
+			}
+		}
+
+		expired := activityKey(time.Now().Add(-activityRetention), 0)
+		c := bucket.Cursor()
+		for k, _ := c.First(); k != nil && string(k) < string(expired); k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถบันทึกการทำงาน: %v", err)
+	}
+	return nil
+}
+
+// Activities เรียก fn กับบันทึกการทำงานในช่วง [from, to) ตามลำดับเวลา หยุดเมื่อ fn คืนข้อผิดพลาด
+// from เป็นค่าศูนย์คืออ่านตั้งแต่รายการแรก
+func (s *Store) Activities(from, to time.Time, fn func(Activity) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(activityBucket)
+		if bucket == nil {
+			return nil
+		}
+		end := activityKey(to, 0)
+		c := bucket.Cursor()
+		k, data := c.First()
+		if !from.IsZero() {
+			k, data = c.Seek(activityKey(from, 0))
+		}
+		for ; k != nil && string(k) < string(end); k, data = c.Next() {
+			var a Activity
+			if err := json.Unmarshal(data, &a); err != nil {
+				return err
+			}
+			if err := fn(a); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// EachBetween เรียก fn กับเหตุการณ์ที่บันทึกในช่วง [from, to) ตามลำดับ หยุดเมื่อ fn คืนข้อผิดพลาด
+func (s *Store) EachBetween(from, to time.Time, fn func(Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+		k, data := c.First()
+		// เวลาที่บันทึกเพิ่มขึ้นตามลำดับ จึงค้นหาจุดเริ่มแบบ binary search จากลำดับได้
+		if last, _ := c.Last(); last != nil {
+			lo, hi := binary.BigEndian.Uint64(k), binary.BigEndian.Uint64(last)
+			for lo < hi {
+				mid := lo + (hi-lo)/2
+				mk, mdata := c.Seek(itob(mid))
+				var rec Record
+				if err := json.Unmarshal(mdata, &rec); err != nil {
+					return err
+				}
+				if rec.CapturedAt.Before(from) {
+					lo = binary.BigEndian.Uint64(mk) + 1
+				} else {
+					hi = mid
+				}
+			}
+			k, data = c.Seek(itob(lo))
+		}
+		for ; k != nil; k, data = c.Next() {
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			if !rec.CapturedAt.Before(to) {
+				return nil
+			}
+			if rec.CapturedAt.Before(from) {
+				continue
+			}
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
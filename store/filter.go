@@ -0,0 +1,55 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// Filter เงื่อนไขสำหรับค้นหาเหตุการณ์ ฟิลด์ที่ว่างคือไม่กรอง
+// ข้อความเทียบแบบไม่สนตัวพิมพ์เล็กใหญ่และตรงบางส่วน
+type Filter struct {
+	Profile    string
+	Table      string // database.table หรือบางส่วนของชื่อ
+	Operation  string // INSERT, UPDATE หรือ DELETE
+	PrimaryKey string
+	// Transaction รหัสธุรกรรม (GTID หรือไฟล์:ตำแหน่ง ของ BEGIN) ต้องตรงทั้งหมด
+	Transaction string
+	From        time.Time
+	To          time.Time
+	// Text ค้นหาในชื่อตาราง primary key คำสั่ง SQL และผู้ทำรายการ (ผู้ใช้ โปรแกรม เครื่อง)
+	Text string
+}
+
+// Match บอกว่าเหตุการณ์ตรงกับทุกเงื่อนไขหรือไม่
+func (f Filter) Match(rec Record) bool {
+	switch {
+	case f.Profile != "" && rec.Profile != f.Profile,
+		f.Operation != "" && !strings.EqualFold(rec.Operation, f.Operation),
+		f.Table != "" && !containsFold(rec.FullTableName(), f.Table),
+		f.PrimaryKey != "" && !containsFold(rec.PrimaryKey, f.PrimaryKey),
+		f.Transaction != "" && rec.Transaction != f.Transaction,
+		!f.From.IsZero() && rec.Time.Before(f.From),
+		!f.To.IsZero() && rec.Time.After(f.To):
+		return false
+	}
+	if f.Text != "" {
+		return containsFold(rec.FullTableName(), f.Text) ||
+			containsFold(rec.PrimaryKey, f.Text) ||
+			containsFold(rec.SQL, f.Text) ||
+			containsFold(rec.OriginalSQL, f.Text) ||
+			containsFold(rec.DBUser, f.Text) ||
+			containsFold(rec.ClientApp, f.Text) ||
+			containsFold(rec.ClientHost, f.Text)
+	}
+	return true
+}
+
+// Active บอกว่ามีเงื่อนไขอื่นนอกจากโปรไฟล์หรือไม่
+func (f Filter) Active() bool {
+	f.Profile = ""
+	return f != Filter{}
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+
+	config "hissync-10/functions"
+)
+
+// ดัชนีประวัติการเปลี่ยนแปลงอยู่ใน bucket history แยกเป็น bucket ย่อย
+// rows:       profile \0 database.table \0 primary key \0 seq
+// people:     p:รหัสบุคคล \0 profile \0 seq และ c:เลขประจำตัวประชาชน \0 profile \0 seq
+// identities: p:รหัสบุคคล \0 profile → เลขประจำตัวประชาชน และ c:เลขประจำตัวประชาชน \0 profile → รหัสบุคคล
+// links:      profile \0 database.table \0 คอลัมน์ \0 ค่า → รหัสบุคคล (ใช้กับ person.via)
+var (
+	historyBucket    = []byte("history")
+	rowsBucket       = []byte("rows")
+	peopleBucket     = []byte("people")
+	identitiesBucket = []byte("identities")
+	linksBucket      = []byte("links")
+)
+
+// personRule การระบุบุคคลของตารางหนึ่ง
+type personRule struct {
+	column, citizenColumn string
+	// viaTable และ viaColumn ตารางที่มีรหัสบุคคลและคอลัมน์ที่ใช้เชื่อม (database.table)
+	viaTable, viaColumn string
+	// linkColumns คอลัมน์ของตารางนี้ที่ตารางอื่นใช้เชื่อมมาหา ต้องจำค่าไว้พร้อมรหัสบุคคล
+	linkColumns []string
+}
+
+// SetTables กำหนดตารางของโปรไฟล์ที่ใช้ระบุบุคคล (person ใน tables.json) สำหรับเหตุการณ์ที่บันทึกหลังจากนี้
+// เรียกซ้ำด้วย tables เดิมได้โดยไม่สร้างกฎใหม่
+func (s *Store) SetTables(profile string, tables *config.TableConfig) {
+	s.mu.Lock()
+	same := s.tables[profile] == tables && s.people[profile] != nil
+	s.mu.Unlock()
+	if same {
+		return
+	}
+	rules := make(map[string]*personRule)
+	if tables != nil {
+		for _, e := range tables.Tables {
+			if e.Person == nil {
+				continue
+			}
+			rule := rules[e.FullName()]
+			if rule == nil {
+				rule = &personRule{}
+				rules[e.FullName()] = rule
+			}
+			rule.column, rule.citizenColumn = e.Person.Column, e.Person.CitizenColumn
+			if table, column := e.Person.ViaTable(); table != "" {
+				rule.viaTable, rule.viaColumn = e.Database+"."+table, column
+				target := rules[rule.viaTable]
+				if target == nil {
+					target = &personRule{}
+					rules[rule.viaTable] = target
+				}
+				target.linkColumns = append(target.linkColumns, column)
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.people == nil {
+		s.people = make(map[string]map[string]*personRule)
+		s.tables = make(map[string]*config.TableConfig)
+	}
+	s.people[profile], s.tables[profile] = rules, tables
+}
+
+// rulesFor คืนการระบุบุคคลของตาราง (nil ถ้าไม่ได้กำหนด)
+func (s *Store) rulesFor(profile, table string) *personRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.people[profile][table]
+}
+
+// key ต่อส่วนของคีย์ดัชนีด้วย \0
+func key(parts ...string) []byte {
+	return []byte(strings.Join(parts, "\x00"))
+}
+
+// withSeq ต่อท้ายคีย์ด้วย \0 และลำดับของเหตุการณ์
+func withSeq(prefix []byte, seq uint64) []byte {
+	return append(append(append([]byte(nil), prefix...), 0), itob(seq)...)
+}
+
+// columnValues ค่าหลังและก่อนเปลี่ยนของคอลัมน์ (ไม่ซ้ำกันและไม่ว่าง)
+func columnValues(rec Record, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var values []string
+	for _, c := range rec.Columns {
+		if c.Name != name {
+			continue
+		}
+		for _, v := range []*string{c.After, c.Before} {
+			if v != nil && *v != "" && (len(values) == 0 || values[0] != *v) {
+				values = append(values, *v)
+			}
+		}
+	}
+	return values
+}
+
+// indexHistory เพิ่มเหตุการณ์เข้าดัชนีตามโปรไฟล์และตาราง (ดู indexScopes) และดัชนีประวัติของแถวและของบุคคล
+func (s *Store) indexHistory(tx *bolt.Tx, rec Record) error {
+	if err := indexScopes(tx, rec); err != nil {
+		return err
+	}
+	if !rec.IsChange() {
+		return nil
+	}
+	history, err := tx.CreateBucketIfNotExists(historyBucket)
+	if err != nil {
+		return err
+	}
+	buckets := make(map[string]*bolt.Bucket)
+	for _, name := range [][]byte{rowsBucket, peopleBucket, identitiesBucket, linksBucket} {
+		b, err := history.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		buckets[string(name)] = b
+	}
+
+	table := rec.FullTableName()
+	if rec.PrimaryKey != "" && rec.PrimaryKey != "{}" {
+		if err := buckets["rows"].Put(withSeq(key(rec.Profile, table, rec.PrimaryKey), rec.Seq), nil); err != nil {
+			return err
+		}
+	}
+
+	rule := s.rulesFor(rec.Profile, table)
+	if rule == nil {
+		return nil
+	}
+	persons := columnValues(rec, rule.column)
+	if len(persons) == 0 && rule.viaTable != "" {
+		for _, v := range columnValues(rec, rule.viaColumn) {
+			if pid := buckets["links"].Get(key(rec.Profile, rule.viaTable, rule.viaColumn, v)); pid != nil {
+				persons = append(persons, string(pid))
+			}
+		}
+	}
+	citizens := columnValues(rec, rule.citizenColumn)
+
+	if len(persons) > 0 {
+		// ค่าล่าสุดของคอลัมน์ที่ตารางอื่นเชื่อมมา ชี้ไปยังบุคคลปัจจุบันของแถว
+		for _, column := range rule.linkColumns {
+			for _, v := range columnValues(rec, column) {
+				if err := buckets["links"].Put(key(rec.Profile, table, column, v), []byte(persons[0])); err != nil {
+					return err
+				}
+			}
+		}
+		if len(citizens) > 0 {
+			if err := buckets["identities"].Put(key("p:"+persons[0], rec.Profile), []byte(citizens[0])); err != nil {
+				return err
+			}
+			if err := buckets["identities"].Put(key("c:"+citizens[0], rec.Profile), []byte(persons[0])); err != nil {
+				return err
+			}
+		}
+	}
+	for _, pid := range persons {
+		if err := buckets["people"].Put(withSeq(key("p:"+pid, rec.Profile), rec.Seq), nil); err != nil {
+			return err
+		}
+	}
+	for _, cid := range citizens {
+		if err := buckets["people"].Put(withSeq(key("c:"+cid, rec.Profile), rec.Seq), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reindex สร้างดัชนีประวัติใหม่จากเหตุการณ์ทั้งหมด ใช้เมื่อแก้ไข person ใน tables.json
+// ต้องเรียก SetTables ของทุกโปรไฟล์ก่อน คืนจำนวนเหตุการณ์ที่อ่าน
+func (s *Store) Reindex() (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(historyBucket) != nil {
+			if err := tx.DeleteBucket(historyBucket); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(eventsBucket).ForEach(func(_, data []byte) error {
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			count++
+			return s.indexHistory(tx, rec)
+		})
+	})
+	if err != nil {
+		return count, fmt.Errorf("ไม่สามารถสร้างดัชนีประวัติใหม่: %v", err)
+	}
+	return count, nil
+}
+
+// RowHistory คืนเหตุการณ์ทั้งหมดของแถว (primary key ตามที่แสดงในเหตุการณ์) เรียงตามเวลาที่เกิด
+func (s *Store) RowHistory(profile, table, primaryKey string) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		rows := subBucket(tx, rowsBucket)
+		if rows == nil {
+			return nil
+		}
+		seqs := scanSeqs(rows, append(key(profile, table, primaryKey), 0))
+		var err error
+		records, err = loadRecords(tx, seqs)
+		return err
+	})
+	return records, err
+}
+
+// PersonTimeline คืนเหตุการณ์ทั้งหมดของบุคคลจากรหัสบุคคลหรือเลขประจำตัวประชาชน เรียงตามเวลาที่เกิด
+// รวมเหตุการณ์ของทุกโปรไฟล์ที่มีรหัสบุคคลนี้ และของรหัสบุคคลในโปรไฟล์อื่นที่มีเลขประจำตัวประชาชนเดียวกัน
+func (s *Store) PersonTimeline(id string) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		people, identities := subBucket(tx, peopleBucket), subBucket(tx, identitiesBucket)
+		if people == nil {
+			return nil
+		}
+
+		// เลขประจำตัวประชาชนที่เกี่ยวข้อง: id เอง และของรหัสบุคคล id ในทุกโปรไฟล์
+		citizens := map[string]bool{id: true}
+		prefixes := [][]byte{append(key("p:"+id), 0)}
+		if identities != nil {
+			c := identities.Cursor()
+			prefix := append(key("p:"+id), 0)
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				citizens[string(v)] = true
+			}
+		}
+		for cid := range citizens {
+			prefixes = append(prefixes, append(key("c:"+cid), 0))
+			if identities == nil {
+				continue
+			}
+			// รหัสบุคคลของเลขประจำตัวประชาชนนี้ในแต่ละโปรไฟล์
+			c := identities.Cursor()
+			prefix := append(key("c:"+cid), 0)
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				profile := string(k[len(prefix):])
+				prefixes = append(prefixes, append(key("p:"+string(v), profile), 0))
+			}
+		}
+
+		seen := make(map[uint64]bool)
+		var seqs []uint64
+		for _, prefix := range prefixes {
+			for _, seq := range scanSeqs(people, prefix) {
+				if !seen[seq] {
+					seen[seq] = true
+					seqs = append(seqs, seq)
+				}
+			}
+		}
+		var err error
+		records, err = loadRecords(tx, seqs)
+		return err
+	})
+	return records, err
+}
+
+// subBucket คืน bucket ย่อยของดัชนีประวัติ (nil ถ้ายังไม่มี)
+func subBucket(tx *bolt.Tx, name []byte) *bolt.Bucket {
+	history := tx.Bucket(historyBucket)
+	if history == nil {
+		return nil
+	}
+	return history.Bucket(name)
+}
+
+// scanSeqs คืนลำดับของเหตุการณ์จากคีย์ที่ขึ้นต้นด้วย prefix (8 ไบต์สุดท้ายของคีย์คือลำดับ)
+func scanSeqs(b *bolt.Bucket, prefix []byte) []uint64 {
+	var seqs []uint64
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if len(k) >= 8 {
+			seqs = append(seqs, binary.BigEndian.Uint64(k[len(k)-8:]))
+		}
+	}
+	return seqs
+}
+
+// loadRecords อ่านเหตุการณ์ตามลำดับที่ระบุ แล้วเรียงตามเวลาที่เกิด (เวลาเท่ากันเรียงตามลำดับที่บันทึก)
+func loadRecords(tx *bolt.Tx, seqs []uint64) ([]Record, error) {
+	events := tx.Bucket(eventsBucket)
+	records := make([]Record, 0, len(seqs))
+	for _, seq := range seqs {
+		data := events.Get(itob(seq))
+		if data == nil {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].Time.Equal(records[j].Time) {
+			return records[i].Time.Before(records[j].Time)
+		}
+		return records[i].Seq < records[j].Seq
+	})
+	return records, nil
+}
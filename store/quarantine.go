@@ -0,0 +1,281 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"hissync-10/capture"
+)
+
+// ธุรกรรมที่ยังไม่เข้าคิวปลายทางอยู่ใน bucket holds แยกเป็น bucket ย่อยตามรหัส (ลำดับของเหตุการณ์แรก)
+// แต่ละ bucket มี meta (Hold แบบ JSON) และ bucket ย่อย seqs ของเหตุการณ์ในธุรกรรม
+// withheld เก็บลำดับของเหตุการณ์ที่ยังรอส่งหรือถูกปฏิเสธ เพื่อไม่ให้ replay นำเข้าคิวปลายทาง
+var (
+	holdsBucket    = []byte("holds")
+	holdMetaKey    = []byte("meta")
+	holdSeqs       = []byte("seqs")
+	withheldBucket = []byte("withheld")
+
+	withheldHeld     = []byte("held")
+	withheldRejected = []byte("rejected")
+)
+
+// Hold ธุรกรรมที่ยังไม่เข้าคิวค้างส่งของปลายทาง
+// ระหว่างอ่านธุรกรรมยังไม่ครบจะถูกพักไว้ก่อน ถ้าเกินเกณฑ์ safeguard จะถูกกักไว้จนกว่าจะอนุมัติหรือปฏิเสธ
+type Hold struct {
+	ID          uint64    `json:"id"`
+	Profile     string    `json:"profile"`
+	Transaction string    `json:"transaction"`
+	Sinks       []string  `json:"sinks,omitempty"`
+	Since       time.Time `json:"since"`
+	Count       int       `json:"count"`
+	Deletes     int       `json:"deletes"`
+	// Bounded ต้นทางแจ้งจุดสิ้นสุดของธุรกรรม ธุรกรรมจะเข้าคิวเมื่อได้รับจุดสิ้นสุดเท่านั้น
+	Bounded bool `json:"bounded,omitempty"`
+	// Reason เหตุผลที่กักไว้ ว่างคือธุรกรรมที่กำลังอ่านอยู่ (จะเข้าคิวเมื่ออ่านครบ)
+	Reason        string    `json:"reason,omitempty"`
+	QuarantinedAt time.Time `json:"quarantined_at,omitempty"`
+}
+
+// Quarantined บอกว่าธุรกรรมถูกกักไว้รอการอนุมัติ
+func (h Hold) Quarantined() bool {
+	return h.Reason != ""
+}
+
+// AppendHeld บันทึกเหตุการณ์ของธุรกรรม h โดยยังไม่เข้าคิวปลายทาง
+// h.ID เป็น 0 คือธุรกรรมใหม่ จะได้รหัสเป็นลำดับของเหตุการณ์นี้ จำนวนแถวใน h ถูกปรับตาม
+func (s *Store) AppendHeld(ev capture.Event, h *Hold) (Record, error) {
+	var rec Record
+	updated := *h
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if rec, err = s.put(tx, ev); err != nil {
+			return err
+		}
+		if updated.ID == 0 {
+			updated.ID, updated.Since = rec.Seq, rec.CapturedAt
+		}
+		updated.Count++
+		if ev.Operation == "DELETE" {
+			updated.Deletes++
+		}
+		b, err := holdBucket(tx, updated.ID, true)
+		if err != nil {
+			return err
+		}
+		if err := b.Bucket(holdSeqs).Put(itob(rec.Seq), nil); err != nil {
+			return err
+		}
+		if err := tx.Bucket(withheldBucket).Put(itob(rec.Seq), withheldHeld); err != nil {
+			return err
+		}
+		return putHold(b, updated)
+	})
+	if err != nil {
+		return rec, fmt.Errorf("ไม่สามารถบันทึกเหตุการณ์: %v", err)
+	}
+	*h = updated
+	return rec, nil
+}
+
+// Quarantine กักธุรกรรมไว้รอการอนุมัติพร้อมเหตุผล
+func (s *Store) Quarantine(id uint64, reason string) error {
+	return s.updateHold(id, func(h *Hold) {
+		h.Reason, h.QuarantinedAt = reason, time.Now()
+	})
+}
+
+// Release นำเหตุการณ์ของธุรกรรมเข้าคิวค้างส่งของปลายทาง (ธุรกรรมที่อ่านครบหรือที่อนุมัติแล้ว) คืนจำนวนเหตุการณ์
+func (s *Store) Release(id uint64) (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := holdBucket(tx, id, false)
+		if err != nil {
+			return err
+		}
+		h, err := getHold(b)
+		if err != nil {
+			return err
+		}
+		withheld := tx.Bucket(withheldBucket)
+		err = b.Bucket(holdSeqs).ForEach(func(k, _ []byte) error {
+			count++
+			if err := withheld.Delete(k); err != nil {
+				return err
+			}
+			return enqueue(tx, h.Sinks, k)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(holdsBucket).DeleteBucket(itob(id))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ไม่สามารถส่งธุรกรรม #%d: %v", id, err)
+	}
+	return count, nil
+}
+
+// Reject ทิ้งธุรกรรมที่กักไว้โดยไม่ส่งไปยังปลายทาง เหตุการณ์ยังอยู่ใน hissync.db สำหรับตรวจสอบ
+// แต่ถูกจำว่าถูกปฏิเสธ replay จึงไม่นำเข้าคิวอีก คืนจำนวนเหตุการณ์
+func (s *Store) Reject(id uint64) (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := holdBucket(tx, id, false)
+		if err != nil {
+			return err
+		}
+		withheld := tx.Bucket(withheldBucket)
+		err = b.Bucket(holdSeqs).ForEach(func(k, _ []byte) error {
+			count++
+			return withheld.Put(k, withheldRejected)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(holdsBucket).DeleteBucket(itob(id))
+	})
+	if err != nil {
+		return 0, fmt.Errorf("ไม่สามารถปฏิเสธธุรกรรม #%d: %v", id, err)
+	}
+	return count, nil
+}
+
+// Holds คืนธุรกรรมที่ยังไม่เข้าคิวทั้งหมดเรียงตามรหัส
+func (s *Store) Holds() ([]Hold, error) {
+	var holds []Hold
+	err := s.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(holdsBucket)
+		if root == nil {
+			return nil
+		}
+		return root.ForEachBucket(func(k []byte) error {
+			h, err := getHold(root.Bucket(k))
+			if err != nil {
+				return err
+			}
+			holds = append(holds, h)
+			return nil
+		})
+	})
+	return holds, err
+}
+
+// Withheld คืนลำดับของเหตุการณ์ที่ยังรอส่ง (อ่านธุรกรรมไม่ครบหรือถูกกัก) หรือถูกปฏิเสธ
+func (s *Store) Withheld() (map[uint64]bool, error) {
+	seqs := make(map[uint64]bool)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(withheldBucket).ForEach(func(k, _ []byte) error {
+			seqs[binary.BigEndian.Uint64(k)] = true
+			return nil
+		})
+	})
+	return seqs, err
+}
+
+// HeldRecords คืนเหตุการณ์ของธุรกรรมตามลำดับ ไม่เกิน limit รายการ
+func (s *Store) HeldRecords(id uint64, limit int) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b, err := holdBucket(tx, id, false)
+		if err != nil {
+			return err
+		}
+		var seqs []uint64
+		c := b.Bucket(holdSeqs).Cursor()
+		for k, _ := c.First(); k != nil && len(seqs) < limit; k, _ = c.Next() {
+			seqs = append(seqs, binary.BigEndian.Uint64(k))
+		}
+		events := tx.Bucket(eventsBucket)
+		for _, seq := range seqs {
+			var rec Record
+			if data := events.Get(itob(seq)); data != nil {
+				if err := json.Unmarshal(data, &rec); err != nil {
+					return err
+				}
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// updateHold ปรับข้อมูลของธุรกรรม
+func (s *Store) updateHold(id uint64, fn func(*Hold)) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := holdBucket(tx, id, false)
+		if err != nil {
+			return err
+		}
+		h, err := getHold(b)
+		if err != nil {
+			return err
+		}
+		fn(&h)
+		return putHold(b, h)
+	})
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถปรับธุรกรรม #%d: %v", id, err)
+	}
+	return nil
+}
+
+// createWithheld สร้าง bucket withheld จากธุรกรรมที่รอส่งอยู่ (hissync.db ที่สร้างก่อนมี withheld)
+func createWithheld(tx *bolt.Tx) error {
+	withheld, err := tx.CreateBucket(withheldBucket)
+	if err != nil {
+		return err
+	}
+	root := tx.Bucket(holdsBucket)
+	if root == nil {
+		return nil
+	}
+	return root.ForEachBucket(func(k []byte) error {
+		return root.Bucket(k).Bucket(holdSeqs).ForEach(func(seq, _ []byte) error {
+			return withheld.Put(seq, withheldHeld)
+		})
+	})
+}
+
+// holdBucket คืน bucket ของธุรกรรม (create สร้างใหม่ถ้ายังไม่มี)
+func holdBucket(tx *bolt.Tx, id uint64, create bool) (*bolt.Bucket, error) {
+	if !create {
+		if root := tx.Bucket(holdsBucket); root != nil {
+			if b := root.Bucket(itob(id)); b != nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("ไม่พบธุรกรรม #%d ที่รอส่ง", id)
+	}
+	root, err := tx.CreateBucketIfNotExists(holdsBucket)
+	if err != nil {
+		return nil, err
+	}
+	b, err := root.CreateBucketIfNotExists(itob(id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.CreateBucketIfNotExists(holdSeqs); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func getHold(b *bolt.Bucket) (Hold, error) {
+	var h Hold
+	err := json.Unmarshal(b.Get(holdMetaKey), &h)
+	return h, err
+}
+
+func putHold(b *bolt.Bucket, h Hold) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return b.Put(holdMetaKey, data)
+}
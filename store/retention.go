@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// จำนวนเหตุการณ์สูงสุดที่ Purge ลบในธุรกรรมเดียว เพื่อไม่ให้ล็อกการบันทึกเหตุการณ์ใหม่นานเกินไป
+var purgeBatch = 10000
+
+// Purge ลบเหตุการณ์ที่บันทึกก่อน before และเหตุการณ์ที่เก่าที่สุดจนเหลือไม่เกิน keep รายการ
+// before เป็นค่าศูนย์คือไม่จำกัดอายุ keep เป็น 0 คือไม่จำกัดจำนวน
+// ไม่ลบเหตุการณ์ที่ยังค้างส่งในคิวของปลายทางหรือถูกพักไว้โดย safeguard คืนจำนวนที่ลบ
+func (s *Store) Purge(before time.Time, keep int) (int, error) {
+	if before.IsZero() && keep <= 0 {
+		return 0, nil
+	}
+	// นับเหตุการณ์ครั้งเดียว (Stats อ่านทั้ง bucket) แล้วหักตามที่ลบในแต่ละชุด
+	// เหตุการณ์ที่บันทึกระหว่างลบจะถูกนับในรอบถัดไป
+	excess := 0
+	if keep > 0 {
+		err := s.db.View(func(tx *bolt.Tx) error {
+			excess = tx.Bucket(eventsBucket).Stats().KeyN - keep
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("ไม่สามารถลบเหตุการณ์ที่เกินระยะเก็บรักษา: %v", err)
+		}
+	}
+	deleted := 0
+	var from uint64
+	for {
+		var batch []Record
+		more := false
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			events := tx.Bucket(eventsBucket)
+			// ใช้สำเนาของ excess จนกว่าธุรกรรมจะสำเร็จ
+			excess := excess
+			c := events.Cursor()
+			for k, data := c.Seek(itob(from)); k != nil; k, data = c.Next() {
+				if len(batch) == purgeBatch {
+					more = true
+					break
+				}
+				var rec Record
+				if err := json.Unmarshal(data, &rec); err != nil {
+					return err
+				}
+				if excess <= 0 && (before.IsZero() || !rec.CapturedAt.Before(before)) {
+					break
+				}
+				from = rec.Seq + 1
+				if retained(tx, k) {
+					continue
+				}
+				batch = append(batch, rec)
+				excess--
+			}
+
+			withheld := tx.Bucket(withheldBucket)
+			for _, rec := range batch {
+				if err := events.Delete(itob(rec.Seq)); err != nil {
+					return err
+				}
+				if err := withheld.Delete(itob(rec.Seq)); err != nil {
+					return err
+				}
+				if err := deindex(tx, rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return deleted, fmt.Errorf("ไม่สามารถลบเหตุการณ์ที่เกินระยะเก็บรักษา: %v", err)
+		}
+		deleted += len(batch)
+		excess -= len(batch)
+		if !more {
+			return deleted, nil
+		}
+	}
+}
+
+// retained บอกว่าเหตุการณ์ key ยังต้องเก็บไว้ (ค้างส่งในคิวของปลายทาง หรือถูกพักไว้รอส่งหรือรออนุมัติ)
+// เหตุการณ์ที่ถูกปฏิเสธลบได้
+func retained(tx *bolt.Tx, key []byte) bool {
+	if bytes.Equal(tx.Bucket(withheldBucket).Get(key), withheldHeld) {
+		return true
+	}
+	pending := tx.Bucket(pendingBucket)
+	found := false
+	pending.ForEachBucket(func(sink []byte) error {
+		if k, _ := pending.Bucket(sink).Cursor().Seek(key); bytes.Equal(k, key) {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
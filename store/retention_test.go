@@ -0,0 +1,88 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		name   string
+		before time.Time
+		keep   int
+		// want ลำดับที่เหลือ (1 ค้างส่ง 2 ถูกพักไว้ 3 ถูกปฏิเสธ)
+		want string
+	}{
+		{"no limits", time.Time{}, 0, "1,2,3,4,5,6,7,8"},
+		{"keep newest", time.Time{}, 5, "1,2,6,7,8"},
+		{"keep fewer than retained", time.Time{}, 1, "1,2"},
+		{"older than now", time.Now().Add(time.Hour), 0, "1,2"},
+		{"nothing old enough", time.Now().Add(-time.Hour), 0, "1,2,3,4,5,6,7,8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := openTestStore(t, scopeEvents)
+			err := st.db.Update(func(tx *bolt.Tx) error {
+				if err := enqueue(tx, []string{"hosxp"}, itob(1)); err != nil {
+					return err
+				}
+				if err := tx.Bucket(withheldBucket).Put(itob(2), withheldHeld); err != nil {
+					return err
+				}
+				return tx.Bucket(withheldBucket).Put(itob(3), withheldRejected)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := st.Purge(tt.before, tt.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			page, err := st.After(Filter{}, 0, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := seqsOf(page.Records); got != tt.want {
+				t.Fatalf("เหลือ %s ต้องการ %s", got, tt.want)
+			}
+			if want := len(scopeEvents) - len(page.Records); deleted != want {
+				t.Fatalf("Purge() = %d ต้องการ %d", deleted, want)
+			}
+
+			// ดัชนีตามโปรไฟล์และตาราง ดัชนีของแถว และ withheld ต้องไม่ชี้ไปยังเหตุการณ์ที่ลบแล้ว
+			st.db.View(func(tx *bolt.Tx) error {
+				if n := subBucket(tx, scopesBucket).Stats().KeyN; n != 2*len(page.Records) {
+					t.Fatalf("ดัชนี scopes มี %d คีย์ ต้องการ %d", n, 2*len(page.Records))
+				}
+				if n := subBucket(tx, rowsBucket).Stats().KeyN; n != len(page.Records) {
+					t.Fatalf("ดัชนี rows มี %d คีย์ ต้องการ %d", n, len(page.Records))
+				}
+				if deleted > 0 && tx.Bucket(withheldBucket).Get(itob(3)) != nil {
+					t.Fatal("ไม่ได้ลบเหตุการณ์ที่ถูกปฏิเสธออกจาก withheld")
+				}
+				return nil
+			})
+		})
+	}
+}
+
+func TestPurgeInBatches(t *testing.T) {
+	defer func(n int) { purgeBatch = n }(purgeBatch)
+	purgeBatch = 2
+	st, _ := openTestStore(t, scopeEvents)
+
+	deleted, err := st.Purge(time.Time{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := st.After(Filter{}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); deleted != 5 || got != "6,7,8" {
+		t.Fatalf("Purge() = %d เหลือ %s ต้องการ 5 เหลือ 6,7,8", deleted, got)
+	}
+}
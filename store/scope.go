@@ -0,0 +1,215 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ดัชนีสำหรับหน้าเหตุการณ์อยู่ใน bucket history เช่นเดียวกับดัชนีประวัติ (สร้างใหม่ได้ด้วย Reindex)
+// scopes: p:profile \0 seq และ t:profile \0 database.table \0 seq
+// tables: profile \0 database.table (ตารางที่เคยมีเหตุการณ์ ใช้เลือกดัชนีของตารางจากชื่อบางส่วน)
+var (
+	scopesBucket = []byte("scopes")
+	tablesBucket = []byte("tables")
+)
+
+// maxScan จำนวนเหตุการณ์สูงสุดที่ Before และ After อ่านในการเรียกหนึ่งครั้ง
+// ตัวกรองที่ตรงกับเหตุการณ์น้อยมากจะได้ผลไม่ครบหน้า พร้อม Page.More ให้ค้นต่อจาก Page.Next
+var maxScan = 50000
+
+// Page เหตุการณ์หนึ่งหน้าจาก Before หรือ After เรียงจากเก่าไปใหม่
+type Page struct {
+	Records []Record
+	// More หยุดค้นเพราะอ่านครบ maxScan รายการก่อนได้ครบ limit อาจยังมีเหตุการณ์ที่ตรงกับตัวกรองถัดจาก Next
+	More bool
+	// Next ลำดับที่ใช้ค้นหน้าถัดไปในทิศทางเดิม (ส่งเป็น seq ของ Before หรือ After อีกครั้ง)
+	Next uint64
+}
+
+// profileScope และ tableScope คำนำหน้าคีย์ในดัชนี scopes ของโปรไฟล์และของตาราง (ต่อท้ายด้วยลำดับ)
+func profileScope(profile string) []byte {
+	return append(key("p:"+profile), 0)
+}
+
+func tableScope(profile, table string) []byte {
+	return append(key("t:"+profile, table), 0)
+}
+
+// indexScopes เพิ่มเหตุการณ์เข้าดัชนีตามโปรไฟล์และตาราง
+func indexScopes(tx *bolt.Tx, rec Record) error {
+	history, err := tx.CreateBucketIfNotExists(historyBucket)
+	if err != nil {
+		return err
+	}
+	scopes, err := history.CreateBucketIfNotExists(scopesBucket)
+	if err != nil {
+		return err
+	}
+	tables, err := history.CreateBucketIfNotExists(tablesBucket)
+	if err != nil {
+		return err
+	}
+	table := rec.FullTableName()
+	if err := scopes.Put(append(profileScope(rec.Profile), itob(rec.Seq)...), nil); err != nil {
+		return err
+	}
+	if err := scopes.Put(append(tableScope(rec.Profile, table), itob(rec.Seq)...), nil); err != nil {
+		return err
+	}
+	return tables.Put(key(rec.Profile, table), nil)
+}
+
+// createScopes สร้างดัชนีตามโปรไฟล์และตารางจากเหตุการณ์ที่มีอยู่ (hissync.db ที่สร้างก่อนมีดัชนีนี้)
+func createScopes(tx *bolt.Tx) error {
+	history, err := tx.CreateBucketIfNotExists(historyBucket)
+	if err != nil {
+		return err
+	}
+	if _, err := history.CreateBucket(scopesBucket); err != nil {
+		return err
+	}
+	return tx.Bucket(eventsBucket).ForEach(func(_, data []byte) error {
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		return indexScopes(tx, rec)
+	})
+}
+
+// scope คืน bucket และคำนำหน้าคีย์ที่แคบที่สุดสำหรับ filter (8 ไบต์สุดท้ายของคีย์คือลำดับ)
+// ok เป็น false เมื่อรู้ได้จากดัชนีว่าไม่มีเหตุการณ์ที่ตรงกับ filter
+func scope(tx *bolt.Tx, filter Filter) (b *bolt.Bucket, prefix []byte, ok bool) {
+	scopes := subBucket(tx, scopesBucket)
+	if filter.Profile == "" || scopes == nil {
+		return tx.Bucket(eventsBucket), nil, true
+	}
+	if filter.Table == "" {
+		return scopes, profileScope(filter.Profile), true
+	}
+	// ใช้ดัชนีของตารางเมื่อชื่อบางส่วนตรงกับตารางเดียวของโปรไฟล์
+	var matched []string
+	if tables := subBucket(tx, tablesBucket); tables != nil {
+		c := tables.Cursor()
+		start := append(key(filter.Profile), 0)
+		for k, _ := c.Seek(start); k != nil && bytes.HasPrefix(k, start); k, _ = c.Next() {
+			if table := string(k[len(start):]); containsFold(table, filter.Table) {
+				matched = append(matched, table)
+			}
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, nil, false
+	case 1:
+		return scopes, tableScope(filter.Profile, matched[0]), true
+	}
+	return scopes, profileScope(filter.Profile), true
+}
+
+// scan อ่านเหตุการณ์ที่ตรงกับ filter จากลำดับ from ไปทางเก่า (backward) หรือใหม่ (ไม่รวม from)
+// from เป็น 0 คือเริ่มจากปลายด้านใหม่สุดเมื่อ backward หรือด้านเก่าสุดเมื่อไปทางใหม่
+// ผลลัพธ์เรียงตามทิศทางที่อ่าน
+func scan(tx *bolt.Tx, filter Filter, from uint64, backward bool, limit int) (Page, error) {
+	page := Page{Next: from}
+	b, prefix, ok := scope(tx, filter)
+	if !ok {
+		return page, nil
+	}
+	events := tx.Bucket(eventsBucket)
+	c := b.Cursor()
+	var k []byte
+	switch {
+	case backward:
+		bound := from
+		if bound == 0 {
+			bound = math.MaxUint64
+		}
+		if k, _ = c.Seek(append(append([]byte(nil), prefix...), itob(bound)...)); k == nil {
+			k, _ = c.Last()
+		} else {
+			k, _ = c.Prev()
+		}
+	default:
+		k, _ = c.Seek(append(append([]byte(nil), prefix...), itob(from+1)...))
+	}
+
+	scanned := 0
+	for ; k != nil && bytes.HasPrefix(k, prefix) && len(page.Records) < limit; scanned++ {
+		if scanned == maxScan {
+			page.More = true
+			break
+		}
+		seq := binary.BigEndian.Uint64(k[len(k)-8:])
+		page.Next = seq
+		data := events.Get(itob(seq))
+		if data != nil {
+			rec, match, err := decodeFor(filter, data)
+			if err != nil {
+				return page, err
+			}
+			if match {
+				page.Records = append(page.Records, rec)
+			}
+		}
+		if backward {
+			k, _ = c.Prev()
+		} else {
+			k, _ = c.Next()
+		}
+	}
+	return page, nil
+}
+
+// EachMatch เรียก fn กับเหตุการณ์ทั้งหมดที่ตรงกับ filter ตามลำดับ หยุดเมื่อ fn คืนข้อผิดพลาด
+// อ่านผ่านดัชนีตามโปรไฟล์และตารางเช่นเดียวกับ Before และ After แต่ไม่จำกัดจำนวนที่อ่าน
+func (s *Store) EachMatch(filter Filter, fn func(Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b, prefix, ok := scope(tx, filter)
+		if !ok {
+			return nil
+		}
+		events := tx.Bucket(eventsBucket)
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			data := events.Get(k[len(k)-8:])
+			if data == nil {
+				continue
+			}
+			rec, match, err := decodeFor(filter, data)
+			if err != nil {
+				return err
+			}
+			if match {
+				if err := fn(rec); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// deindex ลบเหตุการณ์ออกจากดัชนีตามโปรไฟล์และตาราง และจากดัชนีประวัติของแถว
+// ดัชนีของบุคคลที่ชี้ไปยังเหตุการณ์ที่ถูกลบจะถูกข้ามเมื่ออ่าน (loadRecords) และหายไปเมื่อ Reindex
+func deindex(tx *bolt.Tx, rec Record) error {
+	table := rec.FullTableName()
+	if scopes := subBucket(tx, scopesBucket); scopes != nil {
+		if err := scopes.Delete(append(profileScope(rec.Profile), itob(rec.Seq)...)); err != nil {
+			return err
+		}
+		if err := scopes.Delete(append(tableScope(rec.Profile, table), itob(rec.Seq)...)); err != nil {
+			return err
+		}
+	}
+	if rows := subBucket(tx, rowsBucket); rows != nil && rec.PrimaryKey != "" && rec.PrimaryKey != "{}" {
+		if err := rows.Delete(withSeq(key(rec.Profile, table, rec.PrimaryKey), rec.Seq)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
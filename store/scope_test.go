@@ -0,0 +1,166 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"hissync-10/capture"
+)
+
+// openTestStore เปิดที่เก็บเหตุการณ์ในโฟลเดอร์ชั่วคราว แล้วบันทึกเหตุการณ์ตาม events (profile, table, operation)
+func openTestStore(t *testing.T, events [][3]string) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hissync.db")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	for i, e := range events {
+		ev := capture.Event{Profile: e[0], Database: "jhcis", Table: e[1], Operation: e[2], PrimaryKey: fmt.Sprintf("{id=%d}", i)}
+		if _, err := st.Append(ev, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return st, path
+}
+
+func seqsOf(records []Record) string {
+	var s string
+	for i, rec := range records {
+		if i > 0 {
+			s += ","
+		}
+		s += fmt.Sprint(rec.Seq)
+	}
+	return s
+}
+
+// scopeEvents เหตุการณ์ลำดับ 1–8 ของสองโปรไฟล์
+var scopeEvents = [][3]string{
+	{"jhcis", "person", "INSERT"},    // 1
+	{"jhcis", "visit", "INSERT"},     // 2
+	{"other", "person", "UPDATE"},    // 3
+	{"jhcis", "visitdrug", "INSERT"}, // 4
+	{"jhcis", "person", "DELETE"},    // 5
+	{"other", "visit", "DELETE"},     // 6
+	{"jhcis", "visit", "UPDATE"},     // 7
+	{"jhcis", "person", "UPDATE"},    // 8
+}
+
+func TestBeforeAfter(t *testing.T) {
+	st, _ := openTestStore(t, scopeEvents)
+	tests := []struct {
+		name   string
+		filter Filter
+		seq    uint64
+		limit  int
+		before string
+		after  string
+	}{
+		{"all", Filter{}, 0, 3, "6,7,8", "1,2,3"},
+		{"all from seq", Filter{}, 5, 2, "3,4", "6,7"},
+		{"profile", Filter{Profile: "jhcis"}, 0, 3, "5,7,8", "1,2,4"},
+		{"profile from seq", Filter{Profile: "jhcis"}, 7, 2, "4,5", "8"},
+		{"one table", Filter{Profile: "jhcis", Table: "PERSON"}, 0, 10, "1,5,8", "1,5,8"},
+		{"one table from seq", Filter{Profile: "jhcis", Table: "person"}, 5, 10, "1", "8"},
+		{"several tables", Filter{Profile: "jhcis", Table: "visit"}, 0, 10, "2,4,7", "2,4,7"},
+		{"unknown table", Filter{Profile: "jhcis", Table: "drug_x"}, 0, 10, "", ""},
+		{"unknown profile", Filter{Profile: "nope"}, 0, 10, "", ""},
+		{"table without profile", Filter{Table: "person"}, 0, 10, "1,3,5,8", "1,3,5,8"},
+		{"operation", Filter{Profile: "jhcis", Operation: "UPDATE"}, 0, 10, "7,8", "7,8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := st.Before(tt.filter, tt.seq, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := seqsOf(before.Records); got != tt.before || before.More {
+				t.Fatalf("Before() = %s more=%v ต้องการ %s", got, before.More, tt.before)
+			}
+			after, err := st.After(tt.filter, tt.seq, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := seqsOf(after.Records); got != tt.after || after.More {
+				t.Fatalf("After() = %s more=%v ต้องการ %s", got, after.More, tt.after)
+			}
+		})
+	}
+}
+
+func TestBeforeAfterStopsAtMaxScan(t *testing.T) {
+	st, _ := openTestStore(t, scopeEvents)
+	defer func(n int) { maxScan = n }(maxScan)
+	maxScan = 3
+	f := Filter{Profile: "jhcis", Operation: "INSERT"}
+
+	// ย้อนจากล่าสุด: อ่าน 8, 7, 5 แล้วหยุด ค้นต่อจาก 5 ได้ 4, 2, 1
+	page, err := st.Before(f, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); got != "" || !page.More || page.Next != 5 {
+		t.Fatalf("Before() = %s more=%v next=%d ต้องการ ว่าง true 5", got, page.More, page.Next)
+	}
+	if page, err = st.Before(f, page.Next, 10); err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); got != "1,2,4" || page.More || page.Next != 1 {
+		t.Fatalf("Before() = %s more=%v next=%d ต้องการ 1,2,4 false 1", got, page.More, page.Next)
+	}
+
+	// ไปทางใหม่จาก 2: อ่าน 4, 5, 7 แล้วหยุด
+	if page, err = st.After(f, 2, 10); err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); got != "4" || !page.More || page.Next != 7 {
+		t.Fatalf("After() = %s more=%v next=%d ต้องการ 4 true 7", got, page.More, page.Next)
+	}
+
+	// ได้ครบ limit ก่อนถึง maxScan ไม่ถือว่าหยุดค้น
+	if page, err = st.After(f, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); got != "1" || page.More {
+		t.Fatalf("After() = %s more=%v ต้องการ 1 false", got, page.More)
+	}
+}
+
+func TestOpenBuildsScopes(t *testing.T) {
+	st, path := openTestStore(t, scopeEvents)
+	// จำลอง hissync.db ที่สร้างก่อนมีดัชนีตามโปรไฟล์และตาราง
+	err := st.db.Update(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		if err := history.DeleteBucket(scopesBucket); err != nil {
+			return err
+		}
+		return history.DeleteBucket(tablesBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+
+	if st, err = Open(path); err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	page, err := st.Before(Filter{Profile: "jhcis", Table: "person"}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := seqsOf(page.Records); got != "1,5,8" {
+		t.Fatalf("Before() = %s ต้องการ 1,5,8", got)
+	}
+	st.db.View(func(tx *bolt.Tx) error {
+		if n := subBucket(tx, scopesBucket).Stats().KeyN; n != 2*len(scopeEvents) {
+			t.Fatalf("ดัชนี scopes มี %d คีย์ ต้องการ %d", n, 2*len(scopeEvents))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+)
+
+// DataFile ชื่อไฟล์ฐานข้อมูลภายในที่เก็บเหตุการณ์และคิวค้างส่ง
+// แทนได้ด้วยตัวแปรสภาพแวดล้อม HISSYNC_DATA_FILE
+const DataFile = "hissync.db"
+
+// เวลารอล็อกไฟล์ ถ้าโปรแกรมอื่น (เช่น hissync run) เปิดไฟล์อยู่จะไม่รอนานกว่านี้
+const lockTimeout = time.Second
+
+var (
+	eventsBucket  = []byte("events")
+	pendingBucket = []byte("pending")
+)
+
+// ErrLocked ไฟล์ฐานข้อมูลภายในถูกเปิดใช้งานโดยโปรแกรมอื่นอยู่
+var ErrLocked = errors.New("hissync.db ถูกใช้งานโดยโปรแกรม HISSYNC อื่นอยู่ (หน้าจอหลักหรือ hissync run)")
+
+// Record เหตุการณ์ที่บันทึกแล้ว พร้อมลำดับและเวลาที่บันทึก
+type Record struct {
+	Seq        uint64    `json:"seq"`
+	CapturedAt time.Time `json:"captured_at"`
+	capture.Event
+}
+
+// Store เก็บเหตุการณ์ทั้งหมดที่อ่านได้ และคิวค้างส่งแยกตามปลายทาง
+type Store struct {
+	db *bolt.DB
+
+	// people การระบุบุคคลของแต่ละโปรไฟล์ แยกตาม database.table (ดู SetTables)
+	mu     sync.Mutex
+	people map[string]map[string]*personRule
+	tables map[string]*config.TableConfig
+}
+
+// DataFilePath คืนตำแหน่งไฟล์ฐานข้อมูลภายในที่ใช้งานจริง
+func DataFilePath() string {
+	if path := os.Getenv("HISSYNC_DATA_FILE"); path != "" {
+		return path
+	}
+	return DataFile
+}
+
+// Open เปิดไฟล์ฐานข้อมูลภายใน (สร้างใหม่ถ้ายังไม่มี)
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("ไม่สามารถเปิด %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(eventsBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(pendingBucket); err != nil {
+			return err
+		}
+		if tx.Bucket(withheldBucket) == nil {
+			if err := createWithheld(tx); err != nil {
+				return err
+			}
+		}
+		if subBucket(tx, scopesBucket) == nil {
+			return createScopes(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ไม่สามารถเตรียม %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close ปิดไฟล์ฐานข้อมูลภายใน
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// Append บันทึกเหตุการณ์และเพิ่มเข้าคิวค้างส่งของปลายทางที่ระบุในธุรกรรมเดียวกัน
+func (s *Store) Append(ev capture.Event, sinks []string) (Record, error) {
+	var rec Record
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if rec, err = s.put(tx, ev); err != nil {
+			return err
+		}
+		return enqueue(tx, sinks, itob(rec.Seq))
+	})
+	if err != nil {
+		return rec, fmt.Errorf("ไม่สามารถบันทึกเหตุการณ์: %v", err)
+	}
+	return rec, nil
+}
+
+// put บันทึกเหตุการณ์พร้อมลำดับใหม่และเพิ่มเข้าดัชนีประวัติ
+func (s *Store) put(tx *bolt.Tx, ev capture.Event) (Record, error) {
+	rec := Record{CapturedAt: time.Now(), Event: ev}
+	events := tx.Bucket(eventsBucket)
+	seq, err := events.NextSequence()
+	if err != nil {
+		return rec, err
+	}
+	rec.Seq = seq
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return rec, err
+	}
+	if err := events.Put(itob(seq), data); err != nil {
+		return rec, err
+	}
+	return rec, s.indexHistory(tx, rec)
+}
+
+// enqueue เพิ่มเหตุการณ์ key เข้าคิวค้างส่งของทุกปลายทางใน sinks
+func enqueue(tx *bolt.Tx, sinks []string, key []byte) error {
+	for _, sink := range sinks {
+		queue, err := tx.Bucket(pendingBucket).CreateBucketIfNotExists([]byte(sink))
+		if err != nil {
+			return err
+		}
+		if err := queue.Put(key, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending คืนเหตุการณ์ค้างส่งของปลายทางตามลำดับ ไม่เกิน limit รายการ
+func (s *Store) Pending(sink string, limit int) ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(pendingBucket).Bucket([]byte(sink))
+		if queue == nil {
+			return nil
+		}
+		events := tx.Bucket(eventsBucket)
+		c := queue.Cursor()
+		for k, _ := c.First(); k != nil && len(records) < limit; k, _ = c.Next() {
+			data := events.Get(k)
+			if data == nil {
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			records = append(records, rec)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Ack นำเหตุการณ์ที่ปลายทางรับสำเร็จแล้วออกจากคิว
+func (s *Store) Ack(sink string, seqs []uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(pendingBucket).Bucket([]byte(sink))
+		if queue == nil {
+			return nil
+		}
+		for _, seq := range seqs {
+			if err := queue.Delete(itob(seq)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Enqueue เพิ่มเหตุการณ์ที่บันทึกไว้แล้วเข้าคิวของปลายทางอีกครั้ง (ใช้สำหรับ replay)
+// ข้ามเหตุการณ์ที่ยังรอส่งหรือถูกปฏิเสธโดย safeguard และคืนจำนวนที่ข้าม
+func (s *Store) Enqueue(sink string, seqs []uint64) (skipped int, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		queue, err := tx.Bucket(pendingBucket).CreateBucketIfNotExists([]byte(sink))
+		if err != nil {
+			return err
+		}
+		withheld := tx.Bucket(withheldBucket)
+		for _, seq := range seqs {
+			if withheld.Get(itob(seq)) != nil {
+				skipped++
+				continue
+			}
+			if err := queue.Put(itob(seq), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return skipped, err
+}
+
+// PendingCount คืนจำนวนเหตุการณ์ค้างส่งของปลายทาง
+func (s *Store) PendingCount(sink string) (int, error) {
+	count := 0
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if queue := tx.Bucket(pendingBucket).Bucket([]byte(sink)); queue != nil {
+			count = queue.Stats().KeyN
+		}
+		return nil
+	})
+	return count, err
+}
+
+// OldestPending คืนเวลาที่บันทึกเหตุการณ์ค้างส่งที่เก่าที่สุดของปลายทาง (ค่าศูนย์ถ้าไม่มีค้างส่ง)
+func (s *Store) OldestPending(sink string) (time.Time, error) {
+	var oldest time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(pendingBucket).Bucket([]byte(sink))
+		if queue == nil {
+			return nil
+		}
+		k, _ := queue.Cursor().First()
+		if k == nil {
+			return nil
+		}
+		data := tx.Bucket(eventsBucket).Get(k)
+		if data == nil {
+			return nil
+		}
+		var rec Record
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		oldest = rec.CapturedAt
+		return nil
+	})
+	return oldest, err
+}
+
+// Each เรียก fn กับเหตุการณ์ที่บันทึกไว้ทุกรายการตามลำดับ หยุดเมื่อ fn คืนข้อผิดพลาด
+func (s *Store) Each(fn func(Record) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).ForEach(func(_, data []byte) error {
+			var rec Record
+			if err := json.Unmarshal(data, &rec); err != nil {
+				return err
+			}
+			return fn(rec)
+		})
+	})
+}
+
+// Before คืนเหตุการณ์ที่ตรงกับ filter และมีลำดับน้อยกว่า seq ไม่เกิน limit รายการ เรียงจากเก่าไปใหม่
+// seq เป็น 0 คือนับย้อนจากรายการล่าสุด อ่านไม่เกิน maxScan รายการต่อครั้ง (ดู Page)
+func (s *Store) Before(filter Filter, seq uint64, limit int) (Page, error) {
+	var page Page
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		page, err = scan(tx, filter, seq, true, limit)
+		return err
+	})
+	records := page.Records
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return page, err
+}
+
+// After คืนเหตุการณ์ที่ตรงกับ filter และมีลำดับมากกว่า seq ไม่เกิน limit รายการ เรียงจากเก่าไปใหม่
+// อ่านไม่เกิน maxScan รายการต่อครั้ง (ดู Page)
+func (s *Store) After(filter Filter, seq uint64, limit int) (Page, error) {
+	var page Page
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		page, err = scan(tx, filter, seq, false, limit)
+		return err
+	})
+	return page, err
+}
+
+// decodeFor แปลงข้อมูลเป็น Record และบอกว่าตรงกับ filter หรือไม่
+func decodeFor(filter Filter, data []byte) (Record, bool, error) {
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, false, err
+	}
+	return rec, filter.Match(rec), nil
+}
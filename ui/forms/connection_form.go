@@ -1,43 +1,41 @@
 package forms
 
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
-	"log"
-	"os"
-	"os/exec"
-	"strings"
+	"strconv"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/widget"
 
-	_ "github.com/denisenkom/go-mssqldb" // Microsoft SQL Server driver
-	_ "github.com/go-sql-driver/mysql"   // MySQL driver
-	_ "github.com/lib/pq"                // PostgreSQL driver
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/logging"
 )
 
-type Config struct {
-    DBType      string   `json:"dbtype"`
-    Host        string   `json:"host"`
-    Port        string   `json:"port"`
-    Username    string   `json:"username"`
-    Password    string   `json:"password"`
-    DBName      string   `json:"dbname"`
-    LogFilePath string   `json:"log_file_path"`
-    StateFile   string   `json:"state_file"`
-    FilterTables []string `json:"filter_tables"`
-}
+// ตัวเลือกสำหรับสร้างโปรไฟล์ใหม่ในรายการโปรไฟล์
+const newProfileOption = "+ โปรไฟล์ใหม่"
 
-// ShowConnectionForm แสดง Popup Form สำหรับกำหนดค่าการเชื่อมต่อกับฐานข้อมูล
+// ShowConnectionForm แสดง Popup Form สำหรับกำหนดค่าการเชื่อมต่อของแต่ละโปรไฟล์แหล่งข้อมูล
 func ShowConnectionForm(myWindow fyne.Window) {
-    dbTypes := []string{"PostgreSQL", "MySQL", "Microsoft SQL Server", "MongoDB"}
-    dbTypeSelect := widget.NewSelect(dbTypes, func(value string) {})
+    // อ่านค่าตามที่บันทึกในไฟล์ (ไม่รวมค่าจากตัวแปรสภาพแวดล้อม) เพื่อไม่ให้ถูกบันทึกกลับลงไฟล์
+    existing, err := config.ReadConfigFile(config.ConfigFile)
+    if err != nil {
+        logging.For(logging.ComponentConfig).Info("ไม่พบ config.json เดิม เริ่มด้วยฟอร์มว่าง", "error", err)
+        existing = &config.Config{}
+    }
+
+    nameEntry := widget.NewEntry()
+    disabledCheck := widget.NewCheck("ปิดการใช้งานโปรไฟล์นี้", func(bool) {})
+    engineSelect := widget.NewSelect(nil, func(value string) {})
+    dbTypeSelect := widget.NewSelect(config.DBTypes, func(value string) {
+        engineSelect.Options = config.Engines(value)
+        if len(engineSelect.Options) > 0 && !contains(engineSelect.Options, engineSelect.Selected) {
+            engineSelect.SetSelected(engineSelect.Options[0])
+        }
+        engineSelect.Refresh()
+    })
 
     hostEntry := widget.NewEntry()
     portEntry := widget.NewEntry()
@@ -46,25 +44,103 @@ func ShowConnectionForm(myWindow fyne.Window) {
     dbNameEntry := widget.NewEntry()
     logFilePathEntry := widget.NewEntry()
     stateFileEntry := widget.NewEntry()
-    filterTablesEntry := widget.NewEntry()
-
-    config, err := loadConfig("config.json")
-    if err == nil {
-        dbTypeSelect.SetSelected(config.DBType)
-        hostEntry.SetText(config.Host)
-        portEntry.SetText(config.Port)
-        userEntry.SetText(config.Username)
-        passwordEntry.SetText(config.Password)
-        dbNameEntry.SetText(config.DBName)
-        logFilePathEntry.SetText(config.LogFilePath)
-        stateFileEntry.SetText(config.StateFile)
-        filterTablesEntry.SetText(strings.Join(config.FilterTables, ","))
-    } else {
-        log.Println("No existing config file found, starting with empty form.")
+    tlsModeSelect := widget.NewSelect(config.TLSModes, func(value string) {})
+    tlsCAEntry := widget.NewEntry()
+    tlsCertEntry := widget.NewEntry()
+    tlsKeyEntry := widget.NewEntry()
+    tlsServerNameEntry := widget.NewEntry()
+    tlsServerNameEntry.SetPlaceHolder("ว่าง = ใช้ Host")
+    sshHostEntry := widget.NewEntry()
+    sshHostEntry.SetPlaceHolder("ว่าง = เชื่อมต่อตรง ไม่ผ่าน SSH")
+    sshPortEntry := widget.NewEntry()
+    sshPortEntry.SetPlaceHolder("22")
+    sshUserEntry := widget.NewEntry()
+    sshPasswordEntry := widget.NewPasswordEntry()
+    sshKeyFileEntry := widget.NewEntry()
+    sshKnownHostsEntry := widget.NewEntry()
+    sshKnownHostsEntry.SetPlaceHolder("ว่าง = ~/.ssh/known_hosts")
+    sshFingerprintEntry := widget.NewEntry()
+    sshFingerprintEntry.SetPlaceHolder("SHA256:... (ใช้แทน known_hosts)")
+    // ตัวเลือกแรกคือให้ตรวจรูปแบบ binlog จากเวอร์ชันของเซิร์ฟเวอร์
+    const flavorAuto = "อัตโนมัติ"
+    flavorSelect := widget.NewSelect(append([]string{flavorAuto}, config.Flavors...), func(value string) {})
+    serverIDEntry := widget.NewEntry()
+    serverIDEntry.SetPlaceHolder("ว่าง = สร้างอัตโนมัติ")
+
+    // current โปรไฟล์ที่กำลังแก้ไข (ค่าว่างคือโปรไฟล์ใหม่)
+    current := config.Profile{}
+
+    loadProfile := func(p config.Profile) {
+        current = p
+        nameEntry.SetText(p.Name)
+        disabledCheck.SetChecked(p.Disabled)
+        if p.DBType == "" {
+            dbTypeSelect.ClearSelected()
+            engineSelect.ClearSelected()
+        } else {
+            dbTypeSelect.SetSelected(p.DBType)
+            engineSelect.SetSelected(p.Engine)
+        }
+        hostEntry.SetText(p.Host)
+        portEntry.SetText(p.Port)
+        userEntry.SetText(p.Username)
+        passwordEntry.SetText(p.Password.Reveal())
+        dbNameEntry.SetText(p.DBName)
+        logFilePathEntry.SetText(p.LogFilePath)
+        stateFileEntry.SetText(p.StateFile)
+        tls := config.TLSConfig{}
+        if p.TLS != nil {
+            tls = *p.TLS
+        }
+        tlsModeSelect.SetSelected(p.TLSMode())
+        tlsCAEntry.SetText(tls.CAFile)
+        tlsCertEntry.SetText(tls.CertFile)
+        tlsKeyEntry.SetText(tls.KeyFile)
+        tlsServerNameEntry.SetText(tls.ServerName)
+        ssh := config.SSHConfig{}
+        if p.SSH != nil {
+            ssh = *p.SSH
+        }
+        sshHostEntry.SetText(ssh.Host)
+        sshPortEntry.SetText(ssh.Port)
+        sshUserEntry.SetText(ssh.User)
+        sshPasswordEntry.SetText(ssh.Password.Reveal())
+        sshKeyFileEntry.SetText(ssh.KeyFile)
+        sshKnownHostsEntry.SetText(ssh.KnownHostsFile)
+        sshFingerprintEntry.SetText(ssh.HostKeyFingerprint)
+        replica := p.Replica()
+        if replica.Flavor == "" {
+            flavorSelect.SetSelected(flavorAuto)
+        } else {
+            flavorSelect.SetSelected(replica.Flavor)
+        }
+        serverIDEntry.SetText("")
+        if replica.ServerID != 0 {
+            serverIDEntry.SetText(strconv.FormatUint(uint64(replica.ServerID), 10))
+        }
     }
 
+    profileNames := make([]string, 0, len(existing.Profiles)+1)
+    for _, p := range existing.Profiles {
+        profileNames = append(profileNames, p.Name)
+    }
+    profileNames = append(profileNames, newProfileOption)
+
+    profileSelect := widget.NewSelect(profileNames, func(value string) {
+        if p := existing.Profile(value); p != nil {
+            loadProfile(*p)
+        } else {
+            loadProfile(config.Profile{})
+        }
+    })
+    profileSelect.SetSelected(profileNames[0])
+
     form := widget.NewForm(
+        widget.NewFormItem("Profile", profileSelect),
+        widget.NewFormItem("Profile Name", nameEntry),
+        widget.NewFormItem("", disabledCheck),
         widget.NewFormItem("Database Type", dbTypeSelect),
+        widget.NewFormItem("Engine", engineSelect),
         widget.NewFormItem("Host", hostEntry),
         widget.NewFormItem("Port", portEntry),
         widget.NewFormItem("Username", userEntry),
@@ -72,35 +148,153 @@ func ShowConnectionForm(myWindow fyne.Window) {
         widget.NewFormItem("Database Name", dbNameEntry),
         widget.NewFormItem("Log File Path", logFilePathEntry),
         widget.NewFormItem("State File", stateFileEntry),
-        widget.NewFormItem("Filter Tables (comma-separated)", filterTablesEntry),
+        widget.NewFormItem("TLS Mode", tlsModeSelect),
+        widget.NewFormItem("CA Certificate", tlsCAEntry),
+        widget.NewFormItem("Client Certificate", tlsCertEntry),
+        widget.NewFormItem("Client Key", tlsKeyEntry),
+        widget.NewFormItem("TLS Server Name", tlsServerNameEntry),
+        widget.NewFormItem("SSH Host", sshHostEntry),
+        widget.NewFormItem("SSH Port", sshPortEntry),
+        widget.NewFormItem("SSH User", sshUserEntry),
+        widget.NewFormItem("SSH Password", sshPasswordEntry),
+        widget.NewFormItem("SSH Key File", sshKeyFileEntry),
+        widget.NewFormItem("SSH Known Hosts", sshKnownHostsEntry),
+        widget.NewFormItem("SSH Host Key", sshFingerprintEntry),
+        widget.NewFormItem("Binlog Flavor", flavorSelect),
+        widget.NewFormItem("Binlog Server ID", serverIDEntry),
     )
 
     var popup dialog.Dialog
 
+    // saveConfig ตรวจสอบด้วยค่าเริ่มต้นที่จะใช้งานจริง แต่บันทึกเฉพาะค่าที่ผู้ใช้กรอก
+    saveConfig := func(cfg *config.Config, test *config.Profile) bool {
+        effective := *cfg
+        effective.Profiles = append([]config.Profile(nil), cfg.Profiles...)
+        effective.Sinks = append([]config.SinkConfig(nil), cfg.Sinks...)
+        effective.ApplyDefaults()
+        if err := effective.Validate(); err != nil {
+            dialog.ShowError(err, myWindow)
+            return false
+        }
+        if test != nil && !test.Disabled {
+            if p := effective.Profile(test.Name); p != nil {
+                if err := config.TestConnection(p); err != nil {
+                    dialog.ShowError(fmt.Errorf("Failed to connect to the database: %v", err), myWindow)
+                    return false
+                }
+            }
+        }
+        if err := config.SaveConfig(config.ConfigFile, cfg); err != nil {
+            dialog.ShowError(config.RedactError(err), myWindow)
+            return false
+        }
+        return true
+    }
+
+    // readForm โปรไฟล์ตามค่าที่กรอกในฟอร์ม
+    readForm := func() config.Profile {
+        p := current
+        p.Name = nameEntry.Text
+        p.Disabled = disabledCheck.Checked
+        p.DBType = dbTypeSelect.Selected
+        p.Engine = engineSelect.Selected
+        p.Host = hostEntry.Text
+        p.Port = portEntry.Text
+        p.Username = userEntry.Text
+        p.Password = config.Secret(passwordEntry.Text)
+        p.DBName = dbNameEntry.Text
+        p.LogFilePath = logFilePathEntry.Text
+        p.StateFile = stateFileEntry.Text
+        p.TLS = &config.TLSConfig{
+            Mode:       tlsModeSelect.Selected,
+            CAFile:     tlsCAEntry.Text,
+            CertFile:   tlsCertEntry.Text,
+            KeyFile:    tlsKeyEntry.Text,
+            ServerName: tlsServerNameEntry.Text,
+        }
+        if *p.TLS == (config.TLSConfig{Mode: config.TLSModeDisable}) {
+            p.TLS = nil
+        }
+        p.SSH = nil
+        if sshHostEntry.Text != "" {
+            // เก็บ key_passphrase เดิมไว้ (ไม่มีในฟอร์ม)
+            ssh := config.SSHConfig{}
+            if current.SSH != nil {
+                ssh = *current.SSH
+            }
+            ssh.Host = sshHostEntry.Text
+            ssh.Port = sshPortEntry.Text
+            ssh.User = sshUserEntry.Text
+            ssh.Password = config.Secret(sshPasswordEntry.Text)
+            ssh.KeyFile = sshKeyFileEntry.Text
+            ssh.KnownHostsFile = sshKnownHostsEntry.Text
+            ssh.HostKeyFingerprint = sshFingerprintEntry.Text
+            p.SSH = &ssh
+        }
+        // เก็บค่า replication อื่นที่ไม่มีในฟอร์มไว้ (port, charset, heartbeat, read timeout)
+        replica := config.ReplicationConfig{}
+        if current.Replication != nil {
+            replica = *current.Replication
+        }
+        replica.Flavor = flavorSelect.Selected
+        if replica.Flavor == flavorAuto {
+            replica.Flavor = ""
+        }
+        id, _ := strconv.ParseUint(serverIDEntry.Text, 10, 32)
+        replica.ServerID = uint32(id)
+        p.Replication = nil
+        if replica != (config.ReplicationConfig{}) {
+            p.Replication = &replica
+        }
+        return p
+    }
+
     saveButton := widget.NewButton("Save", func() {
-        config := Config{
-            DBType:      dbTypeSelect.Selected,
-            Host:        hostEntry.Text,
-            Port:        portEntry.Text,
-            Username:    userEntry.Text,
-            Password:    passwordEntry.Text,
-            DBName:      dbNameEntry.Text,
-            LogFilePath: logFilePathEntry.Text,
-            StateFile:   stateFileEntry.Text,
-            FilterTables: strings.Split(filterTablesEntry.Text, ","),
-        }
-
-        for i := range config.FilterTables {
-            config.FilterTables[i] = strings.TrimSpace(config.FilterTables[i])
-        }
-
-        if testConnection(config) {
-            saveConfig(config)
-            dialog.ShowInformation("Success", "Connection Successful and Config Saved!", myWindow)
-            showRestartConfirmation(myWindow, popup)
-        } else {
-            dialog.ShowError(fmt.Errorf("Failed to connect to the database"), myWindow)
+        p := readForm()
+        cfg := *existing
+            cfg.Profiles = append([]config.Profile(nil), existing.Profiles...)
+        cfg.Upsert(current.Name, p)
+        if !saveConfig(&cfg, &p) {
+            return
+        }
+        popup.Hide()
+        // ตัวเฝ้าดู config.json จะเชื่อมต่อใหม่ให้อัตโนมัติ ไม่ต้องรีสตาร์ทโปรแกรม
+        dialog.ShowInformation("Success", "Connection Successful and Config Saved!\nระบบจะเชื่อมต่อฐานข้อมูลใหม่โดยอัตโนมัติ", myWindow)
+    })
+
+    deleteButton := widget.NewButton("Delete Profile", func() {
+        if current.Name == "" {
+            return
+        }
+        dialog.ShowConfirm("ลบโปรไฟล์", fmt.Sprintf("ต้องการลบโปรไฟล์ %s หรือไม่?", current.Name), func(ok bool) {
+            if !ok {
+                return
+            }
+            cfg := *existing
+            cfg.Profiles = append([]config.Profile(nil), existing.Profiles...)
+            cfg.Remove(current.Name)
+            if !saveConfig(&cfg, nil) {
+                return
+            }
+            popup.Hide()
+        }, myWindow)
+    })
+
+    // diagnoseButton ตรวจการตั้งค่าเซิร์ฟเวอร์ด้วยค่าในฟอร์ม (ยังไม่ต้องบันทึก)
+    diagnoseButton := widget.NewButton("Diagnose", func() {
+        p := readForm()
+        p.ApplyDefaults()
+        if err := p.Validate(); err != nil {
+            dialog.ShowError(err, myWindow)
+            return
         }
+        progress := dialog.NewCustomWithoutButtons("Diagnose", widget.NewLabel("กำลังตรวจ "+p.Host+"..."), myWindow)
+        progress.Show()
+        go func() {
+            checks := capture.Diagnose(&p)
+            progress.Hide()
+            showDiagnosis(p.Name, checks, myWindow)
+        }()
     })
 
     cancelButton := widget.NewButton("Cancel", func() {
@@ -109,117 +303,46 @@ func ShowConnectionForm(myWindow fyne.Window) {
         }
     })
 
-    buttonContainer := container.NewHBox(saveButton, cancelButton)
-    formContainer := container.NewVBox(form, buttonContainer)
+    buttonContainer := container.NewHBox(saveButton, diagnoseButton, deleteButton, cancelButton)
+    formContainer := container.NewBorder(nil, buttonContainer, nil, nil, container.NewVScroll(form))
 
     popup = dialog.NewCustom("Database Connection", "Close", formContainer, myWindow)
-    popup.Resize(fyne.NewSize(600, 500))
+    popup.Resize(fyne.NewSize(600, 760))
     popup.Show()
 }
 
-// testConnection ทดสอบการเชื่อมต่อกับฐานข้อมูล
-func testConnection(config Config) bool {
-    switch config.DBType {
-    case "PostgreSQL":
-        dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-            config.Host, config.Port, config.Username, config.Password, config.DBName)
-        db, err := sql.Open("postgres", dsn)
-        if err != nil {
-            log.Println("PostgreSQL connection error:", err)
-            return false
+// showDiagnosis แสดงผลการตรวจเป็นรายการ ผ่าน/เตือน/ไม่ผ่าน พร้อมวิธีแก้ไข
+func showDiagnosis(name string, checks []capture.Check, myWindow fyne.Window) {
+    list := container.NewVBox()
+    for _, c := range checks {
+        title := widget.NewLabelWithStyle(c.Symbol()+" "+c.Name, fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+        switch c.Status {
+        case capture.CheckFail:
+            title.Importance = widget.DangerImportance
+        case capture.CheckWarn:
+            title.Importance = widget.WarningImportance
+        default:
+            title.Importance = widget.SuccessImportance
         }
-        return db.Ping() == nil
-
-    case "MySQL":
-        dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        db, err := sql.Open("mysql", dsn)
-        if err != nil {
-            log.Println("MySQL connection error:", err)
-            return false
-        }
-        return db.Ping() == nil
-
-    case "Microsoft SQL Server":
-        dsn := fmt.Sprintf("sqlserver://%s:%s@%s:%s?database=%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        db, err := sql.Open("sqlserver", dsn)
-        if err != nil {
-            log.Println("SQL Server connection error:", err)
-            return false
-        }
-        return db.Ping() == nil
-
-    case "MongoDB":
-        dsn := fmt.Sprintf("mongodb://%s:%s@%s:%s/%s",
-            config.Username, config.Password, config.Host, config.Port, config.DBName)
-        client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(dsn))
-        if err != nil {
-            log.Println("MongoDB connection error:", err)
-            return false
+        text := c.Detail
+        if c.Fix != "" {
+            text += "\nแก้ไข: " + c.Fix
         }
-        return client.Ping(context.TODO(), nil) == nil
-
-    default:
-        log.Println("Unsupported database type:", config.DBType)
-        return false
+        detail := widget.NewLabel(text)
+        detail.Wrapping = fyne.TextWrapWord
+        list.Add(title)
+        list.Add(detail)
     }
+    result := dialog.NewCustom("Diagnose "+name, "Close", container.NewVScroll(list), myWindow)
+    result.Resize(fyne.NewSize(640, 560))
+    result.Show()
 }
 
-
-// showRestartConfirmation แสดง Popup แจ้งเตือนก่อน Restart แอพ
-func showRestartConfirmation(myWindow fyne.Window, popup dialog.Dialog) {
-    dialog.ShowConfirm(
-        "Restart Required",
-        "เนื่องจากมีการเปลี่ยนแปลงค่ากำหนดเชื่อมต่อข้อมูล ระบบจำเป็นต้อง Restart เพื่อทำการเชื่อมต่อฐานข้อมูลใหม่อย่างสมบูรณ์",
-        func(confirm bool) {
-            if confirm {
-                popup.Hide() // ปิด Popup การตั้งค่า
-                restartApp() // ทำการ Restart แอพพลิเคชัน
-            }
-        },
-        myWindow,
-    )
-}
-
-// loadConfig โหลดการตั้งค่าจากไฟล์ config.json
-func loadConfig(filepath string) (Config, error) {
-    var config Config
-    file, err := os.Open(filepath)
-    if err != nil {
-        return config, err
-    }
-    defer file.Close()
-
-    decoder := json.NewDecoder(file)
-    err = decoder.Decode(&config)
-    return config, err
-}
-
-// saveConfig บันทึกค่าการเชื่อมต่อฐานข้อมูลลงในไฟล์ config.json
-func saveConfig(config Config) {
-    file, err := os.Create("config.json")
-    if err != nil {
-        fmt.Println("Error creating config file:", err)
-        return
-    }
-    defer file.Close()
-
-    encoder := json.NewEncoder(file)
-    if err := encoder.Encode(config); err != nil {
-        fmt.Println("Error saving config:", err)
-    } else {
-        fmt.Println("Config saved successfully!")
-    }
-}
-
-// restartApp ทำการรีสตาร์ทแอพพลิเคชัน
-func restartApp() {
-    exe, err := os.Executable()
-    if err != nil {
-        log.Fatalf("Failed to find executable: %v", err)
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
     }
-    cmd := exec.Command(exe)
-    cmd.Start()
-    os.Exit(0) // ปิดแอพพลิเคชันเดิม
+    return false
 }
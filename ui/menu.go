@@ -1,7 +1,7 @@
 package ui
 
 import (
-	"fmt"
+	"hissync-10/logging"
 	"hissync-10/ui/forms"
 
 	"fyne.io/fyne/v2"
@@ -13,13 +13,13 @@ import (
 func CreateTopbarMenu(myApp fyne.App, myWindow fyne.Window, contentContainer *fyne.Container) *fyne.MainMenu {
     // สร้างเมนู File
     newItem := fyne.NewMenuItem("New", func() {
-        fmt.Println("New File Created")
+        logging.For(logging.ComponentUI).Debug("New File Created")
     })
     openItem := fyne.NewMenuItem("Open", func() {
-        fmt.Println("Open File")
+        logging.For(logging.ComponentUI).Debug("Open File")
     })
     saveItem := fyne.NewMenuItem("Save", func() {
-        fmt.Println("File Saved")
+        logging.For(logging.ComponentUI).Debug("File Saved")
     })
     quitItem := fyne.NewMenuItem("Quit", func() {
         myApp.Quit()
@@ -43,7 +43,7 @@ func CreateTopbarMenu(myApp fyne.App, myWindow fyne.Window, contentContainer *fy
 
     // สร้างเมนู Help
     aboutItem := fyne.NewMenuItem("About", func() {
-        fmt.Println("About this app")
+        logging.For(logging.ComponentUI).Debug("About this app")
     })
 
     helpMenu := fyne.NewMenu("Help", aboutItem)
@@ -1,26 +1,26 @@
 package ui
 
 import (
-	"fmt"
-
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+
+	"hissync-10/logging"
 )
 
 func CreateToolbar() *widget.Toolbar {
     return widget.NewToolbar(
         widget.NewToolbarAction(theme.ContentAddIcon(), func() {
-            fmt.Println("Add button clicked")
+            logging.For(logging.ComponentUI).Debug("Add button clicked")
         }),
         widget.NewToolbarAction(theme.ContentRemoveIcon(), func() {
-            fmt.Println("Remove button clicked")
+            logging.For(logging.ComponentUI).Debug("Remove button clicked")
         }),
         widget.NewToolbarSpacer(),
         widget.NewToolbarAction(theme.DocumentSaveIcon(), func() {
-            fmt.Println("Save button clicked")
+            logging.For(logging.ComponentUI).Debug("Save button clicked")
         }),
         widget.NewToolbarAction(theme.MailSendIcon(), func() {
-            fmt.Println("Send button clicked")
+            logging.For(logging.ComponentUI).Debug("Send button clicked")
         }),
     )
 }
@@ -0,0 +1,104 @@
+package views
+
+import (
+    "fmt"
+    "log/slog"
+    "strings"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/logging"
+)
+
+// จำนวนบรรทัด log ล่าสุดที่แสดงในหน้าจอ
+const appLogLimit = 2000
+
+// ตัวเลือกทั้งหมดในตัวกรอง
+const allOption = "ทั้งหมด"
+
+// ระดับ log ที่เลือกได้ แสดงตั้งแต่ระดับที่เลือกขึ้นไป
+var appLogLevels = map[string]slog.Level{
+    "DEBUG": slog.LevelDebug,
+    "INFO":  slog.LevelInfo,
+    "WARN":  slog.LevelWarn,
+    "ERROR": slog.LevelError,
+}
+
+// AppLogView แสดง log ของโปรแกรมจากไฟล์ log กรองตามระดับ ส่วนงาน และข้อความ
+// ใช้ดูหรือคัดลอกข้อมูลเมื่อแจ้งปัญหา
+func AppLogView() fyne.CanvasObject {
+    data := [][]string{}
+    headers := []string{"เวลา", "ระดับ", "ส่วนงาน", "โปรไฟล์", "ข้อความ", "รายละเอียด"}
+
+    table := widget.NewTable(
+        func() (int, int) { return len(data) + 1, len(headers) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(headers[id.Col])
+                return
+            }
+            label.TextStyle = fyne.TextStyle{}
+            label.SetText(data[id.Row-1][id.Col])
+        },
+    )
+    table.SetColumnWidth(0, 160)
+    table.SetColumnWidth(1, 70)
+    table.SetColumnWidth(2, 90)
+    table.SetColumnWidth(3, 110)
+    table.SetColumnWidth(4, 350)
+    table.SetColumnWidth(5, 500)
+
+    levelSelect := widget.NewSelect([]string{"DEBUG", "INFO", "WARN", "ERROR"}, nil)
+    levelSelect.SetSelected("INFO")
+    componentSelect := widget.NewSelect([]string{allOption,
+        logging.ComponentApp, logging.ComponentConfig, logging.ComponentCapture,
+        logging.ComponentSink, logging.ComponentMonitor, logging.ComponentUI}, nil)
+    componentSelect.SetSelected(allOption)
+    searchEntry := widget.NewEntry()
+    searchEntry.SetPlaceHolder("ค้นหาข้อความ")
+    infoLabel := widget.NewLabel("")
+
+    refresh := func() {
+        entries, err := logging.ReadEntries(appLogLimit)
+        if err != nil {
+            infoLabel.SetText(err.Error())
+            return
+        }
+        minLevel := appLogLevels[levelSelect.Selected]
+        search := strings.ToLower(strings.TrimSpace(searchEntry.Text))
+
+        data = data[:0]
+        for _, e := range entries {
+            if e.Level < minLevel {
+                continue
+            }
+            if componentSelect.Selected != allOption && e.Component != componentSelect.Selected {
+                continue
+            }
+            if search != "" && !strings.Contains(strings.ToLower(e.Message+" "+e.Profile+" "+e.Attrs), search) {
+                continue
+            }
+            data = append(data, []string{e.Time.Format("2006-01-02 15:04:05"), e.Level.String(),
+                e.Component, e.Profile, e.Message, e.Attrs})
+        }
+        infoLabel.SetText(fmt.Sprintf("%s: แสดง %d จาก %d บรรทัดล่าสุด", logging.File(), len(data), len(entries)))
+        table.Refresh()
+    }
+    levelSelect.OnChanged = func(string) { refresh() }
+    componentSelect.OnChanged = func(string) { refresh() }
+    searchEntry.OnSubmitted = func(string) { refresh() }
+    refresh()
+
+    refreshButton := widget.NewButton("รีเฟรช", refresh)
+
+    filters := container.NewBorder(nil, nil,
+        container.NewHBox(widget.NewLabel("ระดับ"), levelSelect, widget.NewLabel("ส่วนงาน"), componentSelect),
+        refreshButton, searchEntry)
+
+    return container.NewBorder(container.NewVBox(filters, infoLabel), nil, nil, nil, table)
+}
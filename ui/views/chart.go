@@ -0,0 +1,187 @@
+package views
+
+import (
+    "math"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/canvas"
+    "fyne.io/fyne/v2/theme"
+    "fyne.io/fyne/v2/widget"
+)
+
+// ระยะขอบของพื้นที่วาดกราฟ (ซ้ายสำหรับค่าแกน y ล่างสำหรับป้ายแกน x บนสำหรับคำอธิบายสี)
+const (
+    chartLeft   = 60
+    chartBottom = 24
+    chartTop    = 24
+)
+
+// chartSeries ข้อมูลหนึ่งชุดในกราฟ ค่าหนึ่งค่าต่อหนึ่งป้ายบนแกน x
+type chartSeries struct {
+    name   string
+    color  fyne.ThemeColorName
+    values []float64
+}
+
+// chart กราฟแท่งหรือกราฟเส้นอย่างง่ายที่วาดด้วย canvas ของ Fyne
+type chart struct {
+    widget.BaseWidget
+    line   bool
+    labels []string
+    series []chartSeries
+    // format แปลงค่าบนแกน y เป็นข้อความ
+    format func(float64) string
+}
+
+func newChart(line bool, format func(float64) string) *chart {
+    c := &chart{line: line, format: format}
+    c.ExtendBaseWidget(c)
+    return c
+}
+
+// SetData เปลี่ยนข้อมูลแล้ววาดใหม่
+func (c *chart) SetData(labels []string, series []chartSeries) {
+    c.labels = labels
+    c.series = series
+    c.Refresh()
+}
+
+func (c *chart) CreateRenderer() fyne.WidgetRenderer {
+    return &chartRenderer{chart: c}
+}
+
+type chartRenderer struct {
+    chart   *chart
+    size    fyne.Size
+    objects []fyne.CanvasObject
+}
+
+func (r *chartRenderer) MinSize() fyne.Size {
+    return fyne.NewSize(300, 180)
+}
+
+func (r *chartRenderer) Objects() []fyne.CanvasObject {
+    return r.objects
+}
+
+func (r *chartRenderer) Destroy() {}
+
+func (r *chartRenderer) Refresh() {
+    r.Layout(r.size)
+    canvas.Refresh(r.chart)
+}
+
+// Layout สร้างรูปทั้งหมดใหม่ตามขนาดที่ได้รับ
+func (r *chartRenderer) Layout(size fyne.Size) {
+    r.size = size
+    r.objects = nil
+    c := r.chart
+    foreground := theme.Color(theme.ColorNameForeground)
+    muted := theme.Color(theme.ColorNameDisabled)
+
+    plotW := size.Width - chartLeft - theme.Padding()
+    plotH := size.Height - chartTop - chartBottom
+    if plotW <= 0 || plotH <= 0 || len(c.labels) == 0 || len(c.series) == 0 {
+        return
+    }
+
+    maxValue := 0.0
+    for _, s := range c.series {
+        for _, v := range s.values {
+            maxValue = math.Max(maxValue, v)
+        }
+    }
+    if maxValue == 0 {
+        maxValue = 1
+    }
+    y := func(v float64) float32 {
+        return chartTop + plotH - float32(v/maxValue)*plotH
+    }
+
+    // เส้นแกนและค่ากำกับแกน y
+    for _, v := range []float64{0, maxValue / 2, maxValue} {
+        grid := canvas.NewLine(muted)
+        grid.StrokeWidth = 1
+        grid.Position1 = fyne.NewPos(chartLeft, y(v))
+        grid.Position2 = fyne.NewPos(chartLeft+plotW, y(v))
+        text := canvas.NewText(c.format(v), foreground)
+        text.TextSize = theme.CaptionTextSize()
+        text.Alignment = fyne.TextAlignTrailing
+        text.Move(fyne.NewPos(0, y(v)-text.MinSize().Height/2))
+        text.Resize(fyne.NewSize(chartLeft-theme.Padding(), text.MinSize().Height))
+        r.objects = append(r.objects, grid, text)
+    }
+
+    // ป้ายแกน x แสดงเท่าที่มีที่ว่าง
+    group := plotW / float32(len(c.labels))
+    labelW := canvas.NewText("0000-00-00", foreground).MinSize().Width
+    step := int(math.Ceil(float64(labelW+theme.Padding()) / float64(group)))
+    for i := 0; i < len(c.labels); i += step {
+        text := canvas.NewText(c.labels[i], foreground)
+        text.TextSize = theme.CaptionTextSize()
+        text.Alignment = fyne.TextAlignCenter
+        text.Move(fyne.NewPos(chartLeft+group*float32(i), chartTop+plotH+2))
+        text.Resize(fyne.NewSize(group, text.MinSize().Height))
+        r.objects = append(r.objects, text)
+    }
+
+    // คำอธิบายสี
+    legendX := float32(chartLeft)
+    for _, s := range c.series {
+        swatch := canvas.NewRectangle(theme.Color(s.color))
+        swatch.Move(fyne.NewPos(legendX, 6))
+        swatch.Resize(fyne.NewSize(12, 12))
+        text := canvas.NewText(s.name, foreground)
+        text.TextSize = theme.CaptionTextSize()
+        text.Move(fyne.NewPos(legendX+16, 4))
+        r.objects = append(r.objects, swatch, text)
+        legendX += 16 + text.MinSize().Width + 2*theme.Padding()
+    }
+
+    if c.line {
+        r.layoutLines(group, y)
+        return
+    }
+    r.layoutBars(group, y, chartTop+plotH)
+}
+
+// layoutBars วาดแท่งของทุกชุดข้อมูลเรียงกันภายในช่องของแต่ละป้าย
+func (r *chartRenderer) layoutBars(group float32, y func(float64) float32, base float32) {
+    series := r.chart.series
+    barW := group * 0.8 / float32(len(series))
+    for si, s := range series {
+        fill := theme.Color(s.color)
+        for i, v := range s.values {
+            if v <= 0 {
+                continue
+            }
+            bar := canvas.NewRectangle(fill)
+            bar.Move(fyne.NewPos(chartLeft+group*float32(i)+group*0.1+barW*float32(si), y(v)))
+            bar.Resize(fyne.NewSize(barW, base-y(v)))
+            r.objects = append(r.objects, bar)
+        }
+    }
+}
+
+// layoutLines วาดเส้นเชื่อมค่าของแต่ละชุดข้อมูลที่กึ่งกลางช่องของแต่ละป้าย
+func (r *chartRenderer) layoutLines(group float32, y func(float64) float32) {
+    for _, s := range r.chart.series {
+        stroke := theme.Color(s.color)
+        var prev fyne.Position
+        for i, v := range s.values {
+            point := fyne.NewPos(chartLeft+group*(float32(i)+0.5), y(v))
+            if i > 0 {
+                segment := canvas.NewLine(stroke)
+                segment.StrokeWidth = 2
+                segment.Position1 = prev
+                segment.Position2 = point
+                r.objects = append(r.objects, segment)
+            }
+            dot := canvas.NewCircle(stroke)
+            dot.Move(point.SubtractXY(3, 3))
+            dot.Resize(fyne.NewSize(6, 6))
+            r.objects = append(r.objects, dot)
+            prev = point
+        }
+    }
+}
@@ -0,0 +1,164 @@
+package views
+
+import (
+    "fmt"
+    "strings"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    config "hissync-10/functions"
+    "hissync-10/store"
+)
+
+// newEventDetail แผงรายละเอียดของเหตุการณ์ที่เลือก: ค่าก่อน/หลังของแต่ละคอลัมน์ (ไฮไลต์คอลัมน์ที่เปลี่ยน)
+// ตำแหน่งในต้นทาง ธุรกรรม และคำสั่ง SQL ที่คัดลอกได้ คืนแผง และฟังก์ชันสำหรับแสดงเหตุการณ์
+func newEventDetail(myWindow fyne.Window) (fyne.CanvasObject, func(store.Record)) {
+    var current store.Record
+    headers := []string{"คอลัมน์", "ก่อน", "หลัง"}
+
+    summaryLabel := widget.NewLabelWithStyle("เลือกเหตุการณ์ในตารางเพื่อดูรายละเอียด", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    sourceLabel := widget.NewLabel("")
+    transactionLabel := widget.NewLabel("")
+    auditLabel := widget.NewLabel("")
+    // คำสั่ง SQL ต้นฉบับจาก binlog (ANNOTATE_ROWS ของ MariaDB) ซ่อนเมื่อไม่มี
+    originalLabel := widget.NewLabel("")
+    originalLabel.Wrapping = fyne.TextWrapWord
+    originalLabel.Hide()
+
+    columnTable := widget.NewTable(
+        func() (int, int) { return len(current.Columns), len(headers) },
+        func() fyne.CanvasObject {
+            label := widget.NewLabel("")
+            label.Truncation = fyne.TextTruncateEllipsis
+            return label
+        },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row >= len(current.Columns) {
+                return
+            }
+            col := current.Columns[id.Row]
+            if col.Changed() {
+                label.Importance = widget.WarningImportance
+                label.TextStyle = fyne.TextStyle{Bold: true}
+            } else {
+                label.Importance = widget.MediumImportance
+                label.TextStyle = fyne.TextStyle{}
+            }
+            switch id.Col {
+            case 0:
+                label.SetText(col.Name)
+            case 1:
+                label.SetText(columnText(col.Before, hasBeforeImage(current)))
+            default:
+                label.SetText(columnText(col.After, current.Operation != "DELETE"))
+            }
+        },
+    )
+    columnTable.ShowHeaderRow = true
+    columnTable.CreateHeader = func() fyne.CanvasObject {
+        return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    }
+    columnTable.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+        if id.Col >= 0 && id.Col < len(headers) {
+            cell.(*widget.Label).SetText(headers[id.Col])
+        }
+    }
+    columnTable.SetColumnWidth(0, 200)
+    columnTable.SetColumnWidth(1, 350)
+    columnTable.SetColumnWidth(2, 350)
+
+    sqlEntry := widget.NewMultiLineEntry()
+    sqlEntry.Wrapping = fyne.TextWrapWord
+    sqlEntry.SetMinRowsVisible(3)
+    copyButton := widget.NewButton("คัดลอก SQL", func() {
+        myWindow.Clipboard().SetContent(current.Statement())
+    })
+    copyButton.Disable()
+
+    show := func(rec store.Record) {
+        current = rec
+        summaryLabel.SetText(fmt.Sprintf("#%d %s %s เวลา %s (โปรไฟล์ %s)",
+            rec.Seq, rec.Operation, rec.FullTableName(), rec.Time.Format("2006-01-02 15:04:05"), rec.Profile))
+        sourceLabel.SetText(sourceCoordinates(rec))
+        if rec.Transaction != "" {
+            transactionLabel.SetText("ธุรกรรม: " + rec.Transaction)
+        } else {
+            transactionLabel.SetText("ธุรกรรม: ไม่ทราบ")
+        }
+        auditLabel.SetText("ผู้ทำรายการ: " + auditText(rec))
+        if rec.OriginalSQL != "" {
+            originalLabel.SetText("คำสั่งต้นฉบับ: " + rec.OriginalSQL)
+            originalLabel.Show()
+        } else {
+            originalLabel.Hide()
+        }
+        sqlEntry.SetText(rec.Statement())
+        copyButton.Enable()
+        columnTable.Refresh()
+        columnTable.ScrollToTop()
+    }
+
+    info := container.NewVBox(summaryLabel, sourceLabel, transactionLabel, auditLabel, originalLabel)
+    sqlBox := container.NewBorder(nil, nil, nil, copyButton, sqlEntry)
+    return container.NewBorder(info, sqlBox, nil, nil, columnTable), show
+}
+
+// sourceCoordinates ตำแหน่งของเหตุการณ์ในต้นทาง
+func sourceCoordinates(rec store.Record) string {
+    switch rec.Source {
+    case config.DBTypeMySQL:
+        text := fmt.Sprintf("binlog: %s ตำแหน่ง %s", rec.LogFile, rec.Position)
+        if rec.GTID != "" {
+            text += " GTID: " + rec.GTID
+        }
+        return text
+    case config.DBTypePostgreSQL:
+        return fmt.Sprintf("ไฟล์ Log: %s ตำแหน่งไบต์ %d (เวลาในบรรทัด %s)", rec.LogFile, rec.Offset, rec.Position)
+    default:
+        return "ตำแหน่ง: " + rec.Position
+    }
+}
+
+// auditText ผู้ใช้ฐานข้อมูล โปรแกรม เครื่อง และ session ที่ทำให้เกิดเหตุการณ์ เท่าที่ต้นทางบันทึกไว้
+func auditText(rec store.Record) string {
+    var parts []string
+    if rec.DBUser != "" {
+        user := rec.DBUser
+        if rec.ClientHost != "" {
+            user += "@" + rec.ClientHost
+        }
+        parts = append(parts, user)
+    } else if rec.ClientHost != "" {
+        parts = append(parts, "เครื่อง "+rec.ClientHost)
+    }
+    if rec.ClientApp != "" {
+        parts = append(parts, "โปรแกรม "+rec.ClientApp)
+    }
+    if rec.SessionID != "" {
+        parts = append(parts, "session "+rec.SessionID)
+    }
+    if len(parts) == 0 {
+        return "ไม่ทราบ"
+    }
+    return strings.Join(parts, " ")
+}
+
+// hasBeforeImage บอกว่าต้นทางบันทึกค่าเดิมของแถวไว้หรือไม่ (ไฟล์ Log ของ PostgreSQL ไม่มีค่าเดิม)
+func hasBeforeImage(rec store.Record) bool {
+    return rec.Source == config.DBTypeMySQL && rec.Operation != "INSERT"
+}
+
+// columnText ค่าของคอลัมน์สำหรับแสดง ค่า nil เป็น NULL เมื่อฝั่งนั้นมีค่าได้
+func columnText(value *string, applicable bool) string {
+    switch {
+    case value != nil:
+        return *value
+    case applicable:
+        return "NULL"
+    default:
+        return ""
+    }
+}
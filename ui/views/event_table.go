@@ -0,0 +1,365 @@
+package views
+
+import (
+    "fmt"
+    "io"
+    "sync"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/export"
+    "hissync-10/pipeline"
+    "hissync-10/store"
+)
+
+// ความสูงของข้อความหนึ่งบรรทัดในตาราง และจำนวนบรรทัดสูงสุดของแถวที่ข้อความยาว
+const (
+    eventLineHeight  = 24
+    eventMaxRowLines = 6
+)
+
+// eventColumn คอลัมน์หนึ่งของตารางเหตุการณ์
+type eventColumn struct {
+    title string
+    width float32
+    value func(store.Record) string
+    // wrap ตัดบรรทัดข้อความยาว (แถวจะสูงขึ้นตามความยาว)
+    wrap bool
+}
+
+// eventBuffer เก็บเหตุการณ์ล่าสุดไม่เกิน capacity รายการแบบ ring buffer
+// รายการที่เก่ากว่าถูกทิ้งไปแต่ยังเปิดดูได้จาก hissync.db
+type eventBuffer struct {
+    items []store.Record
+    start int
+    count int
+}
+
+func newEventBuffer(capacity int) *eventBuffer {
+    return &eventBuffer{items: make([]store.Record, capacity)}
+}
+
+// Len จำนวนรายการในบัฟเฟอร์
+func (b *eventBuffer) Len() int {
+    return b.count
+}
+
+// At คืนรายการที่ i (0 คือรายการที่เก่าที่สุด)
+func (b *eventBuffer) At(i int) store.Record {
+    return b.items[(b.start+i)%len(b.items)]
+}
+
+// Push เพิ่มรายการใหม่ ถ้าเต็มจะทิ้งรายการที่เก่าที่สุด
+func (b *eventBuffer) Push(rec store.Record) {
+    if b.count < len(b.items) {
+        b.items[(b.start+b.count)%len(b.items)] = rec
+        b.count++
+        return
+    }
+    b.items[b.start] = rec
+    b.start = (b.start + 1) % len(b.items)
+}
+
+// Reset แทนที่ทุกรายการด้วย records (เก็บเฉพาะ capacity รายการล่าสุด)
+func (b *eventBuffer) Reset(records []store.Record) {
+    b.start, b.count = 0, 0
+    for _, rec := range records {
+        b.Push(rec)
+    }
+}
+
+// newEventTable ตารางเหตุการณ์ที่บันทึกแล้วของโปรไฟล์ เก็บในหน่วยความจำไม่เกิน display.max_events รายการ
+// เมื่ออยู่หน้าล่าสุดจะแสดงเหตุการณ์ใหม่ที่ตรงกับตัวกรองทันที และเปิดดูเหตุการณ์ที่เก่ากว่าได้ทีละหน้าจาก hissync.db
+// เลือกแถวเพื่อดูรายละเอียดของเหตุการณ์ในแผงด้านล่าง
+// คืนตาราง และฟังก์ชันสำหรับล้างตาราง (ไม่ลบข้อมูลใน hissync.db)
+func newEventTable(pipe *pipeline.Pipeline, profile string, myWindow fyne.Window, columns []eventColumn) (fyne.CanvasObject, func()) {
+    pageSize := pipe.Manager.Config().DisplayMaxEvents()
+
+    var mu sync.Mutex
+    buffer := newEventBuffer(pageSize)
+    following := true
+    unseen := 0
+    filter := store.Filter{Profile: profile}
+    // ลำดับที่ปุ่มเก่ากว่าและใหม่กว่าใช้ค้นต่อ (Page.Next ของหน้าที่แล้ว) เพราะการค้นแต่ละครั้งอ่าน hissync.db ไม่เกินจำนวนที่กำหนด
+    // stoppedAt ลำดับที่การค้นล่าสุดหยุดก่อนได้ครบหน้า (0 คือค้นครบ)
+    var olderFrom, newerFrom, stoppedAt uint64
+    stopped := func(page store.Page) uint64 {
+        if page.More {
+            return page.Next
+        }
+        return 0
+    }
+    // ความสูงที่กำหนดให้แต่ละแถวแล้ว คำนวณเฉพาะแถวที่ถูกแสดง
+    rowHeights := map[int]float32{}
+
+    var table *widget.Table
+    rowHeight := func(row int, rec store.Record) {
+        height := float32(eventLineHeight)
+        for _, col := range columns {
+            if !col.wrap {
+                continue
+            }
+            lines := len(col.value(rec))/50 + 1
+            if lines > eventMaxRowLines {
+                lines = eventMaxRowLines
+            }
+            if h := float32(lines * eventLineHeight); h > height {
+                height = h
+            }
+        }
+        mu.Lock()
+        changed := rowHeights[row] != height
+        rowHeights[row] = height
+        mu.Unlock()
+        if changed {
+            table.SetRowHeight(row, height)
+        }
+    }
+
+    table = widget.NewTable(
+        func() (int, int) {
+            mu.Lock()
+            defer mu.Unlock()
+            return buffer.Len(), len(columns)
+        },
+        func() fyne.CanvasObject {
+            label := widget.NewLabel("")
+            label.Truncation = fyne.TextTruncateEllipsis
+            return label
+        },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            mu.Lock()
+            if id.Row >= buffer.Len() {
+                mu.Unlock()
+                return
+            }
+            rec := buffer.At(id.Row)
+            mu.Unlock()
+
+            label := cell.(*widget.Label)
+            col := columns[id.Col]
+            if col.wrap {
+                label.Wrapping = fyne.TextWrapWord
+                label.Truncation = fyne.TextTruncateOff
+            } else {
+                label.Wrapping = fyne.TextWrapOff
+                label.Truncation = fyne.TextTruncateEllipsis
+            }
+            label.SetText(col.value(rec))
+            if id.Col == 0 {
+                rowHeight(id.Row, rec)
+            }
+        },
+    )
+    table.ShowHeaderRow = true
+    table.CreateHeader = func() fyne.CanvasObject {
+        return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    }
+    table.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+        if id.Col >= 0 && id.Col < len(columns) {
+            cell.(*widget.Label).SetText(columns[id.Col].title)
+        }
+    }
+    for i, col := range columns {
+        table.SetColumnWidth(i, col.width)
+    }
+
+    info := widget.NewLabel("")
+    olderButton := widget.NewButton("◀ เก่ากว่า", nil)
+    newerButton := widget.NewButton("ใหม่กว่า ▶", nil)
+    latestButton := widget.NewButton("ล่าสุด", nil)
+
+    // update ปรับข้อความและปุ่มตามหน้าที่แสดง (ต้องถือ mu อยู่)
+    update := func() {
+        text := fmt.Sprintf("แสดง %d รายการ (สูงสุด %d)", buffer.Len(), pageSize)
+        if buffer.Len() > 0 {
+            text += fmt.Sprintf(" ลำดับ %d–%d", buffer.At(0).Seq, buffer.At(buffer.Len()-1).Seq)
+        }
+        if filter.Active() {
+            text += " | กรองอยู่"
+        }
+        if stoppedAt != 0 {
+            text += fmt.Sprintf(" | ค้นถึงลำดับ %d แล้วหยุดก่อนครบหน้า กดปุ่มเดิมเพื่อค้นต่อ", stoppedAt)
+        }
+        if following {
+            newerButton.Disable()
+            latestButton.Disable()
+        } else {
+            text += fmt.Sprintf(" | กำลังดูข้อมูลย้อนหลัง มีเหตุการณ์ใหม่ %d รายการ", unseen)
+            newerButton.Enable()
+            latestButton.Enable()
+        }
+        info.SetText(text)
+    }
+    // show แสดง records แทนหน้าปัจจุบัน
+    show := func(records []store.Record, live bool) {
+        mu.Lock()
+        buffer.Reset(records)
+        following = live
+        if live {
+            unseen = 0
+        }
+        rowHeights = map[int]float32{}
+        update()
+        mu.Unlock()
+        table.Refresh()
+        if live {
+            table.ScrollToBottom()
+        } else {
+            table.ScrollToTop()
+        }
+    }
+    showLatest := func() {
+        mu.Lock()
+        f := filter
+        mu.Unlock()
+        page, err := pipe.Store.Before(f, 0, pageSize)
+        if err != nil {
+            info.SetText(err.Error())
+            return
+        }
+        mu.Lock()
+        olderFrom, stoppedAt = page.Next, stopped(page)
+        mu.Unlock()
+        show(page.Records, true)
+    }
+    // continued หน้าที่ค้นถึง maxScan ของ hissync.db โดยไม่พบเหตุการณ์ที่ตรงกับตัวกรอง
+    // ไม่เปลี่ยนหน้าที่แสดง แต่จำตำแหน่งไว้ให้กดปุ่มเดิมเพื่อค้นต่อ
+    continued := func(page store.Page, from *uint64) {
+        mu.Lock()
+        *from, stoppedAt = page.Next, page.Next
+        update()
+        mu.Unlock()
+    }
+
+    olderButton.OnTapped = func() {
+        mu.Lock()
+        from, f := olderFrom, filter
+        mu.Unlock()
+        if from == 1 {
+            return
+        }
+        page, err := pipe.Store.Before(f, from, pageSize)
+        if err != nil {
+            return
+        }
+        if len(page.Records) == 0 {
+            if page.More {
+                continued(page, &olderFrom)
+            }
+            return
+        }
+        mu.Lock()
+        olderFrom, newerFrom, stoppedAt = page.Next, page.Records[len(page.Records)-1].Seq, stopped(page)
+        mu.Unlock()
+        show(page.Records, false)
+    }
+    newerButton.OnTapped = func() {
+        mu.Lock()
+        from, f := newerFrom, filter
+        mu.Unlock()
+        page, err := pipe.Store.After(f, from, pageSize)
+        if err != nil {
+            return
+        }
+        if !page.More && len(page.Records) < pageSize {
+            // ถึงเหตุการณ์ล่าสุดแล้ว กลับไปแสดงเหตุการณ์ใหม่ทันที
+            showLatest()
+            return
+        }
+        if len(page.Records) == 0 {
+            continued(page, &newerFrom)
+            return
+        }
+        mu.Lock()
+        olderFrom, newerFrom, stoppedAt = page.Records[0].Seq, page.Next, stopped(page)
+        mu.Unlock()
+        show(page.Records, false)
+    }
+    latestButton.OnTapped = showLatest
+
+    pipe.Subscribe(func(rec store.Record) {
+        mu.Lock()
+        if !filter.Match(rec) {
+            mu.Unlock()
+            return
+        }
+        if !following {
+            unseen++
+            update()
+            mu.Unlock()
+            return
+        }
+        evicted := buffer.Len() == pageSize
+        buffer.Push(rec)
+        if evicted {
+            // แถวเลื่อนขึ้นทั้งหมด ความสูงเดิมใช้ไม่ได้ คำนวณใหม่เมื่อแถวถูกแสดง
+            rowHeights = map[int]float32{}
+            olderFrom = buffer.At(0).Seq
+        }
+        update()
+        mu.Unlock()
+        table.Refresh()
+        table.ScrollToBottom()
+    })
+    showLatest()
+
+    clear := func() {
+        show(nil, true)
+    }
+
+    filterBar := newFilterBar(func(f store.Filter) {
+        f.Profile = profile
+        mu.Lock()
+        filter = f
+        mu.Unlock()
+        showLatest()
+    })
+
+    detail, showDetail := newEventDetail(myWindow)
+    // selected เหตุการณ์ที่เลือกล่าสุด ใช้กับการย้อนกลับ
+    var selected store.Record
+    table.OnSelected = func(id widget.TableCellID) {
+        mu.Lock()
+        if id.Row < 0 || id.Row >= buffer.Len() {
+            mu.Unlock()
+            return
+        }
+        rec := buffer.At(id.Row)
+        selected = rec
+        mu.Unlock()
+        showDetail(rec)
+    }
+
+    exportButton := widget.NewButton("ส่งออก", func() {
+        mu.Lock()
+        f := filter
+        mu.Unlock()
+        showExportDialog(pipe, profile, f, myWindow)
+    })
+
+    undoButton := widget.NewButton("ย้อนกลับ", func() {
+        mu.Lock()
+        f, rec := filter, selected
+        mu.Unlock()
+        showUndoDialog(pipe, profile, f, rec, myWindow)
+    })
+
+    paging := container.NewHBox(olderButton, newerButton, latestButton, exportButton, undoButton, info)
+    split := container.NewVSplit(table, detail)
+    split.SetOffset(0.6)
+    return container.NewBorder(container.NewVBox(filterBar, paging), nil, nil, nil, split), clear
+}
+
+// showExportDialog ให้ผู้ใช้เลือกรูปแบบและไฟล์ แล้วส่งออกเหตุการณ์ทั้งหมดในที่เก็บที่ตรงกับ filter
+// (ไม่จำกัดเฉพาะหน้าที่แสดงอยู่)
+func showExportDialog(pipe *pipeline.Pipeline, profile string, filter store.Filter, myWindow fyne.Window) {
+    showSaveAsDialog("ส่งออกเหตุการณ์", export.Formats,
+        func(format string) string { return export.FileName(profile, format) },
+        func(w io.Writer, format string) (string, error) {
+            count, err := export.Write(w, format, pipe.Store, filter)
+            return fmt.Sprintf("ส่งออก %d รายการ", count), err
+        }, myWindow)
+}
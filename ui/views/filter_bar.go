@@ -0,0 +1,276 @@
+package views
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/logging"
+    "hissync-10/store"
+)
+
+// FilterPresetsFile ไฟล์เก็บชุดตัวกรองที่บันทึกไว้ ใช้ร่วมกันทุกโปรไฟล์
+const FilterPresetsFile = "filter_presets.json"
+
+// รูปแบบเวลาที่รับในช่อง ตั้งแต่/ถึง (เวลาท้องถิ่น)
+var filterTimeFormats = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// filterPreset ชุดตัวกรองที่บันทึกไว้ เก็บค่าตามที่กรอกในช่อง
+type filterPreset struct {
+    Name        string `json:"name"`
+    Table       string `json:"table,omitempty"`
+    Operation   string `json:"operation,omitempty"`
+    PrimaryKey  string `json:"primary_key,omitempty"`
+    Transaction string `json:"transaction,omitempty"`
+    From        string `json:"from,omitempty"`
+    To          string `json:"to,omitempty"`
+    Text        string `json:"text,omitempty"`
+}
+
+// ชุดตัวกรองที่บันทึกไว้ และแถบตัวกรองที่ต้องปรับรายการเมื่อมีการบันทึกหรือลบ
+var presets struct {
+    sync.Mutex
+    loaded    bool
+    items     []filterPreset
+    listeners []func([]string)
+    // unreadable ไฟล์อ่านไม่ได้และสำรองไม่สำเร็จ จะไม่บันทึกทับจนกว่าจะแก้ไขไฟล์
+    unreadable error
+}
+
+func loadFilterPresets() []filterPreset {
+    presets.Lock()
+    defer presets.Unlock()
+    if !presets.loaded {
+        presets.loaded = true
+        if data, err := os.ReadFile(FilterPresetsFile); err == nil {
+            if err := json.Unmarshal(data, &presets.items); err != nil {
+                presets.items = nil
+                presets.unreadable = backupFilterPresets(err)
+            }
+        }
+    }
+    return append([]filterPreset(nil), presets.items...)
+}
+
+// backupFilterPresets เปลี่ยนชื่อไฟล์ชุดตัวกรองที่อ่านไม่ได้เป็นไฟล์สำรอง เพื่อไม่ให้การบันทึกครั้งถัดไปเขียนทับ
+// คืนข้อผิดพลาดถ้าสำรองไม่สำเร็จ
+func backupFilterPresets(readErr error) error {
+    logger := logging.For(logging.ComponentUI)
+    backup := fmt.Sprintf("%s.%s.bak", FilterPresetsFile, time.Now().Format("20060102-150405"))
+    if err := os.Rename(FilterPresetsFile, backup); err != nil {
+        logger.Error("อ่านชุดตัวกรองที่บันทึกไว้ไม่ได้ และสำรองไฟล์ไม่สำเร็จ จะไม่บันทึกทับ",
+            "file", FilterPresetsFile, "error", readErr, "backup_error", err)
+        return fmt.Errorf("%s อ่านไม่ได้ (%v) แก้ไขหรือย้ายไฟล์ก่อนบันทึกชุดตัวกรอง", FilterPresetsFile, readErr)
+    }
+    logger.Warn("อ่านชุดตัวกรองที่บันทึกไว้ไม่ได้ ย้ายไฟล์เดิมไปเป็นไฟล์สำรอง",
+        "file", FilterPresetsFile, "backup", backup, "error", readErr)
+    return nil
+}
+
+// saveFilterPresets บันทึกชุดตัวกรองลงไฟล์แล้วแจ้งทุกแถบตัวกรอง
+// ไม่บันทึกถ้าไฟล์เดิมอ่านไม่ได้และยังไม่ได้สำรองไว้
+func saveFilterPresets(items []filterPreset) error {
+    presets.Lock()
+    unreadable := presets.unreadable
+    presets.Unlock()
+    if unreadable != nil {
+        return unreadable
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+    data, err := json.MarshalIndent(items, "", "  ")
+    if err != nil {
+        return err
+    }
+    if err := os.WriteFile(FilterPresetsFile, data, 0644); err != nil {
+        return fmt.Errorf("ไม่สามารถบันทึก %s: %v", FilterPresetsFile, err)
+    }
+    presets.Lock()
+    presets.items = items
+    listeners := append([]func([]string){}, presets.listeners...)
+    presets.Unlock()
+    for _, fn := range listeners {
+        fn(presetNames(items))
+    }
+    return nil
+}
+
+func presetNames(items []filterPreset) []string {
+    names := make([]string, len(items))
+    for i, p := range items {
+        names[i] = p.Name
+    }
+    return names
+}
+
+// parseFilterTime แปลงเวลาในช่องตัวกรอง (ว่างคือไม่กรอง)
+func parseFilterTime(value string) (time.Time, error) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return time.Time{}, nil
+    }
+    for _, layout := range filterTimeFormats {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("เวลา %q ไม่ตรงรูปแบบ %s", value, filterTimeFormats[0])
+}
+
+// newFilterBar แถบตัวกรองเหตุการณ์ เรียก onApply เมื่อกดค้นหา เลือกประเภทคำสั่ง หรือเลือกชุดตัวกรอง
+func newFilterBar(onApply func(store.Filter)) fyne.CanvasObject {
+    tableEntry := widget.NewEntry()
+    tableEntry.SetPlaceHolder("database.table")
+    operationSelect := widget.NewSelect([]string{allOption, "INSERT", "UPDATE", "DELETE"}, nil)
+    operationSelect.SetSelected(allOption)
+    pkEntry := widget.NewEntry()
+    pkEntry.SetPlaceHolder("Primary Key")
+    transactionEntry := widget.NewEntry()
+    transactionEntry.SetPlaceHolder("ธุรกรรม")
+    fromEntry := widget.NewEntry()
+    fromEntry.SetPlaceHolder("ตั้งแต่ " + filterTimeFormats[1])
+    toEntry := widget.NewEntry()
+    toEntry.SetPlaceHolder("ถึง " + filterTimeFormats[1])
+    textEntry := widget.NewEntry()
+    textEntry.SetPlaceHolder("ค้นหาข้อความใน SQL หรือผู้ทำรายการ")
+    messageLabel := widget.NewLabel("")
+
+    apply := func() {
+        from, err := parseFilterTime(fromEntry.Text)
+        if err == nil {
+            var to time.Time
+            if to, err = parseFilterTime(toEntry.Text); err == nil {
+                messageLabel.SetText("")
+                filter := store.Filter{
+                    Table:       strings.TrimSpace(tableEntry.Text),
+                    PrimaryKey:  strings.TrimSpace(pkEntry.Text),
+                    Transaction: strings.TrimSpace(transactionEntry.Text),
+                    From:        from,
+                    To:          to,
+                    Text:        strings.TrimSpace(textEntry.Text),
+                }
+                if operationSelect.Selected != allOption {
+                    filter.Operation = operationSelect.Selected
+                }
+                onApply(filter)
+                return
+            }
+        }
+        messageLabel.SetText(err.Error())
+    }
+    current := func(name string) filterPreset {
+        p := filterPreset{
+            Name:        name,
+            Table:       tableEntry.Text,
+            PrimaryKey:  pkEntry.Text,
+            Transaction: transactionEntry.Text,
+            From:        fromEntry.Text,
+            To:          toEntry.Text,
+            Text:        textEntry.Text,
+        }
+        if operationSelect.Selected != allOption {
+            p.Operation = operationSelect.Selected
+        }
+        return p
+    }
+    // fill ใส่ค่าจากชุดตัวกรองลงในช่อง โดยไม่ค้นหาซ้ำทุกช่อง
+    filling := false
+    fill := func(p filterPreset) {
+        filling = true
+        tableEntry.SetText(p.Table)
+        pkEntry.SetText(p.PrimaryKey)
+        transactionEntry.SetText(p.Transaction)
+        fromEntry.SetText(p.From)
+        toEntry.SetText(p.To)
+        textEntry.SetText(p.Text)
+        if p.Operation == "" {
+            operationSelect.SetSelected(allOption)
+        } else {
+            operationSelect.SetSelected(p.Operation)
+        }
+        filling = false
+        apply()
+    }
+
+    for _, entry := range []*widget.Entry{tableEntry, pkEntry, transactionEntry, fromEntry, toEntry, textEntry} {
+        entry.OnSubmitted = func(string) { apply() }
+    }
+    operationSelect.OnChanged = func(string) {
+        if !filling {
+            apply()
+        }
+    }
+
+    presetSelect := widget.NewSelectEntry(presetNames(loadFilterPresets()))
+    presetSelect.SetPlaceHolder("ชุดตัวกรอง")
+    presetSelect.OnChanged = func(name string) {
+        for _, p := range loadFilterPresets() {
+            if p.Name == name {
+                fill(p)
+                return
+            }
+        }
+    }
+    presets.Lock()
+    presets.listeners = append(presets.listeners, presetSelect.SetOptions)
+    presets.Unlock()
+
+    saveButton := widget.NewButton("บันทึกชุดตัวกรอง", func() {
+        name := strings.TrimSpace(presetSelect.Text)
+        if name == "" {
+            messageLabel.SetText("พิมพ์ชื่อชุดตัวกรองในช่องชุดตัวกรองก่อนบันทึก")
+            return
+        }
+        items := loadFilterPresets()
+        replaced := false
+        for i := range items {
+            if items[i].Name == name {
+                items[i] = current(name)
+                replaced = true
+            }
+        }
+        if !replaced {
+            items = append(items, current(name))
+        }
+        if err := saveFilterPresets(items); err != nil {
+            messageLabel.SetText(err.Error())
+            return
+        }
+        messageLabel.SetText(fmt.Sprintf("บันทึกชุดตัวกรอง %s แล้ว", name))
+    })
+    deleteButton := widget.NewButton("ลบชุดตัวกรอง", func() {
+        name := strings.TrimSpace(presetSelect.Text)
+        items := loadFilterPresets()
+        kept := items[:0]
+        for _, p := range items {
+            if p.Name != name {
+                kept = append(kept, p)
+            }
+        }
+        if len(kept) == len(items) {
+            return
+        }
+        if err := saveFilterPresets(kept); err != nil {
+            messageLabel.SetText(err.Error())
+            return
+        }
+        presetSelect.SetText("")
+    })
+    clearButton := widget.NewButton("ล้างตัวกรอง", func() {
+        presetSelect.SetText("")
+        fill(filterPreset{})
+    })
+
+    fields := container.NewGridWithColumns(7, tableEntry, operationSelect, pkEntry, transactionEntry, fromEntry, toEntry, textEntry)
+    actions := container.NewBorder(nil, nil, nil,
+        container.NewHBox(widget.NewButton("ค้นหา", apply), clearButton, saveButton, deleteButton),
+        presetSelect)
+    return container.NewVBox(fields, actions, messageLabel)
+}
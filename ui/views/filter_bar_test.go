@@ -0,0 +1,76 @@
+package views
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+// resetFilterPresets เปลี่ยนไปทำงานในโฟลเดอร์ชั่วคราวและล้างชุดตัวกรองที่โหลดไว้
+func resetFilterPresets(t *testing.T) string {
+    t.Helper()
+    dir := t.TempDir()
+    wd, err := os.Getwd()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Chdir(dir); err != nil {
+        t.Fatal(err)
+    }
+    reset := func() {
+        presets.Lock()
+        presets.loaded, presets.items, presets.unreadable = false, nil, nil
+        presets.Unlock()
+    }
+    reset()
+    t.Cleanup(func() {
+        reset()
+        os.Chdir(wd)
+    })
+    return dir
+}
+
+func TestLoadFilterPresetsBacksUpUnreadableFile(t *testing.T) {
+    dir := resetFilterPresets(t)
+    corrupt := []byte(`[{"name": "ลบข้อมูล"`)
+    if err := os.WriteFile(FilterPresetsFile, corrupt, 0644); err != nil {
+        t.Fatal(err)
+    }
+
+    if items := loadFilterPresets(); len(items) != 0 {
+        t.Fatalf("loadFilterPresets() = %v ต้องการว่าง", items)
+    }
+    backups, _ := filepath.Glob(filepath.Join(dir, FilterPresetsFile+".*.bak"))
+    if len(backups) != 1 {
+        t.Fatalf("ไฟล์สำรอง = %v ต้องการ 1 ไฟล์", backups)
+    }
+    if data, _ := os.ReadFile(backups[0]); string(data) != string(corrupt) {
+        t.Fatalf("ไฟล์สำรอง = %q ต้องการ %q", data, corrupt)
+    }
+
+    if err := saveFilterPresets([]filterPreset{{Name: "ใหม่"}}); err != nil {
+        t.Fatal(err)
+    }
+    presets.Lock()
+    presets.loaded = false
+    presets.Unlock()
+    if items := loadFilterPresets(); len(items) != 1 || items[0].Name != "ใหม่" {
+        t.Fatalf("loadFilterPresets() = %v", items)
+    }
+}
+
+func TestSaveFilterPresetsRefusesWhenBackupFails(t *testing.T) {
+    resetFilterPresets(t)
+    // ไม่มีไฟล์ให้ย้าย การสำรองจึงไม่สำเร็จ
+    presets.Lock()
+    presets.loaded = true
+    presets.unreadable = backupFilterPresets(os.ErrInvalid)
+    presets.Unlock()
+
+    if err := saveFilterPresets([]filterPreset{{Name: "ใหม่"}}); err == nil {
+        t.Fatal("บันทึกทับทั้งที่ไฟล์เดิมอ่านไม่ได้และสำรองไม่สำเร็จ")
+    }
+    if _, err := os.Stat(FilterPresetsFile); !os.IsNotExist(err) {
+        t.Fatalf("สร้าง %s ทั้งที่ปฏิเสธการบันทึก", FilterPresetsFile)
+    }
+}
@@ -0,0 +1,161 @@
+package views
+
+import (
+    "fmt"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/dialog"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/capture"
+)
+
+// IncidentsView แสดงปัญหาของทุกโปรไฟล์ พร้อมคำสั่งลองใหม่ทันที ล้างตำแหน่ง และปิดรายการ
+// ปรับรายการเองเมื่อเกิดข้อผิดพลาดหรือสถานะโปรไฟล์เปลี่ยน
+func IncidentsView(manager *capture.Manager, myWindow fyne.Window) fyne.CanvasObject {
+    incidents := []capture.Incident{}
+    headers := []string{"ระดับ", "โปรไฟล์", "แหล่งข้อมูล", "ข้อความ", "พบครั้งแรก", "พบล่าสุด", "จำนวน", "สถานะ"}
+    selected := -1
+
+    table := widget.NewTable(
+        func() (int, int) { return len(incidents) + 1, len(headers) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(headers[id.Col])
+                return
+            }
+            inc := incidents[id.Row-1]
+            label.TextStyle = fyne.TextStyle{Bold: inc.Open() && inc.Severity == capture.SeverityError}
+            label.SetText(incidentColumn(inc, id.Col))
+        },
+    )
+    table.SetColumnWidth(0, 80)
+    table.SetColumnWidth(1, 120)
+    table.SetColumnWidth(2, 100)
+    table.SetColumnWidth(3, 450)
+    table.SetColumnWidth(4, 160)
+    table.SetColumnWidth(5, 160)
+    table.SetColumnWidth(6, 70)
+    table.SetColumnWidth(7, 150)
+
+    retryButton := widget.NewButton("ลองใหม่ทันที", nil)
+    resetButton := widget.NewButton("ล้างตำแหน่งที่อ่านถึง", nil)
+    dismissButton := widget.NewButton("ปิดรายการ", nil)
+    summary := widget.NewLabel("")
+
+    current := func() (capture.Incident, bool) {
+        if selected < 0 || selected >= len(incidents) {
+            return capture.Incident{}, false
+        }
+        return incidents[selected], true
+    }
+    updateButtons := func() {
+        if _, ok := current(); ok {
+            retryButton.Enable()
+            resetButton.Enable()
+            dismissButton.Enable()
+            return
+        }
+        retryButton.Disable()
+        resetButton.Disable()
+        dismissButton.Disable()
+    }
+    refresh := func() {
+        incidents = manager.Incidents()
+        open := 0
+        for _, inc := range incidents {
+            if inc.Open() {
+                open++
+            }
+        }
+        summary.SetText(fmt.Sprintf("ยังไม่แก้ไข %d รายการ จากทั้งหมด %d รายการ", open, len(incidents)))
+        if selected >= len(incidents) {
+            selected = -1
+            table.UnselectAll()
+        }
+        updateButtons()
+        table.Refresh()
+    }
+
+    table.OnSelected = func(id widget.TableCellID) {
+        selected = id.Row - 1
+        updateButtons()
+    }
+    table.OnUnselected = func(widget.TableCellID) {
+        selected = -1
+        updateButtons()
+    }
+
+    retryButton.OnTapped = func() {
+        if inc, ok := current(); ok {
+            manager.Retry(inc.Profile)
+        }
+    }
+    resetButton.OnTapped = func() {
+        inc, ok := current()
+        if !ok {
+            return
+        }
+        dialog.ShowConfirm("ล้างตำแหน่งที่อ่านถึง",
+            fmt.Sprintf("โปรไฟล์ %s จะเริ่มอ่านจากตำแหน่งปัจจุบันของต้นทาง\nการเปลี่ยนแปลงที่ยังไม่ได้อ่านจะไม่ถูกส่ง ต้องการดำเนินการหรือไม่?", inc.Profile),
+            func(ok bool) {
+                if !ok {
+                    return
+                }
+                if err := manager.ResetCheckpoint(inc.Profile); err != nil {
+                    dialog.ShowError(err, myWindow)
+                }
+                refresh()
+            }, myWindow)
+    }
+    dismissButton.OnTapped = func() {
+        if inc, ok := current(); ok {
+            manager.DismissIncident(inc.Key)
+            table.UnselectAll()
+            refresh()
+        }
+    }
+
+    manager.Subscribe(func(ev capture.Event) {
+        if ev.Err != nil {
+            refresh()
+        }
+    })
+    manager.SubscribeStatus(func(capture.ProfileStatus) { refresh() })
+    refresh()
+
+    actions := container.NewHBox(retryButton, resetButton, dismissButton, widget.NewButton("รีเฟรช", refresh))
+    return container.NewBorder(container.NewVBox(actions, summary), nil, nil, nil, table)
+}
+
+// incidentColumn ข้อความของปัญหาในคอลัมน์ที่ระบุ
+func incidentColumn(inc capture.Incident, col int) string {
+    switch col {
+    case 0:
+        if inc.Severity == capture.SeverityError {
+            return "ผิดพลาด"
+        }
+        return "เตือน"
+    case 1:
+        return inc.Profile
+    case 2:
+        return inc.Source
+    case 3:
+        return inc.Message
+    case 4:
+        return inc.FirstSeen.Format("2006-01-02 15:04:05")
+    case 5:
+        return inc.LastSeen.Format("2006-01-02 15:04:05")
+    case 6:
+        return fmt.Sprintf("%d", inc.Count)
+    default:
+        if inc.Open() {
+            return "ยังไม่แก้ไข"
+        }
+        return "แก้ไขแล้ว " + inc.Resolved.Format("15:04:05")
+    }
+}
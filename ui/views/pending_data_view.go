@@ -1,8 +1,65 @@
 package views
 
-import "fyne.io/fyne/v2/widget"
+import (
+    "fmt"
+    "time"
 
-// PendingDataView แสดงข้อมูลค้างส่ง
-func PendingDataView() *widget.Label {
-    return widget.NewLabel("ข้อมูลค้างส่ง")
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/pipeline"
+)
+
+// PendingDataView แสดงข้อมูลค้างส่งของแต่ละปลายทาง
+func PendingDataView(pipe *pipeline.Pipeline) fyne.CanvasObject {
+    if pipe == nil {
+        return widget.NewLabel("ข้อมูลค้างส่ง: ไม่ได้เปิดที่เก็บเหตุการณ์ (hissync.db)")
+    }
+
+    data := [][]string{}
+    headers := []string{"ปลายทาง", "ประเภท", "ค้างส่ง", "ส่งสำเร็จ", "ส่งไม่สำเร็จ", "ข้อผิดพลาดล่าสุด"}
+
+    table := widget.NewTable(
+        func() (int, int) { return len(data) + 1, len(headers) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(headers[id.Col])
+                return
+            }
+            label.TextStyle = fyne.TextStyle{}
+            label.SetText(data[id.Row-1][id.Col])
+        },
+    )
+    table.SetColumnWidth(0, 150)
+    table.SetColumnWidth(1, 80)
+    table.SetColumnWidth(2, 150)
+    table.SetColumnWidth(3, 100)
+    table.SetColumnWidth(4, 100)
+    table.SetColumnWidth(5, 500)
+
+    refresh := func() {
+        data = data[:0]
+        for _, st := range pipe.Dispatcher.Statuses() {
+            pendingText := fmt.Sprintf("%d", st.Pending)
+            if lag := st.DeliveryLag(); lag > 0 {
+                pendingText += fmt.Sprintf(" (รอ %s)", lag.Round(time.Second))
+            }
+            lastErr := ""
+            if st.LastErr != nil {
+                lastErr = st.LastErr.Error()
+            }
+            data = append(data, []string{st.Name, st.Type, pendingText,
+                fmt.Sprintf("%d", st.Delivered), fmt.Sprintf("%d", st.Failed), lastErr})
+        }
+        table.Refresh()
+    }
+    refresh()
+
+    refreshButton := widget.NewButton("รีเฟรช", refresh)
+
+    return container.NewBorder(container.NewHBox(refreshButton), nil, nil, nil, table)
 }
@@ -0,0 +1,202 @@
+package views
+
+import (
+    "fmt"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/dialog"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/pipeline"
+    "hissync-10/store"
+)
+
+// จำนวนเหตุการณ์ของธุรกรรมที่แสดงสูงสุด
+const quarantineRecordLimit = 1000
+
+// QuarantineView ธุรกรรมที่ safeguard กักไว้เพราะเปลี่ยนข้อมูลจำนวนมากผิดปกติ
+// เลือกธุรกรรมเพื่อดูเหตุการณ์ แล้วอนุมัติให้ส่งไปยังปลายทางหรือปฏิเสธ
+func QuarantineView(pipe *pipeline.Pipeline, myWindow fyne.Window) fyne.CanvasObject {
+    if pipe == nil {
+        return widget.NewLabel("รออนุมัติ: ไม่ได้เปิดที่เก็บเหตุการณ์ (hissync.db)")
+    }
+
+    var holds []store.Hold
+    headers := []string{"รหัส", "กักเมื่อ", "โปรไฟล์", "ธุรกรรม", "แถว", "ลบ", "เหตุผล"}
+    selected := -1
+
+    holdTable := widget.NewTable(
+        func() (int, int) { return len(holds) + 1, len(headers) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(headers[id.Col])
+                return
+            }
+            label.TextStyle = fyne.TextStyle{}
+            label.SetText(holdColumn(holds[id.Row-1], id.Col))
+        },
+    )
+    holdTable.SetColumnWidth(0, 80)
+    holdTable.SetColumnWidth(1, 160)
+    holdTable.SetColumnWidth(2, 120)
+    holdTable.SetColumnWidth(3, 260)
+    holdTable.SetColumnWidth(4, 70)
+    holdTable.SetColumnWidth(5, 70)
+    holdTable.SetColumnWidth(6, 450)
+
+    var records []store.Record
+    columns := []eventColumn{
+        {title: "เวลา", width: 160, value: func(r store.Record) string { return r.Time.Format("2006-01-02 15:04:05") }},
+        {title: "ตาราง", width: 180, value: func(r store.Record) string { return r.FullTableName() }},
+        {title: "การเปลี่ยนแปลง", width: 90, value: func(r store.Record) string { return r.Operation }},
+        {title: "Primary Key", width: 200, value: func(r store.Record) string { return r.PrimaryKey }},
+        {title: "ผู้ทำรายการ", width: 200, value: auditText},
+    }
+    detail, showDetail := newEventDetail(myWindow)
+    eventTable := widget.NewTable(
+        func() (int, int) { return len(records), len(columns) },
+        func() fyne.CanvasObject {
+            label := widget.NewLabel("")
+            label.Truncation = fyne.TextTruncateEllipsis
+            return label
+        },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            if id.Row >= len(records) {
+                return
+            }
+            cell.(*widget.Label).SetText(columns[id.Col].value(records[id.Row]))
+        },
+    )
+    eventTable.ShowHeaderRow = true
+    eventTable.CreateHeader = func() fyne.CanvasObject {
+        return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    }
+    eventTable.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+        if id.Col >= 0 && id.Col < len(columns) {
+            cell.(*widget.Label).SetText(columns[id.Col].title)
+        }
+    }
+    for i, col := range columns {
+        eventTable.SetColumnWidth(i, col.width)
+    }
+    eventTable.OnSelected = func(id widget.TableCellID) {
+        if id.Row >= 0 && id.Row < len(records) {
+            showDetail(records[id.Row])
+        }
+    }
+
+    approveButton := widget.NewButton("อนุมัติส่ง", nil)
+    rejectButton := widget.NewButton("ปฏิเสธ", nil)
+    rejectButton.Importance = widget.DangerImportance
+    summary := widget.NewLabel("")
+
+    current := func() (store.Hold, bool) {
+        if selected < 0 || selected >= len(holds) {
+            return store.Hold{}, false
+        }
+        return holds[selected], true
+    }
+    showRecords := func() {
+        records = nil
+        if h, ok := current(); ok {
+            found, err := pipe.Store.HeldRecords(h.ID, quarantineRecordLimit)
+            if err != nil {
+                summary.SetText(err.Error())
+            }
+            records = found
+            approveButton.Enable()
+            rejectButton.Enable()
+        } else {
+            approveButton.Disable()
+            rejectButton.Disable()
+        }
+        eventTable.UnselectAll()
+        eventTable.Refresh()
+        eventTable.ScrollToTop()
+    }
+    refresh := func() {
+        all, err := pipe.Store.Holds()
+        if err != nil {
+            summary.SetText(err.Error())
+            return
+        }
+        holds = holds[:0]
+        for _, h := range all {
+            if h.Quarantined() {
+                holds = append(holds, h)
+            }
+        }
+        if len(holds) == 0 {
+            summary.SetText("ไม่มีธุรกรรมที่ถูกกักไว้")
+        } else {
+            summary.SetText(fmt.Sprintf("ธุรกรรมที่ถูกกักไว้รออนุมัติ %d รายการ", len(holds)))
+        }
+        selected = -1
+        holdTable.UnselectAll()
+        holdTable.Refresh()
+        showRecords()
+    }
+
+    holdTable.OnSelected = func(id widget.TableCellID) {
+        selected = id.Row - 1
+        showRecords()
+    }
+
+    // decide ยืนยันแล้วอนุมัติหรือปฏิเสธธุรกรรมที่เลือก
+    decide := func(title, message string, action func(uint64) (int, error), done string) {
+        h, ok := current()
+        if !ok {
+            return
+        }
+        dialog.ShowConfirm(title, fmt.Sprintf(message, h.ID, h.Profile, h.Count), func(ok bool) {
+            if !ok {
+                return
+            }
+            count, err := action(h.ID)
+            if err != nil {
+                dialog.ShowError(err, myWindow)
+            } else {
+                dialog.ShowInformation(title, fmt.Sprintf(done, h.ID, count), myWindow)
+            }
+            refresh()
+        }, myWindow)
+    }
+    approveButton.OnTapped = func() {
+        decide("อนุมัติส่ง", "ส่งธุรกรรม #%d ของ %s (%d เหตุการณ์) ไปยังปลายทางหรือไม่?\n"+
+            "ธุรกรรมนี้จะถูกส่งหลังธุรกรรมที่อ่านได้หลังจากนั้น", pipe.Approve, "ธุรกรรม #%d เข้าคิวส่งแล้ว %d เหตุการณ์")
+    }
+    rejectButton.OnTapped = func() {
+        decide("ปฏิเสธ", "ไม่ส่งธุรกรรม #%d ของ %s (%d เหตุการณ์) ไปยังปลายทางหรือไม่?\n"+
+            "เหตุการณ์ยังค้นหาได้ในหน้าเหตุการณ์ และสร้างคำสั่งย้อนกลับได้", pipe.Reject, "ปฏิเสธธุรกรรม #%d แล้ว %d เหตุการณ์จะไม่ถูกส่ง")
+    }
+    refresh()
+
+    actions := container.NewHBox(approveButton, rejectButton, widget.NewButton("รีเฟรช", refresh))
+    split := container.NewVSplit(holdTable, container.NewVSplit(eventTable, detail))
+    split.SetOffset(0.3)
+    return container.NewBorder(container.NewVBox(actions, summary), nil, nil, nil, split)
+}
+
+// holdColumn ข้อความของธุรกรรมที่ถูกกักในคอลัมน์ที่ระบุ
+func holdColumn(h store.Hold, col int) string {
+    switch col {
+    case 0:
+        return fmt.Sprintf("%d", h.ID)
+    case 1:
+        return h.QuarantinedAt.Format("2006-01-02 15:04:05")
+    case 2:
+        return h.Profile
+    case 3:
+        return h.Transaction
+    case 4:
+        return fmt.Sprintf("%d", h.Count)
+    case 5:
+        return fmt.Sprintf("%d", h.Deletes)
+    default:
+        return h.Reason
+    }
+}
@@ -1,8 +1,181 @@
 package views
 
-import "fyne.io/fyne/v2/widget"
+import (
+    "fmt"
+    "io"
+    "strconv"
+    "time"
 
-// ReportView แสดงรายงาน
-func ReportView() *widget.Label {
-    return widget.NewLabel("รายงาน")
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/theme"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/pipeline"
+    "hissync-10/report"
+)
+
+// รูปแบบวันที่ในช่อง ตั้งแต่/ถึง ของรายงาน
+const reportDateFormat = "2006-01-02"
+
+// ReportView แสดงรายงานการทำงานในช่วงวันที่เลือก: จำนวนเหตุการณ์ที่อ่านได้และส่งสำเร็จรายวันแยกตามตาราง
+// การส่งไม่สำเร็จและการส่งซ้ำ ช่วงที่โปรไฟล์หยุดทำงาน และความล่าช้าในการส่งที่นานที่สุด
+func ReportView(pipe *pipeline.Pipeline, myWindow fyne.Window) fyne.CanvasObject {
+    if pipe == nil {
+        return widget.NewLabel("รายงาน: ไม่ได้เปิดที่เก็บเหตุการณ์ (hissync.db)")
+    }
+
+    today := report.StartOfDay(time.Now())
+    fromEntry := widget.NewEntry()
+    fromEntry.SetPlaceHolder(reportDateFormat)
+    fromEntry.SetText(today.AddDate(0, 0, -6).Format(reportDateFormat))
+    toEntry := widget.NewEntry()
+    toEntry.SetPlaceHolder(reportDateFormat)
+    toEntry.SetText(today.Format(reportDateFormat))
+
+    summary := widget.NewLabel("")
+    summary.Wrapping = fyne.TextWrapWord
+
+    countChart := newChart(false, func(v float64) string { return strconv.FormatFloat(v, 'f', 0, 64) })
+    lagChart := newChart(true, func(v float64) string {
+        return time.Duration(v * float64(time.Second)).Round(time.Second).String()
+    })
+
+    var current *report.Report
+    rows := [][]string{}
+    rowHeaders := []string{"วันที่", "โปรไฟล์", "ตาราง", "คำสั่ง", "อ่านได้", "ส่งสำเร็จ", "ส่งไม่สำเร็จ", "ส่งซ้ำสำเร็จ", "ล่าช้าสูงสุด"}
+    rowTable := newReportTable(&rows, rowHeaders, []float32{100, 100, 220, 80, 80, 80, 90, 100, 100})
+    downtime := [][]string{}
+    downtimeHeaders := []string{"โปรไฟล์", "ตั้งแต่", "ถึง", "ระยะเวลา", "สาเหตุ"}
+    downtimeTable := newReportTable(&downtime, downtimeHeaders, []float32{100, 160, 160, 100, 500})
+
+    show := func(r *report.Report) {
+        current = r
+        var labels []string
+        var captured, delivered, failed, lag []float64
+        for _, d := range r.Days {
+            labels = append(labels, d.Day.Format(reportDateFormat))
+            captured = append(captured, float64(d.Captured))
+            delivered = append(delivered, float64(d.Delivered))
+            failed = append(failed, float64(d.Failed))
+            lag = append(lag, d.MaxLag.Seconds())
+        }
+        countChart.SetData(labels, []chartSeries{
+            {name: "อ่านได้", color: theme.ColorNamePrimary, values: captured},
+            {name: "ส่งสำเร็จ", color: theme.ColorNameSuccess, values: delivered},
+            {name: "ส่งไม่สำเร็จ", color: theme.ColorNameError, values: failed},
+        })
+        lagChart.SetData(labels, []chartSeries{
+            {name: "ความล่าช้าในการส่งสูงสุด", color: theme.ColorNameWarning, values: lag},
+        })
+
+        rows = rows[:0]
+        for _, rw := range r.Rows {
+            rows = append(rows, []string{rw.Day.Format(reportDateFormat), rw.Profile, rw.Table, rw.Operation,
+                strconv.Itoa(rw.Captured), strconv.Itoa(rw.Delivered), strconv.Itoa(rw.Failed),
+                strconv.Itoa(rw.Retried), rw.MaxLag.Round(time.Second).String()})
+        }
+        rowTable.Refresh()
+
+        var totalDowntime time.Duration
+        downtime = downtime[:0]
+        for _, d := range r.Downtime {
+            totalDowntime += d.Duration()
+            to := d.To.Local().Format(time.DateTime)
+            if d.Ongoing {
+                to += " (ยังไม่กลับมา)"
+            }
+            downtime = append(downtime, []string{d.Profile, d.From.Local().Format(time.DateTime), to,
+                d.Duration().Round(time.Second).String(), d.Reason})
+        }
+        downtimeTable.Refresh()
+
+        text := fmt.Sprintf("อ่านได้ %d | ส่งสำเร็จ %d | ส่งไม่สำเร็จ %d | ส่งซ้ำสำเร็จ %d | หยุดทำงาน %d ครั้ง รวม %s",
+            r.Captured, r.Delivered, r.Failed, r.Retried, len(r.Downtime), totalDowntime.Round(time.Second))
+        if lag := r.LongestLag; lag.Duration > 0 {
+            text += fmt.Sprintf("\nความล่าช้านานที่สุด %s (ปลายทาง %s ตาราง %s เมื่อ %s)",
+                lag.Duration.Round(time.Second), lag.Sink, lag.Table, lag.At.Local().Format(time.DateTime))
+        }
+        summary.SetText(text)
+    }
+
+    build := func() {
+        from, err := time.ParseInLocation(reportDateFormat, fromEntry.Text, time.Local)
+        if err != nil {
+            summary.SetText(fmt.Sprintf("วันเริ่มต้นไม่ถูกต้อง (ใช้รูปแบบ %s)", reportDateFormat))
+            return
+        }
+        to, err := time.ParseInLocation(reportDateFormat, toEntry.Text, time.Local)
+        if err != nil {
+            summary.SetText(fmt.Sprintf("วันสิ้นสุดไม่ถูกต้อง (ใช้รูปแบบ %s)", reportDateFormat))
+            return
+        }
+        summary.SetText("กำลังสร้างรายงาน...")
+        go func() {
+            r, err := report.Build(pipe.Store, from, to)
+            if err != nil {
+                summary.SetText(fmt.Sprintf("ไม่สามารถสร้างรายงาน: %v", err))
+                return
+            }
+            show(r)
+        }()
+    }
+    lastDays := func(days int) func() {
+        return func() {
+            fromEntry.SetText(today.AddDate(0, 0, 1-days).Format(reportDateFormat))
+            toEntry.SetText(today.Format(reportDateFormat))
+            build()
+        }
+    }
+
+    exportButton := widget.NewButton("ส่งออก", func() {
+        r := current
+        if r == nil {
+            return
+        }
+        showSaveAsDialog("ส่งออกรายงาน", report.Formats,
+            func(format string) string { return report.FileName(r, format) },
+            func(w io.Writer, format string) (string, error) {
+                return "ส่งออกรายงานเรียบร้อย", report.Write(w, format, r)
+            }, myWindow)
+    })
+
+    controls := container.NewHBox(
+        widget.NewLabel("ตั้งแต่"), container.NewGridWrap(fyne.NewSize(120, fromEntry.MinSize().Height), fromEntry),
+        widget.NewLabel("ถึง"), container.NewGridWrap(fyne.NewSize(120, toEntry.MinSize().Height), toEntry),
+        widget.NewButton("สร้างรายงาน", build),
+        widget.NewButton("7 วัน", lastDays(7)),
+        widget.NewButton("30 วัน", lastDays(30)),
+        exportButton,
+    )
+    tabs := container.NewAppTabs(
+        container.NewTabItem("กราฟ", container.NewGridWithRows(2, countChart, lagChart)),
+        container.NewTabItem("แยกตามตาราง", rowTable),
+        container.NewTabItem("ช่วงที่หยุดทำงาน", downtimeTable),
+    )
+    build()
+
+    return container.NewBorder(container.NewVBox(controls, summary), nil, nil, nil, tabs)
+}
+
+// newReportTable ตารางข้อความที่มีแถวหัวตาราง อ่านข้อมูลจาก *data ทุกครั้งที่ Refresh
+func newReportTable(data *[][]string, headers []string, widths []float32) *widget.Table {
+    table := widget.NewTable(
+        func() (int, int) { return len(*data) + 1, len(headers) },
+        func() fyne.CanvasObject { return widget.NewLabel("") },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            label := cell.(*widget.Label)
+            if id.Row == 0 {
+                label.TextStyle = fyne.TextStyle{Bold: true}
+                label.SetText(headers[id.Col])
+                return
+            }
+            label.TextStyle = fyne.TextStyle{}
+            label.SetText((*data)[id.Row-1][id.Col])
+        },
+    )
+    for i, width := range widths {
+        table.SetColumnWidth(i, width)
+    }
+    return table
 }
@@ -0,0 +1,51 @@
+package views
+
+import (
+    "fmt"
+    "io"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/dialog"
+    "fyne.io/fyne/v2/widget"
+)
+
+// showSaveAsDialog ให้ผู้ใช้เลือกรูปแบบไฟล์และตำแหน่งบันทึก แล้วเรียก write โดยแสดงความคืบหน้าระหว่างเขียน
+// write คืนข้อความที่แสดงเมื่อบันทึกสำเร็จ
+func showSaveAsDialog(title string, formats []string, fileName func(format string) string,
+    write func(w io.Writer, format string) (string, error), myWindow fyne.Window) {
+    formatSelect := widget.NewSelect(formats, nil)
+    formatSelect.SetSelected(formats[0])
+    dialog.ShowForm(title, "ถัดไป", "ยกเลิก",
+        []*widget.FormItem{widget.NewFormItem("รูปแบบไฟล์", formatSelect)},
+        func(ok bool) {
+            if !ok {
+                return
+            }
+            format := formatSelect.Selected
+            save := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+                if err != nil {
+                    dialog.ShowError(err, myWindow)
+                    return
+                }
+                if writer == nil {
+                    return
+                }
+                progress := dialog.NewCustomWithoutButtons(title, widget.NewProgressBarInfinite(), myWindow)
+                progress.Show()
+                go func() {
+                    message, err := write(writer, format)
+                    if closeErr := writer.Close(); err == nil {
+                        err = closeErr
+                    }
+                    progress.Hide()
+                    if err != nil {
+                        dialog.ShowError(fmt.Errorf("บันทึก %s ไม่สำเร็จ: %v", writer.URI().Name(), err), myWindow)
+                        return
+                    }
+                    dialog.ShowInformation(title, message, myWindow)
+                }()
+            }, myWindow)
+            save.SetFileName(fileName(format))
+            save.Show()
+        }, myWindow)
+}
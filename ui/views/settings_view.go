@@ -1,8 +1,368 @@
 package views
 
-import "fyne.io/fyne/v2/widget"
+import (
+    "fmt"
+    "strings"
 
-// SettingsView แสดงการตั้งค่า
-func SettingsView() *widget.Label {
-    return widget.NewLabel("การตั้งค่า")
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/dialog"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/capture"
+    config "hissync-10/functions"
+)
+
+// SettingsView เลือกตารางที่ต้องการติดตามจากโครงสร้างจริงของฐานข้อมูลต้นทาง แล้วบันทึกลง tables.json
+// primary key อ่านจาก catalog ของฐานข้อมูล (แก้ไขได้) และเลือกคอลัมน์ที่ใช้ระบุแถวของคำสั่ง INSERT ได้
+func SettingsView(manager *capture.Manager, myWindow fyne.Window) fyne.CanvasObject {
+    cfg := manager.Config()
+    if cfg == nil {
+        return widget.NewLabel("การตั้งค่า: ยังไม่ได้โหลด config.json")
+    }
+    var profiles []config.Profile
+    var names []string
+    for _, p := range cfg.Profiles {
+        if p.DBType == config.DBTypeMySQL || p.DBType == config.DBTypePostgreSQL || p.DBType == config.DBTypeSQLServer {
+            profiles = append(profiles, p)
+            names = append(names, p.Name)
+        }
+    }
+    if len(profiles) == 0 {
+        return widget.NewLabel("การตั้งค่า: ไม่มีโปรไฟล์ MySQL, PostgreSQL หรือ SQL Server")
+    }
+
+    var (
+        profile  config.Profile
+        tc       *config.TableConfig
+        database string
+        tables   []config.SchemaTable
+        visible  []int
+        current  = -1
+        dirty    bool
+    )
+
+    status := widget.NewLabel("")
+    status.Wrapping = fyne.TextWrapWord
+    saveButton := widget.NewButton("บันทึก", nil)
+    saveButton.Importance = widget.HighImportance
+    saveButton.Disable()
+
+    setDirty := func(value bool) {
+        dirty = value
+        if value {
+            status.SetText(fmt.Sprintf("เลือกไว้ %d ตาราง ยังไม่ได้บันทึก", len(tc.Tables)))
+        }
+    }
+
+    // ส่วนรายละเอียดของตารางที่เลือกในรายการ
+    detailTitle := widget.NewLabelWithStyle("เลือกตารางจากรายการ", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    detailInfo := widget.NewLabel("")
+    detailInfo.Wrapping = fyne.TextWrapWord
+    primaryKeyGroup := widget.NewCheckGroup(nil, nil)
+    keysGroup := widget.NewCheckGroup(nil, nil)
+    operationsGroup := widget.NewCheckGroup([]string{"INSERT", "UPDATE", "DELETE"}, nil)
+
+    var tableList *widget.List
+    showDetail := func() {
+        primaryKeyGroup.OnChanged, keysGroup.OnChanged, operationsGroup.OnChanged = nil, nil, nil
+        if current < 0 || current >= len(tables) {
+            detailTitle.SetText("เลือกตารางจากรายการ")
+            detailInfo.SetText("")
+            primaryKeyGroup.Options, keysGroup.Options = nil, nil
+            primaryKeyGroup.Refresh()
+            keysGroup.Refresh()
+            operationsGroup.Disable()
+            return
+        }
+        t := tables[current]
+        detailTitle.SetText(t.FullName())
+        info := "ไม่มี primary key ในฐานข้อมูล เลือกคอลัมน์ที่ระบุแถวได้ไม่ซ้ำกันเอง"
+        if pk := t.PrimaryKey(); len(pk) > 0 {
+            info = "primary key ในฐานข้อมูล: " + strings.Join(pk, ", ")
+        }
+        var types []string
+        for _, c := range t.Columns {
+            types = append(types, c.Name+" "+c.Type)
+        }
+        detailInfo.SetText(info + "\nคอลัมน์: " + strings.Join(types, ", "))
+
+        columns := t.ColumnNames()
+        primaryKeyGroup.Options, keysGroup.Options = columns, columns
+        entry := tc.Find(t.Database, t.Table)
+        if entry == nil {
+            primaryKeyGroup.SetSelected(nil)
+            keysGroup.SetSelected(nil)
+            operationsGroup.SetSelected(nil)
+            primaryKeyGroup.Disable()
+            keysGroup.Disable()
+            operationsGroup.Disable()
+            return
+        }
+        primaryKeyGroup.SetSelected(entry.PrimaryKey)
+        keysGroup.SetSelected(entry.Keys)
+        operations := operationsGroup.Options
+        if entry.Options != nil && len(entry.Options.Operations) > 0 {
+            operations = entry.Options.Operations
+        }
+        operationsGroup.SetSelected(operations)
+        primaryKeyGroup.Enable()
+        keysGroup.Enable()
+        operationsGroup.Enable()
+
+        primaryKeyGroup.OnChanged = func(selected []string) {
+            tc.Find(t.Database, t.Table).PrimaryKey = inColumnOrder(selected, columns)
+            setDirty(true)
+        }
+        keysGroup.OnChanged = func(selected []string) {
+            tc.Find(t.Database, t.Table).Keys = inColumnOrder(selected, columns)
+            setDirty(true)
+        }
+        operationsGroup.OnChanged = func(selected []string) {
+            e := tc.Find(t.Database, t.Table)
+            if len(selected) == 0 || len(selected) == len(operationsGroup.Options) {
+                e.Options = nil
+            } else {
+                e.Options = &config.TableOptions{Operations: inColumnOrder(selected, operationsGroup.Options)}
+            }
+            setDirty(true)
+        }
+    }
+
+    // setTracked เพิ่มหรือเอาตารางออกจาก tables.json ตารางที่เพิ่มใหม่ใช้ primary key จากฐานข้อมูล
+    setTracked := func(t config.SchemaTable, tracked bool) {
+        exists := tc.Find(t.Database, t.Table) != nil
+        switch {
+        case tracked && !exists:
+            pk := t.PrimaryKey()
+            tc.Tables = append(tc.Tables, config.TableEntry{Database: t.Database, Table: t.Table,
+                PrimaryKey: pk, Keys: append([]string(nil), pk...)})
+        case !tracked && exists:
+            kept := tc.Tables[:0]
+            for _, e := range tc.Tables {
+                if e.Database != t.Database || e.Table != t.Table {
+                    kept = append(kept, e)
+                }
+            }
+            tc.Tables = kept
+        default:
+            return
+        }
+        setDirty(true)
+    }
+
+    searchEntry := widget.NewEntry()
+    searchEntry.SetPlaceHolder("ค้นหาตาราง")
+    onlyTracked := widget.NewCheck("เฉพาะที่เลือก", nil)
+    filterTables := func() {
+        visible = visible[:0]
+        for i, t := range tables {
+            if onlyTracked.Checked && tc.Find(t.Database, t.Table) == nil {
+                continue
+            }
+            if search := strings.ToLower(searchEntry.Text); search != "" && !strings.Contains(strings.ToLower(t.Table), search) {
+                continue
+            }
+            visible = append(visible, i)
+        }
+        tableList.UnselectAll()
+        tableList.Refresh()
+    }
+    searchEntry.OnChanged = func(string) { filterTables() }
+    onlyTracked.OnChanged = func(bool) { filterTables() }
+
+    tableList = widget.NewList(
+        func() int { return len(visible) },
+        func() fyne.CanvasObject {
+            return container.NewHBox(widget.NewCheck("", nil), widget.NewLabel(""))
+        },
+        func(id widget.ListItemID, item fyne.CanvasObject) {
+            row := item.(*fyne.Container)
+            check := row.Objects[0].(*widget.Check)
+            label := row.Objects[1].(*widget.Label)
+            index := visible[id]
+            t := tables[index]
+            label.SetText(fmt.Sprintf("%s (%d คอลัมน์)", t.Table, len(t.Columns)))
+            check.OnChanged = nil
+            check.SetChecked(tc.Find(t.Database, t.Table) != nil)
+            check.OnChanged = func(tracked bool) {
+                setTracked(t, tracked)
+                if index == current {
+                    showDetail()
+                }
+            }
+        },
+    )
+    tableList.OnSelected = func(id widget.ListItemID) {
+        current = visible[id]
+        showDetail()
+    }
+
+    setAllVisible := func(tracked bool) {
+        for _, i := range visible {
+            setTracked(tables[i], tracked)
+        }
+        filterTables()
+        showDetail()
+    }
+    selectAllButton := widget.NewButton("เลือกทั้งหมดที่แสดง", func() { setAllVisible(true) })
+    clearAllButton := widget.NewButton("ยกเลิกทั้งหมดที่แสดง", func() { setAllVisible(false) })
+
+    databaseSelect := widget.NewSelect(nil, nil)
+    databaseSelect.PlaceHolder = "เลือกฐานข้อมูล"
+    databaseSelect.OnChanged = func(name string) {
+        if name == "" {
+            return
+        }
+        database = name
+        status.SetText("กำลังอ่านตารางของ " + name + "...")
+        target := profile
+        go func() {
+            list, err := config.ListTables(&target, name)
+            if err != nil {
+                status.SetText(err.Error())
+                return
+            }
+            tables, current = list, -1
+            filterTables()
+            showDetail()
+
+            known := make(map[string]bool)
+            for _, t := range tables {
+                known[t.Table] = true
+            }
+            var missing []string
+            for _, e := range tc.ForDatabase(database) {
+                if !known[e.Table] {
+                    missing = append(missing, e.Table)
+                }
+            }
+            text := fmt.Sprintf("%s มี %d ตาราง เลือกไว้ %d ตาราง", name, len(tables), len(tc.ForDatabase(name)))
+            if len(missing) > 0 {
+                text += "\nตารางใน " + profile.TableConfigFile + " ที่ไม่พบในฐานข้อมูล: " + strings.Join(missing, ", ")
+            }
+            status.SetText(text)
+        }()
+    }
+
+    connect := func() {
+        status.SetText("กำลังเชื่อมต่อ " + profile.Name + "...")
+        target := profile
+        go func() {
+            databases, err := config.ListDatabases(&target)
+            if err != nil {
+                status.SetText(err.Error())
+                return
+            }
+            databaseSelect.Options = databases
+            databaseSelect.Refresh()
+            for _, name := range databases {
+                if name == target.DBName {
+                    databaseSelect.SetSelected(name)
+                    return
+                }
+            }
+            status.SetText(fmt.Sprintf("พบ %d ฐานข้อมูล เลือกฐานข้อมูลที่ต้องการ", len(databases)))
+        }()
+    }
+    connectButton := widget.NewButton("อ่านโครงสร้างฐานข้อมูล", connect)
+
+    loadProfile := func(name string) {
+        for _, p := range profiles {
+            if p.Name == name {
+                profile = p
+            }
+        }
+        loaded, err := config.LoadTableConfig(profile.TableConfigFile)
+        tables, visible, current = nil, nil, -1
+        databaseSelect.Options = nil
+        databaseSelect.ClearSelected()
+        tableList.Refresh()
+        showDetail()
+        if err != nil {
+            tc = nil
+            saveButton.Disable()
+            connectButton.Disable()
+            status.SetText(fmt.Sprintf("ไม่สามารถโหลด %s แก้ไขไฟล์ให้ถูกต้องก่อน: %v", profile.TableConfigFile, err))
+            return
+        }
+        tc = loaded
+        dirty = false
+        saveButton.Enable()
+        connectButton.Enable()
+        status.SetText(fmt.Sprintf("%s: ติดตาม %d ตาราง กด \"อ่านโครงสร้างฐานข้อมูล\" เพื่อเลือกตาราง",
+            profile.TableConfigFile, len(tc.Tables)))
+    }
+    profileSelect := widget.NewSelect(names, nil)
+
+    saveButton.OnTapped = func() {
+        if err := config.SaveTableConfig(profile.TableConfigFile, tc); err != nil {
+            dialog.ShowError(err, myWindow)
+            return
+        }
+        dirty = false
+        // โหลด tables.json ใหม่เฉพาะโปรไฟล์ที่ใช้ไฟล์เดียวกัน
+        var restarted []string
+        for _, p := range manager.Config().Profiles {
+            if p.TableConfigFile == profile.TableConfigFile && !p.Disabled {
+                manager.Restart(p.Name)
+                restarted = append(restarted, p.Name)
+            }
+        }
+        status.SetText(fmt.Sprintf("บันทึก %s แล้ว (%d ตาราง) เริ่มโปรไฟล์ใหม่: %s",
+            profile.TableConfigFile, len(tc.Tables), strings.Join(restarted, ", ")))
+    }
+
+    profileSelect.OnChanged = func(name string) {
+        if !dirty || name == profile.Name {
+            loadProfile(name)
+            return
+        }
+        dialog.ShowConfirm("ยังไม่ได้บันทึก", "ละทิ้งการเปลี่ยนแปลงของ "+profile.Name+" หรือไม่?", func(discard bool) {
+            if !discard {
+                profileSelect.SetSelected(profile.Name)
+                return
+            }
+            loadProfile(name)
+        }, myWindow)
+    }
+    profileSelect.SetSelected(names[0])
+
+    detail := container.NewVBox(
+        detailTitle,
+        detailInfo,
+        widget.NewLabelWithStyle("Primary key (UPDATE/DELETE)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+        primaryKeyGroup,
+        widget.NewLabelWithStyle("คอลัมน์ระบุแถว (INSERT)", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+        keysGroup,
+        widget.NewLabelWithStyle("คำสั่งที่เก็บ", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+        operationsGroup,
+    )
+    left := container.NewBorder(
+        container.NewVBox(searchEntry, container.NewHBox(onlyTracked, selectAllButton, clearAllButton)),
+        nil, nil, nil, tableList)
+    split := container.NewHSplit(left, container.NewVScroll(detail))
+    split.SetOffset(0.4)
+
+    top := container.NewVBox(
+        container.NewHBox(widget.NewLabel("โปรไฟล์"), profileSelect, connectButton,
+            widget.NewLabel("ฐานข้อมูล"), databaseSelect, saveButton),
+        status,
+    )
+    return container.NewBorder(top, nil, nil, nil, split)
+}
+
+// inColumnOrder เรียง selected ตามลำดับใน columns (CheckGroup คืนตามลำดับที่ติ๊ก)
+func inColumnOrder(selected, columns []string) []string {
+    chosen := make(map[string]bool, len(selected))
+    for _, s := range selected {
+        chosen[s] = true
+    }
+    var ordered []string
+    for _, c := range columns {
+        if chosen[c] {
+            ordered = append(ordered, c)
+        }
+    }
+    return ordered
 }
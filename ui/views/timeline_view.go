@@ -0,0 +1,107 @@
+package views
+
+import (
+    "fmt"
+    "strings"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/pipeline"
+    "hissync-10/store"
+)
+
+// TimelineView ประวัติการเปลี่ยนแปลงรายบุคคล: ค้นด้วยรหัสบุคคลหรือเลขประจำตัวประชาชน
+// แสดงเหตุการณ์ของทุกตารางที่กำหนด person ใน tables.json ตามลำดับเวลา เลือกแถวเพื่อดูรายละเอียด
+func TimelineView(pipe *pipeline.Pipeline, myWindow fyne.Window) fyne.CanvasObject {
+    if pipe == nil {
+        return widget.NewLabel("ประวัติรายบุคคล: ไม่ได้เปิดที่เก็บเหตุการณ์ (hissync.db)")
+    }
+
+    var records []store.Record
+    columns := []eventColumn{
+        {title: "เวลา", width: 160, value: func(r store.Record) string { return r.Time.Format("2006-01-02 15:04:05") }},
+        {title: "โปรไฟล์", width: 120, value: func(r store.Record) string { return r.Profile }},
+        {title: "ตาราง", width: 180, value: func(r store.Record) string { return r.FullTableName() }},
+        {title: "การเปลี่ยนแปลง", width: 90, value: func(r store.Record) string { return r.Operation }},
+        {title: "คอลัมน์ที่เปลี่ยน", width: 300, value: changedColumns},
+        {title: "ผู้ทำรายการ", width: 200, value: auditText},
+    }
+
+    detail, showDetail := newEventDetail(myWindow)
+    table := widget.NewTable(
+        func() (int, int) { return len(records), len(columns) },
+        func() fyne.CanvasObject {
+            label := widget.NewLabel("")
+            label.Truncation = fyne.TextTruncateEllipsis
+            return label
+        },
+        func(id widget.TableCellID, cell fyne.CanvasObject) {
+            if id.Row >= len(records) {
+                return
+            }
+            cell.(*widget.Label).SetText(columns[id.Col].value(records[id.Row]))
+        },
+    )
+    table.ShowHeaderRow = true
+    table.CreateHeader = func() fyne.CanvasObject {
+        return widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+    }
+    table.UpdateHeader = func(id widget.TableCellID, cell fyne.CanvasObject) {
+        if id.Col >= 0 && id.Col < len(columns) {
+            cell.(*widget.Label).SetText(columns[id.Col].title)
+        }
+    }
+    for i, col := range columns {
+        table.SetColumnWidth(i, col.width)
+    }
+    table.OnSelected = func(id widget.TableCellID) {
+        if id.Row >= 0 && id.Row < len(records) {
+            showDetail(records[id.Row])
+        }
+    }
+
+    info := widget.NewLabel("ใส่รหัสบุคคล (เช่น pid) หรือเลขประจำตัวประชาชน แล้วกดค้นหา")
+    searchEntry := widget.NewEntry()
+    searchEntry.SetPlaceHolder("รหัสบุคคลหรือเลขประจำตัวประชาชน")
+    search := func() {
+        id := strings.TrimSpace(searchEntry.Text)
+        if id == "" {
+            return
+        }
+        found, err := pipe.Store.PersonTimeline(id)
+        if err != nil {
+            info.SetText(err.Error())
+            return
+        }
+        records = found
+        table.UnselectAll()
+        table.Refresh()
+        table.ScrollToTop()
+        if len(records) == 0 {
+            info.SetText(fmt.Sprintf("ไม่พบประวัติของ %s (ตารางต้องกำหนด person ใน tables.json)", id))
+            return
+        }
+        info.SetText(fmt.Sprintf("พบ %d รายการของ %s ตั้งแต่ %s ถึง %s", len(records), id,
+            records[0].Time.Format("2006-01-02 15:04"), records[len(records)-1].Time.Format("2006-01-02 15:04")))
+    }
+    searchEntry.OnSubmitted = func(string) { search() }
+    searchButton := widget.NewButton("ค้นหา", search)
+
+    top := container.NewVBox(container.NewBorder(nil, nil, nil, searchButton, searchEntry), info)
+    split := container.NewVSplit(table, detail)
+    split.SetOffset(0.6)
+    return container.NewBorder(top, nil, nil, nil, split)
+}
+
+// changedColumns ชื่อคอลัมน์ที่ค่าเปลี่ยนในเหตุการณ์ คั่นด้วยจุลภาค
+func changedColumns(rec store.Record) string {
+    var names []string
+    for _, c := range rec.Columns {
+        if c.Changed() {
+            names = append(names, c.Name)
+        }
+    }
+    return strings.Join(names, ", ")
+}
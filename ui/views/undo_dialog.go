@@ -0,0 +1,142 @@
+package views
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "strings"
+
+    "fyne.io/fyne/v2"
+    "fyne.io/fyne/v2/container"
+    "fyne.io/fyne/v2/dialog"
+    "fyne.io/fyne/v2/widget"
+
+    "hissync-10/pipeline"
+    "hissync-10/store"
+    "hissync-10/undo"
+)
+
+// ขอบเขตของเหตุการณ์ที่ย้อนกลับ
+const (
+    undoScopeFilter      = "ทุกเหตุการณ์ที่ตรงกับตัวกรอง"
+    undoScopeTransaction = "ธุรกรรมของเหตุการณ์ที่เลือก"
+    undoScopeSelected    = "เฉพาะเหตุการณ์ที่เลือก"
+)
+
+// showUndoDialog แสดงคำสั่งย้อนกลับของเหตุการณ์ตามตัวกรอง ธุรกรรม หรือเหตุการณ์ที่เลือก (selected อาจเป็นค่าว่าง)
+// บันทึกเป็นไฟล์ SQL หรือคัดลอกได้ และใช้กับฐานข้อมูลต้นทางได้เมื่อพิมพ์ชื่อโปรไฟล์ยืนยัน
+func showUndoDialog(pipe *pipeline.Pipeline, profile string, filter store.Filter, selected store.Record, myWindow fyne.Window) {
+    scopes := []string{undoScopeFilter}
+    if selected.Seq != 0 {
+        if selected.Transaction != "" {
+            scopes = append(scopes, undoScopeTransaction)
+        }
+        scopes = append(scopes, undoScopeSelected)
+    }
+
+    var script undo.Script
+    summary := widget.NewLabel("")
+    preview := widget.NewMultiLineEntry()
+    preview.Wrapping = fyne.TextWrapOff
+    preview.SetMinRowsVisible(16)
+    copyButton := widget.NewButton("คัดลอก", func() {
+        myWindow.Clipboard().SetContent(script.Text())
+    })
+    saveButton := widget.NewButton("บันทึกเป็นไฟล์", func() {
+        showSaveAsDialog("บันทึกคำสั่งย้อนกลับ", []string{"sql"},
+            func(string) string { return profile + "_undo.sql" },
+            func(w io.Writer, _ string) (string, error) {
+                _, err := io.WriteString(w, script.Text())
+                return fmt.Sprintf("บันทึกคำสั่งย้อนกลับ %d รายการ", len(script.Statements)), err
+            }, myWindow)
+    })
+    applyButton := widget.NewButton("ใช้กับต้นทาง", func() {
+        confirmUndo(pipe, script, myWindow)
+    })
+    applyButton.Importance = widget.DangerImportance
+
+    build := func(scope string) {
+        var records []store.Record
+        var err error
+        switch scope {
+        case undoScopeSelected:
+            records = []store.Record{selected}
+        case undoScopeTransaction:
+            records, err = undo.Collect(pipe.Store, store.Filter{Profile: profile, Transaction: selected.Transaction})
+        default:
+            f := filter
+            f.Profile = profile
+            records, err = undo.Collect(pipe.Store, f)
+        }
+        if err != nil {
+            script = undo.Script{}
+            summary.SetText(err.Error())
+            preview.SetText("")
+        } else {
+            script = undo.Build(records)
+            text := fmt.Sprintf("เหตุการณ์ %d รายการ สร้างคำสั่งย้อนกลับได้ %d รายการ", len(records), len(script.Statements))
+            if len(script.Skipped) > 0 {
+                text += fmt.Sprintf(" ข้าม %d รายการ (ดูเหตุผลในหมายเหตุ)", len(script.Skipped))
+            }
+            summary.SetText(text)
+            preview.SetText(script.Text())
+        }
+        for _, button := range []*widget.Button{copyButton, saveButton, applyButton} {
+            if len(script.Statements) == 0 {
+                button.Disable()
+            } else {
+                button.Enable()
+            }
+        }
+    }
+    scopeSelect := widget.NewRadioGroup(scopes, build)
+    scopeSelect.Horizontal = true
+    scopeSelect.SetSelected(scopes[len(scopes)-1])
+
+    note := widget.NewLabel("คำสั่งเรียงจากเหตุการณ์ใหม่ไปเก่า UPDATE คืนค่าเฉพาะเมื่อแถวยังมีค่าหลังการเปลี่ยนอยู่")
+    note.Wrapping = fyne.TextWrapWord
+    top := container.NewVBox(scopeSelect, summary)
+    bottom := container.NewVBox(note, container.NewHBox(copyButton, saveButton, applyButton))
+    content := container.NewBorder(top, bottom, nil, nil, preview)
+    d := dialog.NewCustom("ย้อนกลับการเปลี่ยนแปลง "+profile, "ปิด", content, myWindow)
+    d.Resize(fyne.NewSize(900, 600))
+    d.Show()
+}
+
+// confirmUndo ให้ผู้ใช้พิมพ์ชื่อโปรไฟล์ยืนยันก่อนใช้คำสั่งย้อนกลับกับฐานข้อมูลต้นทางในธุรกรรมเดียว
+func confirmUndo(pipe *pipeline.Pipeline, script undo.Script, myWindow fyne.Window) {
+    cfg := pipe.Manager.Config()
+    if cfg == nil || cfg.Profile(script.Profile) == nil {
+        dialog.ShowError(fmt.Errorf("ไม่พบโปรไฟล์ %s ใน config.json", script.Profile), myWindow)
+        return
+    }
+    p := *cfg.Profile(script.Profile)
+
+    nameEntry := widget.NewEntry()
+    nameEntry.SetPlaceHolder(p.Name)
+    warning := widget.NewLabel(fmt.Sprintf("จะใช้คำสั่ง %d รายการกับฐานข้อมูล %s ของ %s ในธุรกรรมเดียว\n"+
+        "ถ้าแถวใดถูกแก้ไขหลังจากนั้นจะยกเลิกทั้งหมด พิมพ์ชื่อโปรไฟล์เพื่อยืนยัน", len(script.Statements), p.DBName, p.Host))
+    dialog.ShowForm("ยืนยันการย้อนกลับ", "ใช้กับต้นทาง", "ยกเลิก",
+        []*widget.FormItem{widget.NewFormItem("", warning), widget.NewFormItem("ชื่อโปรไฟล์", nameEntry)},
+        func(ok bool) {
+            if !ok {
+                return
+            }
+            if strings.TrimSpace(nameEntry.Text) != p.Name {
+                dialog.ShowError(fmt.Errorf("ชื่อโปรไฟล์ไม่ตรง ยกเลิกการย้อนกลับ"), myWindow)
+                return
+            }
+            progress := dialog.NewCustomWithoutButtons("ย้อนกลับการเปลี่ยนแปลง", widget.NewProgressBarInfinite(), myWindow)
+            progress.Show()
+            go func() {
+                err := undo.Apply(context.Background(), &p, script)
+                progress.Hide()
+                if err != nil {
+                    dialog.ShowError(err, myWindow)
+                    return
+                }
+                dialog.ShowInformation("ย้อนกลับการเปลี่ยนแปลง",
+                    fmt.Sprintf("ย้อนกลับ %d รายการแล้ว การเปลี่ยนแปลงนี้จะถูกอ่านเป็นเหตุการณ์ใหม่ด้วย", len(script.Statements)), myWindow)
+            }()
+        }, myWindow)
+}
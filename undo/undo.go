@@ -0,0 +1,303 @@
+package undo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+// MaxEvents จำนวนเหตุการณ์สูงสุดที่ย้อนกลับได้ในครั้งเดียว ให้กรองให้แคบลงถ้าเกิน
+const MaxEvents = 10000
+
+// Statement คำสั่งย้อนกลับของเหตุการณ์หนึ่งรายการ
+type Statement struct {
+	Seq uint64
+	SQL string
+}
+
+// Skipped เหตุการณ์ที่สร้างคำสั่งย้อนกลับไม่ได้ พร้อมเหตุผล
+type Skipped struct {
+	Record store.Record
+	Reason string
+}
+
+// Script คำสั่งย้อนกลับเรียงจากเหตุการณ์ใหม่ไปเก่า ต้องใช้ตามลำดับนี้ในธุรกรรมเดียว
+type Script struct {
+	Profile    string
+	Source     string
+	Statements []Statement
+	Skipped    []Skipped
+}
+
+// Collect คืนเหตุการณ์ที่ตรงกับ filter สำหรับย้อนกลับ เหตุการณ์ต้องมาจากโปรไฟล์เดียว (filter.Profile)
+func Collect(st *store.Store, filter store.Filter) ([]store.Record, error) {
+	if filter.Profile == "" {
+		return nil, fmt.Errorf("ต้องระบุโปรไฟล์ของเหตุการณ์ที่จะย้อนกลับ")
+	}
+	var records []store.Record
+	err := st.Each(func(rec store.Record) error {
+		if !filter.Match(rec) {
+			return nil
+		}
+		if len(records) == MaxEvents {
+			return fmt.Errorf("เหตุการณ์ที่เลือกเกิน %d รายการ กรองตามเวลา ตาราง หรือธุรกรรมให้แคบลง", MaxEvents)
+		}
+		records = append(records, rec)
+		return nil
+	})
+	return records, err
+}
+
+// Build สร้างคำสั่งย้อนกลับจากค่าก่อนและหลังที่บันทึกไว้
+// INSERT ย้อนด้วย DELETE, DELETE ย้อนด้วย INSERT ทุกคอลัมน์ และ UPDATE คืนค่าเดิมของคอลัมน์ที่เปลี่ยน
+// UPDATE มีเงื่อนไขว่าแถวยังมีค่าหลังการเปลี่ยนอยู่ จึงไม่ทับการแก้ไขที่เกิดขึ้นภายหลัง
+func Build(records []store.Record) Script {
+	sorted := append([]store.Record(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Seq > sorted[j].Seq })
+
+	var script Script
+	for _, rec := range sorted {
+		if script.Profile == "" {
+			script.Profile = rec.Profile
+		}
+		if rec.Profile != script.Profile {
+			script.Skipped = append(script.Skipped, Skipped{rec, "อยู่คนละโปรไฟล์กับเหตุการณ์อื่น"})
+			continue
+		}
+		stmt, err := inverse(rec)
+		if err != nil {
+			script.Skipped = append(script.Skipped, Skipped{rec, err.Error()})
+			continue
+		}
+		script.Source = rec.Source
+		script.Statements = append(script.Statements, Statement{rec.Seq, stmt})
+	}
+	return script
+}
+
+// Text คำสั่งย้อนกลับทั้งหมดในรูปแบบไฟล์ SQL พร้อมหมายเหตุของเหตุการณ์ที่ข้าม
+func (s Script) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- คำสั่งย้อนกลับ %d รายการของโปรไฟล์ %s สร้างเมื่อ %s\n",
+		len(s.Statements), s.Profile, time.Now().Format("2006-01-02 15:04:05"))
+	for _, skipped := range s.Skipped {
+		fmt.Fprintf(&b, "-- ข้ามเหตุการณ์ #%d %s %s: %s\n",
+			skipped.Record.Seq, skipped.Record.Operation, skipped.Record.FullTableName(), skipped.Reason)
+	}
+	if len(s.Statements) == 0 {
+		return b.String()
+	}
+	if s.Source == config.DBTypePostgreSQL {
+		b.WriteString("BEGIN;\n")
+	} else {
+		b.WriteString("START TRANSACTION;\n")
+	}
+	for _, stmt := range s.Statements {
+		fmt.Fprintf(&b, "-- #%d\n%s\n", stmt.Seq, stmt.SQL)
+	}
+	b.WriteString("COMMIT;\n")
+	return b.String()
+}
+
+// Apply ใช้คำสั่งย้อนกลับกับฐานข้อมูลต้นทางของโปรไฟล์ในธุรกรรมเดียว
+// ถ้าคำสั่งใดผิดพลาดหรือไม่ได้เปลี่ยนแถวพอดีหนึ่งแถว (แถวถูกแก้ไขหรือลบไปแล้ว) จะยกเลิกทั้งหมด
+// ผู้ใช้ของโปรไฟล์ต้องมีสิทธิ์ INSERT, UPDATE และ DELETE ในตารางเหล่านั้น
+func Apply(ctx context.Context, p *config.Profile, s Script) error {
+	if p.Name != s.Profile {
+		return fmt.Errorf("คำสั่งย้อนกลับเป็นของโปรไฟล์ %s ไม่ใช่ %s", s.Profile, p.Name)
+	}
+	if len(s.Statements) == 0 {
+		return fmt.Errorf("ไม่มีคำสั่งย้อนกลับ")
+	}
+	db, err := config.OpenDB(p)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ไม่สามารถเริ่มธุรกรรม: %v", config.RedactError(err))
+	}
+	defer tx.Rollback()
+	for _, stmt := range s.Statements {
+		if err := execOne(ctx, tx, stmt); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ไม่สามารถยืนยันธุรกรรม: %v", config.RedactError(err))
+	}
+	return nil
+}
+
+func execOne(ctx context.Context, tx *sql.Tx, stmt Statement) error {
+	result, err := tx.ExecContext(ctx, stmt.SQL)
+	if err != nil {
+		return fmt.Errorf("ย้อนเหตุการณ์ #%d ไม่สำเร็จ ยกเลิกทั้งหมด: %v", stmt.Seq, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n != 1 {
+		return fmt.Errorf("ย้อนเหตุการณ์ #%d เปลี่ยน %d แถวแทนที่จะเป็น 1 แถว (แถวถูกแก้ไขหลังจากนั้น) ยกเลิกทั้งหมด", stmt.Seq, n)
+	}
+	return nil
+}
+
+// inverse คำสั่งที่ย้อนการเปลี่ยนแปลงของเหตุการณ์หนึ่งรายการ
+func inverse(rec store.Record) (string, error) {
+	d, err := dialectFor(rec.Source)
+	if err != nil {
+		return "", err
+	}
+	if len(rec.Columns) == 0 {
+		return "", fmt.Errorf("ต้นทางไม่ได้บันทึกค่าของคอลัมน์")
+	}
+	for _, c := range rec.Columns {
+		if strings.HasPrefix(c.Name, "@") {
+			return "", fmt.Errorf("ไม่ทราบชื่อคอลัมน์ (%s)", c.Name)
+		}
+	}
+	table := d.ident(rec.Database) + "." + d.ident(rec.Table)
+	if rec.Source == config.DBTypePostgreSQL {
+		// database ของ PostgreSQL คือฐานข้อมูลที่เชื่อมต่อ ตารางอาจมี schema นำหน้า
+		parts := strings.Split(rec.Table, ".")
+		for i, part := range parts {
+			parts[i] = d.ident(part)
+		}
+		table = strings.Join(parts, ".")
+	}
+
+	switch rec.Operation {
+	case "INSERT":
+		where, err := d.where(rec)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("DELETE FROM %s WHERE %s;", table, where), nil
+
+	case "DELETE":
+		if !hasBeforeImage(rec) {
+			return "", fmt.Errorf("ต้นทางไม่ได้บันทึกค่าเดิมของแถว")
+		}
+		names := make([]string, len(rec.Columns))
+		values := make([]string, len(rec.Columns))
+		for i, c := range rec.Columns {
+			names[i] = d.ident(c.Name)
+			values[i] = d.literal(c.Before)
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", table, strings.Join(names, ", "), strings.Join(values, ", ")), nil
+
+	case "UPDATE":
+		if !hasBeforeImage(rec) {
+			return "", fmt.Errorf("ต้นทางไม่ได้บันทึกค่าเดิมของแถว")
+		}
+		var set, guard []string
+		for _, c := range rec.Columns {
+			if c.Changed() {
+				set = append(set, d.ident(c.Name)+" = "+d.literal(c.Before))
+				guard = append(guard, d.condition(c.Name, c.After))
+			}
+		}
+		if len(set) == 0 {
+			return "", fmt.Errorf("ไม่มีคอลัมน์ที่ค่าเปลี่ยน")
+		}
+		where, err := d.where(rec)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s AND %s;", table, strings.Join(set, ", "), where, strings.Join(guard, " AND ")), nil
+	}
+	return "", fmt.Errorf("ไม่รู้จักประเภทคำสั่ง %q", rec.Operation)
+}
+
+// hasBeforeImage บอกว่าต้นทางบันทึกค่าเดิมของแถวไว้หรือไม่ (ไฟล์ Log ของ PostgreSQL ไม่มีค่าเดิม)
+func hasBeforeImage(rec store.Record) bool {
+	return rec.Source == config.DBTypeMySQL
+}
+
+// dialect การเขียนชื่อและค่าตามฐานข้อมูลต้นทาง
+type dialect struct {
+	quote   string
+	escaper *strings.Replacer
+}
+
+var (
+	mysqlDialect    = dialect{"`", strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`)}
+	postgresDialect = dialect{`"`, strings.NewReplacer(`'`, `''`)}
+)
+
+func dialectFor(source string) (dialect, error) {
+	switch source {
+	case config.DBTypeMySQL:
+		return mysqlDialect, nil
+	case config.DBTypePostgreSQL:
+		return postgresDialect, nil
+	}
+	return dialect{}, fmt.Errorf("ไม่รองรับการย้อนกลับของ %s", source)
+}
+
+func (d dialect) ident(name string) string {
+	return d.quote + strings.ReplaceAll(name, d.quote, d.quote+d.quote) + d.quote
+}
+
+func (d dialect) literal(v *string) string {
+	if v == nil {
+		return "NULL"
+	}
+	return "'" + d.escaper.Replace(*v) + "'"
+}
+
+func (d dialect) condition(name string, v *string) string {
+	if v == nil {
+		return d.ident(name) + " IS NULL"
+	}
+	return d.ident(name) + " = " + d.literal(v)
+}
+
+// where เงื่อนไขระบุแถวปัจจุบันจาก primary key ด้วยค่าหลังการเปลี่ยนของคอลัมน์
+// ถ้าคอลัมน์ primary key ไม่อยู่ในเหตุการณ์ ใช้ค่าใน primary key ที่บันทึกไว้
+func (d dialect) where(rec store.Record) (string, error) {
+	keys := map[string]interface{}{}
+	if rec.PrimaryKey != "" {
+		dec := json.NewDecoder(strings.NewReader(rec.PrimaryKey))
+		dec.UseNumber()
+		if err := dec.Decode(&keys); err != nil {
+			return "", fmt.Errorf("primary key %q ไม่ใช่ JSON", rec.PrimaryKey)
+		}
+	}
+	if len(keys) == 0 {
+		return "", fmt.Errorf("ตารางไม่มี primary key ระบุแถวไม่ได้")
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conds := make([]string, 0, len(names))
+	for _, name := range names {
+		value, found := afterValue(rec, name)
+		if !found && keys[name] != nil {
+			text := fmt.Sprint(keys[name])
+			value = &text
+		}
+		conds = append(conds, d.condition(name, value))
+	}
+	return strings.Join(conds, " AND "), nil
+}
+
+// afterValue ค่าหลังการเปลี่ยนของคอลัมน์ตามชื่อ (ไม่สนตัวพิมพ์เล็กใหญ่)
+func afterValue(rec store.Record, name string) (*string, bool) {
+	for _, c := range rec.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c.After, true
+		}
+	}
+	return nil, false
+}
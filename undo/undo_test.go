@@ -0,0 +1,194 @@
+package undo
+
+import (
+	"strings"
+	"testing"
+
+	"hissync-10/capture"
+	config "hissync-10/functions"
+	"hissync-10/store"
+)
+
+func str(s string) *string { return &s }
+
+func record(seq uint64, source, op string, pk string, cols ...capture.ColumnValue) store.Record {
+	return store.Record{Seq: seq, Event: capture.Event{
+		Profile: "jhcis", Source: source, Database: "jhcis", Table: "person",
+		Operation: op, PrimaryKey: pk, Columns: cols,
+	}}
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		name    string
+		rec     store.Record
+		want    string
+		wantErr string
+	}{
+		{
+			name: "mysql insert",
+			rec: record(1, config.DBTypeMySQL, "INSERT", `{"pid":1}`,
+				capture.ColumnValue{Name: "pid", After: str("1")},
+				capture.ColumnValue{Name: "fname", After: str("สมชาย")}),
+			want: "DELETE FROM `jhcis`.`person` WHERE `pid` = '1';",
+		},
+		{
+			name: "mysql delete",
+			rec: record(1, config.DBTypeMySQL, "DELETE", `{"pid":1}`,
+				capture.ColumnValue{Name: "pid", Before: str("1")},
+				capture.ColumnValue{Name: "fname", Before: str("O'Brien")},
+				capture.ColumnValue{Name: "note", Before: nil}),
+			want: "INSERT INTO `jhcis`.`person` (`pid`, `fname`, `note`) VALUES ('1', 'O\\'Brien', NULL);",
+		},
+		{
+			name: "mysql update restores changed columns only",
+			rec: record(1, config.DBTypeMySQL, "UPDATE", `{"pid":1}`,
+				capture.ColumnValue{Name: "pid", Before: str("1"), After: str("1")},
+				capture.ColumnValue{Name: "fname", Before: str("a"), After: str("b")},
+				capture.ColumnValue{Name: "note", Before: str("x"), After: nil}),
+			want: "UPDATE `jhcis`.`person` SET `fname` = 'a', `note` = 'x' WHERE `pid` = '1' AND `fname` = 'b' AND `note` IS NULL;",
+		},
+		{
+			name: "mysql update of primary key uses value after",
+			rec: record(1, config.DBTypeMySQL, "UPDATE", `{"pid":1}`,
+				capture.ColumnValue{Name: "PID", Before: str("1"), After: str("2")}),
+			want: "UPDATE `jhcis`.`person` SET `PID` = '1' WHERE `pid` = '2' AND `PID` = '2';",
+		},
+		{
+			name: "composite key sorted and taken from primary key",
+			rec: record(1, config.DBTypeMySQL, "INSERT", `{"visitno":7,"pcucode":"0001"}`,
+				capture.ColumnValue{Name: "note", After: str("n")}),
+			want: "DELETE FROM `jhcis`.`person` WHERE `pcucode` = '0001' AND `visitno` = '7';",
+		},
+		{
+			name: "escape identifiers and backslash",
+			rec: record(1, config.DBTypeMySQL, "DELETE", `{"id":1}`,
+				capture.ColumnValue{Name: "we`ird", Before: str(`a\b`)},
+				capture.ColumnValue{Name: "id", Before: str("1")}),
+			want: "INSERT INTO `jhcis`.`person` (`we``ird`, `id`) VALUES ('a\\\\b', '1');",
+		},
+		{
+			name: "postgres insert with schema",
+			rec: store.Record{Seq: 1, Event: capture.Event{
+				Profile: "hosxp", Source: config.DBTypePostgreSQL, Database: "hosxp", Table: "public.ovst",
+				Operation: "INSERT", PrimaryKey: `{"vn":"670101"}`,
+				Columns: []capture.ColumnValue{{Name: "vn", After: str("670101")}, {Name: "note", After: str("it's")}},
+			}},
+			want: `DELETE FROM "public"."ovst" WHERE "vn" = '670101';`,
+		},
+		{
+			name: "postgres update has no before image",
+			rec: record(1, config.DBTypePostgreSQL, "UPDATE", `{"pid":1}`,
+				capture.ColumnValue{Name: "fname", After: str("b")}),
+			wantErr: "ต้นทางไม่ได้บันทึกค่าเดิมของแถว",
+		},
+		{
+			name:    "no columns",
+			rec:     record(1, config.DBTypeMySQL, "INSERT", `{"pid":1}`),
+			wantErr: "ต้นทางไม่ได้บันทึกค่าของคอลัมน์",
+		},
+		{
+			name: "unknown column names",
+			rec: record(1, config.DBTypeMySQL, "INSERT", `{"pid":1}`,
+				capture.ColumnValue{Name: "@1", After: str("1")}),
+			wantErr: "ไม่ทราบชื่อคอลัมน์ (@1)",
+		},
+		{
+			name: "no primary key",
+			rec: record(1, config.DBTypeMySQL, "INSERT", "",
+				capture.ColumnValue{Name: "pid", After: str("1")}),
+			wantErr: "ตารางไม่มี primary key",
+		},
+		{
+			name: "update without changes",
+			rec: record(1, config.DBTypeMySQL, "UPDATE", `{"pid":1}`,
+				capture.ColumnValue{Name: "pid", Before: str("1"), After: str("1")}),
+			wantErr: "ไม่มีคอลัมน์ที่ค่าเปลี่ยน",
+		},
+		{
+			name: "unsupported source",
+			rec: record(1, "MongoDB", "INSERT", `{"pid":1}`,
+				capture.ColumnValue{Name: "pid", After: str("1")}),
+			wantErr: "ไม่รองรับการย้อนกลับของ MongoDB",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inverse(tt.rec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("inverse() = %q, %v ต้องการข้อผิดพลาดที่มี %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("inverse() =\n%s\nต้องการ\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	insert := record(1, config.DBTypeMySQL, "INSERT", `{"pid":1}`, capture.ColumnValue{Name: "pid", After: str("1")})
+	update := record(2, config.DBTypeMySQL, "UPDATE", `{"pid":1}`,
+		capture.ColumnValue{Name: "pid", Before: str("1"), After: str("1")},
+		capture.ColumnValue{Name: "fname", Before: str("a"), After: str("b")})
+	noKey := record(3, config.DBTypeMySQL, "INSERT", "", capture.ColumnValue{Name: "pid", After: str("1")})
+	other := record(4, config.DBTypeMySQL, "INSERT", `{"pid":9}`, capture.ColumnValue{Name: "pid", After: str("9")})
+	other.Profile = "hosxp"
+
+	script := Build([]store.Record{insert, other, update, noKey})
+
+	// เหตุการณ์ล่าสุด (other) กำหนดโปรไฟล์ของ script
+	if script.Profile != "hosxp" || script.Source != config.DBTypeMySQL {
+		t.Fatalf("Profile, Source = %q, %q", script.Profile, script.Source)
+	}
+	var seqs []uint64
+	for _, stmt := range script.Statements {
+		seqs = append(seqs, stmt.Seq)
+	}
+	if len(seqs) != 1 || seqs[0] != 4 {
+		t.Fatalf("Statements = %v ต้องการเฉพาะ #4", seqs)
+	}
+	if len(script.Skipped) != 3 {
+		t.Fatalf("Skipped = %d รายการ ต้องการ 3", len(script.Skipped))
+	}
+
+	// โปรไฟล์เดียว: เรียงจากใหม่ไปเก่า ข้ามเหตุการณ์ที่ระบุแถวไม่ได้
+	script = Build([]store.Record{insert, update, noKey})
+	seqs = nil
+	for _, stmt := range script.Statements {
+		seqs = append(seqs, stmt.Seq)
+	}
+	if len(seqs) != 2 || seqs[0] != 2 || seqs[1] != 1 {
+		t.Fatalf("Statements = %v ต้องการ [2 1]", seqs)
+	}
+	if len(script.Skipped) != 1 || script.Skipped[0].Record.Seq != 3 {
+		t.Fatalf("Skipped = %+v ต้องการ #3", script.Skipped)
+	}
+
+	text := script.Text()
+	for _, want := range []string{
+		"-- ข้ามเหตุการณ์ #3 INSERT jhcis.person: ตารางไม่มี primary key ระบุแถวไม่ได้",
+		"START TRANSACTION;\n-- #2\nUPDATE",
+		"-- #1\nDELETE FROM `jhcis`.`person` WHERE `pid` = '1';\nCOMMIT;\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Text() ไม่มี %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestScriptTextPostgres(t *testing.T) {
+	rec := record(1, config.DBTypePostgreSQL, "INSERT", `{"pid":1}`, capture.ColumnValue{Name: "pid", After: str("1")})
+	text := Build([]store.Record{rec}).Text()
+	if !strings.Contains(text, "BEGIN;\n") || strings.Contains(text, "START TRANSACTION") {
+		t.Fatalf("Text() =\n%s", text)
+	}
+	if text := (Script{Profile: "jhcis"}).Text(); strings.Contains(text, "COMMIT") {
+		t.Fatalf("script ว่างมีคำสั่ง:\n%s", text)
+	}
+}